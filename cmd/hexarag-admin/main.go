@@ -0,0 +1,197 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/username/hexarag/internal/adapters/storage/sqlite"
+	"github.com/username/hexarag/internal/domain/ports"
+	"github.com/username/hexarag/internal/domain/services"
+	"github.com/username/hexarag/pkg/config"
+)
+
+const usage = `Usage: hexarag-admin <command> [flags]
+
+Commands:
+  backup   Snapshot the live SQLite database using the online backup API
+  export   Export all data to a JSON lines archive (.jsonl.gz)
+  restore  Restore an archive into a fresh or existing SQLite database
+
+Run "hexarag-admin <command> -h" for command flags.
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	var err error
+	switch os.Args[1] {
+	case "backup":
+		err = runBackup(os.Args[2:])
+	case "export":
+		err = runExport(os.Args[2:])
+	case "restore":
+		err = runRestore(os.Args[2:])
+	case "-h", "--help", "help":
+		fmt.Print(usage)
+		return
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown command: %s\n\n%s", os.Args[1], usage)
+		os.Exit(2)
+	}
+
+	if err != nil {
+		log.Fatalf("%s failed: %v", os.Args[1], err)
+	}
+}
+
+// runBackup snapshots the configured SQLite database
+func runBackup(args []string) error {
+	fs := flag.NewFlagSet("backup", flag.ExitOnError)
+	configPath := fs.String("config", "", "Path to configuration file")
+	out := fs.String("out", "", "Path of the backup database to create")
+	fs.Parse(args)
+
+	if *out == "" {
+		return fmt.Errorf("-out is required")
+	}
+	if _, err := os.Stat(*out); err == nil {
+		return fmt.Errorf("refusing to overwrite existing file: %s", *out)
+	}
+
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %w", err)
+	}
+
+	storage, err := sqlite.NewAdapter(cfg.Database.Path, cfg.Database.MigrationsPath)
+	if err != nil {
+		return fmt.Errorf("failed to initialize storage: %w", err)
+	}
+	defer storage.Close()
+
+	if err := os.MkdirAll(filepath.Dir(*out), 0755); err != nil {
+		return fmt.Errorf("failed to create backup directory: %w", err)
+	}
+
+	log.Printf("Backing up %s to %s", cfg.Database.Path, *out)
+	if err := storage.Backup(context.Background(), *out); err != nil {
+		return err
+	}
+
+	log.Println("Backup completed successfully")
+	return nil
+}
+
+// runExport writes all data to an archive
+func runExport(args []string) error {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	configPath := fs.String("config", "", "Path to configuration file")
+	out := fs.String("out", "", "Path of the archive to create")
+	fs.Parse(args)
+
+	if *out == "" {
+		return fmt.Errorf("-out is required")
+	}
+
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %w", err)
+	}
+
+	storage, err := openStorage(context.Background(), cfg.Database.Path, cfg.Database.MigrationsPath)
+	if err != nil {
+		return err
+	}
+	defer storage.Close()
+
+	file, err := os.OpenFile(*out, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to create archive: %w", err)
+	}
+	defer file.Close()
+
+	summary, err := services.NewDataArchiver(storage).Export(context.Background(), file, cfg.Database.Path)
+	if err != nil {
+		os.Remove(*out)
+		return err
+	}
+
+	log.Printf("Exported %d system prompts, %d folders, %d conversations, %d messages, %d tool calls, %d events to %s",
+		summary.SystemPrompts, summary.Folders, summary.Conversations, summary.Messages, summary.ToolCalls, summary.Events, *out)
+	return nil
+}
+
+// runRestore loads an archive into the target SQLite database
+func runRestore(args []string) error {
+	fs := flag.NewFlagSet("restore", flag.ExitOnError)
+	configPath := fs.String("config", "", "Path to configuration file")
+	in := fs.String("in", "", "Path of the archive to restore")
+	target := fs.String("target", "", "Target database location (defaults to the configured database path)")
+	fs.Parse(args)
+
+	if *in == "" {
+		return fmt.Errorf("-in is required")
+	}
+
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %w", err)
+	}
+
+	if *target == "" {
+		*target = cfg.Database.Path
+	}
+
+	storage, err := openStorage(context.Background(), *target, cfg.Database.MigrationsPath)
+	if err != nil {
+		return err
+	}
+	defer storage.Close()
+
+	file, err := os.Open(*in)
+	if err != nil {
+		return fmt.Errorf("failed to open archive: %w", err)
+	}
+	defer file.Close()
+
+	summary, err := services.NewDataArchiver(storage).Restore(context.Background(), file)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Restored %d system prompts, %d folders, %d conversations, %d messages, %d tool calls, %d events into %s (%d skipped)",
+		summary.SystemPrompts, summary.Folders, summary.Conversations, summary.Messages, summary.ToolCalls, summary.Events, *target, summary.Skipped)
+	return nil
+}
+
+// closableStorage is a storage adapter that owns resources
+type closableStorage interface {
+	ports.StoragePort
+	Close() error
+}
+
+// openStorage opens and migrates the SQLite database at location, creating it if needed
+func openStorage(ctx context.Context, location, migrationsPath string) (closableStorage, error) {
+	if err := os.MkdirAll(filepath.Dir(location), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create database directory: %w", err)
+	}
+
+	storage, err := sqlite.NewAdapter(location, migrationsPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize storage: %w", err)
+	}
+
+	if err := storage.Migrate(ctx); err != nil {
+		storage.Close()
+		return nil, fmt.Errorf("failed to run database migrations: %w", err)
+	}
+
+	return storage, nil
+}
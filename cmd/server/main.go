@@ -17,16 +17,28 @@ import (
 	httpapi "github.com/username/hexarag/internal/adapters/api/http"
 	"github.com/username/hexarag/internal/adapters/llm/ollama"
 	"github.com/username/hexarag/internal/adapters/llm/openai"
+	"github.com/username/hexarag/internal/adapters/messaging/memory"
 	"github.com/username/hexarag/internal/adapters/messaging/nats"
+	"github.com/username/hexarag/internal/adapters/messaging/redis"
 	"github.com/username/hexarag/internal/adapters/storage/sqlite"
+	"github.com/username/hexarag/internal/adapters/tools/knowledge"
 	"github.com/username/hexarag/internal/adapters/tools/mcp"
+	"github.com/username/hexarag/internal/adapters/tools/openapi"
+	"github.com/username/hexarag/internal/adapters/tools/registry"
+	"github.com/username/hexarag/internal/adapters/tools/sandbox"
 	"github.com/username/hexarag/internal/adapters/websocket"
+	"github.com/username/hexarag/internal/domain/entities"
 	"github.com/username/hexarag/internal/domain/metrics"
+	"github.com/username/hexarag/internal/domain/ports"
 	"github.com/username/hexarag/internal/domain/services"
 	"github.com/username/hexarag/pkg/config"
+	"github.com/username/hexarag/pkg/tokenizer"
 )
 
 func main() {
+	// Code execution runs each program in a copy of this binary
+	sandbox.RunWorkerIfRequested()
+
 	// Load configuration
 	cfg, err := config.Load("")
 	if err != nil {
@@ -58,13 +70,53 @@ func main() {
 	}
 
 	// Initialize messaging adapter
-	messaging, err := nats.NewAdapter(
-		cfg.NATS.URL,
-		cfg.NATS.JetStream.Enabled,
-		cfg.NATS.JetStream.RetentionDays,
-	)
-	if err != nil {
-		log.Fatalf("Failed to initialize messaging: %v", err)
+	deliveryPolicy := ports.DeliveryPolicy{
+		MaxDeliveries:    cfg.NATS.Delivery.MaxDeliveries,
+		Backoff:          cfg.NATS.Delivery.Backoff,
+		AckWait:          cfg.NATS.Delivery.AckWait,
+		DeadLetterPrefix: cfg.NATS.Delivery.DeadLetterPrefix,
+		MaxInFlight:      cfg.NATS.Delivery.MaxInFlight,
+	}
+
+	var messaging ports.MessagingPort
+	if cfg.NATS.UsesInProcess() {
+		// Single-binary mode: services talk over an in-process bus
+		messaging = memory.NewAdapter(deliveryPolicy)
+		log.Println("Using in-process messaging")
+	} else if cfg.NATS.UsesRedis() {
+		redisAdapter, err := redis.NewAdapter(cfg.NATS.URL, cfg.NATS.JetStream.RetentionDays, deliveryPolicy)
+		if err != nil {
+			log.Fatalf("Failed to initialize messaging: %v", err)
+		}
+		messaging = redisAdapter
+		log.Println("Using Redis Streams messaging")
+	} else {
+		natsURL := cfg.NATS.URL
+		if cfg.NATS.Embedded.Enabled {
+			embedded, err := nats.StartEmbeddedServer(nats.EmbeddedOptions{
+				DataDir: cfg.NATS.Embedded.DataDir,
+				Host:    cfg.NATS.Embedded.Host,
+				Port:    cfg.NATS.Embedded.Port,
+			})
+			if err != nil {
+				log.Fatalf("Failed to start embedded NATS server: %v", err)
+			}
+			defer embedded.Shutdown()
+
+			natsURL = embedded.ClientURL()
+			log.Printf("Started embedded NATS server at %s (data: %s)", natsURL, cfg.NATS.Embedded.DataDir)
+		}
+
+		natsAdapter, err := nats.NewAdapter(
+			natsURL,
+			cfg.NATS.UsesJetStream(),
+			cfg.NATS.JetStream.RetentionDays,
+			deliveryPolicy,
+		)
+		if err != nil {
+			log.Fatalf("Failed to initialize messaging: %v", err)
+		}
+		messaging = natsAdapter
 	}
 	defer messaging.Close()
 
@@ -93,11 +145,75 @@ func main() {
 		log.Fatalf("Failed to initialize LLM adapter: %v", err)
 	}
 
-	// Initialize tools adapter
-	toolsAdapter := mcp.NewTimeServerAdapter(
-		cfg.Tools.MCPTimeServer.Enabled,
-		cfg.Tools.MCPTimeServer.Timezones,
-	)
+	// Initialize tools: the built-in time server, code sandbox and knowledge search, and each external MCP server are registered as
+	// providers, with tool names prefixed by their namespace
+	toolRegistry := registry.NewRegistry()
+	if cfg.Tools.MCPTimeServer.Enabled {
+		timeServer := mcp.NewTimeServerAdapter(true, cfg.Tools.MCPTimeServer.Timezones)
+		if err := toolRegistry.Register("time", registry.KindBuiltin, timeServer); err != nil {
+			log.Fatalf("Failed to register time server tools: %v", err)
+		}
+	}
+	if cfg.Tools.CodeExecution.Enabled {
+		codeRunner := sandbox.NewAdapter(sandbox.Config{
+			Timeout:        cfg.Tools.CodeExecution.Timeout,
+			MaxSteps:       cfg.Tools.CodeExecution.MaxSteps,
+			MaxAllocBytes:  cfg.Tools.CodeExecution.MaxAllocBytes,
+			MaxOutputBytes: cfg.Tools.CodeExecution.MaxOutputBytes,
+		})
+		if err := toolRegistry.Register("code", registry.KindBuiltin, codeRunner); err != nil {
+			log.Fatalf("Failed to register code execution tools: %v", err)
+		}
+	}
+	if cfg.Tools.Knowledge.Enabled {
+		if err := toolRegistry.Register("knowledge", registry.KindBuiltin, knowledge.NewAdapter(storage)); err != nil {
+			log.Fatalf("Failed to register knowledge search tools: %v", err)
+		}
+	}
+
+	if len(cfg.Tools.MCPServers) > 0 {
+		startCtx, cancelStart := context.WithTimeout(ctx, 30*time.Second)
+		for _, server := range cfg.Tools.MCPServers {
+			mcpClient := mcp.NewClientAdapter([]mcp.ServerConfig{{
+				Name:      server.Name,
+				Transport: server.Transport,
+				Command:   server.Command,
+				Args:      server.Args,
+				Env:       server.Env,
+				URL:       server.URL,
+				Headers:   server.Headers,
+				Timeout:   server.Timeout,
+			}})
+			if err := toolRegistry.Register(server.Name, registry.KindMCP, mcpClient); err != nil {
+				log.Fatalf("Failed to register MCP server %s: %v", server.Name, err)
+			}
+			mcpClient.Start(startCtx)
+			defer mcpClient.Close()
+		}
+		cancelStart()
+	}
+
+	for _, api := range cfg.Tools.OpenAPI {
+		adapter := openapi.NewAdapter(openapi.Config{
+			Name:             api.Name,
+			Spec:             api.Spec,
+			BaseURL:          api.BaseURL,
+			Operations:       api.Operations,
+			Headers:          api.Headers,
+			Timeout:          api.Timeout,
+			MaxResponseBytes: api.MaxResponseBytes,
+		})
+		loadCtx, cancelLoad := context.WithTimeout(ctx, 30*time.Second)
+		err := adapter.Load(loadCtx)
+		cancelLoad()
+		if err != nil {
+			log.Printf("Warning: skipping OpenAPI tools %s: %v", api.Name, err)
+			continue
+		}
+		if err := toolRegistry.Register(api.Name, registry.KindOpenAPI, adapter); err != nil {
+			log.Fatalf("Failed to register OpenAPI tools %s: %v", api.Name, err)
+		}
+	}
 
 	// Initialize core services
 	contextConstructor, err := services.NewContextConstructor(
@@ -105,6 +221,10 @@ func main() {
 		messaging,
 		cfg.LLM.Model,
 		cfg.LLM.MaxTokens,
+		services.WorkerPoolConfig{
+			Workers:   cfg.Workers.Context.Concurrency,
+			QueueSize: cfg.Workers.Context.QueueSize,
+		},
 	)
 	if err != nil {
 		log.Fatalf("Failed to initialize context constructor: %v", err)
@@ -114,7 +234,12 @@ func main() {
 		storage,
 		messaging,
 		llmAdapter,
-		toolsAdapter,
+		toolRegistry,
+		services.WorkerPoolConfig{
+			Workers:   cfg.Workers.Inference.Concurrency,
+			QueueSize: cfg.Workers.Inference.QueueSize,
+		},
+		cfg.Workers.MaxInFlightPerModel,
 	)
 
 	// Start services
@@ -126,11 +251,59 @@ func main() {
 		log.Fatalf("Failed to start inference engine: %v", err)
 	}
 
+	policies := entities.ToolPolicies{Default: entities.ToolPolicy(cfg.Tools.DefaultPolicy)}
+	for _, rule := range cfg.Tools.Policies {
+		policies.Rules = append(policies.Rules, entities.ToolPolicyRule{Tool: rule.Tool, Policy: entities.ToolPolicy(rule.Policy)})
+	}
+	if err := policies.Validate(); err != nil {
+		log.Fatalf("Invalid tool policies: %v", err)
+	}
+	timeouts := make([]services.ToolTimeout, 0, len(cfg.Tools.Execution.Timeouts))
+	for _, override := range cfg.Tools.Execution.Timeouts {
+		timeouts = append(timeouts, services.ToolTimeout{Tool: override.Tool, Timeout: override.Timeout})
+	}
+	resultTokenizer, err := tokenizer.NewTokenizer(cfg.LLM.Model)
+	if err != nil {
+		log.Fatalf("Failed to create tokenizer for tool results: %v", err)
+	}
+	toolExecutor := services.NewToolExecutor(storage, messaging, toolRegistry, policies, services.ToolExecutionConfig{
+		Workers: services.WorkerPoolConfig{
+			Workers:   cfg.Workers.Tools.Concurrency,
+			QueueSize: cfg.Workers.Tools.QueueSize,
+		},
+		Timeout:         cfg.Tools.Execution.Timeout,
+		Timeouts:        timeouts,
+		MaxResultTokens: cfg.Tools.Execution.MaxResultTokens,
+	}, resultTokenizer)
+	if err := toolExecutor.StartListening(ctx); err != nil {
+		log.Fatalf("Failed to start tool executor: %v", err)
+	}
+
+	// Start retention manager (also purges the trash after its grace period)
+	if cfg.Retention.Enabled || cfg.Trash.GracePeriodDays > 0 {
+		policy := services.RetentionPolicy{
+			Interval:         cfg.Retention.Interval,
+			TrashGracePeriod: time.Duration(cfg.Trash.GracePeriodDays) * 24 * time.Hour,
+		}
+		if cfg.Retention.Enabled {
+			policy.ConversationMaxAge = time.Duration(cfg.Retention.ConversationMaxAgeDays) * 24 * time.Hour
+			policy.ArchiveConversations = cfg.Retention.ArchiveConversations
+			policy.MaxEventsPerConversation = cfg.Retention.MaxEventsPerConversation
+			policy.VacuumInterval = cfg.Retention.VacuumInterval
+		}
+
+		retentionManager := services.NewRetentionManager(storage, messaging, policy)
+		retentionManager.Start(ctx)
+	}
+
 	// Initialize metrics collector
 	metricsCollector := metrics.NewCollector()
 
 	// Initialize WebSocket hub
-	hub := websocket.NewHub()
+	hub := websocket.NewHub(messaging)
+	if err := hub.Start(ctx); err != nil {
+		log.Fatalf("Failed to start WebSocket hub: %v", err)
+	}
 	go hub.Run(ctx) // Start hub in background
 
 	// Initialize HTTP server
@@ -145,7 +318,7 @@ func main() {
 	router.Use(gin.Recovery())
 
 	// Setup API handlers
-	apiHandlers := httpapi.NewAPIHandlers(storage, messaging, contextConstructor, inferenceEngine, modelManager, metricsCollector, hub)
+	apiHandlers := httpapi.NewAPIHandlers(storage, messaging, contextConstructor, inferenceEngine, toolRegistry, modelManager, metricsCollector, hub)
 	apiHandlers.SetupRoutes(router)
 
 	// Setup WebSocket endpoint (legacy)
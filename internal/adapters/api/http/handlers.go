@@ -2,10 +2,15 @@ package http
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"log"
 	"net/http"
 	"os/exec"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -24,19 +29,25 @@ type APIHandlers struct {
 	messaging          ports.MessagingPort
 	contextConstructor *services.ContextConstructor
 	inferenceEngine    *services.InferenceEngine
+	tools              ports.ToolCatalog
 	modelManager       *services.ModelManager
+	transfer           *services.ConversationTransfer
+	projector          *services.EventProjector
 	metricsCollector   *metrics.Collector
 	wsHub              *websocket.Hub
 }
 
 // NewAPIHandlers creates a new API handlers instance
-func NewAPIHandlers(storage ports.StoragePort, messaging ports.MessagingPort, cc *services.ContextConstructor, ie *services.InferenceEngine, mm *services.ModelManager, mc *metrics.Collector, hub *websocket.Hub) *APIHandlers {
+func NewAPIHandlers(storage ports.StoragePort, messaging ports.MessagingPort, cc *services.ContextConstructor, ie *services.InferenceEngine, tools ports.ToolCatalog, mm *services.ModelManager, mc *metrics.Collector, hub *websocket.Hub) *APIHandlers {
 	return &APIHandlers{
 		storage:            storage,
 		messaging:          messaging,
 		contextConstructor: cc,
 		inferenceEngine:    ie,
+		tools:              tools,
 		modelManager:       mm,
+		transfer:           services.NewConversationTransfer(storage),
+		projector:          services.NewEventProjector(storage),
 		metricsCollector:   mc,
 		wsHub:              hub,
 	}
@@ -48,7 +59,8 @@ func (h *APIHandlers) SetupRoutes(r *gin.Engine) {
 	r.Use(func(c *gin.Context) {
 		c.Header("Access-Control-Allow-Origin", "*")
 		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		c.Header("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		c.Header("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Correlation-ID, Idempotency-Key")
+		c.Header("Access-Control-Expose-Headers", "X-Correlation-ID, Idempotent-Replayed")
 
 		if c.Request.Method == "OPTIONS" {
 			c.AbortWithStatus(204)
@@ -70,10 +82,16 @@ func (h *APIHandlers) SetupRoutes(r *gin.Engine) {
 		api.GET("/conversations/:id", h.getConversation)
 		api.PUT("/conversations/:id", h.updateConversation)
 		api.DELETE("/conversations/:id", h.deleteConversation)
+		api.GET("/conversations/:id/export", h.exportConversation)
+		api.POST("/conversations/import", h.importConversation)
+		api.POST("/conversations/:id/restore", h.restoreConversation)
+		api.GET("/conversations/:id/events", h.getConversationEvents)
+		api.POST("/conversations/:id/rebuild", h.rebuildConversation)
 
 		// Messages
 		api.GET("/conversations/:id/messages", h.getMessages)
 		api.POST("/conversations/:id/messages", h.sendMessage)
+		api.POST("/conversations/:id/cancel", h.cancelGeneration)
 
 		// System prompts
 		api.GET("/system-prompts", h.listSystemPrompts)
@@ -81,6 +99,24 @@ func (h *APIHandlers) SetupRoutes(r *gin.Engine) {
 		api.GET("/system-prompts/:id", h.getSystemPrompt)
 		api.PUT("/system-prompts/:id", h.updateSystemPrompt)
 		api.DELETE("/system-prompts/:id", h.deleteSystemPrompt)
+		api.POST("/system-prompts/:id/restore", h.restoreSystemPrompt)
+
+		// Trash
+		api.GET("/trash", h.listTrash)
+
+		// Folders and tags
+		api.GET("/folders", h.listFolders)
+		api.POST("/folders", h.createFolder)
+		api.GET("/folders/:id", h.getFolder)
+		api.PUT("/folders/:id", h.updateFolder)
+		api.DELETE("/folders/:id", h.deleteFolder)
+		api.GET("/tags", h.listTags)
+
+		// Tools
+		api.GET("/tools", h.listTools)
+		api.GET("/tool-calls", h.listToolCalls)
+		api.POST("/tool-calls/:id/approve", h.approveToolCall)
+		api.POST("/tool-calls/:id/reject", h.rejectToolCall)
 
 		// Analysis and insights
 		api.GET("/conversations/:id/analysis", h.analyzeConversation)
@@ -98,6 +134,11 @@ func (h *APIHandlers) SetupRoutes(r *gin.Engine) {
 		api.GET("/system/health", h.getSystemHealth)
 		api.GET("/system/metrics", h.getSystemMetrics)
 		api.GET("/system/connections", h.getSystemConnections)
+
+		// Message stream history for debugging the event pipeline
+		api.GET("/system/streams", h.listStreams)
+		api.GET("/system/streams/:name/messages", h.getStreamMessages)
+		api.POST("/system/streams/:name/replay", h.replayStreamMessages)
 	}
 
 	// Developer dashboard routes
@@ -164,10 +205,61 @@ func (h *APIHandlers) listConversations(c *gin.Context) {
 		}
 	}
 
+	filter := ports.ConversationFilter{
+		SortBy:     c.DefaultQuery("sort", ports.SortByUpdatedAt),
+		Descending: c.DefaultQuery("order", "desc") == "desc",
+		Limit:      limit,
+		Offset:     offset,
+	}
+
+	switch filter.SortBy {
+	case ports.SortByUpdatedAt, ports.SortByCreatedAt, ports.SortByTitle:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid sort value: " + filter.SortBy})
+		return
+	}
+	if order := c.DefaultQuery("order", "desc"); order != "asc" && order != "desc" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid order value: " + order})
+		return
+	}
+
+	for _, tags := range c.QueryArray("tag") {
+		filter.Tags = append(filter.Tags, strings.Split(tags, ",")...)
+	}
+
+	if folderID, ok := c.GetQuery("folder_id"); ok {
+		// "none" selects conversations outside any folder
+		if folderID == "none" {
+			folderID = ""
+		}
+		filter.FolderID = &folderID
+		filter.IncludeSubfolders = c.Query("recursive") == "true"
+	}
+
+	var err error
+	if filter.Pinned, err = parseBoolQuery(c, "pinned"); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if filter.Starred, err = parseBoolQuery(c, "starred"); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Archived conversations are hidden unless asked for; "any" lists both
+	if archived := c.DefaultQuery("archived", "false"); archived != "any" {
+		value, err := strconv.ParseBool(archived)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid archived value: " + archived})
+			return
+		}
+		filter.Archived = &value
+	}
+
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	conversations, err := h.storage.GetConversations(ctx, limit, offset)
+	conversations, err := h.storage.ListConversations(ctx, filter)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -180,16 +272,38 @@ func (h *APIHandlers) listConversations(c *gin.Context) {
 	})
 }
 
+// parseBoolQuery reads an optional true/false query parameter
+func parseBoolQuery(c *gin.Context, name string) (*bool, error) {
+	raw, ok := c.GetQuery(name)
+	if !ok {
+		return nil, nil
+	}
+
+	value, err := strconv.ParseBool(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s value: %s", name, raw)
+	}
+
+	return &value, nil
+}
+
 func (h *APIHandlers) createConversation(c *gin.Context) {
 	var req struct {
-		Title          string `json:"title"`
-		SystemPromptID string `json:"system_prompt_id"`
+		Title          string                `json:"title"`
+		SystemPromptID string                `json:"system_prompt_id"`
+		FolderID       string                `json:"folder_id"`
+		Tags           []string              `json:"tags"`
+		Tools          entities.ToolSettings `json:"tools"`
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if err := req.Tools.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
 	// Use default system prompt if not specified
 	if req.SystemPromptID == "" {
@@ -197,10 +311,20 @@ func (h *APIHandlers) createConversation(c *gin.Context) {
 	}
 
 	conversation := entities.NewConversation(req.Title, req.SystemPromptID)
+	conversation.FolderID = req.FolderID
+	conversation.Tags = entities.NormalizeTags(req.Tags)
+	conversation.Tools = req.Tools.Normalize()
 
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
+	if req.FolderID != "" {
+		if _, err := h.storage.GetFolder(ctx, req.FolderID); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Folder not found"})
+			return
+		}
+	}
+
 	if err := h.storage.SaveConversation(ctx, conversation); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -236,15 +360,28 @@ func (h *APIHandlers) updateConversation(c *gin.Context) {
 		return
 	}
 
+	// Pointer fields are only applied when present in the request
 	var req struct {
-		Title          string `json:"title"`
-		SystemPromptID string `json:"system_prompt_id"`
+		Title          string                 `json:"title"`
+		SystemPromptID string                 `json:"system_prompt_id"`
+		FolderID       *string                `json:"folder_id"` // Empty string removes the conversation from its folder
+		Tags           *[]string              `json:"tags"`
+		Pinned         *bool                  `json:"pinned"`
+		Starred        *bool                  `json:"starred"`
+		Archived       *bool                  `json:"archived"`
+		Tools          *entities.ToolSettings `json:"tools"` // Replaces the conversation's tool settings
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if req.Tools != nil {
+		if err := req.Tools.Validate(); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
 
 	if req.Title != "" {
 		conversation.SetTitle(req.Title)
@@ -252,6 +389,34 @@ func (h *APIHandlers) updateConversation(c *gin.Context) {
 	if req.SystemPromptID != "" {
 		conversation.SetSystemPrompt(req.SystemPromptID)
 	}
+	if req.FolderID != nil {
+		if *req.FolderID != "" {
+			if _, err := h.storage.GetFolder(ctx, *req.FolderID); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Folder not found"})
+				return
+			}
+		}
+		conversation.SetFolder(*req.FolderID)
+	}
+	if req.Tags != nil {
+		conversation.SetTags(*req.Tags)
+	}
+	if req.Pinned != nil {
+		conversation.SetPinned(*req.Pinned)
+	}
+	if req.Starred != nil {
+		conversation.SetStarred(*req.Starred)
+	}
+	if req.Tools != nil {
+		conversation.SetTools(*req.Tools)
+	}
+	if req.Archived != nil && *req.Archived != conversation.IsArchived() {
+		if *req.Archived {
+			conversation.Archive()
+		} else {
+			conversation.Unarchive()
+		}
+	}
 
 	if err := h.storage.UpdateConversation(ctx, conversation); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -267,12 +432,185 @@ func (h *APIHandlers) deleteConversation(c *gin.Context) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
+	if _, err := h.storage.GetConversation(ctx, id); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Conversation not found"})
+		return
+	}
+
 	if err := h.storage.DeleteConversation(ctx, id); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Conversation deleted"})
+	c.JSON(http.StatusOK, gin.H{"message": "Conversation moved to trash"})
+}
+
+func (h *APIHandlers) restoreConversation(c *gin.Context) {
+	id := c.Param("id")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := h.storage.RestoreConversation(ctx, id); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Conversation not found in trash"})
+		return
+	}
+
+	conversation, err := h.storage.GetConversation(ctx, id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, conversation)
+}
+
+// getConversationEvents returns the event log of a conversation, oldest first
+func (h *APIHandlers) getConversationEvents(c *gin.Context) {
+	id := c.Param("id")
+	limit := 100
+	offset := 0
+
+	if l := c.Query("limit"); l != "" {
+		if parsed, err := strconv.Atoi(l); err == nil && parsed > 0 {
+			limit = parsed
+		}
+	}
+
+	if o := c.Query("offset"); o != "" {
+		if parsed, err := strconv.Atoi(o); err == nil && parsed >= 0 {
+			offset = parsed
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if _, err := h.storage.GetConversation(ctx, id); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Conversation not found"})
+		return
+	}
+
+	events, err := h.storage.GetEventLog(ctx, id, limit, offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"conversation_id": id,
+		"events":          events,
+		"limit":           limit,
+		"offset":          offset,
+	})
+}
+
+// rebuildConversation replays the event log of a conversation and stores the result.
+// With dry_run=true the projection is returned without changing storage.
+func (h *APIHandlers) rebuildConversation(c *gin.Context) {
+	id := c.Param("id")
+	dryRun := c.Query("dry_run") == "true"
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	if _, err := h.storage.GetConversation(ctx, id); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Conversation not found"})
+		return
+	}
+
+	var projection *services.Projection
+	var err error
+	if dryRun {
+		projection, err = h.projector.Replay(ctx, id)
+	} else {
+		projection, err = h.projector.Rebuild(ctx, id)
+	}
+	if err != nil {
+		if errors.Is(err, services.ErrIncompleteEventLog) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"conversation":   projection.Conversation,
+		"message_count":  len(projection.Messages),
+		"events_applied": projection.EventsApplied,
+		"dry_run":        dryRun,
+	})
+}
+
+func (h *APIHandlers) exportConversation(c *gin.Context) {
+	id := c.Param("id")
+	format := c.DefaultQuery("format", services.ExportFormatJSON)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	export, err := h.transfer.Export(ctx, id)
+	if err != nil {
+		if errors.Is(err, ports.ErrConversationNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Conversation not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	var body []byte
+	var contentType, extension string
+
+	switch format {
+	case services.ExportFormatJSON:
+		body, err = h.transfer.RenderJSON(export)
+		contentType, extension = "application/json", "json"
+	case services.ExportFormatMarkdown:
+		body = h.transfer.RenderMarkdown(export)
+		contentType, extension = "text/markdown; charset=utf-8", "md"
+	case services.ExportFormatOpenAIJSONL:
+		body, err = h.transfer.RenderOpenAIJSONL(export)
+		contentType, extension = "application/x-ndjson", "jsonl"
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unsupported export format: " + format})
+		return
+	}
+
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"conversation-%s.%s\"", id, extension))
+	c.Data(http.StatusOK, contentType, body)
+}
+
+func (h *APIHandlers) importConversation(c *gin.Context) {
+	var export services.ConversationExport
+	if err := c.ShouldBindJSON(&export); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	conversation, err := h.transfer.Import(ctx, &export)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrConversationExists):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrInvalidExport):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusCreated, conversation)
 }
 
 // Message handlers
@@ -290,24 +628,495 @@ func (h *APIHandlers) getMessages(c *gin.Context) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	messages, err := h.storage.GetMessages(ctx, conversationID, limit)
+	messages, err := h.storage.GetMessages(ctx, conversationID, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"messages": messages,
+		"count":    len(messages),
+	})
+}
+
+func (h *APIHandlers) sendMessage(c *gin.Context) {
+	conversationID := c.Param("id")
+
+	var req struct {
+		Content              string `json:"content" binding:"required"`
+		UseExtendedKnowledge bool   `json:"use_extended_knowledge"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	idempotencyKey := c.GetHeader("Idempotency-Key")
+	if len(idempotencyKey) > maxIdempotencyKeyLength {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Idempotency-Key must be at most %d characters", maxIdempotencyKeyLength)})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	// A retried submission returns the original message instead of sending it again
+	if idempotencyKey != "" {
+		existing, err := h.storage.GetMessageByIdempotencyKey(ctx, conversationID, idempotencyKey)
+		if err == nil {
+			h.replayMessage(ctx, c, existing)
+			return
+		}
+		if !errors.Is(err, ports.ErrMessageNotFound) {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	// Every event caused by this message carries the same correlation ID
+	correlationID := c.GetHeader("X-Correlation-ID")
+	if correlationID == "" {
+		correlationID = ports.NewCorrelationID()
+	}
+	ctx = ports.WithSource(ports.WithCorrelationID(ctx, correlationID), "api")
+	c.Header("X-Correlation-ID", correlationID)
+
+	// Shed load while the pipeline's queues are full, before storing anything
+	if h.contextConstructor.Saturated() || h.inferenceEngine.Saturated() {
+		c.Header("Retry-After", "5")
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"error":   "Message pipeline is at capacity, retry later",
+			"workers": h.workerStats(),
+		})
+		return
+	}
+
+	// Conversations in the trash don't accept new messages
+	conversation, err := h.storage.GetConversation(ctx, conversationID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Conversation not found"})
+		return
+	}
+
+	// Create user message
+	userMessage := entities.NewMessage(conversationID, entities.RoleUser, req.Content)
+	userMessage.IdempotencyKey = idempotencyKey
+
+	// Save user message
+	if err := h.storage.SaveMessage(ctx, userMessage); err != nil {
+		if errors.Is(err, ports.ErrDuplicateIdempotencyKey) {
+			// A concurrent retry saved the message first
+			if existing, err := h.storage.GetMessageByIdempotencyKey(ctx, conversationID, idempotencyKey); err == nil {
+				h.replayMessage(ctx, c, existing)
+				return
+			}
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Update conversation with new message
+	conversation.AddMessage(userMessage.ID)
+	if err := h.storage.UpdateConversation(ctx, conversation); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Trigger context construction
+	contextRequest := &services.ContextRequest{
+		ConversationID:       conversationID,
+		MessageID:            userMessage.ID,
+		UseExtendedKnowledge: req.UseExtendedKnowledge,
+	}
+
+	if err := h.messaging.PublishJSON(ctx, ports.SubjectContextRequest, contextRequest); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to trigger context construction"})
+		return
+	}
+
+	// Messages that have to wait for a worker are reported as queued
+	status := "processing"
+	if h.contextConstructor.Busy() || h.inferenceEngine.Busy() {
+		status = "queued"
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":        userMessage,
+		"status":         status,
+		"message_id":     userMessage.ID,
+		"correlation_id": correlationID,
+	})
+}
+
+// maxIdempotencyKeyLength bounds the Idempotency-Key header stored with messages
+const maxIdempotencyKeyLength = 255
+
+// replayMessage answers a repeated submission with the original message and its processing status
+func (h *APIHandlers) replayMessage(ctx context.Context, c *gin.Context, message *entities.Message) {
+	response := gin.H{
+		"message":    message,
+		"message_id": message.ID,
+		"replayed":   true,
+	}
+
+	reply, err := h.storage.GetReply(ctx, message.ID)
+	switch {
+	case err == nil:
+		response["response"] = reply
+		response["status"] = "completed"
+		if reply.FinishReason == entities.FinishReasonCancelled {
+			response["status"] = "cancelled"
+		}
+	case errors.Is(err, ports.ErrMessageNotFound):
+		response["status"] = "processing"
+		if h.contextConstructor.Busy() || h.inferenceEngine.Busy() {
+			response["status"] = "queued"
+		}
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Header("Idempotent-Replayed", "true")
+	c.JSON(http.StatusOK, response)
+}
+
+// cancelGeneration stops the responses being generated for a conversation.
+// The body is optional; message_id limits the cancellation to the reply to one message.
+func (h *APIHandlers) cancelGeneration(c *gin.Context) {
+	conversationID := c.Param("id")
+
+	var req struct {
+		MessageID string `json:"message_id"`
+		Reason    string `json:"reason"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	ctx = ports.WithSource(ctx, "api")
+
+	if _, err := h.storage.GetConversation(ctx, conversationID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Conversation not found"})
+		return
+	}
+
+	cancelRequest := &ports.CancelRequest{
+		ConversationID: conversationID,
+		MessageID:      req.MessageID,
+		Reason:         req.Reason,
+	}
+
+	subject := fmt.Sprintf(ports.SubjectConversationCancel, conversationID)
+	if err := h.messaging.PublishJSON(ctx, subject, cancelRequest); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to publish cancellation"})
+		return
+	}
+
+	// Generations stop asynchronously; their partial replies arrive as cancelled responses
+	c.JSON(http.StatusAccepted, gin.H{
+		"status":          "cancelling",
+		"conversation_id": conversationID,
+		"message_id":      req.MessageID,
+	})
+}
+
+// workerStats returns the load on the pipeline's worker pools
+func (h *APIHandlers) workerStats() []services.WorkerPoolStats {
+	return []services.WorkerPoolStats{
+		h.contextConstructor.WorkerStats(),
+		h.inferenceEngine.WorkerStats(),
+	}
+}
+
+// System prompt handlers
+
+func (h *APIHandlers) listSystemPrompts(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	prompts, err := h.storage.GetSystemPrompts(ctx)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"system_prompts": prompts})
+}
+
+func (h *APIHandlers) createSystemPrompt(c *gin.Context) {
+	var req struct {
+		Name    string                `json:"name" binding:"required"`
+		Content string                `json:"content" binding:"required"`
+		Tools   entities.ToolSettings `json:"tools"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := req.Tools.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	prompt := entities.NewSystemPrompt(req.Name, req.Content)
+	prompt.Tools = req.Tools.Normalize()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := h.storage.SaveSystemPrompt(ctx, prompt); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, prompt)
+}
+
+func (h *APIHandlers) getSystemPrompt(c *gin.Context) {
+	id := c.Param("id")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	prompt, err := h.storage.GetSystemPrompt(ctx, id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "System prompt not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, prompt)
+}
+
+func (h *APIHandlers) updateSystemPrompt(c *gin.Context) {
+	id := c.Param("id")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	prompt, err := h.storage.GetSystemPrompt(ctx, id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "System prompt not found"})
+		return
+	}
+
+	var req struct {
+		Name    string                 `json:"name"`
+		Content string                 `json:"content"`
+		Tools   *entities.ToolSettings `json:"tools"` // Replaces the prompt's tool settings
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Tools != nil {
+		if err := req.Tools.Validate(); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	if req.Name == "" {
+		req.Name = prompt.Name
+	}
+	if req.Content == "" {
+		req.Content = prompt.Content
+	}
+
+	prompt.Update(req.Name, req.Content)
+	if req.Tools != nil {
+		prompt.SetTools(*req.Tools)
+	}
+
+	if err := h.storage.UpdateSystemPrompt(ctx, prompt); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, prompt)
+}
+
+func (h *APIHandlers) deleteSystemPrompt(c *gin.Context) {
+	id := c.Param("id")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	prompt, err := h.storage.GetSystemPrompt(ctx, id)
+	if err != nil || prompt.IsDeleted() {
+		c.JSON(http.StatusNotFound, gin.H{"error": "System prompt not found"})
+		return
+	}
+
+	// Refuse to delete prompts that conversations still use unless forced
+	if c.Query("force") != "true" {
+		count, err := h.storage.CountConversationsForSystemPrompt(ctx, id)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if count > 0 {
+			c.JSON(http.StatusConflict, gin.H{
+				"error":              "System prompt is used by conversations; pass force=true to delete it anyway",
+				"conversation_count": count,
+			})
+			return
+		}
+	}
+
+	if err := h.storage.DeleteSystemPrompt(ctx, id); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "System prompt moved to trash"})
+}
+
+func (h *APIHandlers) restoreSystemPrompt(c *gin.Context) {
+	id := c.Param("id")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := h.storage.RestoreSystemPrompt(ctx, id); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "System prompt not found in trash"})
+		return
+	}
+
+	prompt, err := h.storage.GetSystemPrompt(ctx, id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, prompt)
+}
+
+// Trash handlers
+
+func (h *APIHandlers) listTrash(c *gin.Context) {
+	limit := 20
+	offset := 0
+
+	if l := c.Query("limit"); l != "" {
+		if parsed, err := strconv.Atoi(l); err == nil && parsed > 0 {
+			limit = parsed
+		}
+	}
+
+	if o := c.Query("offset"); o != "" {
+		if parsed, err := strconv.Atoi(o); err == nil && parsed >= 0 {
+			offset = parsed
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	conversations, err := h.storage.GetDeletedConversations(ctx, limit, offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	prompts, err := h.storage.GetDeletedSystemPrompts(ctx)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"conversations":  conversations,
+		"system_prompts": prompts,
+		"limit":          limit,
+		"offset":         offset,
+	})
+}
+
+// Folder handlers
+
+func (h *APIHandlers) listFolders(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	folders, err := h.storage.GetFolders(ctx)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"folders": folders})
+}
+
+func (h *APIHandlers) createFolder(c *gin.Context) {
+	var req struct {
+		Name     string `json:"name" binding:"required"`
+		ParentID string `json:"parent_id"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if req.ParentID != "" {
+		if _, err := h.storage.GetFolder(ctx, req.ParentID); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Parent folder not found"})
+			return
+		}
+	}
+
+	folder := entities.NewFolder(req.Name, req.ParentID)
+
+	if err := h.storage.SaveFolder(ctx, folder); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, folder)
+}
+
+func (h *APIHandlers) getFolder(c *gin.Context) {
+	id := c.Param("id")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	folder, err := h.storage.GetFolder(ctx, id)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(http.StatusNotFound, gin.H{"error": "Folder not found"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"messages": messages,
-		"count":    len(messages),
-	})
+	c.JSON(http.StatusOK, folder)
 }
 
-func (h *APIHandlers) sendMessage(c *gin.Context) {
-	conversationID := c.Param("id")
+func (h *APIHandlers) updateFolder(c *gin.Context) {
+	id := c.Param("id")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	folder, err := h.storage.GetFolder(ctx, id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Folder not found"})
+		return
+	}
 
 	var req struct {
-		Content              string `json:"content" binding:"required"`
-		UseExtendedKnowledge bool   `json:"use_extended_knowledge"`
+		Name     string  `json:"name"`
+		ParentID *string `json:"parent_id"` // Empty string moves the folder to the top level
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -315,155 +1124,239 @@ func (h *APIHandlers) sendMessage(c *gin.Context) {
 		return
 	}
 
-	// Create user message
-	userMessage := entities.NewMessage(conversationID, entities.RoleUser, req.Content)
+	if req.Name != "" {
+		folder.Rename(req.Name)
+	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
+	if req.ParentID != nil {
+		// Walk up from the new parent to make sure the folder isn't moved under itself
+		for ancestorID := *req.ParentID; ancestorID != ""; {
+			if ancestorID == folder.ID {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Folder cannot be moved into itself or its subfolders"})
+				return
+			}
 
-	// Save user message
-	if err := h.storage.SaveMessage(ctx, userMessage); err != nil {
+			ancestor, err := h.storage.GetFolder(ctx, ancestorID)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Parent folder not found"})
+				return
+			}
+			ancestorID = ancestor.ParentID
+		}
+
+		folder.Move(*req.ParentID)
+	}
+
+	if err := h.storage.UpdateFolder(ctx, folder); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	// Update conversation with new message
-	conversation, err := h.storage.GetConversation(ctx, conversationID)
-	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Conversation not found"})
+	c.JSON(http.StatusOK, folder)
+}
+
+func (h *APIHandlers) deleteFolder(c *gin.Context) {
+	id := c.Param("id")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if _, err := h.storage.GetFolder(ctx, id); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Folder not found"})
 		return
 	}
 
-	conversation.AddMessage(userMessage.ID)
-	if err := h.storage.UpdateConversation(ctx, conversation); err != nil {
+	if err := h.storage.DeleteFolder(ctx, id); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	// Trigger context construction
-	contextRequest := &services.ContextRequest{
-		ConversationID:       conversationID,
-		MessageID:            userMessage.ID,
-		UseExtendedKnowledge: req.UseExtendedKnowledge,
-	}
+	c.JSON(http.StatusOK, gin.H{"message": "Folder deleted; its contents moved to the parent folder"})
+}
 
-	if err := h.messaging.PublishJSON(ctx, ports.SubjectContextRequest, contextRequest); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to trigger context construction"})
+func (h *APIHandlers) listTags(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	tags, err := h.storage.GetTags(ctx)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"message":    userMessage,
-		"status":     "processing",
-		"message_id": userMessage.ID,
-	})
+	c.JSON(http.StatusOK, gin.H{"tags": tags})
 }
 
-// System prompt handlers
+// Tool handlers
 
-func (h *APIHandlers) listSystemPrompts(c *gin.Context) {
+// listTools lists the tools of every provider with their provenance. With conversation_id or
+// system_prompt_id, each tool is marked as enabled or disabled by that scope's settings.
+func (h *APIHandlers) listTools(c *gin.Context) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	prompts, err := h.storage.GetSystemPrompts(ctx)
+	var settings []entities.ToolSettings
+	promptID := c.Query("system_prompt_id")
+	if conversationID := c.Query("conversation_id"); conversationID != "" {
+		conversation, err := h.storage.GetConversation(ctx, conversationID)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Conversation not found"})
+			return
+		}
+		settings = append(settings, conversation.Tools)
+		promptID = conversation.SystemPromptID
+	}
+	if promptID != "" {
+		prompt, err := h.storage.GetSystemPrompt(ctx, promptID)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "System prompt not found"})
+			return
+		}
+		settings = append(settings, prompt.Tools)
+	}
+
+	infos, err := h.tools.ListTools(ctx)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"system_prompts": prompts})
+	tools := make([]gin.H, 0, len(infos))
+	for _, info := range infos {
+		tools = append(tools, gin.H{
+			"name":          info.Function.Name,
+			"description":   info.Function.Description,
+			"parameters":    info.Function.Parameters,
+			"provider":      info.Provider,
+			"provider_kind": info.ProviderKind,
+			"original_name": info.OriginalName,
+			"enabled":       entities.ToolEnabled(info.Function.Name, settings...),
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"tools": tools, "count": len(tools)})
 }
 
-func (h *APIHandlers) createSystemPrompt(c *gin.Context) {
-	var req struct {
-		Name    string `json:"name" binding:"required"`
-		Content string `json:"content" binding:"required"`
+// listToolCalls lists tool calls newest first, filtered by tool, status, conversation_id, since and until (RFC3339),
+// with per-tool counts, error rates and latency percentiles over every call matching the filters
+func (h *APIHandlers) listToolCalls(c *gin.Context) {
+	filter := ports.ToolCallFilter{
+		Tool:           c.Query("tool"),
+		ConversationID: c.Query("conversation_id"),
+		Limit:          50,
 	}
 
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
+	if l := c.Query("limit"); l != "" {
+		if parsed, err := strconv.Atoi(l); err == nil && parsed > 0 {
+			filter.Limit = parsed
+		}
 	}
 
-	prompt := entities.NewSystemPrompt(req.Name, req.Content)
+	if o := c.Query("offset"); o != "" {
+		if parsed, err := strconv.Atoi(o); err == nil && parsed >= 0 {
+			filter.Offset = parsed
+		}
+	}
+
+	if status := c.Query("status"); status != "" {
+		switch entities.ToolCallStatus(status) {
+		case entities.ToolCallStatusPending, entities.ToolCallStatusAwaitingApproval, entities.ToolCallStatusApproved,
+			entities.ToolCallStatusRejected, entities.ToolCallStatusSuccess, entities.ToolCallStatusError:
+			filter.Status = entities.ToolCallStatus(status)
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid status value: " + status})
+			return
+		}
+	}
+
+	for param, target := range map[string]**time.Time{"since": &filter.Since, "until": &filter.Until} {
+		if value := c.Query(param); value != "" {
+			parsed, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid %s, expected RFC3339", param)})
+				return
+			}
+			*target = &parsed
+		}
+	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	if err := h.storage.SaveSystemPrompt(ctx, prompt); err != nil {
+	toolCalls, err := h.storage.ListToolCalls(ctx, filter)
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusCreated, prompt)
-}
-
-func (h *APIHandlers) getSystemPrompt(c *gin.Context) {
-	id := c.Param("id")
-
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
-
-	prompt, err := h.storage.GetSystemPrompt(ctx, id)
+	stats, err := h.storage.GetToolCallStats(ctx, filter)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "System prompt not found"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, prompt)
-}
+	if toolCalls == nil {
+		toolCalls = []*entities.ToolCall{}
+	}
 
-func (h *APIHandlers) updateSystemPrompt(c *gin.Context) {
-	id := c.Param("id")
+	c.JSON(http.StatusOK, gin.H{
+		"tool_calls": toolCalls,
+		"stats":      stats,
+		"count":      len(toolCalls),
+		"limit":      filter.Limit,
+		"offset":     filter.Offset,
+	})
+}
 
+// approveToolCall runs a tool call held for approval; the inference flow resumes with its result
+func (h *APIHandlers) approveToolCall(c *gin.Context) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
+	ctx = ports.WithSource(ctx, "api")
 
-	prompt, err := h.storage.GetSystemPrompt(ctx, id)
+	toolCall, err := h.inferenceEngine.ApproveToolCall(ctx, c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "System prompt not found"})
+		h.respondToolCallError(c, err)
 		return
 	}
 
+	c.JSON(http.StatusOK, toolCall)
+}
+
+// rejectToolCall ends a tool call held for approval without running it, aborting the inference flow
+func (h *APIHandlers) rejectToolCall(c *gin.Context) {
 	var req struct {
-		Name    string `json:"name"`
-		Content string `json:"content"`
+		Reason string `json:"reason"`
 	}
 
-	if err := c.ShouldBindJSON(&req); err != nil {
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	if req.Name == "" {
-		req.Name = prompt.Name
-	}
-	if req.Content == "" {
-		req.Content = prompt.Content
-	}
-
-	prompt.Update(req.Name, req.Content)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	ctx = ports.WithSource(ctx, "api")
 
-	if err := h.storage.UpdateSystemPrompt(ctx, prompt); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	toolCall, err := h.inferenceEngine.RejectToolCall(ctx, c.Param("id"), req.Reason)
+	if err != nil {
+		h.respondToolCallError(c, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, prompt)
+	c.JSON(http.StatusOK, toolCall)
 }
 
-func (h *APIHandlers) deleteSystemPrompt(c *gin.Context) {
-	id := c.Param("id")
-
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
-
-	if err := h.storage.DeleteSystemPrompt(ctx, id); err != nil {
+// respondToolCallError maps a failed approval decision to its HTTP status
+func (h *APIHandlers) respondToolCallError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, ports.ErrToolCallNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Tool call not found"})
+	case errors.Is(err, services.ErrToolCallNotAwaitingApproval):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
 	}
-
-	c.JSON(http.StatusOK, gin.H{"message": "System prompt deleted"})
 }
 
 // Analysis and status handlers
@@ -751,6 +1644,10 @@ func (h *APIHandlers) getSystemMetrics(c *gin.Context) {
 		}
 	}
 
+	// Worker queue depth and in-flight inference
+	metrics["workers"] = h.workerStats()
+	metrics["inference_in_flight"] = h.inferenceEngine.InFlightPerModel()
+
 	c.JSON(http.StatusOK, metrics)
 }
 
@@ -768,6 +1665,226 @@ func (h *APIHandlers) getSystemConnections(c *gin.Context) {
 	c.JSON(http.StatusOK, connections)
 }
 
+// Stream handlers
+
+// streamInspector returns the messaging adapter's stream inspector, responding with 501 if it has none
+func (h *APIHandlers) streamInspector(c *gin.Context) (ports.StreamInspector, bool) {
+	inspector, ok := h.messaging.(ports.StreamInspector)
+	if !ok {
+		c.JSON(http.StatusNotImplemented, gin.H{"error": ports.ErrStreamsNotSupported.Error()})
+		return nil, false
+	}
+	return inspector, true
+}
+
+// streamError responds with the status matching a stream inspection error
+func streamError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, ports.ErrStreamsNotSupported):
+		c.JSON(http.StatusNotImplemented, gin.H{"error": err.Error()})
+	case errors.Is(err, ports.ErrStreamNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+// streamMessageView renders a stored message, decoding its envelope or JSON payload when it has one
+func streamMessageView(msg ports.StreamMessage) gin.H {
+	view := gin.H{
+		"stream":    msg.Stream,
+		"sequence":  msg.Sequence,
+		"subject":   msg.Subject,
+		"timestamp": msg.Timestamp,
+	}
+
+	if envelope, ok, err := ports.OpenEnvelope(msg.Data); ok && err == nil {
+		view["envelope"] = envelope
+	} else if json.Valid(msg.Data) {
+		view["payload"] = json.RawMessage(msg.Data)
+	} else {
+		view["raw_payload"] = string(msg.Data)
+	}
+
+	return view
+}
+
+func (h *APIHandlers) listStreams(c *gin.Context) {
+	inspector, ok := h.streamInspector(c)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	streams, err := inspector.ListStreams(ctx)
+	if err != nil {
+		streamError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"streams": streams})
+}
+
+// getStreamMessages lists stored messages, filtered by subject, start_seq, since and until (RFC3339)
+func (h *APIHandlers) getStreamMessages(c *gin.Context) {
+	name := c.Param("name")
+
+	inspector, ok := h.streamInspector(c)
+	if !ok {
+		return
+	}
+
+	query := ports.StreamQuery{
+		Subject: c.Query("subject"),
+		Limit:   100,
+	}
+
+	if l := c.Query("limit"); l != "" {
+		if parsed, err := strconv.Atoi(l); err == nil && parsed > 0 {
+			query.Limit = parsed
+		}
+	}
+
+	if s := c.Query("start_seq"); s != "" {
+		parsed, err := strconv.ParseUint(s, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid start_seq"})
+			return
+		}
+		query.StartSequence = parsed
+	}
+
+	for param, target := range map[string]*time.Time{"since": &query.Since, "until": &query.Until} {
+		if value := c.Query(param); value != "" {
+			parsed, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid %s, expected RFC3339", param)})
+				return
+			}
+			*target = parsed
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	messages, err := inspector.GetStreamMessages(ctx, name, query)
+	if err != nil {
+		streamError(c, err)
+		return
+	}
+
+	views := make([]gin.H, 0, len(messages))
+	for _, msg := range messages {
+		views = append(views, streamMessageView(msg))
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"stream":   name,
+		"messages": views,
+		"count":    len(views),
+	})
+}
+
+// replayStreamMessages re-publishes stored messages on their original subjects.
+// Messages are selected with the same filters as getStreamMessages, or by a list of sequences;
+// nothing is replayed unless every listed sequence is found.
+func (h *APIHandlers) replayStreamMessages(c *gin.Context) {
+	name := c.Param("name")
+
+	inspector, ok := h.streamInspector(c)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Subject       string    `json:"subject"`
+		StartSequence uint64    `json:"start_sequence"`
+		Sequences     []uint64  `json:"sequences"`
+		Since         time.Time `json:"since"`
+		Until         time.Time `json:"until"`
+		Limit         int       `json:"limit"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.Subject == "" && req.StartSequence == 0 && len(req.Sequences) == 0 && req.Since.IsZero() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Select messages with subject, start_sequence, sequences or since"})
+		return
+	}
+
+	if len(req.Sequences) > 0 && (req.Subject != "" || req.StartSequence != 0 || !req.Since.IsZero() || !req.Until.IsZero()) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "sequences can't be combined with other filters"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	var messages []ports.StreamMessage
+	if len(req.Sequences) > 0 {
+		var missing []uint64
+		for _, seq := range req.Sequences {
+			msg, err := inspector.GetStreamMessage(ctx, name, seq)
+			if errors.Is(err, ports.ErrStreamMessageNotFound) {
+				missing = append(missing, seq)
+				continue
+			}
+			if err != nil {
+				streamError(c, err)
+				return
+			}
+			messages = append(messages, *msg)
+		}
+
+		if len(missing) > 0 {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error":   fmt.Sprintf("%d of the sequences were not found in %s", len(missing), name),
+				"missing": missing,
+			})
+			return
+		}
+	} else {
+		var err error
+		messages, err = inspector.GetStreamMessages(ctx, name, ports.StreamQuery{
+			Subject:       req.Subject,
+			StartSequence: req.StartSequence,
+			Since:         req.Since,
+			Until:         req.Until,
+			Limit:         req.Limit,
+		})
+		if err != nil {
+			streamError(c, err)
+			return
+		}
+	}
+
+	replayed := make([]gin.H, 0, len(messages))
+	for _, msg := range messages {
+		if err := h.messaging.Publish(ctx, msg.Subject, msg.Data); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":    fmt.Sprintf("Failed to replay sequence %d: %v", msg.Sequence, err),
+				"replayed": replayed,
+			})
+			return
+		}
+
+		log.Printf("Replayed %s sequence %d on %s", name, msg.Sequence, msg.Subject)
+		replayed = append(replayed, gin.H{"sequence": msg.Sequence, "subject": msg.Subject})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"stream":   name,
+		"replayed": replayed,
+		"count":    len(replayed),
+	})
+}
+
 func (h *APIHandlers) handleDevEvents(c *gin.Context) {
 	// Set room to "dev-dashboard" for developer dashboard connections
 	c.Request.URL.RawQuery = "room=dev-dashboard"
@@ -0,0 +1,65 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/username/hexarag/internal/adapters/storage/sqlite"
+	"github.com/username/hexarag/internal/domain/services"
+)
+
+func newTestHandlers(t *testing.T) (*APIHandlers, *sqlite.Adapter) {
+	t.Helper()
+
+	storage, err := sqlite.NewAdapter(filepath.Join(t.TempDir(), "test.db"), "../../storage/sqlite/migrations")
+	if err != nil {
+		t.Fatalf("NewAdapter() error = %v", err)
+	}
+	t.Cleanup(func() { storage.Close() })
+
+	if err := storage.Migrate(context.Background()); err != nil {
+		t.Fatalf("Migrate() error = %v", err)
+	}
+
+	return &APIHandlers{storage: storage, transfer: services.NewConversationTransfer(storage)}, storage
+}
+
+func TestAPIHandlers_ImportRejectsMalformedExports(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	handlers, storage := newTestHandlers(t)
+	router := gin.New()
+	router.POST("/conversations/import", handlers.importConversation)
+
+	const conversation = `"conversation": {"id": "conv_1", "title": "Imported"}`
+	tests := map[string]string{
+		"null message":       `{` + conversation + `, "messages": [null]}`,
+		"missing message ID": `{` + conversation + `, "messages": [{"role": "user", "content": "hi"}]}`,
+		"duplicate message ID": `{` + conversation + `, "messages": [
+			{"id": "msg_1", "role": "user", "content": "hi"},
+			{"id": "msg_1", "role": "assistant", "content": "hello"}]}`,
+		"unknown role":         `{` + conversation + `, "messages": [{"id": "msg_1", "role": "narrator", "content": "hi"}]}`,
+		"missing conversation": `{"messages": []}`,
+	}
+
+	for name, body := range tests {
+		t.Run(name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/conversations/import", strings.NewReader(body)))
+
+			if recorder.Code != http.StatusBadRequest {
+				t.Errorf("Expected 400, got %d: %s", recorder.Code, recorder.Body.String())
+			}
+		})
+	}
+
+	// Nothing was stored by the rejected imports
+	if _, err := storage.GetConversation(context.Background(), "conv_1"); err == nil {
+		t.Error("Expected no conversation to be imported")
+	}
+}
@@ -334,11 +334,32 @@ func (a *Adapter) convertMessages(messages []*entities.Message, systemPrompt str
 		}
 
 		result = append(result, oaiMsg)
+
+		// Each tool call is answered by a tool message carrying its result, or its error
+		for _, tc := range msg.ToolCalls {
+			result = append(result, openai.ChatCompletionMessage{
+				Role:       openai.ChatMessageRoleTool,
+				Content:    a.toolResultContent(tc),
+				ToolCallID: tc.ID,
+			})
+		}
 	}
 
 	return result
 }
 
+// toolResultContent renders a tool call's result as the content of a tool message
+func (a *Adapter) toolResultContent(tc entities.ToolCall) string {
+	if tc.Result == nil {
+		return `{"error":"tool call did not complete"}`
+	}
+	content, err := tc.ResultJSON()
+	if err != nil {
+		return fmt.Sprintf(`{"error":%q}`, err.Error())
+	}
+	return content
+}
+
 // convertRole converts our domain roles to OpenAI roles
 func (a *Adapter) convertRole(role entities.MessageRole) string {
 	switch role {
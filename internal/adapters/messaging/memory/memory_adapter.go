@@ -0,0 +1,479 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/username/hexarag/internal/domain/ports"
+)
+
+// Adapter implements the MessagingPort interface in-process.
+// It follows NATS semantics: subjects are dot-separated tokens, "*" matches one token,
+// ">" matches one or more trailing tokens, and queue subscriptions sharing a subject and
+// queue name receive each message exactly once between them.
+type Adapter struct {
+	bus       *bus
+	subs      map[string]*subscription
+	subsMutex sync.RWMutex
+	closed    bool
+}
+
+// NewAdapter creates a new in-process messaging adapter with its own bus
+func NewAdapter(policy ports.DeliveryPolicy) *Adapter {
+	return newConnection(&bus{
+		inboxes: make(map[string]chan []byte),
+		groups:  make(map[string]int),
+		policy:  policy.WithDefaults(),
+	})
+}
+
+// Connect returns another adapter attached to the same bus,
+// like a second process connected to the same NATS server
+func (a *Adapter) Connect() *Adapter {
+	return newConnection(a.bus)
+}
+
+func newConnection(b *bus) *Adapter {
+	return &Adapter{
+		bus:  b,
+		subs: make(map[string]*subscription),
+	}
+}
+
+// Publish sends a message to the specified subject
+func (a *Adapter) Publish(ctx context.Context, subject string, data []byte) error {
+	if err := a.checkOpen(); err != nil {
+		return err
+	}
+	if err := validateSubject(subject, false); err != nil {
+		return err
+	}
+
+	a.bus.route(subject, data, "")
+	return nil
+}
+
+// PublishJSON publishes a JSON-serializable object to the subject, wrapped in a ports.Envelope
+func (a *Adapter) PublishJSON(ctx context.Context, subject string, obj interface{}) error {
+	data, err := ports.MarshalEnvelope(ctx, subject, obj)
+	if err != nil {
+		return fmt.Errorf("failed to marshal object for subject %s: %w", subject, err)
+	}
+
+	return a.Publish(ctx, subject, data)
+}
+
+// Subscribe listens for messages on the specified subject
+func (a *Adapter) Subscribe(ctx context.Context, subject string, handler ports.MessageHandler) error {
+	return a.subscribe(ctx, subject, "", subject, handler)
+}
+
+// SubscribeEphemeral listens for messages on the subject; every subscription here is per-connection
+func (a *Adapter) SubscribeEphemeral(ctx context.Context, subject string, handler ports.MessageHandler) error {
+	return a.Subscribe(ctx, subject, handler)
+}
+
+// SubscribeQueue creates a queue subscription for load balancing
+func (a *Adapter) SubscribeQueue(ctx context.Context, subject, queue string, handler ports.MessageHandler) error {
+	return a.subscribe(ctx, subject, queue, fmt.Sprintf("%s:%s", subject, queue), handler)
+}
+
+func (a *Adapter) subscribe(ctx context.Context, subject, queue, key string, handler ports.MessageHandler) error {
+	if err := validateSubject(subject, true); err != nil {
+		return err
+	}
+
+	a.subsMutex.Lock()
+	defer a.subsMutex.Unlock()
+
+	if a.closed {
+		return fmt.Errorf("connection is closed")
+	}
+
+	// Check if already subscribed
+	if _, exists := a.subs[key]; exists {
+		if queue != "" {
+			return fmt.Errorf("already subscribed to subject %s with queue %s", subject, queue)
+		}
+		return fmt.Errorf("already subscribed to subject: %s", subject)
+	}
+
+	sub := newSubscription(ctx, subject, queue, ports.UnwrapEnvelopes(handler))
+	if queue != "" {
+		sub.dispatch = a.bus.policy.QueueDispatcher()
+	}
+	a.subs[key] = sub
+	a.bus.add(sub)
+	go sub.run(a.bus)
+
+	return nil
+}
+
+// Unsubscribe stops listening to a subject. Queue subscriptions are keyed as "subject:queue".
+func (a *Adapter) Unsubscribe(ctx context.Context, subject string) error {
+	a.subsMutex.Lock()
+	defer a.subsMutex.Unlock()
+
+	sub, exists := a.subs[subject]
+	if !exists {
+		return fmt.Errorf("not subscribed to subject: %s", subject)
+	}
+
+	a.bus.remove(sub)
+	sub.stop()
+	delete(a.subs, subject)
+	return nil
+}
+
+// Request sends a request and waits for a response
+func (a *Adapter) Request(ctx context.Context, subject string, data []byte, timeout ...interface{}) ([]byte, error) {
+	if err := a.checkOpen(); err != nil {
+		return nil, err
+	}
+	if err := validateSubject(subject, false); err != nil {
+		return nil, err
+	}
+
+	// Default timeout
+	requestTimeout := 10 * time.Second
+
+	// Override timeout if provided
+	if len(timeout) > 0 {
+		if t, ok := timeout[0].(time.Duration); ok {
+			requestTimeout = t
+		}
+	}
+
+	reqCtx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+
+	inbox, responses := a.bus.openInbox()
+	defer a.bus.closeInbox(inbox)
+
+	if delivered := a.bus.route(subject, data, inbox); delivered == 0 {
+		return nil, fmt.Errorf("failed to send request to subject %s: no responders", subject)
+	}
+
+	select {
+	case response := <-responses:
+		return response, nil
+	case <-reqCtx.Done():
+		return nil, fmt.Errorf("failed to send request to subject %s: %w", subject, reqCtx.Err())
+	}
+}
+
+// Close closes the messaging connection
+func (a *Adapter) Close() error {
+	a.subsMutex.Lock()
+	defer a.subsMutex.Unlock()
+
+	for _, sub := range a.subs {
+		a.bus.remove(sub)
+		sub.stop()
+	}
+
+	a.subs = make(map[string]*subscription)
+	a.closed = true
+	return nil
+}
+
+// Ping checks messaging connectivity
+func (a *Adapter) Ping() error {
+	return a.checkOpen()
+}
+
+// GetConnectionStatus returns detailed connection information
+func (a *Adapter) GetConnectionStatus() map[string]interface{} {
+	a.subsMutex.RLock()
+	defer a.subsMutex.RUnlock()
+
+	return map[string]interface{}{
+		"connected":            !a.closed,
+		"url":                  "inproc",
+		"jetstream_enabled":    false,
+		"active_subscriptions": len(a.subs),
+	}
+}
+
+func (a *Adapter) checkOpen() error {
+	a.subsMutex.RLock()
+	defer a.subsMutex.RUnlock()
+
+	if a.closed {
+		return fmt.Errorf("connection is closed")
+	}
+	return nil
+}
+
+// bus routes messages between all connections created from one adapter
+type bus struct {
+	mu      sync.Mutex
+	subs    []*subscription // In subscription order, so queue groups rotate predictably
+	inboxes map[string]chan []byte
+	groups  map[string]int // Round-robin position per queue group
+	policy  ports.DeliveryPolicy
+	inboxID uint64
+}
+
+func (b *bus) add(sub *subscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.subs = append(b.subs, sub)
+}
+
+func (b *bus) remove(sub *subscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for i, s := range b.subs {
+		if s == sub {
+			b.subs = append(b.subs[:i], b.subs[i+1:]...)
+			return
+		}
+	}
+}
+
+// route delivers a message to every matching plain subscription and to one member of
+// each matching queue group. It returns the number of subscriptions that received it.
+func (b *bus) route(subject string, data []byte, reply string) int {
+	tokens := splitSubject(subject)
+
+	b.mu.Lock()
+	var targets []*subscription
+	groups := make(map[string][]*subscription)
+	var groupOrder []string
+
+	for _, sub := range b.subs {
+		if !matches(sub.tokens, tokens) {
+			continue
+		}
+		if sub.queue == "" {
+			targets = append(targets, sub)
+			continue
+		}
+
+		group := sub.subject + "\x00" + sub.queue
+		if _, seen := groups[group]; !seen {
+			groupOrder = append(groupOrder, group)
+		}
+		groups[group] = append(groups[group], sub)
+	}
+
+	for _, group := range groupOrder {
+		members := groups[group]
+		position := b.groups[group] % len(members)
+		b.groups[group] = position + 1
+		targets = append(targets, members[position])
+	}
+	b.mu.Unlock()
+
+	for _, sub := range targets {
+		sub.enqueue(delivery{subject: subject, data: data, reply: reply, delivered: 1})
+	}
+
+	return len(targets)
+}
+
+// dispatch runs the handler for a delivery and redelivers or dead-letters it according to the delivery policy
+func (b *bus) dispatch(sub *subscription, d delivery) {
+	ctx := sub.ctx
+	if d.reply != "" {
+		inbox := d.reply
+		ctx = ports.WithReplyFunc(ctx, func(data []byte) error {
+			return b.respond(inbox, data)
+		})
+	}
+
+	err := sub.handler(ctx, d.subject, d.data)
+	action, delay := b.policy.Decide(err, d.delivered)
+
+	switch action {
+	case ports.AckActionNack:
+		log.Printf("Handler error for subject %s (delivery %d, retrying in %s): %v", d.subject, d.delivered, delay, err)
+		time.AfterFunc(delay, func() {
+			d.delivered++
+			sub.enqueue(d)
+		})
+
+	case ports.AckActionTerminate:
+		log.Printf("Handler error for subject %s (delivery %d, giving up): %v", d.subject, d.delivered, err)
+		b.deadLetter(d, err)
+	}
+}
+
+// deadLetter publishes a failed delivery to its dead-letter subject
+func (b *bus) deadLetter(d delivery, cause error) {
+	subject := b.policy.DeadLetterSubject(d.subject)
+	if subject == "" {
+		return
+	}
+
+	data, err := ports.MarshalDeadLetter(subject, d.subject, d.data, cause, d.delivered)
+	if err != nil {
+		log.Printf("Failed to dead-letter message from %s: %v", d.subject, err)
+		return
+	}
+
+	b.route(subject, data, "")
+}
+
+func (b *bus) openInbox() (string, chan []byte) {
+	inbox := fmt.Sprintf("_INBOX.%d", atomic.AddUint64(&b.inboxID, 1))
+	responses := make(chan []byte, 1)
+
+	b.mu.Lock()
+	b.inboxes[inbox] = responses
+	b.mu.Unlock()
+
+	return inbox, responses
+}
+
+func (b *bus) closeInbox(inbox string) {
+	b.mu.Lock()
+	delete(b.inboxes, inbox)
+	b.mu.Unlock()
+}
+
+// respond delivers a reply to a waiting requester; only the first reply is kept
+func (b *bus) respond(inbox string, data []byte) error {
+	b.mu.Lock()
+	responses, ok := b.inboxes[inbox]
+	b.mu.Unlock()
+
+	if !ok {
+		return fmt.Errorf("requester is no longer waiting")
+	}
+
+	select {
+	case responses <- data:
+	default:
+	}
+	return nil
+}
+
+// delivery is a message queued for one subscription
+type delivery struct {
+	subject   string
+	data      []byte
+	reply     string
+	delivered int
+}
+
+// subscription delivers messages to its handler one at a time, in order, unless it is a
+// queue subscription, whose handlers run concurrently
+type subscription struct {
+	ctx      context.Context
+	subject  string
+	tokens   []string
+	queue    string
+	handler  ports.MessageHandler
+	dispatch func(handle func()) // Runs each delivery's handler
+
+	mu      sync.Mutex
+	cond    *sync.Cond
+	pending []delivery
+	stopped bool
+}
+
+func newSubscription(ctx context.Context, subject, queue string, handler ports.MessageHandler) *subscription {
+	sub := &subscription{
+		ctx:      ctx,
+		subject:  subject,
+		tokens:   splitSubject(subject),
+		queue:    queue,
+		handler:  handler,
+		dispatch: func(handle func()) { handle() },
+	}
+	sub.cond = sync.NewCond(&sub.mu)
+	return sub
+}
+
+// enqueue never blocks, so handlers can publish to their own subjects
+func (s *subscription) enqueue(d delivery) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.stopped {
+		return
+	}
+	s.pending = append(s.pending, d)
+	s.cond.Signal()
+}
+
+func (s *subscription) run(b *bus) {
+	for {
+		s.mu.Lock()
+		for len(s.pending) == 0 && !s.stopped {
+			s.cond.Wait()
+		}
+		if s.stopped {
+			s.mu.Unlock()
+			return
+		}
+		d := s.pending[0]
+		s.pending = s.pending[1:]
+		s.mu.Unlock()
+
+		s.dispatch(func() { b.dispatch(s, d) })
+	}
+}
+
+func (s *subscription) stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.stopped = true
+	s.pending = nil
+	s.cond.Broadcast()
+}
+
+// splitSubject splits a subject into its dot-separated tokens
+func splitSubject(subject string) []string {
+	return strings.Split(subject, ".")
+}
+
+// matches reports whether subject tokens match a subscription pattern
+func matches(pattern, subject []string) bool {
+	for i, token := range pattern {
+		if token == ">" {
+			return len(subject) > i
+		}
+		if i >= len(subject) {
+			return false
+		}
+		if token != "*" && token != subject[i] {
+			return false
+		}
+	}
+	return len(pattern) == len(subject)
+}
+
+// validateSubject checks subject syntax; wildcards are only allowed in subscriptions
+func validateSubject(subject string, allowWildcards bool) error {
+	if subject == "" {
+		return fmt.Errorf("subject cannot be empty")
+	}
+
+	tokens := splitSubject(subject)
+	for i, token := range tokens {
+		switch {
+		case token == "":
+			return fmt.Errorf("invalid subject %q: empty token", subject)
+		case token == "*" || token == ">":
+			if !allowWildcards {
+				return fmt.Errorf("invalid subject %q: wildcards are only allowed in subscriptions", subject)
+			}
+			if token == ">" && i != len(tokens)-1 {
+				return fmt.Errorf("invalid subject %q: > must be the last token", subject)
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,43 @@
+package memory
+
+import (
+	"testing"
+
+	"github.com/username/hexarag/internal/adapters/messaging/messagingtest"
+	"github.com/username/hexarag/internal/domain/ports"
+)
+
+func TestAdapter_Conformance(t *testing.T) {
+	messagingtest.RunConformance(t, func(t *testing.T, policy ports.DeliveryPolicy) messagingtest.Connector {
+		root := NewAdapter(policy)
+		t.Cleanup(func() { root.Close() })
+
+		return func(t *testing.T) ports.MessagingPort {
+			conn := root.Connect()
+			t.Cleanup(func() { conn.Close() })
+			return conn
+		}
+	})
+}
+
+func TestMatches(t *testing.T) {
+	tests := []struct {
+		pattern string
+		subject string
+		want    bool
+	}{
+		{"a.b.c", "a.b.c", true},
+		{"a.*.c", "a.b.c", true},
+		{"a.*", "a.b.c", false},
+		{"a.>", "a.b.c", true},
+		{"a.>", "a", false},
+		{"a.b.c", "a.b", false},
+		{">", "a", true},
+	}
+
+	for _, tt := range tests {
+		if got := matches(splitSubject(tt.pattern), splitSubject(tt.subject)); got != tt.want {
+			t.Errorf("matches(%q, %q) = %t, want %t", tt.pattern, tt.subject, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,553 @@
+// Package messagingtest provides a conformance suite for MessagingPort implementations
+package messagingtest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/username/hexarag/internal/domain/ports"
+)
+
+// Connector opens a new connection to a shared bus. Connections from one Connector must
+// see each other's messages like separate processes on one broker, and are closed by the
+// Connector when the test ends.
+type Connector func(t *testing.T) ports.MessagingPort
+
+// Factory creates a bus configured with the given delivery policy
+type Factory func(t *testing.T, policy ports.DeliveryPolicy) Connector
+
+// waitTimeout bounds how long the suite waits for asynchronous deliveries
+const waitTimeout = 5 * time.Second
+
+// TestPolicy retries quickly so redelivery tests stay fast
+var TestPolicy = ports.DeliveryPolicy{
+	MaxDeliveries:    3,
+	Backoff:          []time.Duration{10 * time.Millisecond},
+	AckWait:          time.Second,
+	DeadLetterPrefix: ports.DefaultDeadLetterPrefix,
+	MaxInFlight:      4,
+}
+
+// RunConformance checks that a MessagingPort implementation honours the port contract
+func RunConformance(t *testing.T, factory Factory) {
+	tests := []struct {
+		name string
+		run  func(t *testing.T, connect Connector)
+	}{
+		{"PublishSubscribe", testPublishSubscribe},
+		{"EphemeralFanOut", testEphemeralFanOut},
+		{"Wildcards", testWildcards},
+		{"QueueGroups", testQueueGroups},
+		{"ConcurrentQueueHandlers", testConcurrentQueueHandlers},
+		{"RequestReply", testRequestReply},
+		{"Redelivery", testRedelivery},
+		{"DeadLetter", testDeadLetter},
+		{"Unsubscribe", testUnsubscribe},
+		{"Envelopes", testEnvelopes},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.run(t, factory(t, TestPolicy))
+		})
+	}
+}
+
+func testPublishSubscribe(t *testing.T, connect Connector) {
+	ctx := context.Background()
+	publisher, subscriber := connect(t), connect(t)
+	subject := uniqueSubject("events")
+
+	received := newCollector()
+	if err := subscriber.Subscribe(ctx, subject, received.handle); err != nil {
+		t.Fatalf("Subscribe() error = %v", err)
+	}
+	if err := subscriber.Subscribe(ctx, subject, received.handle); err == nil {
+		t.Error("Expected a second subscription to the same subject on one connection to fail")
+	}
+
+	for _, payload := range []string{"1", "2", "3"} {
+		mustPublish(t, publisher, subject, payload)
+	}
+
+	received.wait(t, 3)
+	if got := strings.Join(received.payloadList(), ","); got != "1,2,3" {
+		t.Errorf("Expected messages in publish order, got %s", got)
+	}
+}
+
+func testEphemeralFanOut(t *testing.T, connect Connector) {
+	ctx := context.Background()
+	publisher, first, second := connect(t), connect(t), connect(t)
+	subject := uniqueSubject("broadcast")
+
+	firstReceived, secondReceived := newCollector(), newCollector()
+	if err := first.SubscribeEphemeral(ctx, subject, firstReceived.handle); err != nil {
+		t.Fatalf("SubscribeEphemeral() error = %v", err)
+	}
+	if err := second.SubscribeEphemeral(ctx, subject, secondReceived.handle); err != nil {
+		t.Fatalf("SubscribeEphemeral() error = %v", err)
+	}
+
+	for _, payload := range []string{"1", "2", "3"} {
+		mustPublish(t, publisher, subject, payload)
+	}
+
+	// Every connection sees every message
+	firstReceived.wait(t, 3)
+	secondReceived.wait(t, 3)
+	settle()
+	if firstReceived.count() != 3 || secondReceived.count() != 3 {
+		t.Errorf("Expected both connections to receive 3 messages, got %d and %d", firstReceived.count(), secondReceived.count())
+	}
+
+	if err := first.Unsubscribe(ctx, subject); err != nil {
+		t.Errorf("Unsubscribe() error = %v", err)
+	}
+}
+
+func testWildcards(t *testing.T, connect Connector) {
+	ctx := context.Background()
+	bus := connect(t)
+	base := uniqueSubject("conversation")
+
+	single := newCollector()
+	if err := bus.Subscribe(ctx, base+".*.updated", single.handle); err != nil {
+		t.Fatalf("Subscribe(*) error = %v", err)
+	}
+
+	tail := newCollector()
+	if err := bus.Subscribe(ctx, base+".>", tail.handle); err != nil {
+		t.Fatalf("Subscribe(>) error = %v", err)
+	}
+
+	mustPublish(t, bus, base+".a.updated", "1")
+	mustPublish(t, bus, base+".a.b.updated", "2")
+	mustPublish(t, bus, base+".a.created", "3")
+
+	tail.wait(t, 3)
+	single.wait(t, 1)
+	settle()
+
+	if single.count() != 1 || single.subjectList()[0] != base+".a.updated" {
+		t.Errorf("Expected * to match exactly one token, got %v", single.subjectList())
+	}
+	if tail.count() != 3 {
+		t.Errorf("Expected > to match all trailing tokens, got %v", tail.subjectList())
+	}
+}
+
+func testQueueGroups(t *testing.T, connect Connector) {
+	ctx := context.Background()
+	first, second, observer := connect(t), connect(t), connect(t)
+	subject := uniqueSubject("work")
+	const messages = 20
+
+	// Handlers take a while, so a member busy with as many messages as the policy lets it handle
+	// at once leaves the rest to the other member
+	var total int32
+	done := make(chan struct{}, messages)
+	member := func(counter *int32) ports.MessageHandler {
+		return func(ctx context.Context, subject string, data []byte) error {
+			time.Sleep(10 * time.Millisecond)
+			atomic.AddInt32(counter, 1)
+			atomic.AddInt32(&total, 1)
+			done <- struct{}{}
+			return nil
+		}
+	}
+
+	var firstCount, secondCount int32
+	if err := first.SubscribeQueue(ctx, subject, "workers", member(&firstCount)); err != nil {
+		t.Fatalf("SubscribeQueue() error = %v", err)
+	}
+	if err := second.SubscribeQueue(ctx, subject, "workers", member(&secondCount)); err != nil {
+		t.Fatalf("SubscribeQueue() error = %v", err)
+	}
+
+	everyone := newCollector()
+	if err := observer.Subscribe(ctx, subject, everyone.handle); err != nil {
+		t.Fatalf("Subscribe() error = %v", err)
+	}
+
+	for i := 0; i < messages; i++ {
+		mustPublish(t, observer, subject, fmt.Sprint(i))
+	}
+
+	deadline := time.After(waitTimeout)
+	for i := 0; i < messages; i++ {
+		select {
+		case <-done:
+		case <-deadline:
+			t.Fatalf("Timed out waiting for queue deliveries, got %d", atomic.LoadInt32(&total))
+		}
+	}
+	everyone.wait(t, messages)
+	settle()
+
+	if got := atomic.LoadInt32(&total); got != messages {
+		t.Errorf("Expected each message to reach exactly one queue member, got %d deliveries", got)
+	}
+	if atomic.LoadInt32(&firstCount) == 0 || atomic.LoadInt32(&secondCount) == 0 {
+		t.Errorf("Expected load to be shared, got %d and %d", firstCount, secondCount)
+	}
+	if everyone.count() != messages {
+		t.Errorf("Expected plain subscribers to receive every message, got %d", everyone.count())
+	}
+}
+
+func testConcurrentQueueHandlers(t *testing.T, connect Connector) {
+	ctx := context.Background()
+	bus := connect(t)
+	subject := uniqueSubject("parallel")
+
+	// Each handler waits for the other, so they only succeed if both run at once
+	var running sync.WaitGroup
+	running.Add(2)
+	finished := newCollector()
+	err := bus.SubscribeQueue(ctx, subject, "workers", func(ctx context.Context, subject string, data []byte) error {
+		running.Done()
+		both := make(chan struct{})
+		go func() {
+			running.Wait()
+			close(both)
+		}()
+
+		select {
+		case <-both:
+			return finished.handle(ctx, subject, data)
+		case <-time.After(waitTimeout):
+			return ports.Terminate(errors.New("queue handlers ran one at a time"))
+		}
+	})
+	if err != nil {
+		t.Fatalf("SubscribeQueue() error = %v", err)
+	}
+
+	mustPublish(t, bus, subject, "1")
+	mustPublish(t, bus, subject, "2")
+	finished.wait(t, 2)
+}
+
+func testRequestReply(t *testing.T, connect Connector) {
+	ctx := context.Background()
+	responder, requester := connect(t), connect(t)
+	subject := uniqueSubject("echo")
+
+	respondErrors := make(chan error, 10)
+	err := responder.Subscribe(ctx, subject, func(ctx context.Context, subject string, data []byte) error {
+		respondErrors <- ports.Respond(ctx, append([]byte("echo:"), data...))
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Subscribe() error = %v", err)
+	}
+
+	reply, err := requester.Request(ctx, subject, []byte("hi"), 2*time.Second)
+	if err != nil {
+		t.Fatalf("Request() error = %v", err)
+	}
+	if string(reply) != "echo:hi" {
+		t.Errorf("Expected echo:hi, got %s", reply)
+	}
+	if err := <-respondErrors; err != nil {
+		t.Errorf("Respond() error = %v", err)
+	}
+
+	// Plain publishes have nobody to answer
+	mustPublish(t, requester, subject, "no reply")
+	select {
+	case err := <-respondErrors:
+		if !errors.Is(err, ports.ErrNoReplyExpected) {
+			t.Errorf("Expected ErrNoReplyExpected, got %v", err)
+		}
+	case <-time.After(waitTimeout):
+		t.Fatal("Timed out waiting for published message")
+	}
+
+	if _, err := requester.Request(ctx, uniqueSubject("nobody"), []byte("hi"), 500*time.Millisecond); err == nil {
+		t.Error("Expected a request without responders to fail")
+	}
+}
+
+func testRedelivery(t *testing.T, connect Connector) {
+	ctx := context.Background()
+	bus := connect(t)
+	subject := uniqueSubject("flaky")
+
+	var attempts int32
+	succeeded := make(chan struct{}, 1)
+	err := bus.Subscribe(ctx, subject, func(ctx context.Context, subject string, data []byte) error {
+		if atomic.AddInt32(&attempts, 1) < 3 {
+			return errors.New("transient failure")
+		}
+		succeeded <- struct{}{}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Subscribe() error = %v", err)
+	}
+
+	mustPublish(t, bus, subject, "retry me")
+
+	select {
+	case <-succeeded:
+	case <-time.After(waitTimeout):
+		t.Fatalf("Timed out waiting for redelivery, got %d attempts", atomic.LoadInt32(&attempts))
+	}
+	settle()
+
+	if got := atomic.LoadInt32(&attempts); got != 3 {
+		t.Errorf("Expected 3 attempts and no redelivery after success, got %d", got)
+	}
+}
+
+func testDeadLetter(t *testing.T, connect Connector) {
+	ctx := context.Background()
+	bus := connect(t)
+	poison := uniqueSubject("poison")
+	failing := uniqueSubject("failing")
+
+	letters := newCollector()
+	for _, subject := range []string{poison, failing} {
+		if err := bus.Subscribe(ctx, TestPolicy.DeadLetterSubject(subject), letters.handle); err != nil {
+			t.Fatalf("Subscribe(dead letters) error = %v", err)
+		}
+	}
+
+	err := bus.Subscribe(ctx, poison, func(ctx context.Context, subject string, data []byte) error {
+		return ports.Terminate(errors.New("malformed payload"))
+	})
+	if err != nil {
+		t.Fatalf("Subscribe() error = %v", err)
+	}
+
+	var failingAttempts int32
+	err = bus.Subscribe(ctx, failing, func(ctx context.Context, subject string, data []byte) error {
+		atomic.AddInt32(&failingAttempts, 1)
+		return errors.New("still broken")
+	})
+	if err != nil {
+		t.Fatalf("Subscribe() error = %v", err)
+	}
+
+	mustPublish(t, bus, poison, `{"n":1}`)
+	mustPublish(t, bus, failing, `{"n":2}`)
+
+	letters.wait(t, 2)
+	settle()
+
+	byOrigin := make(map[string]ports.DeadLetter)
+	for _, payload := range letters.payloadList() {
+		var letter ports.DeadLetter
+		if err := json.Unmarshal([]byte(payload), &letter); err != nil {
+			t.Fatalf("Unmarshal(dead letter) error = %v", err)
+		}
+		byOrigin[letter.Subject] = letter
+	}
+
+	if letter := byOrigin[poison]; letter.Deliveries != 1 || letter.Error != "malformed payload" || string(letter.Payload) != `{"n":1}` {
+		t.Errorf("Unexpected dead letter for terminated message: %+v", letter)
+	}
+	if letter := byOrigin[failing]; letter.Deliveries != TestPolicy.MaxDeliveries || letter.Error != "still broken" {
+		t.Errorf("Unexpected dead letter for exhausted message: %+v", letter)
+	}
+	if got := atomic.LoadInt32(&failingAttempts); int(got) != TestPolicy.MaxDeliveries {
+		t.Errorf("Expected %d attempts before dead-lettering, got %d", TestPolicy.MaxDeliveries, got)
+	}
+}
+
+func testUnsubscribe(t *testing.T, connect Connector) {
+	ctx := context.Background()
+	bus := connect(t)
+	subject := uniqueSubject("temporary")
+
+	received := newCollector()
+	if err := bus.Subscribe(ctx, subject, received.handle); err != nil {
+		t.Fatalf("Subscribe() error = %v", err)
+	}
+
+	mustPublish(t, bus, subject, "before")
+	received.wait(t, 1)
+
+	if err := bus.Unsubscribe(ctx, subject); err != nil {
+		t.Fatalf("Unsubscribe() error = %v", err)
+	}
+	if err := bus.Unsubscribe(ctx, subject); err == nil {
+		t.Error("Expected unsubscribing twice to fail")
+	}
+
+	mustPublish(t, bus, subject, "after")
+	settle()
+
+	if received.count() != 1 {
+		t.Errorf("Expected no deliveries after unsubscribing, got %v", received.payloadList())
+	}
+}
+
+func testEnvelopes(t *testing.T, connect Connector) {
+	ctx := context.Background()
+	publisher, worker, observer := connect(t), connect(t), connect(t)
+	request := uniqueSubject("request")
+	ready := uniqueSubject("ready")
+	poison := uniqueSubject("poison")
+
+	type received struct {
+		payload  string
+		envelope *ports.Envelope
+	}
+	results := make(chan received, 3)
+	record := func(ctx context.Context, subject string, data []byte) error {
+		envelope, _ := ports.EnvelopeFromContext(ctx)
+		results <- received{payload: string(data), envelope: envelope}
+		return nil
+	}
+
+	// The worker handles a request and publishes a follow-up from the handler context
+	workerCtx := ports.WithSource(ctx, "worker")
+	err := worker.Subscribe(workerCtx, request, func(ctx context.Context, subject string, data []byte) error {
+		if err := record(ctx, subject, data); err != nil {
+			return err
+		}
+		return worker.PublishJSON(ctx, ready, map[string]int{"n": 2})
+	})
+	if err != nil {
+		t.Fatalf("Subscribe() error = %v", err)
+	}
+	if err := observer.Subscribe(ctx, ready, record); err != nil {
+		t.Fatalf("Subscribe() error = %v", err)
+	}
+	if err := observer.Subscribe(ctx, poison, func(ctx context.Context, subject string, data []byte) error {
+		return ports.Terminate(errors.New("malformed payload"))
+	}); err != nil {
+		t.Fatalf("Subscribe() error = %v", err)
+	}
+	if err := observer.Subscribe(ctx, TestPolicy.DeadLetterSubject(poison), record); err != nil {
+		t.Fatalf("Subscribe(dead letters) error = %v", err)
+	}
+
+	publishCtx := ports.WithCorrelationID(ports.WithSource(ctx, "publisher"), "correlation-1")
+	if err := publisher.PublishJSON(publishCtx, request, map[string]int{"n": 1}); err != nil {
+		t.Fatalf("PublishJSON() error = %v", err)
+	}
+
+	next := func() received {
+		t.Helper()
+		select {
+		case r := <-results:
+			return r
+		case <-time.After(waitTimeout):
+			t.Fatal("Timed out waiting for an envelope")
+			return received{}
+		}
+	}
+
+	first := next()
+	if first.payload != `{"n":1}` {
+		t.Errorf("Expected handlers to receive the bare payload, got %s", first.payload)
+	}
+	if first.envelope == nil || first.envelope.CorrelationID != "correlation-1" || first.envelope.Source != "publisher" ||
+		first.envelope.Subject != request || first.envelope.SchemaVersion != ports.EnvelopeSchemaVersion {
+		t.Fatalf("Unexpected request envelope: %+v", first.envelope)
+	}
+
+	second := next()
+	if second.payload != `{"n":2}` {
+		t.Errorf("Expected the follow-up payload, got %s", second.payload)
+	}
+	if second.envelope == nil || second.envelope.CorrelationID != "correlation-1" ||
+		second.envelope.CausationID != first.envelope.ID || second.envelope.Source != "worker" {
+		t.Errorf("Expected the follow-up to be caused by the request, got %+v", second.envelope)
+	}
+
+	// Dead letters keep the correlation of the message that failed
+	if err := publisher.PublishJSON(publishCtx, poison, map[string]int{"n": 3}); err != nil {
+		t.Fatalf("PublishJSON() error = %v", err)
+	}
+	letter := next()
+	if letter.envelope == nil || letter.envelope.CorrelationID != "correlation-1" {
+		t.Errorf("Expected the dead letter to keep its correlation ID, got %+v", letter.envelope)
+	}
+}
+
+// collector records messages received by a handler
+type collector struct {
+	mu       sync.Mutex
+	subjects []string
+	payloads []string
+	notify   chan struct{}
+}
+
+func newCollector() *collector {
+	return &collector{notify: make(chan struct{}, 1000)}
+}
+
+func (c *collector) handle(ctx context.Context, subject string, data []byte) error {
+	c.mu.Lock()
+	c.subjects = append(c.subjects, subject)
+	c.payloads = append(c.payloads, string(data))
+	c.mu.Unlock()
+
+	c.notify <- struct{}{}
+	return nil
+}
+
+// wait blocks until at least n messages have been received
+func (c *collector) wait(t *testing.T, n int) {
+	t.Helper()
+
+	deadline := time.After(waitTimeout)
+	for c.count() < n {
+		select {
+		case <-c.notify:
+		case <-deadline:
+			t.Fatalf("Timed out waiting for %d messages, got %d", n, c.count())
+		}
+	}
+}
+
+func (c *collector) count() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.subjects)
+}
+
+func (c *collector) subjectList() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]string(nil), c.subjects...)
+}
+
+func (c *collector) payloadList() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]string(nil), c.payloads...)
+}
+
+// settle gives stray deliveries a chance to arrive before asserting on exact counts
+func settle() {
+	time.Sleep(100 * time.Millisecond)
+}
+
+var subjectSequence int64
+
+// uniqueSubject keeps subjects from different tests and runs apart on shared brokers.
+// Subjects live under system.> so they are captured by the default JetStream streams.
+func uniqueSubject(suffix string) string {
+	return fmt.Sprintf("system.conformance.%d_%d.%s", time.Now().UnixNano(), atomic.AddInt64(&subjectSequence, 1), suffix)
+}
+
+func mustPublish(t *testing.T, bus ports.MessagingPort, subject, payload string) {
+	t.Helper()
+
+	if err := bus.Publish(context.Background(), subject, []byte(payload)); err != nil {
+		t.Fatalf("Publish(%s) error = %v", subject, err)
+	}
+}
@@ -0,0 +1,70 @@
+package nats
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/nats-io/nats-server/v2/server"
+)
+
+// EmbeddedOptions configures an in-process NATS server
+type EmbeddedOptions struct {
+	DataDir      string        // JetStream storage directory
+	Host         string        // Listen address for clients
+	Port         int           // Client port; -1 picks a random free port
+	ReadyTimeout time.Duration // How long to wait for the server to accept connections
+}
+
+// EmbeddedServer is a NATS server with JetStream running inside the HexaRAG process
+type EmbeddedServer struct {
+	server *server.Server
+}
+
+// StartEmbeddedServer starts an in-process NATS server with JetStream enabled
+func StartEmbeddedServer(opts EmbeddedOptions) (*EmbeddedServer, error) {
+	if opts.DataDir == "" {
+		return nil, fmt.Errorf("embedded NATS data directory cannot be empty")
+	}
+	if err := os.MkdirAll(opts.DataDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create embedded NATS data directory: %w", err)
+	}
+
+	readyTimeout := opts.ReadyTimeout
+	if readyTimeout <= 0 {
+		readyTimeout = 10 * time.Second
+	}
+
+	ns, err := server.NewServer(&server.Options{
+		ServerName: "hexarag-embedded",
+		Host:       opts.Host,
+		Port:       opts.Port,
+		JetStream:  true,
+		StoreDir:   opts.DataDir,
+		NoSigs:     true, // Signals are handled by the HexaRAG process
+		NoLog:      true,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create embedded NATS server: %w", err)
+	}
+
+	go ns.Start()
+
+	if !ns.ReadyForConnections(readyTimeout) {
+		ns.Shutdown()
+		return nil, fmt.Errorf("embedded NATS server not ready after %s", readyTimeout)
+	}
+
+	return &EmbeddedServer{server: ns}, nil
+}
+
+// ClientURL returns the URL clients use to connect to the embedded server
+func (e *EmbeddedServer) ClientURL() string {
+	return e.server.ClientURL()
+}
+
+// Shutdown stops the embedded server and waits for it to exit
+func (e *EmbeddedServer) Shutdown() {
+	e.server.Shutdown()
+	e.server.WaitForShutdown()
+}
@@ -2,8 +2,8 @@ package nats
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
+	"log"
 	"strings"
 	"sync"
 	"time"
@@ -13,16 +13,20 @@ import (
 	"github.com/username/hexarag/internal/domain/ports"
 )
 
+// replyToHeader carries the requester inbox on JetStream requests, whose reply subject is used for acks
+const replyToHeader = "Hexarag-Reply-To"
+
 // Adapter implements the MessagingPort interface using NATS
 type Adapter struct {
 	conn      *nats.Conn
 	js        nats.JetStreamContext
 	subs      map[string]*nats.Subscription
 	subsMutex sync.RWMutex
+	policy    ports.DeliveryPolicy
 }
 
 // NewAdapter creates a new NATS messaging adapter
-func NewAdapter(url string, jsEnabled bool, retentionDays int) (*Adapter, error) {
+func NewAdapter(url string, jsEnabled bool, retentionDays int, policy ports.DeliveryPolicy) (*Adapter, error) {
 	// Connect to NATS
 	conn, err := nats.Connect(url,
 		nats.ReconnectWait(2*time.Second),
@@ -35,8 +39,9 @@ func NewAdapter(url string, jsEnabled bool, retentionDays int) (*Adapter, error)
 	}
 
 	adapter := &Adapter{
-		conn: conn,
-		subs: make(map[string]*nats.Subscription),
+		conn:   conn,
+		subs:   make(map[string]*nats.Subscription),
+		policy: policy.WithDefaults(),
 	}
 
 	// Setup JetStream if enabled
@@ -160,9 +165,9 @@ func (a *Adapter) Publish(ctx context.Context, subject string, data []byte) erro
 	}
 }
 
-// PublishJSON publishes a JSON-serializable object to the subject
+// PublishJSON publishes a JSON-serializable object to the subject, wrapped in a ports.Envelope
 func (a *Adapter) PublishJSON(ctx context.Context, subject string, obj interface{}) error {
-	data, err := json.Marshal(obj)
+	data, err := ports.MarshalEnvelope(ctx, subject, obj)
 	if err != nil {
 		return fmt.Errorf("failed to marshal object for subject %s: %w", subject, err)
 	}
@@ -181,11 +186,9 @@ func (a *Adapter) Subscribe(ctx context.Context, subject string, handler ports.M
 	}
 
 	// Create message handler wrapper
+	handler = ports.UnwrapEnvelopes(handler)
 	msgHandler := func(msg *nats.Msg) {
-		if err := handler(ctx, msg.Subject, msg.Data); err != nil {
-			// Log error but don't fail subscription
-			fmt.Printf("Handler error for subject %s: %v\n", msg.Subject, err)
-		}
+		a.dispatch(ctx, msg, handler, 1)
 	}
 
 	var sub *nats.Subscription
@@ -193,11 +196,11 @@ func (a *Adapter) Subscribe(ctx context.Context, subject string, handler ports.M
 
 	if a.js != nil {
 		// Use JetStream subscription for durability
-		sub, err = a.js.Subscribe(subject, msgHandler,
-			nats.Durable(fmt.Sprintf("hexarag_%s", sanitizeSubjectForDurable(subject))),
-			nats.DeliverAll(),
-			nats.AckExplicit(),
-		)
+		durable := fmt.Sprintf("hexarag_%s", sanitizeSubjectForDurable(subject))
+		if err := a.reconcileConsumer(subject, durable); err != nil {
+			return err
+		}
+		sub, err = a.js.Subscribe(subject, msgHandler, a.consumerOptions(durable)...)
 	} else {
 		// Use core NATS subscription
 		sub, err = a.conn.Subscribe(subject, msgHandler)
@@ -207,6 +210,46 @@ func (a *Adapter) Subscribe(ctx context.Context, subject string, handler ports.M
 		return fmt.Errorf("failed to subscribe to subject %s: %w", subject, err)
 	}
 
+	// Make sure the server has registered the subscription before returning
+	if err := a.conn.Flush(); err != nil {
+		sub.Unsubscribe()
+		return fmt.Errorf("failed to register subscription to subject %s: %w", subject, err)
+	}
+
+	a.subs[subject] = sub
+	return nil
+}
+
+// SubscribeEphemeral listens for messages on the subject with a core NATS subscription, even when
+// JetStream is enabled, so each connection sees every message published while it is subscribed
+func (a *Adapter) SubscribeEphemeral(ctx context.Context, subject string, handler ports.MessageHandler) error {
+	a.subsMutex.Lock()
+	defer a.subsMutex.Unlock()
+
+	// Check if already subscribed
+	if _, exists := a.subs[subject]; exists {
+		return fmt.Errorf("already subscribed to subject: %s", subject)
+	}
+
+	handler = ports.UnwrapEnvelopes(handler)
+	msgHandler := func(msg *nats.Msg) {
+		if a.js != nil {
+			// The reply subject of a JetStream publish is its publish ack, which is not ours to answer
+			msg = &nats.Msg{Subject: msg.Subject, Header: msg.Header, Data: msg.Data}
+		}
+		a.dispatch(ctx, msg, handler, 1)
+	}
+
+	sub, err := a.conn.Subscribe(subject, msgHandler)
+	if err != nil {
+		return fmt.Errorf("failed to subscribe to subject %s: %w", subject, err)
+	}
+
+	if err := a.conn.Flush(); err != nil {
+		sub.Unsubscribe()
+		return fmt.Errorf("failed to register subscription to subject %s: %w", subject, err)
+	}
+
 	a.subs[subject] = sub
 	return nil
 }
@@ -223,12 +266,11 @@ func (a *Adapter) SubscribeQueue(ctx context.Context, subject, queue string, han
 		return fmt.Errorf("already subscribed to subject %s with queue %s", subject, queue)
 	}
 
-	// Create message handler wrapper
+	// Create message handler wrapper; queue members handle messages concurrently
+	handler = ports.UnwrapEnvelopes(handler)
+	queueDispatch := a.policy.QueueDispatcher()
 	msgHandler := func(msg *nats.Msg) {
-		if err := handler(ctx, msg.Subject, msg.Data); err != nil {
-			// Log error but don't fail subscription
-			fmt.Printf("Queue handler error for subject %s queue %s: %v\n", msg.Subject, queue, err)
-		}
+		queueDispatch(func() { a.dispatch(ctx, msg, handler, 1) })
 	}
 
 	var sub *nats.Subscription
@@ -236,11 +278,11 @@ func (a *Adapter) SubscribeQueue(ctx context.Context, subject, queue string, han
 
 	if a.js != nil {
 		// Use JetStream queue subscription
-		sub, err = a.js.QueueSubscribe(subject, queue, msgHandler,
-			nats.Durable(fmt.Sprintf("hexarag_%s_%s", sanitizeSubjectForDurable(subject), queue)),
-			nats.DeliverAll(),
-			nats.AckExplicit(),
-		)
+		durable := fmt.Sprintf("hexarag_%s_%s", sanitizeSubjectForDurable(subject), queue)
+		if err := a.reconcileConsumer(subject, durable); err != nil {
+			return err
+		}
+		sub, err = a.js.QueueSubscribe(subject, queue, msgHandler, a.consumerOptions(durable)...)
 	} else {
 		// Use core NATS queue subscription
 		sub, err = a.conn.QueueSubscribe(subject, queue, msgHandler)
@@ -250,10 +292,158 @@ func (a *Adapter) SubscribeQueue(ctx context.Context, subject, queue string, han
 		return fmt.Errorf("failed to subscribe to subject %s with queue %s: %w", subject, queue, err)
 	}
 
+	if err := a.conn.Flush(); err != nil {
+		sub.Unsubscribe()
+		return fmt.Errorf("failed to register subscription to subject %s with queue %s: %w", subject, queue, err)
+	}
+
 	a.subs[key] = sub
 	return nil
 }
 
+// consumerOptions returns the JetStream consumer options for a durable subscription.
+// Messages are acked manually by dispatch once the handler result is known.
+func (a *Adapter) consumerOptions(durable string) []nats.SubOpt {
+	opts := []nats.SubOpt{
+		nats.Durable(durable),
+		nats.DeliverAll(),
+		nats.AckExplicit(),
+		nats.ManualAck(),
+	}
+
+	// The server limit is a safety net; dispatch terminates on the last attempt itself
+	return append(opts, nats.MaxDeliver(a.policy.MaxDeliveries), nats.AckWait(a.policy.AckWait))
+}
+
+// reconcileConsumer updates an existing durable consumer whose redelivery settings differ
+// from the delivery policy, so subscribing doesn't fail after the policy changes
+func (a *Adapter) reconcileConsumer(subject, durable string) error {
+	stream, err := a.js.StreamNameBySubject(subject)
+	if err != nil {
+		return nil // Let the subscription report a missing stream
+	}
+
+	info, err := a.js.ConsumerInfo(stream, durable)
+	if err != nil {
+		return nil // Consumer doesn't exist yet
+	}
+
+	cfg := info.Config
+	if cfg.MaxDeliver == a.policy.MaxDeliveries && cfg.AckWait == a.policy.AckWait {
+		return nil
+	}
+
+	cfg.MaxDeliver = a.policy.MaxDeliveries
+	cfg.AckWait = a.policy.AckWait
+	if _, err := a.js.UpdateConsumer(stream, &cfg); err != nil {
+		return fmt.Errorf("failed to update consumer %s: %w", durable, err)
+	}
+
+	return nil
+}
+
+// dispatch runs the handler for a message and acks, naks or terminates it according to the delivery policy.
+// delivered counts attempts for core NATS messages, which are redelivered in-process.
+func (a *Adapter) dispatch(ctx context.Context, msg *nats.Msg, handler ports.MessageHandler, delivered int) {
+	jetStream := a.js != nil && msg.Reply != ""
+	if jetStream {
+		if meta, err := msg.Metadata(); err == nil {
+			delivered = int(meta.NumDelivered)
+		}
+		if inbox := msg.Header.Get(replyToHeader); inbox != "" {
+			ctx = ports.WithReplyFunc(ctx, func(data []byte) error {
+				return a.conn.Publish(inbox, data)
+			})
+		}
+	} else if msg.Reply != "" {
+		// Core NATS requests can be answered with ports.Respond
+		ctx = ports.WithReplyFunc(ctx, msg.Respond)
+	}
+
+	var err error
+	if jetStream {
+		err = a.handleInProgress(ctx, msg, handler)
+	} else {
+		err = handler(ctx, msg.Subject, msg.Data)
+	}
+	action, delay := a.policy.Decide(err, delivered)
+
+	switch action {
+	case ports.AckActionAck:
+		if jetStream {
+			if err := msg.Ack(); err != nil {
+				log.Printf("Failed to ack message on %s: %v", msg.Subject, err)
+			}
+		}
+
+	case ports.AckActionNack:
+		log.Printf("Handler error for subject %s (delivery %d, retrying in %s): %v", msg.Subject, delivered, delay, err)
+		if jetStream {
+			if err := msg.NakWithDelay(delay); err != nil {
+				log.Printf("Failed to nak message on %s: %v", msg.Subject, err)
+			}
+		} else {
+			time.AfterFunc(delay, func() {
+				if ctx.Err() == nil {
+					a.dispatch(ctx, msg, handler, delivered+1)
+				}
+			})
+		}
+
+	case ports.AckActionTerminate:
+		log.Printf("Handler error for subject %s (delivery %d, giving up): %v", msg.Subject, delivered, err)
+		a.deadLetter(ctx, msg.Subject, msg.Data, err, delivered)
+		if jetStream {
+			if err := msg.Term(); err != nil {
+				log.Printf("Failed to terminate message on %s: %v", msg.Subject, err)
+			}
+		}
+	}
+}
+
+// handleInProgress runs the handler for a JetStream message, telling the server every half ack
+// wait that it is still being worked on, so handlers may run longer than the ack wait
+func (a *Adapter) handleInProgress(ctx context.Context, msg *nats.Msg, handler ports.MessageHandler) error {
+	done := make(chan struct{})
+	defer close(done)
+
+	go func() {
+		ticker := time.NewTicker(a.policy.AckWait / 2)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if err := msg.InProgress(); err != nil {
+					log.Printf("Failed to report progress on %s: %v", msg.Subject, err)
+				}
+			}
+		}
+	}()
+
+	return handler(ctx, msg.Subject, msg.Data)
+}
+
+// deadLetter publishes a failed message to its dead-letter subject
+func (a *Adapter) deadLetter(ctx context.Context, subject string, data []byte, cause error, delivered int) {
+	dlqSubject := a.policy.DeadLetterSubject(subject)
+	if dlqSubject == "" {
+		return
+	}
+
+	letter, err := ports.MarshalDeadLetter(dlqSubject, subject, data, cause, delivered)
+	if err != nil {
+		log.Printf("Failed to dead-letter message from %s: %v", subject, err)
+		return
+	}
+
+	if err := a.Publish(ctx, dlqSubject, letter); err != nil {
+		log.Printf("Failed to dead-letter message from %s: %v", subject, err)
+	}
+}
+
 // Unsubscribe stops listening to a subject
 func (a *Adapter) Unsubscribe(ctx context.Context, subject string) error {
 	a.subsMutex.Lock()
@@ -288,6 +478,10 @@ func (a *Adapter) Request(ctx context.Context, subject string, data []byte, time
 	reqCtx, cancel := context.WithTimeout(ctx, requestTimeout)
 	defer cancel()
 
+	if a.js != nil {
+		return a.requestJetStream(reqCtx, subject, data)
+	}
+
 	// Send request
 	msg, err := a.conn.RequestWithContext(reqCtx, subject, data)
 	if err != nil {
@@ -297,6 +491,32 @@ func (a *Adapter) Request(ctx context.Context, subject string, data []byte, time
 	return msg.Data, nil
 }
 
+// requestJetStream sends a request through JetStream. Subscribers receive it from a stream,
+// so the requester inbox travels in a header instead of the reply subject.
+func (a *Adapter) requestJetStream(ctx context.Context, subject string, data []byte) ([]byte, error) {
+	inbox := nats.NewInbox()
+	sub, err := a.conn.SubscribeSync(inbox)
+	if err != nil {
+		return nil, fmt.Errorf("failed to subscribe to reply inbox: %w", err)
+	}
+	defer sub.Unsubscribe()
+
+	msg := nats.NewMsg(subject)
+	msg.Data = data
+	msg.Header.Set(replyToHeader, inbox)
+
+	if _, err := a.js.PublishMsg(msg, nats.Context(ctx)); err != nil {
+		return nil, fmt.Errorf("failed to send request to subject %s: %w", subject, err)
+	}
+
+	reply, err := sub.NextMsgWithContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to receive reply from subject %s: %w", subject, err)
+	}
+
+	return reply.Data, nil
+}
+
 // Close closes the messaging connection
 func (a *Adapter) Close() error {
 	a.subsMutex.Lock()
@@ -0,0 +1,78 @@
+package nats
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/username/hexarag/internal/adapters/messaging/messagingtest"
+	"github.com/username/hexarag/internal/domain/ports"
+)
+
+// startTestServer runs an embedded NATS server for the duration of a test
+func startTestServer(t *testing.T) *EmbeddedServer {
+	t.Helper()
+
+	embedded, err := StartEmbeddedServer(EmbeddedOptions{
+		DataDir: t.TempDir(),
+		Host:    "127.0.0.1",
+		Port:    -1,
+	})
+	if err != nil {
+		t.Fatalf("StartEmbeddedServer() error = %v", err)
+	}
+	t.Cleanup(embedded.Shutdown)
+
+	return embedded
+}
+
+func conformanceFactory(jetStream bool) messagingtest.Factory {
+	return func(t *testing.T, policy ports.DeliveryPolicy) messagingtest.Connector {
+		embedded := startTestServer(t)
+
+		return func(t *testing.T) ports.MessagingPort {
+			adapter, err := NewAdapter(embedded.ClientURL(), jetStream, 1, policy)
+			if err != nil {
+				t.Fatalf("NewAdapter() error = %v", err)
+			}
+			t.Cleanup(func() { adapter.Close() })
+			return adapter
+		}
+	}
+}
+
+func TestAdapter_Conformance(t *testing.T) {
+	messagingtest.RunConformance(t, conformanceFactory(false))
+}
+
+func TestAdapter_JetStreamConformance(t *testing.T) {
+	messagingtest.RunConformance(t, conformanceFactory(true))
+}
+
+func TestAdapter_SlowHandlersAreNotRedelivered(t *testing.T) {
+	ctx := context.Background()
+	policy := messagingtest.TestPolicy
+	policy.AckWait = 200 * time.Millisecond
+	connect := conformanceFactory(true)(t, policy)
+	bus := connect(t)
+
+	// The handler runs for several ack waits, reporting progress meanwhile
+	var deliveries atomic.Int32
+	err := bus.SubscribeQueue(ctx, "inference.request", "engines", func(ctx context.Context, subject string, data []byte) error {
+		deliveries.Add(1)
+		time.Sleep(5 * policy.AckWait)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("SubscribeQueue() error = %v", err)
+	}
+	if err := bus.Publish(ctx, "inference.request", []byte("job")); err != nil {
+		t.Fatalf("Publish() error = %v", err)
+	}
+
+	time.Sleep(7 * policy.AckWait)
+	if got := deliveries.Load(); got != 1 {
+		t.Errorf("Expected the slow message to be delivered once, got %d deliveries", got)
+	}
+}
@@ -0,0 +1,160 @@
+package nats
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/nats-io/nats.go"
+
+	"github.com/username/hexarag/internal/domain/ports"
+)
+
+const (
+	defaultStreamMessageLimit = 100
+	maxStreamMessageLimit     = 1000
+)
+
+// ListStreams returns the JetStream streams and their statistics
+func (a *Adapter) ListStreams(ctx context.Context) ([]ports.StreamInfo, error) {
+	if a.js == nil {
+		return nil, ports.ErrStreamsNotSupported
+	}
+
+	streams := make([]ports.StreamInfo, 0)
+	for info := range a.js.StreamsInfo(nats.Context(ctx)) {
+		streams = append(streams, streamInfo(info))
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list streams: %w", err)
+	}
+
+	return streams, nil
+}
+
+// GetStreamMessages reads stored messages from a stream through a temporary pull consumer,
+// leaving the durable consumers used by subscribers untouched
+func (a *Adapter) GetStreamMessages(ctx context.Context, stream string, query ports.StreamQuery) ([]ports.StreamMessage, error) {
+	if a.js == nil {
+		return nil, ports.ErrStreamsNotSupported
+	}
+
+	if _, err := a.js.StreamInfo(stream, nats.Context(ctx)); err != nil {
+		if errors.Is(err, nats.ErrStreamNotFound) {
+			return nil, fmt.Errorf("%w: %s", ports.ErrStreamNotFound, stream)
+		}
+		return nil, fmt.Errorf("failed to get stream info for %s: %w", stream, err)
+	}
+
+	limit := query.Limit
+	if limit <= 0 {
+		limit = defaultStreamMessageLimit
+	}
+	if limit > maxStreamMessageLimit {
+		limit = maxStreamMessageLimit
+	}
+
+	opts := []nats.SubOpt{nats.BindStream(stream), nats.AckNone(), nats.InactiveThreshold(time.Minute)}
+	switch {
+	case query.StartSequence > 0:
+		opts = append(opts, nats.StartSequence(query.StartSequence))
+	case !query.Since.IsZero():
+		opts = append(opts, nats.StartTime(query.Since))
+	default:
+		opts = append(opts, nats.DeliverAll())
+	}
+
+	sub, err := a.js.PullSubscribe(query.Subject, "", opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read stream %s: %w", stream, err)
+	}
+	defer sub.Unsubscribe()
+
+	// The consumer knows how many stored messages match, so reading never waits for new ones
+	info, err := sub.ConsumerInfo()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get consumer info for %s: %w", stream, err)
+	}
+
+	messages := make([]ports.StreamMessage, 0)
+	pending := info.NumPending
+	for pending > 0 && len(messages) < limit {
+		batch := limit - len(messages)
+		if uint64(batch) > pending {
+			batch = int(pending)
+		}
+
+		msgs, err := sub.Fetch(batch, nats.MaxWait(5*time.Second))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read stream %s: %w", stream, err)
+		}
+
+		for _, msg := range msgs {
+			meta, err := msg.Metadata()
+			if err != nil {
+				return nil, fmt.Errorf("failed to read message metadata: %w", err)
+			}
+			pending = meta.NumPending
+
+			if !query.Since.IsZero() && meta.Timestamp.Before(query.Since) {
+				continue
+			}
+			if !query.Until.IsZero() && !meta.Timestamp.Before(query.Until) {
+				return messages, nil
+			}
+
+			messages = append(messages, ports.StreamMessage{
+				Stream:    stream,
+				Sequence:  meta.Sequence.Stream,
+				Subject:   msg.Subject,
+				Timestamp: meta.Timestamp,
+				Data:      msg.Data,
+			})
+		}
+	}
+
+	return messages, nil
+}
+
+// GetStreamMessage reads a single stored message by its stream sequence
+func (a *Adapter) GetStreamMessage(ctx context.Context, stream string, sequence uint64) (*ports.StreamMessage, error) {
+	if a.js == nil {
+		return nil, ports.ErrStreamsNotSupported
+	}
+
+	msg, err := a.js.GetMsg(stream, sequence, nats.Context(ctx))
+	if err != nil {
+		switch {
+		case errors.Is(err, nats.ErrStreamNotFound):
+			return nil, fmt.Errorf("%w: %s", ports.ErrStreamNotFound, stream)
+		case errors.Is(err, nats.ErrMsgNotFound):
+			return nil, fmt.Errorf("%w: %s sequence %d", ports.ErrStreamMessageNotFound, stream, sequence)
+		}
+		return nil, fmt.Errorf("failed to get %s sequence %d: %w", stream, sequence, err)
+	}
+
+	return &ports.StreamMessage{
+		Stream:    stream,
+		Sequence:  msg.Sequence,
+		Subject:   msg.Subject,
+		Timestamp: msg.Time,
+		Data:      msg.Data,
+	}, nil
+}
+
+// streamInfo converts JetStream stream info to its port representation
+func streamInfo(info *nats.StreamInfo) ports.StreamInfo {
+	return ports.StreamInfo{
+		Name:          info.Config.Name,
+		Subjects:      info.Config.Subjects,
+		Messages:      info.State.Msgs,
+		Bytes:         info.State.Bytes,
+		FirstSequence: info.State.FirstSeq,
+		LastSequence:  info.State.LastSeq,
+		FirstTime:     info.State.FirstTime,
+		LastTime:      info.State.LastTime,
+		Consumers:     info.State.Consumers,
+		MaxAge:        info.Config.MaxAge,
+	}
+}
@@ -0,0 +1,91 @@
+package nats
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/username/hexarag/internal/domain/ports"
+)
+
+func TestAdapter_StreamMessages(t *testing.T) {
+	ctx := context.Background()
+	embedded := startTestServer(t)
+
+	adapter, err := NewAdapter(embedded.ClientURL(), true, 1, ports.DefaultDeliveryPolicy())
+	if err != nil {
+		t.Fatalf("NewAdapter() error = %v", err)
+	}
+	defer adapter.Close()
+
+	for _, subject := range []string{"conversation.a.updated", "conversation.b.updated", "conversation.a.updated"} {
+		if err := adapter.Publish(ctx, subject, []byte(`{"ok":true}`)); err != nil {
+			t.Fatalf("Publish() error = %v", err)
+		}
+	}
+
+	streams, err := adapter.ListStreams(ctx)
+	if err != nil {
+		t.Fatalf("ListStreams() error = %v", err)
+	}
+	found := false
+	for _, stream := range streams {
+		if stream.Name == "CONVERSATION_EVENTS" {
+			found = true
+			if stream.Messages != 3 || stream.LastSequence != 3 {
+				t.Errorf("Unexpected stream stats: %+v", stream)
+			}
+		}
+	}
+	if !found {
+		t.Fatalf("Expected CONVERSATION_EVENTS in %+v", streams)
+	}
+
+	messages, err := adapter.GetStreamMessages(ctx, "CONVERSATION_EVENTS", ports.StreamQuery{Subject: "conversation.a.>"})
+	if err != nil {
+		t.Fatalf("GetStreamMessages() error = %v", err)
+	}
+	if len(messages) != 2 || messages[0].Sequence != 1 || messages[1].Sequence != 3 || string(messages[1].Data) != `{"ok":true}` {
+		t.Errorf("Unexpected filtered messages: %+v", messages)
+	}
+
+	messages, err = adapter.GetStreamMessages(ctx, "CONVERSATION_EVENTS", ports.StreamQuery{StartSequence: 2, Limit: 1})
+	if err != nil {
+		t.Fatalf("GetStreamMessages() error = %v", err)
+	}
+	if len(messages) != 1 || messages[0].Subject != "conversation.b.updated" {
+		t.Errorf("Expected only sequence 2, got %+v", messages)
+	}
+
+	message, err := adapter.GetStreamMessage(ctx, "CONVERSATION_EVENTS", 2)
+	if err != nil || message.Subject != "conversation.b.updated" || message.Sequence != 2 {
+		t.Errorf("Expected sequence 2, got %+v (err = %v)", message, err)
+	}
+	if _, err := adapter.GetStreamMessage(ctx, "CONVERSATION_EVENTS", 42); !errors.Is(err, ports.ErrStreamMessageNotFound) {
+		t.Errorf("Expected ErrStreamMessageNotFound, got %v", err)
+	}
+
+	// Streams without matching messages return immediately
+	messages, err = adapter.GetStreamMessages(ctx, "TOOL_EVENTS", ports.StreamQuery{})
+	if err != nil || len(messages) != 0 {
+		t.Errorf("Expected no messages, got %+v (err = %v)", messages, err)
+	}
+
+	if _, err := adapter.GetStreamMessages(ctx, "MISSING", ports.StreamQuery{}); !errors.Is(err, ports.ErrStreamNotFound) {
+		t.Errorf("Expected ErrStreamNotFound, got %v", err)
+	}
+}
+
+func TestAdapter_StreamsRequireJetStream(t *testing.T) {
+	embedded := startTestServer(t)
+
+	adapter, err := NewAdapter(embedded.ClientURL(), false, 1, ports.DefaultDeliveryPolicy())
+	if err != nil {
+		t.Fatalf("NewAdapter() error = %v", err)
+	}
+	defer adapter.Close()
+
+	if _, err := adapter.ListStreams(context.Background()); !errors.Is(err, ports.ErrStreamsNotSupported) {
+		t.Errorf("Expected ErrStreamsNotSupported, got %v", err)
+	}
+}
@@ -0,0 +1,352 @@
+package redis
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/username/hexarag/internal/domain/ports"
+)
+
+const (
+	keyPrefix    = "hexarag:"
+	streamsKey   = keyPrefix + "streams" // Set of every stream key, for subscriptions starting with a wildcard
+	streamMaxLen = 100000
+
+	// pollInterval bounds how long a subscription blocks in one read, and so how quickly it
+	// notices new streams and unsubscribes
+	pollInterval = time.Second
+
+	// inboxTTL removes reply inboxes whose requester has given up
+	inboxTTL = time.Minute
+
+	fieldSubject = "subject"
+	fieldData    = "data"
+	fieldReply   = "reply"
+)
+
+// Adapter implements the MessagingPort interface on Redis Streams.
+// Subjects keep NATS semantics: each subject is stored in the stream named after its first
+// token, so "conversation.*.updated" reads hexarag:stream:conversation and filters by subject.
+// Queue subscriptions are consumer groups; other subscriptions read new entries with XREAD.
+type Adapter struct {
+	client    *redis.Client
+	subs      map[string]*subscription
+	subsMutex sync.RWMutex
+	policy    ports.DeliveryPolicy
+	retention time.Duration
+	consumer  string // Consumer name of this connection in every group it joins
+	closed    bool
+}
+
+// NewAdapter connects to the Redis server at url, e.g. redis://localhost:6379/0.
+// Streams are trimmed to retentionDays of history, or to a fixed length when it is zero.
+func NewAdapter(url string, retentionDays int, policy ports.DeliveryPolicy) (*Adapter, error) {
+	opts, err := redis.ParseURL(url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse Redis URL: %w", err)
+	}
+
+	client := redis.NewClient(opts)
+	if err := client.Ping(context.Background()).Err(); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("failed to connect to Redis: %w", err)
+	}
+
+	return &Adapter{
+		client:    client,
+		subs:      make(map[string]*subscription),
+		policy:    policy.WithDefaults(),
+		retention: time.Duration(retentionDays) * 24 * time.Hour,
+		consumer:  "hexarag-" + newID(),
+	}, nil
+}
+
+// Publish sends a message to the specified subject
+func (a *Adapter) Publish(ctx context.Context, subject string, data []byte) error {
+	if err := a.checkOpen(); err != nil {
+		return err
+	}
+	if err := validateSubject(subject, false); err != nil {
+		return err
+	}
+
+	if err := a.add(ctx, subject, data, ""); err != nil {
+		return fmt.Errorf("failed to publish to subject %s: %w", subject, err)
+	}
+	return nil
+}
+
+// PublishJSON publishes a JSON-serializable object to the subject, wrapped in a ports.Envelope
+func (a *Adapter) PublishJSON(ctx context.Context, subject string, obj interface{}) error {
+	data, err := ports.MarshalEnvelope(ctx, subject, obj)
+	if err != nil {
+		return fmt.Errorf("failed to marshal object for subject %s: %w", subject, err)
+	}
+
+	return a.Publish(ctx, subject, data)
+}
+
+// add appends a message to the stream for its subject, registering the stream for wildcard subscribers
+func (a *Adapter) add(ctx context.Context, subject string, data []byte, reply string) error {
+	key := streamKey(subject)
+
+	values := []interface{}{fieldSubject, subject, fieldData, data}
+	if reply != "" {
+		values = append(values, fieldReply, reply)
+	}
+
+	args := &redis.XAddArgs{
+		Stream: key,
+		Approx: true,
+		Values: values,
+	}
+	if a.retention > 0 {
+		args.MinID = fmt.Sprintf("%d-0", time.Now().Add(-a.retention).UnixMilli())
+	} else {
+		args.MaxLen = streamMaxLen
+	}
+
+	pipe := a.client.Pipeline()
+	pipe.SAdd(ctx, streamsKey, key)
+	pipe.XAdd(ctx, args)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// Subscribe listens for messages on the specified subject
+func (a *Adapter) Subscribe(ctx context.Context, subject string, handler ports.MessageHandler) error {
+	return a.subscribe(ctx, subject, "", subject, handler)
+}
+
+// SubscribeEphemeral listens for messages on the subject. Subscriptions outside a queue read the
+// streams without a consumer group, so they are already per-connection.
+func (a *Adapter) SubscribeEphemeral(ctx context.Context, subject string, handler ports.MessageHandler) error {
+	return a.Subscribe(ctx, subject, handler)
+}
+
+// SubscribeQueue creates a queue subscription for load balancing.
+// Members of a queue share a consumer group per stream, named after the queue and subject.
+func (a *Adapter) SubscribeQueue(ctx context.Context, subject, queue string, handler ports.MessageHandler) error {
+	return a.subscribe(ctx, subject, queue, fmt.Sprintf("%s:%s", subject, queue), handler)
+}
+
+func (a *Adapter) subscribe(ctx context.Context, subject, queue, key string, handler ports.MessageHandler) error {
+	if err := validateSubject(subject, true); err != nil {
+		return err
+	}
+
+	a.subsMutex.Lock()
+	defer a.subsMutex.Unlock()
+
+	if a.closed {
+		return fmt.Errorf("connection is closed")
+	}
+
+	// Check if already subscribed
+	if _, exists := a.subs[key]; exists {
+		if queue != "" {
+			return fmt.Errorf("already subscribed to subject %s with queue %s", subject, queue)
+		}
+		return fmt.Errorf("already subscribed to subject: %s", subject)
+	}
+
+	sub := newSubscription(a, ctx, subject, queue, ports.UnwrapEnvelopes(handler))
+
+	// Fix the starting position before returning, so no message published afterwards is missed
+	keys, err := sub.streamKeys(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to subscribe to subject %s: %w", subject, err)
+	}
+	for _, streamKey := range keys {
+		if err := sub.attach(ctx, streamKey, false); err != nil {
+			return fmt.Errorf("failed to subscribe to subject %s: %w", subject, err)
+		}
+	}
+
+	a.subs[key] = sub
+	go sub.run()
+
+	return nil
+}
+
+// Unsubscribe stops listening to a subject. Queue subscriptions are keyed as "subject:queue".
+// Messages a queue member was still processing are claimed by other members after the ack wait.
+func (a *Adapter) Unsubscribe(ctx context.Context, subject string) error {
+	a.subsMutex.Lock()
+	defer a.subsMutex.Unlock()
+
+	sub, exists := a.subs[subject]
+	if !exists {
+		return fmt.Errorf("not subscribed to subject: %s", subject)
+	}
+
+	sub.stop()
+	delete(a.subs, subject)
+	return nil
+}
+
+// Request sends a request and waits for a response.
+// The reply is read from an ephemeral inbox stream named in the request entry.
+func (a *Adapter) Request(ctx context.Context, subject string, data []byte, timeout ...interface{}) ([]byte, error) {
+	if err := a.checkOpen(); err != nil {
+		return nil, err
+	}
+	if err := validateSubject(subject, false); err != nil {
+		return nil, err
+	}
+
+	// Default timeout
+	requestTimeout := 10 * time.Second
+
+	// Override timeout if provided
+	if len(timeout) > 0 {
+		if t, ok := timeout[0].(time.Duration); ok {
+			requestTimeout = t
+		}
+	}
+
+	reqCtx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+
+	inbox := keyPrefix + "inbox:" + newID()
+	defer a.client.Del(context.Background(), inbox)
+
+	if err := a.add(reqCtx, subject, data, inbox); err != nil {
+		return nil, fmt.Errorf("failed to send request to subject %s: %w", subject, err)
+	}
+
+	streams, err := a.client.XRead(reqCtx, &redis.XReadArgs{
+		Streams: []string{inbox, "0-0"},
+		Count:   1,
+		Block:   requestTimeout,
+	}).Result()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			err = context.DeadlineExceeded
+		}
+		return nil, fmt.Errorf("failed to receive reply from subject %s: %w", subject, err)
+	}
+
+	return entryData(streams[0].Messages[0]), nil
+}
+
+// respond appends a reply to a requester's inbox stream
+func (a *Adapter) respond(ctx context.Context, inbox string, data []byte) error {
+	pipe := a.client.Pipeline()
+	pipe.XAdd(ctx, &redis.XAddArgs{
+		Stream: inbox,
+		Values: []interface{}{fieldData, data},
+	})
+	pipe.Expire(ctx, inbox, inboxTTL)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to send reply: %w", err)
+	}
+	return nil
+}
+
+// Close closes the messaging connection
+func (a *Adapter) Close() error {
+	a.subsMutex.Lock()
+	subs := a.subs
+	a.subs = make(map[string]*subscription)
+	a.closed = true
+	a.subsMutex.Unlock()
+
+	for _, sub := range subs {
+		sub.stop()
+	}
+
+	// Closing the client interrupts blocked reads
+	err := a.client.Close()
+	for _, sub := range subs {
+		<-sub.done
+	}
+
+	return err
+}
+
+// Ping checks messaging connectivity
+func (a *Adapter) Ping() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := a.client.Ping(ctx).Err(); err != nil {
+		return fmt.Errorf("failed to ping Redis: %w", err)
+	}
+	return nil
+}
+
+// GetConnectionStatus returns detailed connection information
+func (a *Adapter) GetConnectionStatus() map[string]interface{} {
+	status := map[string]interface{}{
+		"connected":         a.Ping() == nil,
+		"url":               a.client.Options().Addr,
+		"jetstream_enabled": false,
+		"consumer":          a.consumer,
+	}
+
+	stats := a.client.PoolStats()
+	status["pool_total_connections"] = stats.TotalConns
+	status["pool_idle_connections"] = stats.IdleConns
+
+	a.subsMutex.RLock()
+	status["active_subscriptions"] = len(a.subs)
+	a.subsMutex.RUnlock()
+
+	return status
+}
+
+func (a *Adapter) checkOpen() error {
+	a.subsMutex.RLock()
+	defer a.subsMutex.RUnlock()
+
+	if a.closed {
+		return fmt.Errorf("connection is closed")
+	}
+	return nil
+}
+
+// deadLetter publishes a failed message to its dead-letter subject
+func (a *Adapter) deadLetter(ctx context.Context, subject string, data []byte, cause error, delivered int) {
+	dlqSubject := a.policy.DeadLetterSubject(subject)
+	if dlqSubject == "" {
+		return
+	}
+
+	letter, err := ports.MarshalDeadLetter(dlqSubject, subject, data, cause, delivered)
+	if err != nil {
+		log.Printf("Failed to dead-letter message from %s: %v", subject, err)
+		return
+	}
+
+	if err := a.Publish(ctx, dlqSubject, letter); err != nil {
+		log.Printf("Failed to dead-letter message from %s: %v", subject, err)
+	}
+}
+
+// streamKey returns the stream holding a subject, named after its first token
+func streamKey(subject string) string {
+	root, _, _ := strings.Cut(subject, ".")
+	return keyPrefix + "stream:" + root
+}
+
+// entryData returns the message payload of a stream entry
+func entryData(msg redis.XMessage) []byte {
+	data, _ := msg.Values[fieldData].(string)
+	return []byte(data)
+}
+
+// newID creates a unique identifier using timestamp and random bytes
+func newID() string {
+	randomBytes := make([]byte, 4)
+	rand.Read(randomBytes)
+	return fmt.Sprintf("%x_%x", time.Now().UnixNano(), randomBytes)
+}
@@ -0,0 +1,137 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+
+	"github.com/username/hexarag/internal/adapters/messaging/messagingtest"
+	"github.com/username/hexarag/internal/domain/ports"
+)
+
+func conformanceFactory(t *testing.T, policy ports.DeliveryPolicy) messagingtest.Connector {
+	server := miniredis.RunT(t)
+
+	return func(t *testing.T) ports.MessagingPort {
+		adapter, err := NewAdapter("redis://"+server.Addr(), 1, policy)
+		if err != nil {
+			t.Fatalf("NewAdapter() error = %v", err)
+		}
+		t.Cleanup(func() { adapter.Close() })
+		return adapter
+	}
+}
+
+func TestAdapter_Conformance(t *testing.T) {
+	messagingtest.RunConformance(t, conformanceFactory)
+}
+
+func TestAdapter_ClaimsAbandonedEntries(t *testing.T) {
+	ctx := context.Background()
+	policy := messagingtest.TestPolicy
+	policy.AckWait = 200 * time.Millisecond
+	policy.Backoff = []time.Duration{time.Minute}
+	connect := conformanceFactory(t, policy)
+	first, second := connect(t), connect(t)
+
+	// The first member fails and leaves the entry pending while it waits to retry
+	failed := make(chan struct{}, 1)
+	err := first.SubscribeQueue(ctx, "tool.execute", "workers", func(ctx context.Context, subject string, data []byte) error {
+		failed <- struct{}{}
+		return errors.New("worker crashed")
+	})
+	if err != nil {
+		t.Fatalf("SubscribeQueue() error = %v", err)
+	}
+	if err := first.Publish(ctx, "tool.execute", []byte("job")); err != nil {
+		t.Fatalf("Publish() error = %v", err)
+	}
+
+	select {
+	case <-failed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for the first delivery")
+	}
+	first.Close()
+
+	claimed := make(chan struct{}, 1)
+	err = second.SubscribeQueue(ctx, "tool.execute", "workers", func(ctx context.Context, subject string, data []byte) error {
+		claimed <- struct{}{}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("SubscribeQueue() error = %v", err)
+	}
+
+	select {
+	case <-claimed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the abandoned entry to be claimed by the remaining member")
+	}
+}
+
+func TestAdapter_KeepsSlowEntriesClaimed(t *testing.T) {
+	ctx := context.Background()
+	policy := messagingtest.TestPolicy
+	policy.AckWait = 200 * time.Millisecond
+	connect := conformanceFactory(t, policy)
+	first, second := connect(t), connect(t)
+
+	// Handlers run for several ack waits and a stale entry check; the entry must not be handed
+	// to the other member meanwhile
+	hold := pollInterval + 4*policy.AckWait
+	var deliveries atomic.Int32
+	handler := func(ctx context.Context, subject string, data []byte) error {
+		deliveries.Add(1)
+		time.Sleep(hold)
+		return nil
+	}
+	for _, member := range []ports.MessagingPort{first, second} {
+		if err := member.SubscribeQueue(ctx, "inference.request", "engines", handler); err != nil {
+			t.Fatalf("SubscribeQueue() error = %v", err)
+		}
+	}
+	if err := first.Publish(ctx, "inference.request", []byte("job")); err != nil {
+		t.Fatalf("Publish() error = %v", err)
+	}
+
+	time.Sleep(hold + pollInterval)
+	if got := deliveries.Load(); got != 1 {
+		t.Errorf("Expected the slow entry to be delivered once, got %d deliveries", got)
+	}
+}
+
+func TestAdapter_WildcardRootDiscoversStreams(t *testing.T) {
+	ctx := context.Background()
+	bus := conformanceFactory(t, messagingtest.TestPolicy)(t)
+
+	subjects := make(chan string, 2)
+	err := bus.Subscribe(ctx, ">", func(ctx context.Context, subject string, data []byte) error {
+		subjects <- subject
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Subscribe() error = %v", err)
+	}
+
+	// Neither stream exists when subscribing
+	for _, subject := range []string{"inference.response", "context.ready"} {
+		if err := bus.Publish(ctx, subject, []byte("{}")); err != nil {
+			t.Fatalf("Publish() error = %v", err)
+		}
+	}
+
+	got := make(map[string]bool)
+	for len(got) < 2 {
+		select {
+		case subject := <-subjects:
+			got[subject] = true
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Timed out waiting for messages on new streams, got %v", got)
+		}
+	}
+}
@@ -0,0 +1,438 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/username/hexarag/internal/domain/ports"
+)
+
+const (
+	// readBatchSize is the number of entries a plain subscription reads at once
+	readBatchSize = 100
+
+	// claimBatchSize is the number of stale entries a queue member takes over at once
+	claimBatchSize = 10
+)
+
+// subscription reads the streams matching its subject and delivers matching entries to its handler
+type subscription struct {
+	adapter  *Adapter
+	ctx      context.Context // Passed to the handler
+	subject  string
+	tokens   []string
+	queue    string
+	group    string
+	handler  ports.MessageHandler
+	dispatch func(handle func()) // Runs each entry's handler; concurrently for queue members
+
+	loopCtx context.Context // Cancelled when the subscription stops
+	cancel  context.CancelFunc
+	done    chan struct{}
+
+	// Read position per stream: the last ID read, or ">" for consumer groups.
+	// Only touched by subscribe and then by run.
+	positions map[string]string
+	keys      []string
+
+	retryMutex sync.Mutex
+	retrying   map[string]bool // Group entries waiting for an in-process redelivery
+}
+
+func newSubscription(a *Adapter, ctx context.Context, subject, queue string, handler ports.MessageHandler) *subscription {
+	loopCtx, cancel := context.WithCancel(context.Background())
+
+	sub := &subscription{
+		adapter:   a,
+		ctx:       ctx,
+		subject:   subject,
+		tokens:    splitSubject(subject),
+		queue:     queue,
+		handler:   handler,
+		loopCtx:   loopCtx,
+		cancel:    cancel,
+		done:      make(chan struct{}),
+		positions: make(map[string]string),
+		retrying:  make(map[string]bool),
+		dispatch:  func(handle func()) { handle() },
+	}
+	if queue != "" {
+		sub.group = fmt.Sprintf("%s:%s", queue, subject)
+		sub.dispatch = a.policy.QueueDispatcher()
+	}
+	return sub
+}
+
+// wildcardRoot reports whether the subject starts with a wildcard, so any stream may match
+func (s *subscription) wildcardRoot() bool {
+	return s.tokens[0] == "*" || s.tokens[0] == ">"
+}
+
+// streamKeys returns the keys of the streams that can hold matching subjects
+func (s *subscription) streamKeys(ctx context.Context) ([]string, error) {
+	if !s.wildcardRoot() {
+		return []string{streamKey(s.subject)}, nil
+	}
+
+	keys, err := s.adapter.client.SMembers(ctx, streamsKey).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list streams: %w", err)
+	}
+	return keys, nil
+}
+
+// attach starts reading a stream. Streams discovered after subscribing are read from the
+// beginning, since everything in them was published after the subscription was made.
+func (s *subscription) attach(ctx context.Context, key string, discovered bool) error {
+	if _, ok := s.positions[key]; ok {
+		return nil
+	}
+
+	if s.queue != "" {
+		start := "$"
+		if discovered {
+			start = "0"
+		}
+		err := s.adapter.client.XGroupCreateMkStream(ctx, key, s.group, start).Err()
+		if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+			return fmt.Errorf("failed to create consumer group %s on %s: %w", s.group, key, err)
+		}
+		s.positions[key] = ">"
+	} else {
+		position := "0-0"
+		if !discovered {
+			last, err := s.adapter.client.XRevRangeN(ctx, key, "+", "-", 1).Result()
+			if err != nil {
+				return fmt.Errorf("failed to read stream %s: %w", key, err)
+			}
+			if len(last) > 0 {
+				position = last[0].ID
+			}
+		}
+		s.positions[key] = position
+	}
+
+	s.keys = append(s.keys, key)
+	return nil
+}
+
+// run reads and dispatches entries until the subscription stops
+func (s *subscription) run() {
+	defer close(s.done)
+
+	lastClaim := time.Now()
+	for s.loopCtx.Err() == nil {
+		if s.wildcardRoot() {
+			s.discoverStreams()
+		}
+		if len(s.keys) == 0 {
+			s.pause()
+			continue
+		}
+
+		if s.queue != "" && time.Since(lastClaim) >= s.adapter.policy.AckWait {
+			s.claimStale()
+			lastClaim = time.Now()
+		}
+
+		streams, err := s.read()
+		if err != nil {
+			if errors.Is(err, redis.Nil) {
+				continue // Nothing new within the poll interval
+			}
+			if s.loopCtx.Err() != nil {
+				return
+			}
+			log.Printf("Failed to read streams for subject %s: %v", s.subject, err)
+			s.pause()
+			continue
+		}
+
+		for _, stream := range streams {
+			for _, msg := range stream.Messages {
+				if s.loopCtx.Err() != nil {
+					return
+				}
+				if s.queue == "" {
+					s.positions[stream.Stream] = msg.ID
+				}
+				key, msg := stream.Stream, msg
+				s.dispatch(func() { s.handle(key, msg, 1) })
+			}
+		}
+	}
+}
+
+// read blocks until new entries arrive on any attached stream or the poll interval passes
+func (s *subscription) read() ([]redis.XStream, error) {
+	streams := make([]string, 0, len(s.keys)*2)
+	streams = append(streams, s.keys...)
+	for _, key := range s.keys {
+		streams = append(streams, s.positions[key])
+	}
+
+	if s.queue == "" {
+		return s.adapter.client.XRead(s.loopCtx, &redis.XReadArgs{
+			Streams: streams,
+			Count:   readBatchSize,
+			Block:   pollInterval,
+		}).Result()
+	}
+
+	// Members take one entry at a time so the group shares load like a NATS queue group
+	return s.adapter.client.XReadGroup(s.loopCtx, &redis.XReadGroupArgs{
+		Group:    s.group,
+		Consumer: s.adapter.consumer,
+		Streams:  streams,
+		Count:    1,
+		Block:    pollInterval,
+	}).Result()
+}
+
+// discoverStreams attaches streams created since the last read
+func (s *subscription) discoverStreams() {
+	keys, err := s.streamKeys(s.loopCtx)
+	if err != nil {
+		if s.loopCtx.Err() == nil {
+			log.Printf("Failed to discover streams for subject %s: %v", s.subject, err)
+		}
+		return
+	}
+
+	for _, key := range keys {
+		if err := s.attach(s.loopCtx, key, true); err != nil {
+			log.Printf("Failed to attach stream %s for subject %s: %v", key, s.subject, err)
+		}
+	}
+}
+
+// handle runs the handler for an entry and acks, retries or dead-letters it according to the delivery policy.
+// Retries happen in-process; group entries stay pending meanwhile so another member can claim them.
+func (s *subscription) handle(key string, msg redis.XMessage, delivered int) {
+	subject, _ := msg.Values[fieldSubject].(string)
+	if !matches(s.tokens, splitSubject(subject)) {
+		s.ack(key, msg.ID) // Other subjects share the stream
+		return
+	}
+
+	data := entryData(msg)
+	ctx := s.ctx
+	if inbox, _ := msg.Values[fieldReply].(string); inbox != "" {
+		ctx = ports.WithReplyFunc(ctx, func(data []byte) error {
+			return s.adapter.respond(context.Background(), inbox, data)
+		})
+	}
+
+	release := s.keepClaimed(key, msg.ID)
+	err := s.handler(ctx, subject, data)
+	release()
+	action, delay := s.adapter.policy.Decide(err, delivered)
+
+	switch action {
+	case ports.AckActionAck:
+		s.ack(key, msg.ID)
+
+	case ports.AckActionNack:
+		log.Printf("Handler error for subject %s (delivery %d, retrying in %s): %v", subject, delivered, delay, err)
+		s.setRetrying(msg.ID, true)
+		time.AfterFunc(delay, func() {
+			s.setRetrying(msg.ID, false)
+			if s.loopCtx.Err() != nil {
+				return
+			}
+			if s.queue != "" {
+				// Reclaiming resets the idle time, and fails if another member already took the entry over
+				claimed, err := s.adapter.client.XClaim(s.loopCtx, &redis.XClaimArgs{
+					Stream:   key,
+					Group:    s.group,
+					Consumer: s.adapter.consumer,
+					Messages: []string{msg.ID},
+				}).Result()
+				if err != nil || len(claimed) == 0 {
+					return
+				}
+				msg = claimed[0]
+			}
+			s.handle(key, msg, delivered+1)
+		})
+
+	case ports.AckActionTerminate:
+		log.Printf("Handler error for subject %s (delivery %d, giving up): %v", subject, delivered, err)
+		s.adapter.deadLetter(s.ctx, subject, data, err, delivered)
+		s.ack(key, msg.ID)
+	}
+}
+
+// keepClaimed resets a group entry's idle time every half ack wait until the returned function
+// is called, so other members don't claim an entry whose handler is still running
+func (s *subscription) keepClaimed(key, id string) func() {
+	if s.queue == "" {
+		return func() {}
+	}
+
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(s.adapter.policy.AckWait / 2)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-s.loopCtx.Done():
+				return // Other members take over once the ack wait passes
+			case <-ticker.C:
+				err := s.adapter.client.XClaimJustID(s.loopCtx, &redis.XClaimArgs{
+					Stream:   key,
+					Group:    s.group,
+					Consumer: s.adapter.consumer,
+					Messages: []string{id},
+				}).Err()
+				if err != nil && s.loopCtx.Err() == nil {
+					log.Printf("Failed to refresh claim on entry %s on %s: %v", id, key, err)
+				}
+			}
+		}
+	}()
+
+	return func() { close(done) }
+}
+
+// ack removes a group entry from the pending list
+func (s *subscription) ack(key, id string) {
+	if s.queue == "" {
+		return
+	}
+	if err := s.adapter.client.XAck(context.Background(), key, s.group, id).Err(); err != nil {
+		log.Printf("Failed to ack entry %s on %s: %v", id, key, err)
+	}
+}
+
+// claimStale takes over group entries left unacknowledged for longer than the ack wait,
+// e.g. by a member that stopped while handling them. Entries whose handler is still running
+// stay claimed by keepClaimed.
+func (s *subscription) claimStale() {
+	for _, key := range s.keys {
+		msgs, _, err := s.adapter.client.XAutoClaim(s.loopCtx, &redis.XAutoClaimArgs{
+			Stream:   key,
+			Group:    s.group,
+			Consumer: s.adapter.consumer,
+			MinIdle:  s.adapter.policy.AckWait,
+			Start:    "0-0",
+			Count:    claimBatchSize,
+		}).Result()
+		if err != nil {
+			if s.loopCtx.Err() == nil {
+				log.Printf("Failed to claim stale entries on %s: %v", key, err)
+			}
+			continue
+		}
+
+		for _, msg := range msgs {
+			if s.loopCtx.Err() != nil {
+				return
+			}
+			if s.isRetrying(msg.ID) {
+				continue // Already waiting for its redelivery here
+			}
+			key, msg, delivered := key, msg, s.deliveries(key, msg.ID)
+			s.dispatch(func() { s.handle(key, msg, delivered) })
+		}
+	}
+}
+
+// deliveries returns how many times a group entry has been delivered
+func (s *subscription) deliveries(key, id string) int {
+	pending, err := s.adapter.client.XPendingExt(s.loopCtx, &redis.XPendingExtArgs{
+		Stream: key,
+		Group:  s.group,
+		Start:  id,
+		End:    id,
+		Count:  1,
+	}).Result()
+	if err != nil || len(pending) == 0 {
+		return 2 // It was delivered at least once before being claimed
+	}
+	return int(pending[0].RetryCount)
+}
+
+func (s *subscription) setRetrying(id string, retrying bool) {
+	s.retryMutex.Lock()
+	defer s.retryMutex.Unlock()
+
+	if retrying {
+		s.retrying[id] = true
+	} else {
+		delete(s.retrying, id)
+	}
+}
+
+func (s *subscription) isRetrying(id string) bool {
+	s.retryMutex.Lock()
+	defer s.retryMutex.Unlock()
+	return s.retrying[id]
+}
+
+// pause waits for the poll interval unless the subscription stops first
+func (s *subscription) pause() {
+	select {
+	case <-s.loopCtx.Done():
+	case <-time.After(pollInterval):
+	}
+}
+
+func (s *subscription) stop() {
+	s.cancel()
+}
+
+// splitSubject splits a subject into its dot-separated tokens
+func splitSubject(subject string) []string {
+	return strings.Split(subject, ".")
+}
+
+// matches reports whether subject tokens match a subscription pattern
+func matches(pattern, subject []string) bool {
+	for i, token := range pattern {
+		if token == ">" {
+			return len(subject) > i
+		}
+		if i >= len(subject) {
+			return false
+		}
+		if token != "*" && token != subject[i] {
+			return false
+		}
+	}
+	return len(pattern) == len(subject)
+}
+
+// validateSubject checks subject syntax; wildcards are only allowed in subscriptions
+func validateSubject(subject string, allowWildcards bool) error {
+	if subject == "" {
+		return fmt.Errorf("subject cannot be empty")
+	}
+
+	tokens := splitSubject(subject)
+	for i, token := range tokens {
+		switch {
+		case token == "":
+			return fmt.Errorf("invalid subject %q: empty token", subject)
+		case token == "*" || token == ">":
+			if !allowWildcards {
+				return fmt.Errorf("invalid subject %q: wildcards are only allowed in subscriptions", subject)
+			}
+			if token == ">" && i != len(tokens)-1 {
+				return fmt.Errorf("invalid subject %q: > must be the last token", subject)
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,11 @@
+-- Add pinning and archive state to conversations for retention policies
+
+-- Pinned conversations are never pruned by retention
+ALTER TABLE conversations ADD COLUMN pinned BOOLEAN NOT NULL DEFAULT 0;
+
+-- Archived conversations are kept but excluded from active use
+ALTER TABLE conversations ADD COLUMN archived_at TIMESTAMP;
+
+-- Indexes for retention queries
+CREATE INDEX IF NOT EXISTS idx_conversations_updated_at ON conversations(updated_at);
+CREATE INDEX IF NOT EXISTS idx_conversations_archived_at ON conversations(archived_at);
@@ -0,0 +1,9 @@
+-- Soft delete support for conversations and system prompts
+
+-- Deleted rows stay in the trash until purged after the grace period
+ALTER TABLE conversations ADD COLUMN deleted_at TIMESTAMP;
+ALTER TABLE system_prompts ADD COLUMN deleted_at TIMESTAMP;
+
+-- Indexes for trash queries
+CREATE INDEX IF NOT EXISTS idx_conversations_deleted_at ON conversations(deleted_at);
+CREATE INDEX IF NOT EXISTS idx_system_prompts_deleted_at ON system_prompts(deleted_at);
@@ -0,0 +1,31 @@
+-- Organize conversations with folders, tags and stars
+
+-- Folders form a hierarchy through parent_id
+CREATE TABLE IF NOT EXISTS folders (
+    id TEXT PRIMARY KEY,
+    name TEXT NOT NULL,
+    parent_id TEXT,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (parent_id) REFERENCES folders(id)
+);
+
+-- Conversations belong to at most one folder
+ALTER TABLE conversations ADD COLUMN folder_id TEXT REFERENCES folders(id) ON DELETE SET NULL;
+
+-- Starred conversations are favourites; unlike pinning this has no effect on retention
+ALTER TABLE conversations ADD COLUMN starred BOOLEAN NOT NULL DEFAULT 0;
+
+-- Tags are free-form labels shared between conversations
+CREATE TABLE IF NOT EXISTS conversation_tags (
+    conversation_id TEXT NOT NULL,
+    tag TEXT NOT NULL,
+    PRIMARY KEY (conversation_id, tag),
+    FOREIGN KEY (conversation_id) REFERENCES conversations(id) ON DELETE CASCADE
+);
+
+-- Indexes for list filters
+CREATE INDEX IF NOT EXISTS idx_folders_parent_id ON folders(parent_id);
+CREATE INDEX IF NOT EXISTS idx_conversations_folder_id ON conversations(folder_id);
+CREATE INDEX IF NOT EXISTS idx_conversations_pinned ON conversations(pinned);
+CREATE INDEX IF NOT EXISTS idx_conversation_tags_tag ON conversation_tags(tag);
@@ -0,0 +1,2 @@
+-- Record why the model stopped generating an assistant message, e.g. "cancelled"
+ALTER TABLE messages ADD COLUMN finish_reason TEXT;
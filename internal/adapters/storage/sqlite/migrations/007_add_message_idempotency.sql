@@ -0,0 +1,7 @@
+-- Clients retrying a submission send the same idempotency key, which is unique per conversation
+ALTER TABLE messages ADD COLUMN idempotency_key TEXT;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_messages_idempotency_key ON messages(conversation_id, idempotency_key) WHERE idempotency_key IS NOT NULL;
+
+-- Replies are found through the message they answer
+CREATE INDEX IF NOT EXISTS idx_messages_parent_message_id ON messages(parent_message_id);
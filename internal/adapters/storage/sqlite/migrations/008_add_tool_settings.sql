@@ -0,0 +1,3 @@
+-- Tools enabled or disabled per conversation and per system prompt, stored as JSON
+ALTER TABLE conversations ADD COLUMN tool_settings TEXT;
+ALTER TABLE system_prompts ADD COLUMN tool_settings TEXT;
@@ -0,0 +1,20 @@
+-- Tool calls can wait for a user's approval; SQLite can't alter a CHECK constraint, so the table is rebuilt
+CREATE TABLE tool_calls_new (
+    id TEXT PRIMARY KEY,
+    message_id TEXT NOT NULL,
+    tool_name TEXT NOT NULL,
+    arguments TEXT NOT NULL, -- JSON
+    result TEXT, -- JSON
+    status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'awaiting_approval', 'approved', 'rejected', 'success', 'error')),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (message_id) REFERENCES messages(id) ON DELETE CASCADE
+);
+
+INSERT INTO tool_calls_new (id, message_id, tool_name, arguments, result, status, created_at)
+SELECT id, message_id, tool_name, arguments, result, status, created_at FROM tool_calls;
+
+DROP TABLE tool_calls;
+ALTER TABLE tool_calls_new RENAME TO tool_calls;
+
+CREATE INDEX IF NOT EXISTS idx_tool_calls_message_id ON tool_calls(message_id);
+CREATE INDEX IF NOT EXISTS idx_tool_calls_status ON tool_calls(status);
@@ -0,0 +1,20 @@
+-- Full-text index over message content, kept in sync with the messages table by triggers
+CREATE VIRTUAL TABLE IF NOT EXISTS messages_fts USING fts4(content="messages", content, tokenize=unicode61);
+
+INSERT INTO messages_fts(messages_fts) VALUES ('rebuild');
+
+CREATE TRIGGER IF NOT EXISTS messages_fts_before_update BEFORE UPDATE OF content ON messages BEGIN
+    DELETE FROM messages_fts WHERE docid = old.rowid;
+END;
+
+CREATE TRIGGER IF NOT EXISTS messages_fts_before_delete BEFORE DELETE ON messages BEGIN
+    DELETE FROM messages_fts WHERE docid = old.rowid;
+END;
+
+CREATE TRIGGER IF NOT EXISTS messages_fts_after_update AFTER UPDATE OF content ON messages BEGIN
+    INSERT INTO messages_fts(docid, content) VALUES (new.rowid, new.content);
+END;
+
+CREATE TRIGGER IF NOT EXISTS messages_fts_after_insert AFTER INSERT ON messages BEGIN
+    INSERT INTO messages_fts(docid, content) VALUES (new.rowid, new.content);
+END;
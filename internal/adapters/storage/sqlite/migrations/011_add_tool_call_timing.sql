@@ -0,0 +1,10 @@
+-- When tool calls finish and how long their tools ran, for tool call analytics
+ALTER TABLE tool_calls ADD COLUMN completed_at TIMESTAMP;
+ALTER TABLE tool_calls ADD COLUMN duration_ms INTEGER;
+
+-- Calls completed before this migration finished when their result was recorded
+UPDATE tool_calls SET completed_at = json_extract(result, '$.timestamp')
+WHERE status IN ('success', 'error', 'rejected') AND result IS NOT NULL AND result != '';
+
+CREATE INDEX IF NOT EXISTS idx_tool_calls_tool_name ON tool_calls(tool_name);
+CREATE INDEX IF NOT EXISTS idx_tool_calls_created_at ON tool_calls(created_at);
@@ -2,21 +2,37 @@ package sqlite
 
 import (
 	"context"
+	"crypto/rand"
 	"database/sql"
+	"encoding/binary"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"math"
 	"os"
 	"path/filepath"
 	"sort"
 	"strings"
 	"time"
+	"unicode"
 
-	_ "github.com/mattn/go-sqlite3"
+	sqlite3 "github.com/mattn/go-sqlite3"
 
 	"github.com/username/hexarag/internal/domain/entities"
 	"github.com/username/hexarag/internal/domain/ports"
 )
 
+// driverName is the SQLite driver with the SQL functions the adapter's queries use
+const driverName = "sqlite3_hexarag"
+
+func init() {
+	sql.Register(driverName, &sqlite3.SQLiteDriver{
+		ConnectHook: func(conn *sqlite3.SQLiteConn) error {
+			return conn.RegisterFunc("bm25", bm25, true)
+		},
+	})
+}
+
 // Adapter implements the StoragePort interface using SQLite
 type Adapter struct {
 	db             *sql.DB
@@ -25,7 +41,7 @@ type Adapter struct {
 
 // NewAdapter creates a new SQLite storage adapter
 func NewAdapter(dbPath, migrationsPath string) (*Adapter, error) {
-	db, err := sql.Open("sqlite3", dbPath+"?_foreign_keys=1")
+	db, err := sql.Open(driverName, dbPath+"?_foreign_keys=1")
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
@@ -126,14 +142,98 @@ func (a *Adapter) Close() error {
 	return a.db.Close()
 }
 
+// backupPagesPerStep controls how many pages are copied before yielding to writers
+const backupPagesPerStep = 256
+
+// Backup writes a consistent snapshot of the live database to destPath using the SQLite online backup API
+func (a *Adapter) Backup(ctx context.Context, destPath string) error {
+	destDB, err := sql.Open("sqlite3", destPath)
+	if err != nil {
+		return fmt.Errorf("failed to open backup database: %w", err)
+	}
+	defer destDB.Close()
+
+	destConn, err := destDB.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to connect to backup database: %w", err)
+	}
+	defer destConn.Close()
+
+	srcConn, err := a.db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to connect to source database: %w", err)
+	}
+	defer srcConn.Close()
+
+	return destConn.Raw(func(destDriverConn interface{}) error {
+		return srcConn.Raw(func(srcDriverConn interface{}) error {
+			dest, ok := destDriverConn.(*sqlite3.SQLiteConn)
+			if !ok {
+				return fmt.Errorf("unexpected backup connection type %T", destDriverConn)
+			}
+			src, ok := srcDriverConn.(*sqlite3.SQLiteConn)
+			if !ok {
+				return fmt.Errorf("unexpected source connection type %T", srcDriverConn)
+			}
+
+			backup, err := dest.Backup("main", src, "main")
+			if err != nil {
+				return fmt.Errorf("failed to start backup: %w", err)
+			}
+
+			for {
+				done, err := backup.Step(backupPagesPerStep)
+				if err != nil {
+					backup.Finish()
+					return fmt.Errorf("backup step failed: %w", err)
+				}
+				if done {
+					break
+				}
+
+				select {
+				case <-ctx.Done():
+					backup.Finish()
+					return fmt.Errorf("backup cancelled: %w", ctx.Err())
+				default:
+				}
+			}
+
+			if err := backup.Finish(); err != nil {
+				return fmt.Errorf("failed to finish backup: %w", err)
+			}
+			return nil
+		})
+	})
+}
+
 // Message operations
 func (a *Adapter) SaveMessage(ctx context.Context, message *entities.Message) error {
+	tx, err := a.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := insertMessage(ctx, tx, message); err != nil {
+		return err
+	}
+
+	if err := appendEvents(ctx, tx, message.ConversationID, entities.MessageAdded{Message: *message}); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// insertMessage stores a message and its tool calls
+func insertMessage(ctx context.Context, db execer, message *entities.Message) error {
 	query := `
-		INSERT INTO messages (id, conversation_id, role, content, parent_message_id, token_count, model, created_at)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		INSERT INTO messages (id, conversation_id, role, content, parent_message_id, token_count, model, finish_reason, idempotency_key, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`
 
-	_, err := a.db.ExecContext(ctx, query,
+	_, err := db.ExecContext(ctx, query,
 		message.ID,
 		message.ConversationID,
 		string(message.Role),
@@ -141,15 +241,21 @@ func (a *Adapter) SaveMessage(ctx context.Context, message *entities.Message) er
 		message.ParentID,
 		message.TokenCount,
 		message.Model,
+		nullIfEmpty(message.FinishReason),
+		nullIfEmpty(message.IdempotencyKey),
 		message.CreatedAt,
 	)
 	if err != nil {
+		var sqliteErr sqlite3.Error
+		if errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique && message.IdempotencyKey != "" {
+			return fmt.Errorf("failed to save message: %w", ports.ErrDuplicateIdempotencyKey)
+		}
 		return fmt.Errorf("failed to save message: %w", err)
 	}
 
 	// Save tool calls if present
 	for _, toolCall := range message.ToolCalls {
-		if err := a.SaveToolCall(ctx, &toolCall); err != nil {
+		if err := insertToolCall(ctx, db, &toolCall); err != nil {
 			return fmt.Errorf("failed to save tool call: %w", err)
 		}
 	}
@@ -157,17 +263,16 @@ func (a *Adapter) SaveMessage(ctx context.Context, message *entities.Message) er
 	return nil
 }
 
-func (a *Adapter) GetMessage(ctx context.Context, id string) (*entities.Message, error) {
-	query := `
-		SELECT id, conversation_id, role, content, parent_message_id, token_count, model, created_at
-		FROM messages WHERE id = ?
-	`
-
-	row := a.db.QueryRowContext(ctx, query, id)
+// messageColumns lists the columns read by scanMessage, in order
+const messageColumns = "id, conversation_id, role, content, parent_message_id, token_count, model, finish_reason, idempotency_key, created_at"
 
+// scanMessage reads a message row selected with messageColumns
+func scanMessage(row rowScanner) (*entities.Message, error) {
 	var message entities.Message
 	var parentID sql.NullString
 	var model sql.NullString
+	var finishReason sql.NullString
+	var idempotencyKey sql.NullString
 
 	err := row.Scan(
 		&message.ID,
@@ -177,13 +282,12 @@ func (a *Adapter) GetMessage(ctx context.Context, id string) (*entities.Message,
 		&parentID,
 		&message.TokenCount,
 		&model,
+		&finishReason,
+		&idempotencyKey,
 		&message.CreatedAt,
 	)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("message not found: %s", id)
-		}
-		return nil, fmt.Errorf("failed to get message: %w", err)
+		return nil, err
 	}
 
 	if parentID.Valid {
@@ -192,9 +296,30 @@ func (a *Adapter) GetMessage(ctx context.Context, id string) (*entities.Message,
 	if model.Valid {
 		message.Model = model.String
 	}
+	if finishReason.Valid {
+		message.FinishReason = finishReason.String
+	}
+	if idempotencyKey.Valid {
+		message.IdempotencyKey = idempotencyKey.String
+	}
+
+	return &message, nil
+}
+
+// getMessageWhere loads the first message matching a condition, with its tool calls
+func (a *Adapter) getMessageWhere(ctx context.Context, condition string, args ...interface{}) (*entities.Message, error) {
+	query := "SELECT " + messageColumns + " FROM messages WHERE " + condition + " LIMIT 1"
+
+	message, err := scanMessage(a.db.QueryRowContext(ctx, query, args...))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ports.ErrMessageNotFound
+		}
+		return nil, fmt.Errorf("failed to get message: %w", err)
+	}
 
 	// Load tool calls
-	toolCalls, err := a.GetToolCallsForMessage(ctx, id)
+	toolCalls, err := a.GetToolCallsForMessage(ctx, message.ID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to load tool calls: %w", err)
 	}
@@ -203,12 +328,30 @@ func (a *Adapter) GetMessage(ctx context.Context, id string) (*entities.Message,
 		message.ToolCalls = append(message.ToolCalls, *tc)
 	}
 
-	return &message, nil
+	return message, nil
+}
+
+func (a *Adapter) GetMessage(ctx context.Context, id string) (*entities.Message, error) {
+	message, err := a.getMessageWhere(ctx, "id = ?", id)
+	if errors.Is(err, ports.ErrMessageNotFound) {
+		return nil, fmt.Errorf("%w: %s", ports.ErrMessageNotFound, id)
+	}
+	return message, err
+}
+
+// GetMessageByIdempotencyKey finds the message a client submitted with an idempotency key
+func (a *Adapter) GetMessageByIdempotencyKey(ctx context.Context, conversationID, key string) (*entities.Message, error) {
+	return a.getMessageWhere(ctx, "conversation_id = ? AND idempotency_key = ?", conversationID, key)
+}
+
+// GetReply finds the latest assistant message answering a message
+func (a *Adapter) GetReply(ctx context.Context, messageID string) (*entities.Message, error) {
+	return a.getMessageWhere(ctx, "parent_message_id = ? AND role = ? ORDER BY created_at DESC", messageID, string(entities.RoleAssistant))
 }
 
 func (a *Adapter) GetMessages(ctx context.Context, conversationID string, limit int) ([]*entities.Message, error) {
 	query := `
-		SELECT id, conversation_id, role, content, parent_message_id, token_count, model, created_at
+		SELECT ` + messageColumns + `
 		FROM messages 
 		WHERE conversation_id = ? 
 		ORDER BY created_at ASC
@@ -221,49 +364,7 @@ func (a *Adapter) GetMessages(ctx context.Context, conversationID string, limit
 	}
 	defer rows.Close()
 
-	var messages []*entities.Message
-	for rows.Next() {
-		var message entities.Message
-		var parentID sql.NullString
-		var model sql.NullString
-
-		err := rows.Scan(
-			&message.ID,
-			&message.ConversationID,
-			&message.Role,
-			&message.Content,
-			&parentID,
-			&message.TokenCount,
-			&model,
-			&message.CreatedAt,
-		)
-		if err != nil {
-			return nil, fmt.Errorf("failed to scan message: %w", err)
-		}
-
-		if parentID.Valid {
-			message.ParentID = &parentID.String
-		}
-		if model.Valid {
-			message.Model = model.String
-		}
-
-		messages = append(messages, &message)
-	}
-
-	// Load tool calls for all messages
-	for _, msg := range messages {
-		toolCalls, err := a.GetToolCallsForMessage(ctx, msg.ID)
-		if err != nil {
-			return nil, fmt.Errorf("failed to load tool calls for message %s: %w", msg.ID, err)
-		}
-
-		for _, tc := range toolCalls {
-			msg.ToolCalls = append(msg.ToolCalls, *tc)
-		}
-	}
-
-	return messages, nil
+	return a.collectMessages(ctx, rows)
 }
 
 func (a *Adapter) GetMessagesAfter(ctx context.Context, conversationID string, afterID string, limit int) ([]*entities.Message, error) {
@@ -275,7 +376,7 @@ func (a *Adapter) GetMessagesAfter(ctx context.Context, conversationID string, a
 	}
 
 	query := `
-		SELECT id, conversation_id, role, content, parent_message_id, token_count, model, created_at
+		SELECT ` + messageColumns + `
 		FROM messages 
 		WHERE conversation_id = ? AND created_at > ?
 		ORDER BY created_at ASC
@@ -288,35 +389,20 @@ func (a *Adapter) GetMessagesAfter(ctx context.Context, conversationID string, a
 	}
 	defer rows.Close()
 
+	return a.collectMessages(ctx, rows)
+}
+
+// collectMessages scans message rows and loads their tool calls
+func (a *Adapter) collectMessages(ctx context.Context, rows *sql.Rows) ([]*entities.Message, error) {
 	var messages []*entities.Message
 	for rows.Next() {
-		var message entities.Message
-		var parentID sql.NullString
-		var model sql.NullString
-
-		err := rows.Scan(
-			&message.ID,
-			&message.ConversationID,
-			&message.Role,
-			&message.Content,
-			&parentID,
-			&message.TokenCount,
-			&model,
-			&message.CreatedAt,
-		)
+		message, err := scanMessage(rows)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan message: %w", err)
 		}
-
-		if parentID.Valid {
-			message.ParentID = &parentID.String
-		}
-		if model.Valid {
-			message.Model = model.String
-		}
-
-		messages = append(messages, &message)
+		messages = append(messages, message)
 	}
+	rows.Close()
 
 	// Load tool calls for all messages
 	for _, msg := range messages {
@@ -333,204 +419,963 @@ func (a *Adapter) GetMessagesAfter(ctx context.Context, conversationID string, a
 	return messages, nil
 }
 
-// Conversation operations
-func (a *Adapter) SaveConversation(ctx context.Context, conversation *entities.Conversation) error {
-	query := `
-		INSERT INTO conversations (id, title, system_prompt_id, created_at, updated_at)
-		VALUES (?, ?, ?, ?, ?)
-	`
+// maxSearchHits bounds the results of searches without a limit
+const maxSearchHits = 500
 
-	_, err := a.db.ExecContext(ctx, query,
-		conversation.ID,
-		conversation.Title,
-		conversation.SystemPromptID,
-		conversation.CreatedAt,
-		conversation.UpdatedAt,
-	)
+// SearchMessages finds user and assistant messages containing the search's words, ranked by BM25.
+// Messages containing all of the words come first, followed by those containing some of them.
+func (a *Adapter) SearchMessages(ctx context.Context, search ports.MessageSearch) ([]ports.MessageHit, error) {
+	terms := ftsTerms(search.Text)
+	if len(terms) == 0 {
+		return nil, nil
+	}
+	if search.Limit <= 0 {
+		search.Limit = maxSearchHits
+	}
+
+	hits, err := a.rankMessages(ctx, search, strings.Join(terms, " "))
+	if err != nil || len(terms) == 1 || len(hits) >= search.Limit {
+		return hits, err
+	}
+
+	partial := search
+	partial.Limit -= len(hits)
+	partial.ExcludeIDs = append([]string(nil), search.ExcludeIDs...)
+	for _, hit := range hits {
+		partial.ExcludeIDs = append(partial.ExcludeIDs, hit.Message.ID)
+	}
+	more, err := a.rankMessages(ctx, partial, strings.Join(terms, " OR "))
 	if err != nil {
-		return fmt.Errorf("failed to save conversation: %w", err)
+		return nil, err
 	}
+	return append(hits, more...), nil
+}
 
-	return nil
+// rankMessages returns the messages matching a full-text query, best first, with ties going to recent messages
+func (a *Adapter) rankMessages(ctx context.Context, search ports.MessageSearch, match string) ([]ports.MessageHit, error) {
+	conditions := []string{
+		"messages_fts MATCH ?",
+		"conversations.deleted_at IS NULL",
+		"m.role IN ('user', 'assistant')",
+	}
+	args := []interface{}{match}
+
+	if search.ConversationID != "" {
+		conditions = append(conditions, "m.conversation_id = ?")
+		args = append(args, search.ConversationID)
+	}
+	if len(search.ExcludeIDs) > 0 {
+		conditions = append(conditions, "m.id NOT IN (?"+strings.Repeat(", ?", len(search.ExcludeIDs)-1)+")")
+		for _, id := range search.ExcludeIDs {
+			args = append(args, id)
+		}
+	}
+
+	query := "SELECT m." + strings.ReplaceAll(messageColumns, ", ", ", m.") + `,
+			conversations.title,
+			snippet(messages_fts, '', '', '…', -1, 32),
+			bm25(matchinfo(messages_fts, 'pcnalx')) AS score
+		FROM messages_fts
+		JOIN messages m ON m.rowid = messages_fts.docid
+		JOIN conversations ON conversations.id = m.conversation_id
+		WHERE ` + strings.Join(conditions, " AND ") + `
+		ORDER BY score DESC, m.created_at DESC
+		LIMIT ?`
+	args = append(args, search.Limit)
+
+	rows, err := a.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search messages: %w", err)
+	}
+	defer rows.Close()
+
+	var hits []ports.MessageHit
+	for rows.Next() {
+		var hit ports.MessageHit
+		hit.Message, err = scanMessage(scannerWith(rows, &hit.ConversationTitle, &hit.Snippet, &hit.Score))
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan search hit: %w", err)
+		}
+		hits = append(hits, hit)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to search messages: %w", err)
+	}
+	return hits, nil
 }
 
-func (a *Adapter) GetConversation(ctx context.Context, id string) (*entities.Conversation, error) {
-	query := `
-		SELECT id, title, system_prompt_id, created_at, updated_at
-		FROM conversations WHERE id = ?
-	`
+// extraColumns scans columns selected after those a scan function knows about
+type extraColumns struct {
+	row   rowScanner
+	extra []interface{}
+}
+
+// scannerWith returns a scanner that reads the trailing columns of row into extra
+func scannerWith(row rowScanner, extra ...interface{}) rowScanner {
+	return extraColumns{row: row, extra: extra}
+}
+
+func (e extraColumns) Scan(dest ...interface{}) error {
+	return e.row.Scan(append(dest, e.extra...)...)
+}
+
+// ftsTerms splits free text into the distinct lowercase words a full-text query looks for
+func ftsTerms(text string) []string {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	seen := make(map[string]bool, len(words))
+	var terms []string
+	for _, word := range words {
+		if !seen[word] {
+			seen[word] = true
+			terms = append(terms, word)
+		}
+	}
+	return terms
+}
+
+// BM25 parameters, as commonly used by search engines
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+// bm25 scores a row from the output of FTS4's matchinfo(..., 'pcnalx') over a single column.
+// Queries call it as the SQL function bm25.
+func bm25(matchInfo []byte) float64 {
+	values := make([]uint32, len(matchInfo)/4)
+	for i := range values {
+		values[i] = binary.NativeEndian.Uint32(matchInfo[i*4:])
+	}
+	// p, c, n, then a and l for the one column, then hits in this row, all rows and matching rows per phrase
+	if len(values) < 5 || values[1] != 1 {
+		return 0
+	}
+	phrases, rows := int(values[0]), float64(values[2])
+	avgLength, length := math.Max(float64(values[3]), 1), float64(values[4])
+
+	score := 0.0
+	for i := 0; i < phrases && 5+3*i+2 < len(values); i++ {
+		hits, matchingRows := float64(values[5+3*i]), float64(values[5+3*i+2])
+		if hits == 0 {
+			continue
+		}
+		idf := math.Log(1 + (rows-matchingRows+0.5)/(matchingRows+0.5))
+		score += idf * hits * (bm25K1 + 1) / (hits + bm25K1*(1-bm25B+bm25B*length/avgLength))
+	}
+	return score
+}
 
-	row := a.db.QueryRowContext(ctx, query, id)
+// Conversation operations
+
+// conversationColumns lists the columns read by scanConversation, in order
+const conversationColumns = "id, title, system_prompt_id, model, folder_id, pinned, starred, tool_settings, archived_at, deleted_at, created_at, updated_at"
+
+// rowScanner is satisfied by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
 
+// scanConversation reads a conversation row selected with conversationColumns
+func scanConversation(row rowScanner) (*entities.Conversation, error) {
 	var conversation entities.Conversation
 	var title sql.NullString
+	var model sql.NullString
+	var folderID sql.NullString
+	var toolSettings sql.NullString
+	var archivedAt sql.NullTime
+	var deletedAt sql.NullTime
 
 	err := row.Scan(
 		&conversation.ID,
 		&title,
 		&conversation.SystemPromptID,
+		&model,
+		&folderID,
+		&conversation.Pinned,
+		&conversation.Starred,
+		&toolSettings,
+		&archivedAt,
+		&deletedAt,
 		&conversation.CreatedAt,
 		&conversation.UpdatedAt,
 	)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("conversation not found: %s", id)
-		}
-		return nil, fmt.Errorf("failed to get conversation: %w", err)
+		return nil, err
 	}
 
 	if title.Valid {
 		conversation.Title = title.String
 	}
+	if model.Valid {
+		conversation.Model = model.String
+	}
+	if folderID.Valid {
+		conversation.FolderID = folderID.String
+	}
+	if err := decodeToolSettings(toolSettings, &conversation.Tools); err != nil {
+		return nil, err
+	}
+	if archivedAt.Valid {
+		conversation.ArchivedAt = &archivedAt.Time
+	}
+	if deletedAt.Valid {
+		conversation.DeletedAt = &deletedAt.Time
+	}
 
-	// Load message IDs
-	messageRows, err := a.db.QueryContext(ctx,
-		"SELECT id FROM messages WHERE conversation_id = ? ORDER BY created_at ASC", id)
+	return &conversation, nil
+}
+
+// encodeToolSettings stores tool settings as JSON, or NULL when they restrict nothing
+func encodeToolSettings(settings entities.ToolSettings) (interface{}, error) {
+	if settings.IsEmpty() {
+		return nil, nil
+	}
+	data, err := json.Marshal(settings)
 	if err != nil {
-		return nil, fmt.Errorf("failed to load message IDs: %w", err)
+		return nil, fmt.Errorf("failed to marshal tool settings: %w", err)
 	}
-	defer messageRows.Close()
+	return string(data), nil
+}
 
-	for messageRows.Next() {
-		var messageID string
-		if err := messageRows.Scan(&messageID); err != nil {
-			return nil, fmt.Errorf("failed to scan message ID: %w", err)
-		}
-		conversation.MessageIDs = append(conversation.MessageIDs, messageID)
+// decodeToolSettings reads tool settings stored by encodeToolSettings
+func decodeToolSettings(value sql.NullString, settings *entities.ToolSettings) error {
+	if !value.Valid || value.String == "" {
+		return nil
 	}
-
-	return &conversation, nil
+	if err := json.Unmarshal([]byte(value.String), settings); err != nil {
+		return fmt.Errorf("failed to unmarshal tool settings: %w", err)
+	}
+	return nil
 }
 
-func (a *Adapter) GetConversations(ctx context.Context, limit int, offset int) ([]*entities.Conversation, error) {
-	query := `
-		SELECT id, title, system_prompt_id, created_at, updated_at
-		FROM conversations 
-		ORDER BY updated_at DESC
-		LIMIT ? OFFSET ?
-	`
+// nullIfEmpty stores empty strings as NULL so optional foreign keys stay valid
+func nullIfEmpty(value string) interface{} {
+	if value == "" {
+		return nil
+	}
+	return value
+}
 
-	rows, err := a.db.QueryContext(ctx, query, limit, offset)
+func (a *Adapter) SaveConversation(ctx context.Context, conversation *entities.Conversation) error {
+	tx, err := a.db.BeginTx(ctx, nil)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get conversations: %w", err)
+		return fmt.Errorf("failed to begin transaction: %w", err)
 	}
-	defer rows.Close()
+	defer tx.Rollback()
 
-	var conversations []*entities.Conversation
-	for rows.Next() {
-		var conversation entities.Conversation
-		var title sql.NullString
+	if err := insertConversation(ctx, tx, conversation, true); err != nil {
+		return err
+	}
 
-		err := rows.Scan(
-			&conversation.ID,
-			&title,
-			&conversation.SystemPromptID,
-			&conversation.CreatedAt,
-			&conversation.UpdatedAt,
-		)
-		if err != nil {
-			return nil, fmt.Errorf("failed to scan conversation: %w", err)
-		}
+	return tx.Commit()
+}
+
+// ImportConversation stores a conversation with its messages and, unless it is nil, its system prompt.
+// Without a history it records the same events as saving them one by one; with one, it stores the
+// history as the conversation's event log.
+func (a *Adapter) ImportConversation(ctx context.Context, conversation *entities.Conversation, prompt *entities.SystemPrompt, messages []*entities.Message, history []ports.Event) error {
+	tx, err := a.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
 
-		if title.Valid {
-			conversation.Title = title.String
+	if prompt != nil {
+		if err := insertSystemPrompt(ctx, tx, prompt); err != nil {
+			return err
 		}
+	}
 
-		conversations = append(conversations, &conversation)
+	if err := insertConversation(ctx, tx, conversation, history == nil); err != nil {
+		return err
 	}
 
-	// Load message IDs for each conversation
-	for _, conv := range conversations {
-		messageRows, err := a.db.QueryContext(ctx,
-			"SELECT id FROM messages WHERE conversation_id = ? ORDER BY created_at ASC", conv.ID)
-		if err != nil {
-			return nil, fmt.Errorf("failed to load message IDs for conversation %s: %w", conv.ID, err)
+	for _, message := range messages {
+		if err := insertMessage(ctx, tx, message); err != nil {
+			return err
 		}
-
-		for messageRows.Next() {
-			var messageID string
-			if err := messageRows.Scan(&messageID); err != nil {
-				messageRows.Close()
-				return nil, fmt.Errorf("failed to scan message ID: %w", err)
+		if history == nil {
+			if err := appendEvents(ctx, tx, message.ConversationID, entities.MessageAdded{Message: *message}); err != nil {
+				return err
 			}
-			conv.MessageIDs = append(conv.MessageIDs, messageID)
 		}
-		messageRows.Close()
 	}
 
-	return conversations, nil
+	for _, event := range history {
+		event.ConversationID = conversation.ID
+		if _, err := importEvent(ctx, tx, event); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
 }
 
-func (a *Adapter) UpdateConversation(ctx context.Context, conversation *entities.Conversation) error {
+// insertConversation stores a new conversation with its tags, recording its creation if record is set
+func insertConversation(ctx context.Context, tx *sql.Tx, conversation *entities.Conversation, record bool) error {
 	query := `
-		UPDATE conversations 
-		SET title = ?, system_prompt_id = ?, updated_at = ?
-		WHERE id = ?
+		INSERT INTO conversations (id, title, system_prompt_id, model, folder_id, pinned, starred, tool_settings, archived_at, deleted_at, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`
 
-	_, err := a.db.ExecContext(ctx, query,
+	toolSettings, err := encodeToolSettings(conversation.Tools)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, query,
+		conversation.ID,
 		conversation.Title,
 		conversation.SystemPromptID,
+		conversation.Model,
+		nullIfEmpty(conversation.FolderID),
+		conversation.Pinned,
+		conversation.Starred,
+		toolSettings,
+		conversation.ArchivedAt,
+		conversation.DeletedAt,
+		conversation.CreatedAt,
 		conversation.UpdatedAt,
-		conversation.ID,
 	)
 	if err != nil {
-		return fmt.Errorf("failed to update conversation: %w", err)
+		var sqliteErr sqlite3.Error
+		if errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == sqlite3.ErrConstraintPrimaryKey {
+			return fmt.Errorf("%w: %s", ports.ErrConversationExists, conversation.ID)
+		}
+		return fmt.Errorf("failed to save conversation: %w", err)
 	}
 
-	return nil
+	if err := replaceTags(ctx, tx, conversation); err != nil {
+		return err
+	}
+
+	if !record {
+		return nil
+	}
+	return appendEvents(ctx, tx, conversation.ID, entities.NewConversationCreated(conversation))
 }
 
-func (a *Adapter) DeleteConversation(ctx context.Context, id string) error {
-	// SQLite will cascade delete messages and tool calls due to foreign key constraints
-	query := "DELETE FROM conversations WHERE id = ?"
+// replaceTags overwrites the stored tags of a conversation
+func replaceTags(ctx context.Context, tx *sql.Tx, conversation *entities.Conversation) error {
+	if _, err := tx.ExecContext(ctx, "DELETE FROM conversation_tags WHERE conversation_id = ?", conversation.ID); err != nil {
+		return fmt.Errorf("failed to clear conversation tags: %w", err)
+	}
 
-	_, err := a.db.ExecContext(ctx, query, id)
-	if err != nil {
-		return fmt.Errorf("failed to delete conversation: %w", err)
+	for _, tag := range entities.NormalizeTags(conversation.Tags) {
+		_, err := tx.ExecContext(ctx,
+			"INSERT INTO conversation_tags (conversation_id, tag) VALUES (?, ?)", conversation.ID, tag)
+		if err != nil {
+			return fmt.Errorf("failed to save conversation tag: %w", err)
+		}
 	}
 
 	return nil
 }
 
-// System prompt operations
-func (a *Adapter) SaveSystemPrompt(ctx context.Context, prompt *entities.SystemPrompt) error {
-	query := `
-		INSERT INTO system_prompts (id, name, content, created_at, updated_at)
-		VALUES (?, ?, ?, ?, ?)
-	`
+func (a *Adapter) GetConversation(ctx context.Context, id string) (*entities.Conversation, error) {
+	query := "SELECT " + conversationColumns + " FROM conversations WHERE id = ? AND deleted_at IS NULL"
 
-	_, err := a.db.ExecContext(ctx, query,
-		prompt.ID,
-		prompt.Name,
-		prompt.Content,
-		prompt.CreatedAt,
-		prompt.UpdatedAt,
-	)
+	conversation, err := scanConversation(a.db.QueryRowContext(ctx, query, id))
 	if err != nil {
-		return fmt.Errorf("failed to save system prompt: %w", err)
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("%w: %s", ports.ErrConversationNotFound, id)
+		}
+		return nil, fmt.Errorf("failed to get conversation: %w", err)
+	}
+
+	if err := a.loadConversationDetails(ctx, conversation); err != nil {
+		return nil, err
+	}
+
+	return conversation, nil
+}
+
+func (a *Adapter) GetConversations(ctx context.Context, limit int, offset int) ([]*entities.Conversation, error) {
+	query := `
+		SELECT ` + conversationColumns + `
+		FROM conversations 
+		WHERE deleted_at IS NULL
+		ORDER BY updated_at DESC
+		LIMIT ? OFFSET ?
+	`
+
+	return a.queryConversations(ctx, query, limit, offset)
+}
+
+// GetAllConversations returns conversations whether or not they are in the trash, ordered by ID so
+// that pages stay stable while conversations change
+func (a *Adapter) GetAllConversations(ctx context.Context, afterID string, limit int) ([]*entities.Conversation, error) {
+	query := `
+		SELECT ` + conversationColumns + `
+		FROM conversations
+		WHERE id > ?
+		ORDER BY id ASC
+		LIMIT ?
+	`
+
+	return a.queryConversations(ctx, query, afterID, limit)
+}
+
+// GetDeletedConversations returns conversations in the trash, most recently deleted first
+func (a *Adapter) GetDeletedConversations(ctx context.Context, limit int, offset int) ([]*entities.Conversation, error) {
+	query := `
+		SELECT ` + conversationColumns + `
+		FROM conversations
+		WHERE deleted_at IS NOT NULL
+		ORDER BY deleted_at DESC
+		LIMIT ? OFFSET ?
+	`
+
+	return a.queryConversations(ctx, query, limit, offset)
+}
+
+// queryConversations runs a query selecting conversationColumns and loads message IDs for each row
+func (a *Adapter) queryConversations(ctx context.Context, query string, args ...interface{}) ([]*entities.Conversation, error) {
+	rows, err := a.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get conversations: %w", err)
+	}
+	defer rows.Close()
+
+	var conversations []*entities.Conversation
+	for rows.Next() {
+		conversation, err := scanConversation(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan conversation: %w", err)
+		}
+
+		conversations = append(conversations, conversation)
+	}
+	rows.Close()
+
+	// Load message IDs and tags for each conversation
+	for _, conv := range conversations {
+		if err := a.loadConversationDetails(ctx, conv); err != nil {
+			return nil, err
+		}
+	}
+
+	return conversations, nil
+}
+
+// loadConversationDetails fills in the message IDs and tags of a conversation
+func (a *Adapter) loadConversationDetails(ctx context.Context, conversation *entities.Conversation) error {
+	if err := a.loadMessageIDs(ctx, conversation); err != nil {
+		return err
 	}
+	return a.loadTags(ctx, conversation)
+}
 
+// loadTags fills in the sorted tags of a conversation
+func (a *Adapter) loadTags(ctx context.Context, conversation *entities.Conversation) error {
+	tags, err := queryTags(ctx, a.db, conversation.ID)
+	if err != nil {
+		return err
+	}
+	conversation.Tags = tags
 	return nil
 }
 
-func (a *Adapter) GetSystemPrompt(ctx context.Context, id string) (*entities.SystemPrompt, error) {
+// queryTags returns the sorted tags of a conversation
+func queryTags(ctx context.Context, db querier, conversationID string) ([]string, error) {
+	rows, err := db.QueryContext(ctx,
+		"SELECT tag FROM conversation_tags WHERE conversation_id = ? ORDER BY tag ASC", conversationID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load tags for conversation %s: %w", conversationID, err)
+	}
+	defer rows.Close()
+
+	tags := make([]string, 0)
+	for rows.Next() {
+		var tag string
+		if err := rows.Scan(&tag); err != nil {
+			return nil, fmt.Errorf("failed to scan tag: %w", err)
+		}
+		tags = append(tags, tag)
+	}
+
+	return tags, nil
+}
+
+// loadMessageIDs fills in the ordered message IDs of a conversation
+func (a *Adapter) loadMessageIDs(ctx context.Context, conversation *entities.Conversation) error {
+	messageRows, err := a.db.QueryContext(ctx,
+		"SELECT id FROM messages WHERE conversation_id = ? ORDER BY created_at ASC", conversation.ID)
+	if err != nil {
+		return fmt.Errorf("failed to load message IDs for conversation %s: %w", conversation.ID, err)
+	}
+	defer messageRows.Close()
+
+	for messageRows.Next() {
+		var messageID string
+		if err := messageRows.Scan(&messageID); err != nil {
+			return fmt.Errorf("failed to scan message ID: %w", err)
+		}
+		conversation.MessageIDs = append(conversation.MessageIDs, messageID)
+	}
+
+	return nil
+}
+
+// UpdateConversation stores changes to a conversation and records them in its event log
+func (a *Adapter) UpdateConversation(ctx context.Context, conversation *entities.Conversation) error {
+	query := `
+		UPDATE conversations 
+		SET title = ?, system_prompt_id = ?, model = ?, folder_id = ?, pinned = ?, starred = ?, tool_settings = ?, archived_at = ?, updated_at = ?
+		WHERE id = ?
+	`
+
+	toolSettings, err := encodeToolSettings(conversation.Tools)
+	if err != nil {
+		return err
+	}
+
+	tx, err := a.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	before, err := scanConversation(tx.QueryRowContext(ctx,
+		"SELECT "+conversationColumns+" FROM conversations WHERE id = ?", conversation.ID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("%w: %s", ports.ErrConversationNotFound, conversation.ID)
+		}
+		return fmt.Errorf("failed to get conversation: %w", err)
+	}
+	if before.Tags, err = queryTags(ctx, tx, conversation.ID); err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, query,
+		conversation.Title,
+		conversation.SystemPromptID,
+		conversation.Model,
+		nullIfEmpty(conversation.FolderID),
+		conversation.Pinned,
+		conversation.Starred,
+		toolSettings,
+		conversation.ArchivedAt,
+		conversation.UpdatedAt,
+		conversation.ID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update conversation: %w", err)
+	}
+
+	if err := replaceTags(ctx, tx, conversation); err != nil {
+		return err
+	}
+
+	if err := appendEvents(ctx, tx, conversation.ID, entities.DiffConversation(before, conversation)...); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// conversationSortColumns maps ConversationFilter.SortBy values to ORDER BY expressions
+var conversationSortColumns = map[string]string{
+	ports.SortByUpdatedAt: "updated_at",
+	ports.SortByCreatedAt: "created_at",
+	ports.SortByTitle:     "title COLLATE NOCASE",
+}
+
+// ListConversations returns conversations outside the trash matching the filter, pinned first
+func (a *Adapter) ListConversations(ctx context.Context, filter ports.ConversationFilter) ([]*entities.Conversation, error) {
+	conditions := []string{"deleted_at IS NULL"}
+	var args []interface{}
+
+	if filter.FolderID != nil {
+		switch {
+		case *filter.FolderID == "":
+			conditions = append(conditions, "folder_id IS NULL")
+		case filter.IncludeSubfolders:
+			conditions = append(conditions, `folder_id IN (
+				WITH RECURSIVE subfolders(id) AS (
+					SELECT ?
+					UNION
+					SELECT folders.id FROM folders JOIN subfolders ON folders.parent_id = subfolders.id
+				)
+				SELECT id FROM subfolders
+			)`)
+			args = append(args, *filter.FolderID)
+		default:
+			conditions = append(conditions, "folder_id = ?")
+			args = append(args, *filter.FolderID)
+		}
+	}
+
+	if filter.Pinned != nil {
+		conditions = append(conditions, "pinned = ?")
+		args = append(args, *filter.Pinned)
+	}
+
+	if filter.Starred != nil {
+		conditions = append(conditions, "starred = ?")
+		args = append(args, *filter.Starred)
+	}
+
+	if filter.Archived != nil {
+		if *filter.Archived {
+			conditions = append(conditions, "archived_at IS NOT NULL")
+		} else {
+			conditions = append(conditions, "archived_at IS NULL")
+		}
+	}
+
+	for _, tag := range entities.NormalizeTags(filter.Tags) {
+		conditions = append(conditions,
+			"EXISTS (SELECT 1 FROM conversation_tags WHERE conversation_tags.conversation_id = conversations.id AND conversation_tags.tag = ?)")
+		args = append(args, tag)
+	}
+
+	sortBy := filter.SortBy
+	if sortBy == "" {
+		sortBy = ports.SortByUpdatedAt
+	}
+	sortColumn, ok := conversationSortColumns[sortBy]
+	if !ok {
+		return nil, fmt.Errorf("unsupported sort field: %s", filter.SortBy)
+	}
+
+	direction := "ASC"
+	if filter.Descending {
+		direction = "DESC"
+	}
+
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = -1 // No limit
+	}
+
+	query := "SELECT " + conversationColumns + " FROM conversations WHERE " + strings.Join(conditions, " AND ") +
+		" ORDER BY pinned DESC, " + sortColumn + " " + direction + ", id " + direction + " LIMIT ? OFFSET ?"
+	args = append(args, limit, filter.Offset)
+
+	return a.queryConversations(ctx, query, args...)
+}
+
+// GetTags returns tags in use by conversations outside the trash, most used first
+func (a *Adapter) GetTags(ctx context.Context) ([]ports.TagCount, error) {
+	query := `
+		SELECT conversation_tags.tag, COUNT(*)
+		FROM conversation_tags
+		JOIN conversations ON conversations.id = conversation_tags.conversation_id
+		WHERE conversations.deleted_at IS NULL
+		GROUP BY conversation_tags.tag
+		ORDER BY COUNT(*) DESC, conversation_tags.tag ASC
+	`
+
+	rows, err := a.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tags: %w", err)
+	}
+	defer rows.Close()
+
+	tags := make([]ports.TagCount, 0)
+	for rows.Next() {
+		var tag ports.TagCount
+		if err := rows.Scan(&tag.Tag, &tag.Count); err != nil {
+			return nil, fmt.Errorf("failed to scan tag: %w", err)
+		}
+		tags = append(tags, tag)
+	}
+
+	return tags, nil
+}
+
+// DeleteConversation moves a conversation to the trash
+func (a *Adapter) DeleteConversation(ctx context.Context, id string) error {
+	query := "UPDATE conversations SET deleted_at = ? WHERE id = ? AND deleted_at IS NULL"
+
+	tx, err := a.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	result, err := tx.ExecContext(ctx, query, now, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete conversation: %w", err)
+	}
+
+	if err := requireAffected(result, "conversation not found: %s", id); err != nil {
+		return err
+	}
+
+	if err := appendEvents(ctx, tx, id, entities.ConversationDeleted{DeletedAt: now}); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// RestoreConversation moves a conversation out of the trash
+func (a *Adapter) RestoreConversation(ctx context.Context, id string) error {
+	query := "UPDATE conversations SET deleted_at = NULL WHERE id = ? AND deleted_at IS NOT NULL"
+
+	tx, err := a.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, query, id)
+	if err != nil {
+		return fmt.Errorf("failed to restore conversation: %w", err)
+	}
+
+	if err := requireAffected(result, "conversation not found in trash: %s", id); err != nil {
+		return err
+	}
+
+	if err := appendEvents(ctx, tx, id, entities.ConversationRestored{}); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// PurgeConversations permanently deletes conversations that were moved to the trash before deletedBefore
+func (a *Adapter) PurgeConversations(ctx context.Context, deletedBefore time.Time) (int, error) {
+	// SQLite will cascade delete messages and tool calls due to foreign key constraints
+	query := "DELETE FROM conversations WHERE deleted_at IS NOT NULL AND deleted_at < ?"
+
+	result, err := a.db.ExecContext(ctx, query, deletedBefore)
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge conversations: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to count purged conversations: %w", err)
+	}
+
+	return int(affected), nil
+}
+
+// requireAffected returns a not found error built from format and id when result changed no rows
+func requireAffected(result sql.Result, format, id string) error {
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check affected rows: %w", err)
+	}
+	if affected == 0 {
+		return fmt.Errorf(format, id)
+	}
+	return nil
+}
+
+// Folder operations
+
+// folderColumns lists the columns read by scanFolder, in order
+const folderColumns = "id, name, parent_id, created_at, updated_at"
+
+// scanFolder reads a folder row selected with folderColumns
+func scanFolder(row rowScanner) (*entities.Folder, error) {
+	var folder entities.Folder
+	var parentID sql.NullString
+
+	if err := row.Scan(&folder.ID, &folder.Name, &parentID, &folder.CreatedAt, &folder.UpdatedAt); err != nil {
+		return nil, err
+	}
+
+	if parentID.Valid {
+		folder.ParentID = parentID.String
+	}
+
+	return &folder, nil
+}
+
+func (a *Adapter) SaveFolder(ctx context.Context, folder *entities.Folder) error {
+	query := `
+		INSERT INTO folders (id, name, parent_id, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?)
+	`
+
+	_, err := a.db.ExecContext(ctx, query,
+		folder.ID,
+		folder.Name,
+		nullIfEmpty(folder.ParentID),
+		folder.CreatedAt,
+		folder.UpdatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save folder: %w", err)
+	}
+
+	return nil
+}
+
+func (a *Adapter) GetFolder(ctx context.Context, id string) (*entities.Folder, error) {
+	query := "SELECT " + folderColumns + " FROM folders WHERE id = ?"
+
+	folder, err := scanFolder(a.db.QueryRowContext(ctx, query, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("folder not found: %s", id)
+		}
+		return nil, fmt.Errorf("failed to get folder: %w", err)
+	}
+
+	return folder, nil
+}
+
+func (a *Adapter) GetFolders(ctx context.Context) ([]*entities.Folder, error) {
+	query := "SELECT " + folderColumns + " FROM folders ORDER BY name COLLATE NOCASE ASC"
+
+	rows, err := a.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get folders: %w", err)
+	}
+	defer rows.Close()
+
+	var folders []*entities.Folder
+	for rows.Next() {
+		folder, err := scanFolder(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan folder: %w", err)
+		}
+		folders = append(folders, folder)
+	}
+
+	return folders, nil
+}
+
+func (a *Adapter) UpdateFolder(ctx context.Context, folder *entities.Folder) error {
 	query := `
-		SELECT id, name, content, created_at, updated_at
-		FROM system_prompts WHERE id = ?
+		UPDATE folders
+		SET name = ?, parent_id = ?, updated_at = ?
+		WHERE id = ?
 	`
 
-	row := a.db.QueryRowContext(ctx, query, id)
+	result, err := a.db.ExecContext(ctx, query,
+		folder.Name,
+		nullIfEmpty(folder.ParentID),
+		folder.UpdatedAt,
+		folder.ID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update folder: %w", err)
+	}
+
+	return requireAffected(result, "folder not found: %s", folder.ID)
+}
+
+// DeleteFolder removes a folder, moving its child folders and conversations up to its parent
+func (a *Adapter) DeleteFolder(ctx context.Context, id string) error {
+	folder, err := a.GetFolder(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	tx, err := a.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	parentID := nullIfEmpty(folder.ParentID)
+
+	if _, err := tx.ExecContext(ctx, "UPDATE folders SET parent_id = ? WHERE parent_id = ?", parentID, id); err != nil {
+		return fmt.Errorf("failed to move child folders: %w", err)
+	}
+
+	moved, err := queryIDs(ctx, tx, "UPDATE conversations SET folder_id = ? WHERE folder_id = ? RETURNING id", parentID, id)
+	if err != nil {
+		return fmt.Errorf("failed to move folder conversations: %w", err)
+	}
+
+	for _, conversationID := range moved {
+		if err := appendEvents(ctx, tx, conversationID, entities.ConversationMoved{FolderID: folder.ParentID}); err != nil {
+			return err
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM folders WHERE id = ?", id); err != nil {
+		return fmt.Errorf("failed to delete folder: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+// System prompt operations
+func (a *Adapter) SaveSystemPrompt(ctx context.Context, prompt *entities.SystemPrompt) error {
+	return insertSystemPrompt(ctx, a.db, prompt)
+}
+
+// insertSystemPrompt stores a new system prompt
+func insertSystemPrompt(ctx context.Context, db execer, prompt *entities.SystemPrompt) error {
+	query := `
+		INSERT INTO system_prompts (id, name, content, tool_settings, deleted_at, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`
+
+	toolSettings, err := encodeToolSettings(prompt.Tools)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.ExecContext(ctx, query,
+		prompt.ID,
+		prompt.Name,
+		prompt.Content,
+		toolSettings,
+		prompt.DeletedAt,
+		prompt.CreatedAt,
+		prompt.UpdatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save system prompt: %w", err)
+	}
+
+	return nil
+}
+
+// systemPromptColumns lists the columns read by scanSystemPrompt, in order
+const systemPromptColumns = "id, name, content, tool_settings, deleted_at, created_at, updated_at"
 
+// scanSystemPrompt reads a system prompt row selected with systemPromptColumns
+func scanSystemPrompt(row rowScanner) (*entities.SystemPrompt, error) {
 	var prompt entities.SystemPrompt
+	var toolSettings sql.NullString
+	var deletedAt sql.NullTime
 
 	err := row.Scan(
 		&prompt.ID,
 		&prompt.Name,
 		&prompt.Content,
+		&toolSettings,
+		&deletedAt,
 		&prompt.CreatedAt,
 		&prompt.UpdatedAt,
 	)
+	if err != nil {
+		return nil, err
+	}
+
+	if deletedAt.Valid {
+		prompt.DeletedAt = &deletedAt.Time
+	}
+	if err := decodeToolSettings(toolSettings, &prompt.Tools); err != nil {
+		return nil, err
+	}
+
+	return &prompt, nil
+}
+
+// GetSystemPrompt returns a system prompt, including prompts in the trash so that
+// conversations referencing them keep working
+func (a *Adapter) GetSystemPrompt(ctx context.Context, id string) (*entities.SystemPrompt, error) {
+	query := "SELECT " + systemPromptColumns + " FROM system_prompts WHERE id = ?"
+
+	prompt, err := scanSystemPrompt(a.db.QueryRowContext(ctx, query, id))
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, fmt.Errorf("system prompt not found: %s", id)
@@ -538,53 +1383,81 @@ func (a *Adapter) GetSystemPrompt(ctx context.Context, id string) (*entities.Sys
 		return nil, fmt.Errorf("failed to get system prompt: %w", err)
 	}
 
-	return &prompt, nil
+	return prompt, nil
 }
 
 func (a *Adapter) GetSystemPrompts(ctx context.Context) ([]*entities.SystemPrompt, error) {
 	query := `
-		SELECT id, name, content, created_at, updated_at
+		SELECT ` + systemPromptColumns + `
 		FROM system_prompts 
+		WHERE deleted_at IS NULL
 		ORDER BY name ASC
 	`
 
-	rows, err := a.db.QueryContext(ctx, query)
+	return a.querySystemPrompts(ctx, query)
+}
+
+// GetDeletedSystemPrompts returns system prompts in the trash, most recently deleted first
+func (a *Adapter) GetDeletedSystemPrompts(ctx context.Context) ([]*entities.SystemPrompt, error) {
+	query := `
+		SELECT ` + systemPromptColumns + `
+		FROM system_prompts
+		WHERE deleted_at IS NOT NULL
+		ORDER BY deleted_at DESC
+	`
+
+	return a.querySystemPrompts(ctx, query)
+}
+
+// querySystemPrompts runs a query selecting systemPromptColumns
+func (a *Adapter) querySystemPrompts(ctx context.Context, query string, args ...interface{}) ([]*entities.SystemPrompt, error) {
+	rows, err := a.db.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get system prompts: %w", err)
 	}
 	defer rows.Close()
 
 	var prompts []*entities.SystemPrompt
-	for rows.Next() {
-		var prompt entities.SystemPrompt
-
-		err := rows.Scan(
-			&prompt.ID,
-			&prompt.Name,
-			&prompt.Content,
-			&prompt.CreatedAt,
-			&prompt.UpdatedAt,
-		)
+	for rows.Next() {
+		prompt, err := scanSystemPrompt(rows)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan system prompt: %w", err)
 		}
 
-		prompts = append(prompts, &prompt)
+		prompts = append(prompts, prompt)
 	}
 
 	return prompts, nil
 }
 
+// CountConversationsForSystemPrompt returns how many conversations outside the trash use a system prompt
+func (a *Adapter) CountConversationsForSystemPrompt(ctx context.Context, id string) (int, error) {
+	query := "SELECT COUNT(*) FROM conversations WHERE system_prompt_id = ? AND deleted_at IS NULL"
+
+	var count int
+	if err := a.db.QueryRowContext(ctx, query, id).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count conversations for system prompt: %w", err)
+	}
+
+	return count, nil
+}
+
 func (a *Adapter) UpdateSystemPrompt(ctx context.Context, prompt *entities.SystemPrompt) error {
 	query := `
 		UPDATE system_prompts 
-		SET name = ?, content = ?, updated_at = ?
+		SET name = ?, content = ?, tool_settings = ?, updated_at = ?
 		WHERE id = ?
 	`
 
-	_, err := a.db.ExecContext(ctx, query,
+	toolSettings, err := encodeToolSettings(prompt.Tools)
+	if err != nil {
+		return err
+	}
+
+	_, err = a.db.ExecContext(ctx, query,
 		prompt.Name,
 		prompt.Content,
+		toolSettings,
 		prompt.UpdatedAt,
 		prompt.ID,
 	)
@@ -595,19 +1468,59 @@ func (a *Adapter) UpdateSystemPrompt(ctx context.Context, prompt *entities.Syste
 	return nil
 }
 
+// DeleteSystemPrompt moves a system prompt to the trash
 func (a *Adapter) DeleteSystemPrompt(ctx context.Context, id string) error {
-	query := "DELETE FROM system_prompts WHERE id = ?"
+	query := "UPDATE system_prompts SET deleted_at = ? WHERE id = ? AND deleted_at IS NULL"
 
-	_, err := a.db.ExecContext(ctx, query, id)
+	result, err := a.db.ExecContext(ctx, query, time.Now(), id)
 	if err != nil {
 		return fmt.Errorf("failed to delete system prompt: %w", err)
 	}
 
-	return nil
+	return requireAffected(result, "system prompt not found: %s", id)
+}
+
+// RestoreSystemPrompt moves a system prompt out of the trash
+func (a *Adapter) RestoreSystemPrompt(ctx context.Context, id string) error {
+	query := "UPDATE system_prompts SET deleted_at = NULL WHERE id = ? AND deleted_at IS NOT NULL"
+
+	result, err := a.db.ExecContext(ctx, query, id)
+	if err != nil {
+		return fmt.Errorf("failed to restore system prompt: %w", err)
+	}
+
+	return requireAffected(result, "system prompt not found in trash: %s", id)
+}
+
+// PurgeSystemPrompts permanently deletes system prompts that were moved to the trash before
+// deletedBefore. Prompts still referenced by any conversation are kept.
+func (a *Adapter) PurgeSystemPrompts(ctx context.Context, deletedBefore time.Time) (int, error) {
+	query := `
+		DELETE FROM system_prompts
+		WHERE deleted_at IS NOT NULL AND deleted_at < ?
+		AND id NOT IN (SELECT system_prompt_id FROM conversations)
+	`
+
+	result, err := a.db.ExecContext(ctx, query, deletedBefore)
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge system prompts: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to count purged system prompts: %w", err)
+	}
+
+	return int(affected), nil
 }
 
 // Tool call operations
 func (a *Adapter) SaveToolCall(ctx context.Context, toolCall *entities.ToolCall) error {
+	return insertToolCall(ctx, a.db, toolCall)
+}
+
+// insertToolCall stores a tool call
+func insertToolCall(ctx context.Context, db execer, toolCall *entities.ToolCall) error {
 	argumentsJSON, err := toolCall.ArgumentsJSON()
 	if err != nil {
 		return fmt.Errorf("failed to marshal tool call arguments: %w", err)
@@ -619,11 +1532,11 @@ func (a *Adapter) SaveToolCall(ctx context.Context, toolCall *entities.ToolCall)
 	}
 
 	query := `
-		INSERT INTO tool_calls (id, message_id, tool_name, arguments, result, status, created_at)
-		VALUES (?, ?, ?, ?, ?, ?, ?)
+		INSERT INTO tool_calls (id, message_id, tool_name, arguments, result, status, created_at, completed_at, duration_ms)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`
 
-	_, err = a.db.ExecContext(ctx, query,
+	_, err = db.ExecContext(ctx, query,
 		toolCall.ID,
 		toolCall.MessageID,
 		toolCall.Name,
@@ -631,6 +1544,8 @@ func (a *Adapter) SaveToolCall(ctx context.Context, toolCall *entities.ToolCall)
 		resultJSON,
 		string(toolCall.Status),
 		toolCall.CreatedAt,
+		toolCall.CompletedAt,
+		toolCall.DurationMs,
 	)
 	if err != nil {
 		return fmt.Errorf("failed to save tool call: %w", err)
@@ -639,16 +1554,15 @@ func (a *Adapter) SaveToolCall(ctx context.Context, toolCall *entities.ToolCall)
 	return nil
 }
 
-func (a *Adapter) GetToolCall(ctx context.Context, id string) (*entities.ToolCall, error) {
-	query := `
-		SELECT id, message_id, tool_name, arguments, result, status, created_at
-		FROM tool_calls WHERE id = ?
-	`
-
-	row := a.db.QueryRowContext(ctx, query, id)
+// toolCallColumns lists the columns read by scanToolCall, in order
+const toolCallColumns = "id, message_id, tool_name, arguments, result, status, created_at, completed_at, duration_ms"
 
+// scanToolCall reads a tool call row selected with toolCallColumns
+func scanToolCall(row rowScanner) (*entities.ToolCall, error) {
 	var toolCall entities.ToolCall
 	var argumentsJSON, resultJSON sql.NullString
+	var completedAt sql.NullTime
+	var durationMs sql.NullInt64
 
 	err := row.Scan(
 		&toolCall.ID,
@@ -658,40 +1572,59 @@ func (a *Adapter) GetToolCall(ctx context.Context, id string) (*entities.ToolCal
 		&resultJSON,
 		&toolCall.Status,
 		&toolCall.CreatedAt,
+		&completedAt,
+		&durationMs,
 	)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("tool call not found: %s", id)
-		}
-		return nil, fmt.Errorf("failed to get tool call: %w", err)
+		return nil, err
 	}
 
-	// Unmarshal arguments
 	if argumentsJSON.Valid {
 		if err := json.Unmarshal([]byte(argumentsJSON.String), &toolCall.Arguments); err != nil {
 			return nil, fmt.Errorf("failed to unmarshal tool call arguments: %w", err)
 		}
 	}
-
-	// Unmarshal result
 	if resultJSON.Valid && resultJSON.String != "" {
 		if err := json.Unmarshal([]byte(resultJSON.String), &toolCall.Result); err != nil {
 			return nil, fmt.Errorf("failed to unmarshal tool call result: %w", err)
 		}
 	}
+	if completedAt.Valid {
+		toolCall.CompletedAt = &completedAt.Time
+	}
+	toolCall.DurationMs = durationMs.Int64
 
 	return &toolCall, nil
 }
 
+func (a *Adapter) GetToolCall(ctx context.Context, id string) (*entities.ToolCall, error) {
+	query := "SELECT " + toolCallColumns + " FROM tool_calls WHERE id = ?"
+
+	toolCall, err := scanToolCall(a.db.QueryRowContext(ctx, query, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("%w: %s", ports.ErrToolCallNotFound, id)
+		}
+		return nil, fmt.Errorf("failed to get tool call: %w", err)
+	}
+
+	return toolCall, nil
+}
+
 func (a *Adapter) GetToolCallsForMessage(ctx context.Context, messageID string) ([]*entities.ToolCall, error) {
 	query := `
-		SELECT id, message_id, tool_name, arguments, result, status, created_at
+		SELECT ` + toolCallColumns + `
 		FROM tool_calls 
 		WHERE message_id = ?
 		ORDER BY created_at ASC
 	`
 
-	rows, err := a.db.QueryContext(ctx, query, messageID)
+	return a.queryToolCalls(ctx, query, messageID)
+}
+
+// queryToolCalls runs a query selecting toolCallColumns
+func (a *Adapter) queryToolCalls(ctx context.Context, query string, args ...interface{}) ([]*entities.ToolCall, error) {
+	rows, err := a.db.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get tool calls: %w", err)
 	}
@@ -699,111 +1632,432 @@ func (a *Adapter) GetToolCallsForMessage(ctx context.Context, messageID string)
 
 	var toolCalls []*entities.ToolCall
 	for rows.Next() {
-		var toolCall entities.ToolCall
-		var argumentsJSON, resultJSON sql.NullString
-
-		err := rows.Scan(
-			&toolCall.ID,
-			&toolCall.MessageID,
-			&toolCall.Name,
-			&argumentsJSON,
-			&resultJSON,
-			&toolCall.Status,
-			&toolCall.CreatedAt,
-		)
+		toolCall, err := scanToolCall(rows)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan tool call: %w", err)
 		}
+		toolCalls = append(toolCalls, toolCall)
+	}
 
-		// Unmarshal arguments
-		if argumentsJSON.Valid {
-			if err := json.Unmarshal([]byte(argumentsJSON.String), &toolCall.Arguments); err != nil {
-				return nil, fmt.Errorf("failed to unmarshal tool call arguments: %w", err)
-			}
+	return toolCalls, rows.Err()
+}
+
+// toolCallConditions builds the WHERE clause, if any, selecting the tool calls matching a filter.
+// Queries using it select FROM tool_calls JOIN messages. Times are compared through julianday, since
+// stored times and the bounds may carry different UTC offsets.
+func toolCallConditions(filter ports.ToolCallFilter) (string, []interface{}) {
+	var conditions []string
+	var args []interface{}
+
+	if filter.Tool != "" {
+		conditions = append(conditions, "tool_calls.tool_name = ?")
+		args = append(args, filter.Tool)
+	}
+	if filter.Status != "" {
+		conditions = append(conditions, "tool_calls.status = ?")
+		args = append(args, string(filter.Status))
+	}
+	if filter.ConversationID != "" {
+		conditions = append(conditions, "messages.conversation_id = ?")
+		args = append(args, filter.ConversationID)
+	}
+	if filter.Since != nil {
+		conditions = append(conditions, "julianday(tool_calls.created_at) >= julianday(?)")
+		args = append(args, *filter.Since)
+	}
+	if filter.Until != nil {
+		conditions = append(conditions, "julianday(tool_calls.created_at) < julianday(?)")
+		args = append(args, *filter.Until)
+	}
+
+	if len(conditions) == 0 {
+		return "", nil
+	}
+	return "WHERE " + strings.Join(conditions, " AND "), args
+}
+
+// ListToolCalls returns the tool calls matching a filter, newest first
+func (a *Adapter) ListToolCalls(ctx context.Context, filter ports.ToolCallFilter) ([]*entities.ToolCall, error) {
+	where, args := toolCallConditions(filter)
+
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = -1 // No limit
+	}
+
+	query := "SELECT tool_calls." + strings.ReplaceAll(toolCallColumns, ", ", ", tool_calls.") + `
+		FROM tool_calls
+		JOIN messages ON messages.id = tool_calls.message_id
+		` + where + `
+		ORDER BY tool_calls.created_at DESC, tool_calls.id DESC
+		LIMIT ? OFFSET ?`
+	args = append(args, limit, filter.Offset)
+
+	return a.queryToolCalls(ctx, query, args...)
+}
+
+// GetToolCallStats aggregates the tool calls matching a filter per tool, ignoring its limit and offset.
+// Latency percentiles cover the calls whose tool ran.
+func (a *Adapter) GetToolCallStats(ctx context.Context, filter ports.ToolCallFilter) ([]ports.ToolCallStats, error) {
+	where, args := toolCallConditions(filter)
+
+	query := `
+		SELECT tool_calls.tool_name, COUNT(*),
+			SUM(tool_calls.status = ?), SUM(tool_calls.status = ?), SUM(tool_calls.status = ?)
+		FROM tool_calls
+		JOIN messages ON messages.id = tool_calls.message_id
+		` + where + `
+		GROUP BY tool_calls.tool_name`
+	countArgs := append([]interface{}{
+		string(entities.ToolCallStatusSuccess), string(entities.ToolCallStatusError), string(entities.ToolCallStatusRejected),
+	}, args...)
+
+	rows, err := a.db.QueryContext(ctx, query, countArgs...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tool call stats: %w", err)
+	}
+	defer rows.Close()
+
+	byTool := make(map[string]*ports.ToolCallStats)
+	for rows.Next() {
+		stats := &ports.ToolCallStats{}
+		if err := rows.Scan(&stats.Tool, &stats.Count, &stats.Succeeded, &stats.Failed, &stats.Rejected); err != nil {
+			return nil, fmt.Errorf("failed to scan tool call stats: %w", err)
 		}
+		byTool[stats.Tool] = stats
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get tool call stats: %w", err)
+	}
 
-		// Unmarshal result
-		if resultJSON.Valid && resultJSON.String != "" {
-			if err := json.Unmarshal([]byte(resultJSON.String), &toolCall.Result); err != nil {
-				return nil, fmt.Errorf("failed to unmarshal tool call result: %w", err)
-			}
+	// Only the calls that ran have a latency; rejected calls never did, and calls from before
+	// durations were recorded have none
+	ran := "(tool_calls.status IN (?, ?) AND tool_calls.duration_ms IS NOT NULL)"
+	if where == "" {
+		where = "WHERE " + ran
+	} else {
+		where += " AND " + ran
+	}
+	// Nearest-rank percentiles, ceil(p/100 * n), picked in SQL so only two rows per tool come back
+	query = `
+		WITH ranked AS (
+			SELECT tool_calls.tool_name, tool_calls.duration_ms,
+				ROW_NUMBER() OVER (PARTITION BY tool_calls.tool_name ORDER BY tool_calls.duration_ms) AS rn,
+				COUNT(*) OVER (PARTITION BY tool_calls.tool_name) AS n
+			FROM tool_calls
+			JOIN messages ON messages.id = tool_calls.message_id
+			` + where + `
+		)
+		SELECT tool_name, duration_ms, rn = (50 * n + 99) / 100, rn = (95 * n + 99) / 100
+		FROM ranked
+		WHERE rn IN ((50 * n + 99) / 100, (95 * n + 99) / 100)`
+	args = append(args, string(entities.ToolCallStatusSuccess), string(entities.ToolCallStatusError))
+
+	percentileRows, err := a.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tool call percentiles: %w", err)
+	}
+	defer percentileRows.Close()
+
+	for percentileRows.Next() {
+		var name string
+		var durationMs int64
+		var isP50, isP95 bool
+		if err := percentileRows.Scan(&name, &durationMs, &isP50, &isP95); err != nil {
+			return nil, fmt.Errorf("failed to scan tool call percentiles: %w", err)
+		}
+		stats, ok := byTool[name]
+		if !ok {
+			continue
+		}
+		if isP50 {
+			stats.P50Ms = durationMs
+		}
+		if isP95 {
+			stats.P95Ms = durationMs
 		}
+	}
+	if err := percentileRows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get tool call percentiles: %w", err)
+	}
 
-		toolCalls = append(toolCalls, &toolCall)
+	result := make([]ports.ToolCallStats, 0, len(byTool))
+	for _, stats := range byTool {
+		if finished := stats.Succeeded + stats.Failed; finished > 0 {
+			stats.ErrorRate = float64(stats.Failed) / float64(finished)
+		}
+		result = append(result, *stats)
 	}
 
-	return toolCalls, nil
+	// Most used first
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Count != result[j].Count {
+			return result[i].Count > result[j].Count
+		}
+		return result[i].Tool < result[j].Tool
+	})
+	return result, nil
 }
 
 func (a *Adapter) UpdateToolCall(ctx context.Context, toolCall *entities.ToolCall) error {
+	_, err := a.updateToolCall(ctx, toolCall, "")
+	return err
+}
+
+// UpdateToolCallFromStatus updates a tool call only if its stored status is still from, reporting whether it did
+func (a *Adapter) UpdateToolCallFromStatus(ctx context.Context, toolCall *entities.ToolCall, from entities.ToolCallStatus) (bool, error) {
+	return a.updateToolCall(ctx, toolCall, from)
+}
+
+// updateToolCall stores a tool call's outcome and records its event. A non-empty from makes the
+// update conditional on the stored status, so concurrent transitions can't both apply.
+func (a *Adapter) updateToolCall(ctx context.Context, toolCall *entities.ToolCall, from entities.ToolCallStatus) (bool, error) {
 	resultJSON, err := toolCall.ResultJSON()
 	if err != nil {
-		return fmt.Errorf("failed to marshal tool call result: %w", err)
+		return false, fmt.Errorf("failed to marshal tool call result: %w", err)
 	}
 
 	query := `
 		UPDATE tool_calls 
-		SET result = ?, status = ?
+		SET result = ?, status = ?, completed_at = ?, duration_ms = ?
 		WHERE id = ?
 	`
+	args := []interface{}{resultJSON, string(toolCall.Status), toolCall.CompletedAt, toolCall.DurationMs, toolCall.ID}
+	if from != "" {
+		query += " AND status = ?"
+		args = append(args, string(from))
+	}
 
-	_, err = a.db.ExecContext(ctx, query,
-		resultJSON,
-		string(toolCall.Status),
-		toolCall.ID,
-	)
+	tx, err := a.db.BeginTx(ctx, nil)
 	if err != nil {
-		return fmt.Errorf("failed to update tool call: %w", err)
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
 	}
+	defer tx.Rollback()
 
-	return nil
+	result, err := tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		return false, fmt.Errorf("failed to update tool call: %w", err)
+	}
+	if from != "" {
+		updated, err := result.RowsAffected()
+		if err != nil {
+			return false, fmt.Errorf("failed to check tool call update: %w", err)
+		}
+		if updated == 0 {
+			return false, nil
+		}
+	}
+
+	if event := entities.ToolCallEvent(*toolCall); event != nil {
+		var conversationID string
+		err := tx.QueryRowContext(ctx, `
+			SELECT messages.conversation_id FROM tool_calls
+			JOIN messages ON messages.id = tool_calls.message_id
+			WHERE tool_calls.id = ?
+		`, toolCall.ID).Scan(&conversationID)
+
+		switch {
+		case err == sql.ErrNoRows:
+			// The tool call isn't stored yet; it is recorded with its message
+		case err != nil:
+			return false, fmt.Errorf("failed to find conversation for tool call: %w", err)
+		default:
+			if err := appendEvents(ctx, tx, conversationID, event); err != nil {
+				return false, err
+			}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit tool call update: %w", err)
+	}
+	return true, nil
 }
 
 // Event operations
+
+// execer is satisfied by both *sql.DB and *sql.Tx
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// querier is satisfied by both *sql.DB and *sql.Tx
+type querier interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// queryIDs runs a query returning a single ID column, such as an UPDATE ... RETURNING id
+func queryIDs(ctx context.Context, db querier, query string, args ...interface{}) ([]string, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
+
+// newEventID returns an event ID that sorts by creation time
+func newEventID() string {
+	randomBytes := make([]byte, 4)
+	rand.Read(randomBytes)
+	return fmt.Sprintf("%d_%x", time.Now().UnixNano(), randomBytes)
+}
+
+// insertEvent appends an event to a conversation's log.
+// Timestamps are stored in UTC with nanoseconds so the log replays in order.
+func insertEvent(ctx context.Context, db execer, conversationID, eventType string, payload []byte) error {
+	query := `
+		INSERT INTO events (id, conversation_id, event_type, payload, created_at)
+		VALUES (?, ?, ?, ?, ?)
+	`
+
+	_, err := db.ExecContext(ctx, query, newEventID(), conversationID, eventType, string(payload), time.Now().UTC())
+	if err != nil {
+		return fmt.Errorf("failed to save event: %w", err)
+	}
+
+	return nil
+}
+
+// appendEvents records domain events in a conversation's log
+func appendEvents(ctx context.Context, db execer, conversationID string, events ...entities.DomainEvent) error {
+	for _, event := range events {
+		payload, err := json.Marshal(event)
+		if err != nil {
+			return fmt.Errorf("failed to marshal %s event: %w", event.EventType(), err)
+		}
+
+		if err := insertEvent(ctx, db, conversationID, event.EventType(), payload); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 func (a *Adapter) SaveEvent(ctx context.Context, conversationID, eventType string, payload map[string]interface{}) error {
 	payloadJSON, err := json.Marshal(payload)
 	if err != nil {
 		return fmt.Errorf("failed to marshal event payload: %w", err)
 	}
 
-	eventID := fmt.Sprintf("%d_%x", time.Now().UnixNano(), []byte(eventType + conversationID)[:4])
+	return insertEvent(ctx, a.db, conversationID, eventType, payloadJSON)
+}
+
+// ImportEvent stores an event with its own ID and timestamp, skipping events already stored
+func (a *Adapter) ImportEvent(ctx context.Context, event ports.Event) (bool, error) {
+	return importEvent(ctx, a.db, event)
+}
+
+// importEvent stores an event with its own ID and timestamp unless its ID is taken, reporting whether it did
+func importEvent(ctx context.Context, db execer, event ports.Event) (bool, error) {
+	payloadJSON, err := json.Marshal(event.Payload)
+	if err != nil {
+		return false, fmt.Errorf("failed to marshal event payload: %w", err)
+	}
+
+	var conversationID interface{}
+	if event.ConversationID != "" {
+		conversationID = event.ConversationID
+	}
+
+	// Store timestamps in the same form as insertEvent so imported events sort with local ones
+	var createdAt interface{} = event.CreatedAt
+	if parsed, err := time.Parse(time.RFC3339Nano, event.CreatedAt); err == nil {
+		createdAt = parsed.UTC()
+	}
 
 	query := `
 		INSERT INTO events (id, conversation_id, event_type, payload, created_at)
-		VALUES (?, ?, ?, ?, CURRENT_TIMESTAMP)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (id) DO NOTHING
 	`
 
-	_, err = a.db.ExecContext(ctx, query, eventID, conversationID, eventType, string(payloadJSON))
+	result, err := db.ExecContext(ctx, query, event.ID, conversationID, event.EventType, string(payloadJSON), createdAt)
 	if err != nil {
-		return fmt.Errorf("failed to save event: %w", err)
+		return false, fmt.Errorf("failed to import event: %w", err)
 	}
 
-	return nil
+	imported, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to check imported event: %w", err)
+	}
+	return imported == 1, nil
+}
+
+// GetUnattachedEvents returns events recorded without a conversation
+func (a *Adapter) GetUnattachedEvents(ctx context.Context, afterID string, limit int) ([]ports.Event, error) {
+	query := `
+		SELECT ` + eventColumns + `
+		FROM events
+		WHERE (conversation_id IS NULL OR conversation_id = '') AND id > ?
+		ORDER BY id ASC
+		LIMIT ?
+	`
+
+	return a.queryEvents(ctx, query, afterID, limit)
 }
 
+// eventColumns lists the columns read by queryEvents, in order
+const eventColumns = "id, conversation_id, event_type, payload, created_at"
+
+// GetEvents returns the newest events of a conversation, newest first
 func (a *Adapter) GetEvents(ctx context.Context, conversationID string, limit int) ([]ports.Event, error) {
 	query := `
-		SELECT id, conversation_id, event_type, payload, created_at
+		SELECT ` + eventColumns + `
 		FROM events 
 		WHERE conversation_id = ?
-		ORDER BY created_at DESC
+		ORDER BY created_at DESC, id DESC
 		LIMIT ?
 	`
 
-	rows, err := a.db.QueryContext(ctx, query, conversationID, limit)
+	return a.queryEvents(ctx, query, conversationID, limit)
+}
+
+// GetEventLog returns the events of a conversation in the order they happened
+func (a *Adapter) GetEventLog(ctx context.Context, conversationID string, limit int, offset int) ([]ports.Event, error) {
+	if limit <= 0 {
+		limit = -1 // No limit
+	}
+
+	query := `
+		SELECT ` + eventColumns + `
+		FROM events
+		WHERE conversation_id = ?
+		ORDER BY created_at ASC, id ASC
+		LIMIT ? OFFSET ?
+	`
+
+	return a.queryEvents(ctx, query, conversationID, limit, offset)
+}
+
+// queryEvents runs a query selecting eventColumns
+func (a *Adapter) queryEvents(ctx context.Context, query string, args ...interface{}) ([]ports.Event, error) {
+	rows, err := a.db.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get events: %w", err)
 	}
 	defer rows.Close()
 
-	var events []ports.Event
+	events := make([]ports.Event, 0)
 	for rows.Next() {
 		var event ports.Event
+		var conversationID sql.NullString
 		var payloadJSON string
 
 		err := rows.Scan(
 			&event.ID,
-			&event.ConversationID,
+			&conversationID,
 			&event.EventType,
 			&payloadJSON,
 			&event.CreatedAt,
@@ -811,6 +2065,7 @@ func (a *Adapter) GetEvents(ctx context.Context, conversationID string, limit in
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan event: %w", err)
 		}
+		event.ConversationID = conversationID.String
 
 		// Unmarshal payload
 		if err := json.Unmarshal([]byte(payloadJSON), &event.Payload); err != nil {
@@ -822,3 +2077,155 @@ func (a *Adapter) GetEvents(ctx context.Context, conversationID string, limit in
 
 	return events, nil
 }
+
+// ReplaceConversation overwrites a conversation, its messages and tool calls without recording events.
+// It applies a projection rebuilt from the event log.
+func (a *Adapter) ReplaceConversation(ctx context.Context, conversation *entities.Conversation, messages []*entities.Message) error {
+	query := `
+		INSERT INTO conversations (id, title, system_prompt_id, model, folder_id, pinned, starred, tool_settings, archived_at, deleted_at, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET
+			title = excluded.title,
+			system_prompt_id = excluded.system_prompt_id,
+			model = excluded.model,
+			folder_id = excluded.folder_id,
+			pinned = excluded.pinned,
+			starred = excluded.starred,
+			tool_settings = excluded.tool_settings,
+			archived_at = excluded.archived_at,
+			deleted_at = excluded.deleted_at,
+			created_at = excluded.created_at,
+			updated_at = excluded.updated_at
+	`
+
+	toolSettings, err := encodeToolSettings(conversation.Tools)
+	if err != nil {
+		return err
+	}
+
+	tx, err := a.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, query,
+		conversation.ID,
+		conversation.Title,
+		conversation.SystemPromptID,
+		conversation.Model,
+		nullIfEmpty(conversation.FolderID),
+		conversation.Pinned,
+		conversation.Starred,
+		toolSettings,
+		conversation.ArchivedAt,
+		conversation.DeletedAt,
+		conversation.CreatedAt,
+		conversation.UpdatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to replace conversation: %w", err)
+	}
+
+	if err := replaceTags(ctx, tx, conversation); err != nil {
+		return err
+	}
+
+	// Tool calls are removed with their messages
+	if _, err := tx.ExecContext(ctx, "DELETE FROM messages WHERE conversation_id = ?", conversation.ID); err != nil {
+		return fmt.Errorf("failed to clear conversation messages: %w", err)
+	}
+
+	for _, message := range messages {
+		if err := insertMessage(ctx, tx, message); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// Retention operations
+func (a *Adapter) PruneConversations(ctx context.Context, olderThan time.Time, archive bool) (int, error) {
+	var query string
+
+	if archive {
+		query = `
+			UPDATE conversations
+			SET archived_at = ?
+			WHERE pinned = 0 AND archived_at IS NULL AND deleted_at IS NULL AND updated_at < ?
+			RETURNING id
+		`
+	} else {
+		// Moves conversations to the trash; they are purged after the trash grace period
+		query = `
+			UPDATE conversations
+			SET deleted_at = ?
+			WHERE pinned = 0 AND deleted_at IS NULL AND updated_at < ?
+			RETURNING id
+		`
+	}
+
+	tx, err := a.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	pruned, err := queryIDs(ctx, tx, query, now, olderThan)
+	if err != nil {
+		return 0, fmt.Errorf("failed to prune conversations: %w", err)
+	}
+
+	var event entities.DomainEvent = entities.ConversationDeleted{DeletedAt: now}
+	if archive {
+		event = entities.ConversationArchived{ArchivedAt: now}
+	}
+
+	for _, id := range pruned {
+		if err := appendEvents(ctx, tx, id, event); err != nil {
+			return 0, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit pruned conversations: %w", err)
+	}
+
+	return len(pruned), nil
+}
+
+func (a *Adapter) PruneEvents(ctx context.Context, maxPerConversation int) (int, error) {
+	query := `
+		DELETE FROM events WHERE id IN (
+			SELECT id FROM (
+				SELECT id, ROW_NUMBER() OVER (
+					PARTITION BY conversation_id ORDER BY created_at DESC, id DESC
+				) AS position
+				FROM events
+				WHERE conversation_id IS NOT NULL
+			)
+			WHERE position > ?
+		)
+	`
+
+	result, err := a.db.ExecContext(ctx, query, maxPerConversation)
+	if err != nil {
+		return 0, fmt.Errorf("failed to prune events: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to count pruned events: %w", err)
+	}
+
+	return int(affected), nil
+}
+
+func (a *Adapter) Vacuum(ctx context.Context) error {
+	if _, err := a.db.ExecContext(ctx, "VACUUM"); err != nil {
+		return fmt.Errorf("failed to vacuum database: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,416 @@
+package sqlite
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/username/hexarag/internal/domain/entities"
+	"github.com/username/hexarag/internal/domain/ports"
+)
+
+// newTestAdapter creates a migrated database in a temporary directory
+func newTestAdapter(t *testing.T) *Adapter {
+	t.Helper()
+
+	adapter, err := NewAdapter(filepath.Join(t.TempDir(), "test.db"), "migrations")
+	if err != nil {
+		t.Fatalf("NewAdapter() error = %v", err)
+	}
+	t.Cleanup(func() { adapter.Close() })
+
+	if err := adapter.Migrate(context.Background()); err != nil {
+		t.Fatalf("Migrate() error = %v", err)
+	}
+
+	return adapter
+}
+
+func conversationTitles(conversations []*entities.Conversation) []string {
+	titles := make([]string, 0, len(conversations))
+	for _, conversation := range conversations {
+		titles = append(titles, conversation.Title)
+	}
+	return titles
+}
+
+func TestAdapter_ListConversations(t *testing.T) {
+	ctx := context.Background()
+	adapter := newTestAdapter(t)
+
+	work := entities.NewFolder("Work", "")
+	project := entities.NewFolder("Project", work.ID)
+	for _, folder := range []*entities.Folder{work, project} {
+		if err := adapter.SaveFolder(ctx, folder); err != nil {
+			t.Fatalf("SaveFolder() error = %v", err)
+		}
+	}
+
+	base := time.Now().Add(-time.Hour)
+	newConversation := func(title, folderID string, tags ...string) *entities.Conversation {
+		conversation := entities.NewConversation(title, "default")
+		conversation.FolderID = folderID
+		conversation.Tags = tags
+		conversation.UpdatedAt = base.Add(time.Duration(len(title)) * time.Minute)
+		return conversation
+	}
+
+	alpha := newConversation("alpha", work.ID, "Go", "design")
+	beta := newConversation("beta-long", project.ID, "go")
+	gamma := newConversation("gamma-longer", "")
+	gamma.Pinned = true
+	delta := newConversation("delta-longest", "", "go")
+	delta.Starred = true
+	delta.Archive()
+
+	for _, conversation := range []*entities.Conversation{alpha, beta, gamma, delta} {
+		if err := adapter.SaveConversation(ctx, conversation); err != nil {
+			t.Fatalf("SaveConversation() error = %v", err)
+		}
+	}
+
+	notArchived := false
+	unfiled := ""
+	starred := true
+
+	tests := []struct {
+		name   string
+		filter ports.ConversationFilter
+		want   []string
+	}{
+		{
+			name:   "pinned first then newest",
+			filter: ports.ConversationFilter{Archived: &notArchived, Descending: true},
+			want:   []string{"gamma-longer", "beta-long", "alpha"},
+		},
+		{
+			name:   "title ascending",
+			filter: ports.ConversationFilter{SortBy: ports.SortByTitle},
+			want:   []string{"gamma-longer", "alpha", "beta-long", "delta-longest"},
+		},
+		{
+			name:   "all tags required",
+			filter: ports.ConversationFilter{Tags: []string{"go", "DESIGN"}},
+			want:   []string{"alpha"},
+		},
+		{
+			name:   "folder only",
+			filter: ports.ConversationFilter{FolderID: &work.ID},
+			want:   []string{"alpha"},
+		},
+		{
+			name:   "folder with subfolders",
+			filter: ports.ConversationFilter{FolderID: &work.ID, IncludeSubfolders: true},
+			want:   []string{"alpha", "beta-long"},
+		},
+		{
+			name:   "unfiled",
+			filter: ports.ConversationFilter{FolderID: &unfiled},
+			want:   []string{"gamma-longer", "delta-longest"},
+		},
+		{
+			name:   "starred",
+			filter: ports.ConversationFilter{Starred: &starred},
+			want:   []string{"delta-longest"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conversations, err := adapter.ListConversations(ctx, tt.filter)
+			if err != nil {
+				t.Fatalf("ListConversations() error = %v", err)
+			}
+
+			got := conversationTitles(conversations)
+			if len(got) != len(tt.want) {
+				t.Fatalf("Expected %v, got %v", tt.want, got)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("Expected %v, got %v", tt.want, got)
+				}
+			}
+		})
+	}
+
+	loaded, err := adapter.GetConversation(ctx, alpha.ID)
+	if err != nil {
+		t.Fatalf("GetConversation() error = %v", err)
+	}
+	if len(loaded.Tags) != 2 || loaded.Tags[0] != "design" || loaded.Tags[1] != "go" {
+		t.Errorf("Expected normalized tags [design go], got %v", loaded.Tags)
+	}
+
+	tags, err := adapter.GetTags(ctx)
+	if err != nil {
+		t.Fatalf("GetTags() error = %v", err)
+	}
+	if len(tags) != 2 || tags[0].Tag != "go" || tags[0].Count != 3 {
+		t.Errorf("Expected go to be the most used tag, got %+v", tags)
+	}
+
+	// Deleting a folder moves its contents up to the parent
+	if err := adapter.DeleteFolder(ctx, project.ID); err != nil {
+		t.Fatalf("DeleteFolder() error = %v", err)
+	}
+	moved, err := adapter.GetConversation(ctx, beta.ID)
+	if err != nil {
+		t.Fatalf("GetConversation() error = %v", err)
+	}
+	if moved.FolderID != work.ID {
+		t.Errorf("Expected conversation to move to parent folder %s, got %q", work.ID, moved.FolderID)
+	}
+}
+
+func TestAdapter_MessageIdempotencyKeys(t *testing.T) {
+	ctx := context.Background()
+	adapter := newTestAdapter(t)
+
+	conversation := entities.NewConversation("Retries", "default")
+	other := entities.NewConversation("Other", "default")
+	for _, c := range []*entities.Conversation{conversation, other} {
+		if err := adapter.SaveConversation(ctx, c); err != nil {
+			t.Fatalf("SaveConversation() error = %v", err)
+		}
+	}
+
+	message := entities.NewMessage(conversation.ID, entities.RoleUser, "Hello")
+	message.IdempotencyKey = "retry-1"
+	if err := adapter.SaveMessage(ctx, message); err != nil {
+		t.Fatalf("SaveMessage() error = %v", err)
+	}
+
+	got, err := adapter.GetMessageByIdempotencyKey(ctx, conversation.ID, "retry-1")
+	if err != nil {
+		t.Fatalf("GetMessageByIdempotencyKey() error = %v", err)
+	}
+	if got.ID != message.ID || got.IdempotencyKey != "retry-1" {
+		t.Errorf("Expected message %s, got %+v", message.ID, got)
+	}
+
+	retry := entities.NewMessage(conversation.ID, entities.RoleUser, "Hello")
+	retry.IdempotencyKey = "retry-1"
+	if err := adapter.SaveMessage(ctx, retry); !errors.Is(err, ports.ErrDuplicateIdempotencyKey) {
+		t.Errorf("Expected ErrDuplicateIdempotencyKey, got %v", err)
+	}
+
+	// Keys are scoped to their conversation, and messages without keys never collide
+	elsewhere := entities.NewMessage(other.ID, entities.RoleUser, "Hello")
+	elsewhere.IdempotencyKey = "retry-1"
+	for _, m := range []*entities.Message{elsewhere, entities.NewMessage(conversation.ID, entities.RoleUser, "a"), entities.NewMessage(conversation.ID, entities.RoleUser, "b")} {
+		if err := adapter.SaveMessage(ctx, m); err != nil {
+			t.Errorf("SaveMessage() error = %v", err)
+		}
+	}
+
+	if _, err := adapter.GetReply(ctx, message.ID); !errors.Is(err, ports.ErrMessageNotFound) {
+		t.Errorf("Expected ErrMessageNotFound before a reply, got %v", err)
+	}
+	reply := entities.NewMessage(conversation.ID, entities.RoleAssistant, "Hi")
+	reply.ParentID = &message.ID
+	if err := adapter.SaveMessage(ctx, reply); err != nil {
+		t.Fatalf("SaveMessage() error = %v", err)
+	}
+	if got, err := adapter.GetReply(ctx, message.ID); err != nil || got.ID != reply.ID {
+		t.Errorf("Expected reply %s, got %+v (%v)", reply.ID, got, err)
+	}
+}
+
+func TestAdapter_SearchMessages(t *testing.T) {
+	ctx := context.Background()
+	adapter := newTestAdapter(t)
+
+	deploys := entities.NewConversation("Deploys", "default")
+	recipes := entities.NewConversation("Recipes", "default")
+	trashed := entities.NewConversation("Old", "default")
+	for _, c := range []*entities.Conversation{deploys, recipes, trashed} {
+		if err := adapter.SaveConversation(ctx, c); err != nil {
+			t.Fatalf("SaveConversation() error = %v", err)
+		}
+	}
+
+	rollback := entities.NewMessage(deploys.ID, entities.RoleAssistant, "To roll back a Kubernetes deployment, run kubectl rollout undo.")
+	mention := entities.NewMessage(deploys.ID, entities.RoleUser, "Our Kubernetes cluster runs on three nodes and hosts the billing service among many others.")
+	bread := entities.NewMessage(recipes.ID, entities.RoleUser, "How long should bread dough rest before it goes in the oven?")
+	system := entities.NewMessage(deploys.ID, entities.RoleSystem, "Kubernetes rollback instructions")
+	old := entities.NewMessage(trashed.ID, entities.RoleUser, "Kubernetes rollback checklist")
+	for _, m := range []*entities.Message{rollback, mention, bread, system, old} {
+		if err := adapter.SaveMessage(ctx, m); err != nil {
+			t.Fatalf("SaveMessage() error = %v", err)
+		}
+	}
+	if err := adapter.DeleteConversation(ctx, trashed.ID); err != nil {
+		t.Fatalf("DeleteConversation() error = %v", err)
+	}
+
+	hitIDs := func(hits []ports.MessageHit) []string {
+		ids := make([]string, 0, len(hits))
+		for _, hit := range hits {
+			ids = append(ids, hit.Message.ID)
+		}
+		return ids
+	}
+
+	hits, err := adapter.SearchMessages(ctx, ports.MessageSearch{Text: "Kubernetes: roll back"})
+	if err != nil {
+		t.Fatalf("SearchMessages() error = %v", err)
+	}
+	if got := hitIDs(hits); len(got) != 2 || got[0] != rollback.ID || got[1] != mention.ID {
+		t.Fatalf("Expected the rollback answer first, then the mention, got %v", got)
+	}
+	if hits[0].ConversationTitle != "Deploys" || hits[0].Snippet == "" || hits[0].Score <= hits[1].Score {
+		t.Errorf("Expected a titled, scored hit with a snippet, got %+v", hits[0])
+	}
+
+	hits, _ = adapter.SearchMessages(ctx, ports.MessageSearch{Text: "kubernetes", ConversationID: deploys.ID, ExcludeIDs: []string{mention.ID}, Limit: 5})
+	if got := hitIDs(hits); len(got) != 1 || got[0] != rollback.ID {
+		t.Errorf("Expected the search to honour the conversation and exclusions, got %v", got)
+	}
+
+	if hits, _ := adapter.SearchMessages(ctx, ports.MessageSearch{Text: " ?! "}); len(hits) != 0 {
+		t.Errorf("Expected no hits without words, got %v", hitIDs(hits))
+	}
+
+	// The index follows messages replaced from the event log
+	bread.Content = "Sourdough starter feeding schedule"
+	if err := adapter.ReplaceConversation(ctx, recipes, []*entities.Message{bread}); err != nil {
+		t.Fatalf("ReplaceConversation() error = %v", err)
+	}
+	if hits, _ := adapter.SearchMessages(ctx, ports.MessageSearch{Text: "oven"}); len(hits) != 0 {
+		t.Errorf("Expected replaced content to leave the index, got %v", hitIDs(hits))
+	}
+	if hits, _ := adapter.SearchMessages(ctx, ports.MessageSearch{Text: "sourdough"}); len(hits) != 1 {
+		t.Errorf("Expected replaced content to be indexed, got %v", hitIDs(hits))
+	}
+}
+
+func TestAdapter_SearchMessagesRanksBeyondRecentMatches(t *testing.T) {
+	ctx := context.Background()
+	adapter := newTestAdapter(t)
+
+	conversation := entities.NewConversation("Chatter", "default")
+	if err := adapter.SaveConversation(ctx, conversation); err != nil {
+		t.Fatalf("SaveConversation() error = %v", err)
+	}
+
+	// The best match is older than 600 messages sharing its common words
+	answer := entities.NewMessage(conversation.ID, entities.RoleAssistant, "The rollback of the service failed")
+	answer.CreatedAt = time.Now().Add(-time.Hour)
+	messages := []*entities.Message{answer}
+	for i := 0; i < 600; i++ {
+		messages = append(messages, entities.NewMessage(conversation.ID, entities.RoleUser, fmt.Sprintf("The service is fine, update %d", i)))
+	}
+	if err := adapter.ReplaceConversation(ctx, conversation, messages); err != nil {
+		t.Fatalf("ReplaceConversation() error = %v", err)
+	}
+
+	// All the words match only the answer, and the rare word outranks the common ones
+	for _, text := range []string{"the service rollback", "service rollback zebra"} {
+		hits, err := adapter.SearchMessages(ctx, ports.MessageSearch{Text: text, Limit: 3})
+		if err != nil {
+			t.Fatalf("SearchMessages(%q) error = %v", text, err)
+		}
+		if len(hits) != 3 || hits[0].Message.ID != answer.ID {
+			t.Errorf("Expected the older answer first for %q, got %d hits starting with %q", text, len(hits), hits[0].Message.Content)
+		}
+	}
+}
+
+func TestAdapter_ToolCallHistory(t *testing.T) {
+	ctx := context.Background()
+	adapter := newTestAdapter(t)
+
+	math := entities.NewConversation("Math", "default")
+	travel := entities.NewConversation("Travel", "default")
+	var messages []*entities.Message
+	for _, c := range []*entities.Conversation{math, travel} {
+		if err := adapter.SaveConversation(ctx, c); err != nil {
+			t.Fatalf("SaveConversation() error = %v", err)
+		}
+		message := entities.NewMessage(c.ID, entities.RoleAssistant, "")
+		if err := adapter.SaveMessage(ctx, message); err != nil {
+			t.Fatalf("SaveMessage() error = %v", err)
+		}
+		messages = append(messages, message)
+	}
+
+	start := time.Now().Add(-time.Hour).UTC()
+	calls := []struct {
+		message  *entities.Message
+		tool     string
+		status   entities.ToolCallStatus
+		duration int64
+	}{
+		{messages[0], "calc", entities.ToolCallStatusSuccess, 30},
+		{messages[0], "calc", entities.ToolCallStatusError, 40},
+		{messages[0], "calc", entities.ToolCallStatusSuccess, 10},
+		{messages[0], "calc", entities.ToolCallStatusSuccess, 20},
+		{messages[1], "weather", entities.ToolCallStatusRejected, 0},
+		{messages[1], "weather", entities.ToolCallStatusSuccess, 0},
+	}
+	var ids []string
+	for i, call := range calls {
+		toolCall := entities.NewToolCall(call.message.ID, call.tool, map[string]interface{}{})
+		toolCall.CreatedAt = start.Add(time.Duration(i) * time.Minute)
+		if err := adapter.SaveToolCall(ctx, toolCall); err != nil {
+			t.Fatalf("SaveToolCall() error = %v", err)
+		}
+		switch call.status {
+		case entities.ToolCallStatusSuccess:
+			toolCall.SetResult("ok")
+		case entities.ToolCallStatusError:
+			toolCall.SetError("failed")
+		case entities.ToolCallStatusRejected:
+			toolCall.Reject("no")
+		}
+		toolCall.DurationMs = call.duration
+		if err := adapter.UpdateToolCall(ctx, toolCall); err != nil {
+			t.Fatalf("UpdateToolCall() error = %v", err)
+		}
+		ids = append(ids, toolCall.ID)
+	}
+
+	listed, err := adapter.ListToolCalls(ctx, ports.ToolCallFilter{Tool: "calc", Limit: 2, Offset: 1})
+	if err != nil {
+		t.Fatalf("ListToolCalls() error = %v", err)
+	}
+	if len(listed) != 2 || listed[0].ID != ids[2] || listed[1].ID != ids[1] {
+		t.Errorf("Expected the second page of calc calls, newest first, got %+v", listed)
+	}
+	if listed[1].CompletedAt == nil || listed[1].DurationMs != 40 || listed[1].Status != entities.ToolCallStatusError {
+		t.Errorf("Expected the completion time and duration to be stored, got %+v", listed[1])
+	}
+
+	until := start.Add(90 * time.Second)
+	// The same instant written with another offset, which sorts differently as text
+	since := until.In(time.FixedZone("UTC+2", 2*60*60))
+	for name, test := range map[string]struct {
+		filter ports.ToolCallFilter
+		want   int
+	}{
+		"status":       {ports.ToolCallFilter{Status: entities.ToolCallStatusSuccess}, 4},
+		"conversation": {ports.ToolCallFilter{ConversationID: travel.ID}, 2},
+		"time range":   {ports.ToolCallFilter{Until: &until}, 2},
+		"offset range": {ports.ToolCallFilter{Since: &since}, 4},
+	} {
+		if listed, _ := adapter.ListToolCalls(ctx, test.filter); len(listed) != test.want {
+			t.Errorf("%s: expected %d tool calls, got %d", name, test.want, len(listed))
+		}
+	}
+
+	stats, err := adapter.GetToolCallStats(ctx, ports.ToolCallFilter{})
+	if err != nil {
+		t.Fatalf("GetToolCallStats() error = %v", err)
+	}
+	want := []ports.ToolCallStats{
+		{Tool: "calc", Count: 4, Succeeded: 3, Failed: 1, ErrorRate: 0.25, P50Ms: 20, P95Ms: 40},
+		{Tool: "weather", Count: 2, Succeeded: 1, Rejected: 1},
+	}
+	if len(stats) != len(want) || stats[0] != want[0] || stats[1] != want[1] {
+		t.Errorf("Expected per-tool stats %+v, got %+v", want, stats)
+	}
+}
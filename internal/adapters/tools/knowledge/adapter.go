@@ -0,0 +1,164 @@
+// Package knowledge offers a tool that lets the model search stored conversations when it needs to
+package knowledge
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/username/hexarag/internal/domain/ports"
+)
+
+// SearchToolName is the name of the search tool
+const SearchToolName = "search_knowledge"
+
+const (
+	defaultLimit = 5
+	maxLimit     = 20
+)
+
+// Search scopes
+const (
+	ScopeAll          = "all"
+	ScopeConversation = "conversation"
+)
+
+// Adapter implements the tool port on top of the storage's full-text message search.
+// Results are ranked snippets whose IDs the model can cite in its answer.
+type Adapter struct {
+	storage ports.StoragePort
+}
+
+// NewAdapter creates a knowledge search adapter over storage
+func NewAdapter(storage ports.StoragePort) *Adapter {
+	return &Adapter{storage: storage}
+}
+
+// Execute searches stored messages. The question being answered and the calling message are left out,
+// since they would always match their own words.
+func (a *Adapter) Execute(ctx context.Context, name string, arguments map[string]interface{}) (*ports.ToolResult, error) {
+	if name != SearchToolName {
+		return &ports.ToolResult{
+			Success: false,
+			Error:   fmt.Sprintf("unknown tool: %s", name),
+		}, nil
+	}
+
+	query, _ := arguments["query"].(string)
+	search := ports.MessageSearch{Text: query, Limit: defaultLimit}
+	// Model arguments decode as float64, schema defaults keep their Go type
+	switch limit := arguments["limit"].(type) {
+	case float64:
+		search.Limit = int(math.Max(1, math.Min(limit, maxLimit)))
+	case int:
+		search.Limit = max(1, min(limit, maxLimit))
+	}
+
+	scope, _ := arguments["scope"].(string)
+	if call, ok := ports.ToolCallFromContext(ctx); ok {
+		if scope == ScopeConversation {
+			search.ConversationID = call.ConversationID
+		}
+		search.ExcludeIDs = a.currentExchange(ctx, call.MessageID)
+	} else if scope == ScopeConversation {
+		return &ports.ToolResult{
+			Success: false,
+			Error:   "the conversation scope is only available within a conversation",
+		}, nil
+	}
+
+	hits, err := a.storage.SearchMessages(ctx, search)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search knowledge: %w", err)
+	}
+
+	results := make([]map[string]interface{}, 0, len(hits))
+	for _, hit := range hits {
+		results = append(results, map[string]interface{}{
+			"id":                 hit.Message.ID,
+			"source":             "message",
+			"conversation_id":    hit.Message.ConversationID,
+			"conversation_title": hit.ConversationTitle,
+			"role":               string(hit.Message.Role),
+			"created_at":         hit.Message.CreatedAt.Format(time.RFC3339),
+			"snippet":            hit.Snippet,
+			"score":              math.Round(hit.Score*1000) / 1000,
+		})
+	}
+
+	return &ports.ToolResult{
+		Success: true,
+		Data: map[string]interface{}{
+			"query":   query,
+			"results": results,
+		},
+		Metadata: map[string]interface{}{"count": len(results)},
+	}, nil
+}
+
+// currentExchange returns the IDs of the calling message and the message it answers
+func (a *Adapter) currentExchange(ctx context.Context, messageID string) []string {
+	if messageID == "" {
+		return nil
+	}
+	ids := []string{messageID}
+
+	if message, err := a.storage.GetMessage(ctx, messageID); err == nil && message.ParentID != nil {
+		ids = append(ids, *message.ParentID)
+	}
+	return ids
+}
+
+// GetAvailableTools returns the search tool
+func (a *Adapter) GetAvailableTools(ctx context.Context) ([]ports.Tool, error) {
+	return []ports.Tool{searchTool}, nil
+}
+
+// GetTool returns the search tool
+func (a *Adapter) GetTool(ctx context.Context, name string) (*ports.Tool, error) {
+	if name != SearchToolName {
+		return nil, fmt.Errorf("tool not found: %s", name)
+	}
+	tool := searchTool
+	return &tool, nil
+}
+
+var searchTool = ports.Tool{
+	Type: "function",
+	Function: ports.ToolFunction{
+		Name: SearchToolName,
+		Description: "Search the knowledge base of past conversations for information you don't have in context. " +
+			"Pass a few distinctive keywords rather than a full question. Results are ranked snippets, best first; " +
+			"cite the ones your answer relies on by their id in square brackets, e.g. [id].",
+		Parameters: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"query": map[string]interface{}{
+					"type":        "string",
+					"description": "Keywords to look for",
+					"minLength":   1,
+				},
+				"scope": map[string]interface{}{
+					"type":        "string",
+					"description": "Search every conversation, or only the current one",
+					"enum":        []string{ScopeAll, ScopeConversation},
+					"default":     ScopeAll,
+				},
+				"limit": map[string]interface{}{
+					"type":        "integer",
+					"description": "Maximum number of results",
+					"minimum":     1,
+					"maximum":     maxLimit,
+					"default":     defaultLimit,
+				},
+			},
+			"required": []string{"query"},
+		},
+	},
+}
+
+// Ping checks that the storage is reachable
+func (a *Adapter) Ping(ctx context.Context) error {
+	return a.storage.Ping(ctx)
+}
@@ -0,0 +1,70 @@
+package knowledge
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/username/hexarag/internal/adapters/storage/sqlite"
+	"github.com/username/hexarag/internal/domain/entities"
+	"github.com/username/hexarag/internal/domain/ports"
+)
+
+func TestAdapter_SearchesConversations(t *testing.T) {
+	ctx := context.Background()
+	storage, err := sqlite.NewAdapter(filepath.Join(t.TempDir(), "test.db"), "../../storage/sqlite/migrations")
+	if err != nil {
+		t.Fatalf("NewAdapter() error = %v", err)
+	}
+	t.Cleanup(func() { storage.Close() })
+	if err := storage.Migrate(ctx); err != nil {
+		t.Fatalf("Migrate() error = %v", err)
+	}
+
+	earlier := entities.NewConversation("Billing incident", "default")
+	current := entities.NewConversation("Postmortem", "default")
+	for _, c := range []*entities.Conversation{earlier, current} {
+		if err := storage.SaveConversation(ctx, c); err != nil {
+			t.Fatalf("SaveConversation() error = %v", err)
+		}
+	}
+
+	answer := entities.NewMessage(earlier.ID, entities.RoleAssistant, "The billing outage was caused by an expired TLS certificate.")
+	question := entities.NewMessage(current.ID, entities.RoleUser, "What caused the billing outage?")
+	toolCallMessage := entities.NewMessage(current.ID, entities.RoleAssistant, "")
+	toolCallMessage.ParentID = &question.ID
+	for _, m := range []*entities.Message{answer, question, toolCallMessage} {
+		if err := storage.SaveMessage(ctx, m); err != nil {
+			t.Fatalf("SaveMessage() error = %v", err)
+		}
+	}
+
+	adapter := NewAdapter(storage)
+	callCtx := ports.WithToolCall(ctx, &ports.ToolExecutionRequest{
+		Name:           SearchToolName,
+		MessageID:      toolCallMessage.ID,
+		ConversationID: current.ID,
+	})
+
+	result, err := adapter.Execute(callCtx, SearchToolName, map[string]interface{}{"query": "billing outage cause"})
+	if err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	results := result.Data.(map[string]interface{})["results"].([]map[string]interface{})
+	if !result.Success || len(results) != 1 || results[0]["id"] != answer.ID || results[0]["conversation_title"] != "Billing incident" {
+		t.Fatalf("Expected only the earlier answer, without the question being answered, got %+v", result.Data)
+	}
+	if results[0]["snippet"] == "" || results[0]["score"].(float64) <= 0 {
+		t.Errorf("Expected a ranked snippet, got %+v", results[0])
+	}
+
+	result, _ = adapter.Execute(callCtx, SearchToolName, map[string]interface{}{"query": "billing outage", "scope": ScopeConversation})
+	if results := result.Data.(map[string]interface{})["results"].([]map[string]interface{}); len(results) != 0 {
+		t.Errorf("Expected the conversation scope to leave out other conversations, got %+v", results)
+	}
+
+	result, _ = adapter.Execute(ctx, SearchToolName, map[string]interface{}{"query": "billing", "scope": ScopeConversation})
+	if result.Success {
+		t.Errorf("Expected the conversation scope to need a calling conversation, got %+v", result)
+	}
+}
@@ -0,0 +1,182 @@
+package mcp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+
+	"github.com/username/hexarag/internal/domain/ports"
+)
+
+// ClientAdapter implements the tool port on top of external MCP servers
+type ClientAdapter struct {
+	clients []*client
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+}
+
+// NewClientAdapter creates an adapter for the given servers. Call Start to connect.
+func NewClientAdapter(servers []ServerConfig) *ClientAdapter {
+	clients := make([]*client, 0, len(servers))
+	for _, server := range servers {
+		clients = append(clients, newClient(server))
+	}
+	return &ClientAdapter{clients: clients}
+}
+
+// Start connects to every server in the background, reconnecting when a server exits or
+// drops its session. It waits until each server has connected once or ctx is done.
+func (a *ClientAdapter) Start(ctx context.Context) {
+	runCtx, cancel := context.WithCancel(context.Background())
+	a.cancel = cancel
+
+	for _, c := range a.clients {
+		a.wg.Add(1)
+		go func(c *client) {
+			defer a.wg.Done()
+			c.run(runCtx)
+		}(c)
+	}
+
+	for _, c := range a.clients {
+		select {
+		case <-c.connected:
+		case <-ctx.Done():
+			log.Printf("MCP server %s is not ready yet, continuing to connect in the background", c.config.Name)
+		}
+	}
+}
+
+// Close disconnects from every server, stopping stdio server processes
+func (a *ClientAdapter) Close() error {
+	if a.cancel != nil {
+		a.cancel()
+	}
+	a.wg.Wait()
+	return nil
+}
+
+// Execute runs a tool on the server that provides it
+func (a *ClientAdapter) Execute(ctx context.Context, name string, arguments map[string]interface{}) (*ports.ToolResult, error) {
+	c := a.clientFor(name)
+	if c == nil {
+		return &ports.ToolResult{
+			Success: false,
+			Error:   fmt.Sprintf("unknown tool: %s", name),
+		}, nil
+	}
+
+	result, err := c.callTool(ctx, name, arguments)
+	if err != nil {
+		var rpcErr *rpcError
+		if errors.As(err, &rpcErr) {
+			// Protocol errors, such as invalid arguments, are reported to the model like tool errors
+			return &ports.ToolResult{
+				Success:  false,
+				Error:    rpcErr.Message,
+				Metadata: map[string]interface{}{"mcp_server": c.config.Name},
+			}, nil
+		}
+		return nil, err
+	}
+
+	return toToolResult(c.config.Name, result), nil
+}
+
+// toToolResult maps an MCP tool result, preferring structured content over text
+func toToolResult(server string, result *callToolResult) *ports.ToolResult {
+	var texts []string
+	var other []contentBlock
+	for _, block := range result.Content {
+		if block.Type == "text" {
+			texts = append(texts, block.Text)
+		} else {
+			other = append(other, block)
+		}
+	}
+	text := strings.Join(texts, "\n")
+
+	toolResult := &ports.ToolResult{
+		Success:  !result.IsError,
+		Metadata: map[string]interface{}{"mcp_server": server},
+	}
+	if len(other) > 0 {
+		toolResult.Metadata["content"] = other
+	}
+
+	switch {
+	case result.IsError:
+		toolResult.Error = text
+		if toolResult.Error == "" {
+			toolResult.Error = "tool reported an error"
+		}
+	case result.StructuredContent != nil:
+		toolResult.Data = result.StructuredContent
+	default:
+		toolResult.Data = text
+	}
+	return toolResult
+}
+
+// GetAvailableTools returns the tools of every connected server
+func (a *ClientAdapter) GetAvailableTools(ctx context.Context) ([]ports.Tool, error) {
+	tools := []ports.Tool{}
+	seen := make(map[string]string)
+	for _, c := range a.clients {
+		for _, tool := range c.availableTools() {
+			if owner, ok := seen[tool.Function.Name]; ok {
+				log.Printf("Tool %s of MCP server %s is hidden by MCP server %s", tool.Function.Name, c.config.Name, owner)
+				continue
+			}
+			seen[tool.Function.Name] = c.config.Name
+			tools = append(tools, tool)
+		}
+	}
+	return tools, nil
+}
+
+// GetTool returns information about a specific tool
+func (a *ClientAdapter) GetTool(ctx context.Context, name string) (*ports.Tool, error) {
+	for _, c := range a.clients {
+		for _, tool := range c.availableTools() {
+			if tool.Function.Name == name {
+				return &tool, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("tool not found: %s", name)
+}
+
+// Ping checks that every server answers
+func (a *ClientAdapter) Ping(ctx context.Context) error {
+	for _, c := range a.clients {
+		if err := c.ping(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetStatus returns the connection status of each server
+func (a *ClientAdapter) GetStatus() map[string]interface{} {
+	servers := make(map[string]interface{}, len(a.clients))
+	for _, c := range a.clients {
+		servers[c.config.Name] = c.status()
+	}
+	return map[string]interface{}{"servers": servers}
+}
+
+// clientFor returns the first server that provides the tool
+func (a *ClientAdapter) clientFor(name string) *client {
+	for _, c := range a.clients {
+		for _, tool := range c.availableTools() {
+			if tool.Function.Name == name {
+				return c
+			}
+		}
+	}
+	return nil
+}
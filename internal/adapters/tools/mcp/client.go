@@ -0,0 +1,312 @@
+package mcp
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/username/hexarag/internal/domain/ports"
+)
+
+const (
+	// Transport types accepted in ServerConfig
+	TransportStdio = "stdio"
+	TransportHTTP  = "http"
+
+	defaultRequestTimeout = 30 * time.Second
+
+	// Restart backoff for servers that exit or can't be reached
+	minRestartDelay = time.Second
+	maxRestartDelay = 30 * time.Second
+)
+
+// clientInfo identifies HexaRAG to MCP servers
+var clientInfo = implementation{Name: "hexarag", Version: "1.0.0"}
+
+// ServerConfig describes an MCP server to launch or connect to
+type ServerConfig struct {
+	Name      string
+	Transport string            // TransportStdio or TransportHTTP
+	Command   string            // Executable for stdio servers
+	Args      []string          // Arguments for stdio servers
+	Env       map[string]string // Extra environment for stdio servers
+	URL       string            // Endpoint of HTTP servers
+	Headers   map[string]string // Sent with every HTTP request, e.g. Authorization
+	Timeout   time.Duration     // Per request; defaults to 30 seconds
+}
+
+// client keeps a connection to one MCP server, reconnecting whenever it is lost
+type client struct {
+	config ServerConfig
+
+	mu         sync.RWMutex
+	conn       transport // nil while disconnected
+	tools      []ports.Tool
+	serverInfo implementation
+	lastError  error
+	restarts   int
+
+	connected chan struct{} // Closed after the first successful connection
+	once      sync.Once
+}
+
+func newClient(config ServerConfig) *client {
+	if config.Timeout <= 0 {
+		config.Timeout = defaultRequestTimeout
+	}
+	return &client{
+		config:    config,
+		connected: make(chan struct{}),
+	}
+}
+
+// run connects to the server and reconnects with backoff until ctx is done
+func (c *client) run(ctx context.Context) {
+	delay := minRestartDelay
+	for ctx.Err() == nil {
+		conn, err := c.connect(ctx)
+		if err != nil {
+			c.setError(err)
+			log.Printf("Failed to connect to MCP server %s (retrying in %s): %v", c.config.Name, delay, err)
+			if !sleep(ctx, delay) {
+				return
+			}
+			delay = min(delay*2, maxRestartDelay)
+			continue
+		}
+
+		connectedAt := time.Now()
+		select {
+		case <-conn.done():
+			c.disconnect(conn)
+			log.Printf("Lost connection to MCP server %s, reconnecting", c.config.Name)
+			// Servers that crash right after starting are restarted with growing delays
+			if time.Since(connectedAt) > maxRestartDelay {
+				delay = minRestartDelay
+			}
+			if !sleep(ctx, delay) {
+				return
+			}
+			delay = min(delay*2, maxRestartDelay)
+
+		case <-ctx.Done():
+			c.disconnect(conn)
+			conn.close()
+			return
+		}
+	}
+}
+
+// connect opens a transport, initializes the session and loads the server's tools
+func (c *client) connect(ctx context.Context) (transport, error) {
+	var conn transport
+	switch c.config.Transport {
+	case TransportStdio:
+		stdio, err := startStdio(c.config.Name, c.config.Command, c.config.Args, c.config.Env, c.handleNotification)
+		if err != nil {
+			return nil, err
+		}
+		conn = stdio
+	case TransportHTTP:
+		conn = newHTTPTransport(c.config.Name, c.config.URL, c.config.Headers, c.handleNotification)
+	default:
+		return nil, fmt.Errorf("unknown MCP transport %q", c.config.Transport)
+	}
+
+	initCtx, cancel := context.WithTimeout(ctx, c.config.Timeout)
+	defer cancel()
+
+	var init initializeResult
+	err := conn.call(initCtx, "initialize", initializeParams{
+		ProtocolVersion: protocolVersion,
+		Capabilities:    map[string]interface{}{},
+		ClientInfo:      clientInfo,
+	}, &init)
+	if err != nil {
+		conn.close()
+		return nil, fmt.Errorf("failed to initialize: %w", err)
+	}
+	if err := conn.notify(initCtx, "notifications/initialized", nil); err != nil {
+		conn.close()
+		return nil, fmt.Errorf("failed to confirm initialization: %w", err)
+	}
+
+	tools, err := listTools(initCtx, conn)
+	if err != nil {
+		conn.close()
+		return nil, err
+	}
+
+	c.mu.Lock()
+	select {
+	case <-c.connected:
+		c.restarts++
+	default:
+	}
+	c.conn = conn
+	c.tools = tools
+	c.serverInfo = init.ServerInfo
+	c.lastError = nil
+	c.mu.Unlock()
+	c.once.Do(func() { close(c.connected) })
+
+	log.Printf("Connected to MCP server %s (%s %s, protocol %s, %d tools)",
+		c.config.Name, init.ServerInfo.Name, init.ServerInfo.Version, init.ProtocolVersion, len(tools))
+	return conn, nil
+}
+
+// listTools reads every page of tools/list
+func listTools(ctx context.Context, conn transport) ([]ports.Tool, error) {
+	var tools []ports.Tool
+	cursor := ""
+	for {
+		var page listToolsResult
+		if err := conn.call(ctx, "tools/list", listToolsParams{Cursor: cursor}, &page); err != nil {
+			return nil, fmt.Errorf("failed to list tools: %w", err)
+		}
+
+		for _, def := range page.Tools {
+			schema := def.InputSchema
+			if schema == nil {
+				schema = map[string]interface{}{"type": "object"}
+			}
+			description := def.Description
+			if description == "" {
+				description = def.Title
+			}
+			tools = append(tools, ports.Tool{
+				Type: "function",
+				Function: ports.ToolFunction{
+					Name:        def.Name,
+					Description: description,
+					Parameters:  schema,
+				},
+			})
+		}
+
+		if page.NextCursor == "" {
+			return tools, nil
+		}
+		cursor = page.NextCursor
+	}
+}
+
+// handleNotification reloads the tool list when the server reports a change.
+// It runs on the transport's reader, so the reload happens asynchronously.
+func (c *client) handleNotification(method string) {
+	if method != "notifications/tools/list_changed" {
+		return
+	}
+
+	go func() {
+		conn := c.connection()
+		if conn == nil {
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), c.config.Timeout)
+		defer cancel()
+
+		tools, err := listTools(ctx, conn)
+		if err != nil {
+			log.Printf("Failed to reload tools of MCP server %s: %v", c.config.Name, err)
+			return
+		}
+
+		c.mu.Lock()
+		if c.conn == conn {
+			c.tools = tools
+		}
+		c.mu.Unlock()
+	}()
+}
+
+// callTool runs a tool on the server
+func (c *client) callTool(ctx context.Context, name string, arguments map[string]interface{}) (*callToolResult, error) {
+	conn := c.connection()
+	if conn == nil {
+		return nil, fmt.Errorf("MCP server %s is not connected", c.config.Name)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.config.Timeout)
+	defer cancel()
+
+	var result callToolResult
+	if err := conn.call(ctx, "tools/call", callToolParams{Name: name, Arguments: arguments}, &result); err != nil {
+		return nil, fmt.Errorf("failed to call tool %s on MCP server %s: %w", name, c.config.Name, err)
+	}
+	return &result, nil
+}
+
+// ping checks that the server answers
+func (c *client) ping(ctx context.Context) error {
+	conn := c.connection()
+	if conn == nil {
+		return fmt.Errorf("MCP server %s is not connected", c.config.Name)
+	}
+	if err := conn.call(ctx, "ping", nil, nil); err != nil {
+		return fmt.Errorf("failed to ping MCP server %s: %w", c.config.Name, err)
+	}
+	return nil
+}
+
+func (c *client) connection() transport {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.conn
+}
+
+func (c *client) availableTools() []ports.Tool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.conn == nil {
+		return nil
+	}
+	return c.tools
+}
+
+func (c *client) disconnect(conn transport) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.conn == conn {
+		c.conn = nil
+	}
+}
+
+func (c *client) setError(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.lastError = err
+}
+
+// status describes the connection for status endpoints
+func (c *client) status() map[string]interface{} {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	status := map[string]interface{}{
+		"transport": c.config.Transport,
+		"connected": c.conn != nil,
+		"tools":     len(c.tools),
+		"restarts":  c.restarts,
+	}
+	if c.serverInfo.Name != "" {
+		status["server"] = c.serverInfo.Name + " " + c.serverInfo.Version
+	}
+	if c.lastError != nil {
+		status["error"] = c.lastError.Error()
+	}
+	return status
+}
+
+// sleep waits for d, returning false if ctx is done first
+func sleep(ctx context.Context, d time.Duration) bool {
+	select {
+	case <-ctx.Done():
+		return false
+	case <-time.After(d):
+		return true
+	}
+}
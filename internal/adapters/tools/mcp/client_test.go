@@ -0,0 +1,262 @@
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+)
+
+// The test binary doubles as a stdio MCP server when this variable is set
+const fixtureServerEnv = "HEXARAG_MCP_FIXTURE_SERVER"
+
+func TestMain(m *testing.M) {
+	if os.Getenv(fixtureServerEnv) == "1" {
+		serveFixtureStdio(os.Stdin, os.Stdout)
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// serveFixtureStdio answers newline-delimited requests until its input closes
+func serveFixtureStdio(in io.Reader, out io.Writer) {
+	scanner := bufio.NewScanner(in)
+	encoder := json.NewEncoder(out)
+	for scanner.Scan() {
+		var msg rpcMessage
+		if err := json.Unmarshal(scanner.Bytes(), &msg); err != nil {
+			continue
+		}
+		if msg.Method == "tools/call" && toolName(&msg) == "crash" {
+			os.Exit(1)
+		}
+		if reply := fixtureReply(&msg); reply != nil {
+			encoder.Encode(reply)
+		}
+	}
+}
+
+// fixtureReply answers one request of the fixture server; notifications get no reply
+func fixtureReply(msg *rpcMessage) *rpcMessage {
+	if len(msg.ID) == 0 {
+		return nil
+	}
+
+	var result interface{}
+	switch msg.Method {
+	case "initialize":
+		result = initializeResult{
+			ProtocolVersion: protocolVersion,
+			Capabilities:    map[string]interface{}{"tools": map[string]interface{}{"listChanged": true}},
+			ServerInfo:      implementation{Name: "fixture", Version: "0.1.0"},
+		}
+	case "ping":
+		result = struct{}{}
+	case "tools/list":
+		var params listToolsParams
+		json.Unmarshal(msg.Params, &params)
+		// Two pages, to exercise pagination
+		if params.Cursor == "" {
+			result = listToolsResult{Tools: []toolDefinition{{
+				Name:        "echo",
+				Description: "Echo a message",
+				InputSchema: map[string]interface{}{
+					"type":       "object",
+					"properties": map[string]interface{}{"message": map[string]interface{}{"type": "string"}},
+					"required":   []string{"message"},
+				},
+			}}, NextCursor: "2"}
+		} else {
+			result = listToolsResult{Tools: []toolDefinition{
+				{Name: "add", Title: "Add two numbers"},
+				{Name: "fail", Description: "Always fails"},
+				{Name: "crash", Description: "Exits the server"},
+			}}
+		}
+	case "tools/call":
+		var params callToolParams
+		json.Unmarshal(msg.Params, &params)
+		switch params.Name {
+		case "echo":
+			result = callToolResult{Content: []contentBlock{{Type: "text", Text: fmt.Sprint(params.Arguments["message"])}}}
+		case "add":
+			a, _ := params.Arguments["a"].(float64)
+			b, _ := params.Arguments["b"].(float64)
+			result = callToolResult{
+				Content:           []contentBlock{{Type: "text", Text: fmt.Sprint(a + b)}},
+				StructuredContent: map[string]interface{}{"sum": a + b},
+			}
+		case "fail":
+			result = callToolResult{Content: []contentBlock{{Type: "text", Text: "something went wrong"}}, IsError: true}
+		default:
+			return &rpcMessage{JSONRPC: "2.0", ID: msg.ID, Error: &rpcError{Code: -32602, Message: "unknown tool: " + params.Name}}
+		}
+	default:
+		return &rpcMessage{JSONRPC: "2.0", ID: msg.ID, Error: &rpcError{Code: codeMethodNotFound, Message: "method not found"}}
+	}
+
+	data, _ := json.Marshal(result)
+	return &rpcMessage{JSONRPC: "2.0", ID: msg.ID, Result: data}
+}
+
+func toolName(msg *rpcMessage) string {
+	var params callToolParams
+	json.Unmarshal(msg.Params, &params)
+	return params.Name
+}
+
+func startAdapter(t *testing.T, server ServerConfig) *ClientAdapter {
+	t.Helper()
+	server.Timeout = 5 * time.Second
+
+	adapter := NewClientAdapter([]ServerConfig{server})
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	adapter.Start(ctx)
+	t.Cleanup(func() { adapter.Close() })
+
+	if ctx.Err() != nil {
+		t.Fatalf("Timed out connecting to the fixture server: %v", adapter.GetStatus())
+	}
+	return adapter
+}
+
+// checkTools runs the fixture's tools through the adapter
+func checkTools(t *testing.T, adapter *ClientAdapter) {
+	t.Helper()
+	ctx := context.Background()
+
+	tools, err := adapter.GetAvailableTools(ctx)
+	if err != nil {
+		t.Fatalf("GetAvailableTools() error = %v", err)
+	}
+	if len(tools) != 4 {
+		t.Fatalf("Expected 4 tools across both pages, got %+v", tools)
+	}
+	echo, err := adapter.GetTool(ctx, "echo")
+	if err != nil {
+		t.Fatalf("GetTool() error = %v", err)
+	}
+	if echo.Type != "function" || echo.Function.Parameters["required"] == nil {
+		t.Errorf("Expected the input schema to become the parameters, got %+v", echo)
+	}
+
+	result, err := adapter.Execute(ctx, "echo", map[string]interface{}{"message": "hello"})
+	if err != nil {
+		t.Fatalf("Execute(echo) error = %v", err)
+	}
+	if !result.Success || result.Data != "hello" {
+		t.Errorf("Expected echo to return hello, got %+v", result)
+	}
+
+	result, err = adapter.Execute(ctx, "add", map[string]interface{}{"a": 2, "b": 3})
+	if err != nil {
+		t.Fatalf("Execute(add) error = %v", err)
+	}
+	if sum, _ := result.Data.(map[string]interface{}); sum["sum"] != 5.0 {
+		t.Errorf("Expected structured content to become the data, got %+v", result)
+	}
+
+	result, err = adapter.Execute(ctx, "fail", nil)
+	if err != nil {
+		t.Fatalf("Execute(fail) error = %v", err)
+	}
+	if result.Success || result.Error != "something went wrong" {
+		t.Errorf("Expected a failed result, got %+v", result)
+	}
+
+	if err := adapter.Ping(ctx); err != nil {
+		t.Errorf("Ping() error = %v", err)
+	}
+}
+
+func TestClientAdapter_Stdio(t *testing.T) {
+	executable, err := os.Executable()
+	if err != nil {
+		t.Fatalf("os.Executable() error = %v", err)
+	}
+
+	adapter := startAdapter(t, ServerConfig{
+		Name:      "fixture",
+		Transport: TransportStdio,
+		Command:   executable,
+		Args:      []string{"-test.run=^$"},
+		Env:       map[string]string{fixtureServerEnv: "1"},
+	})
+	checkTools(t, adapter)
+
+	// The server exits mid-call, and is restarted
+	if _, err := adapter.Execute(context.Background(), "crash", nil); err == nil {
+		t.Fatal("Expected an error when the server exits during a call")
+	}
+
+	deadline := time.Now().Add(10 * time.Second)
+	for adapter.clients[0].status()["restarts"] != 1 || adapter.clients[0].connection() == nil {
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for the server to restart: %v", adapter.GetStatus())
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	checkTools(t, adapter)
+}
+
+func TestClientAdapter_HTTP(t *testing.T) {
+	const sessionID = "session-1"
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodDelete {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		var msg rpcMessage
+		if err := json.NewDecoder(r.Body).Decode(&msg); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		if msg.Method == "initialize" {
+			w.Header().Set(headerSessionID, sessionID)
+		} else if r.Header.Get(headerSessionID) != sessionID || r.Header.Get(headerProtocolVersion) != protocolVersion {
+			http.Error(w, "missing session", http.StatusBadRequest)
+			return
+		}
+
+		reply := fixtureReply(&msg)
+		if reply == nil {
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+
+		if msg.Method != "tools/call" {
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(reply)
+			return
+		}
+
+		// Tool calls answer over an event stream, after a notification
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "event: message\ndata: {\"jsonrpc\":\"2.0\",\"method\":\"notifications/progress\",\"params\":{}}\n\n")
+		data, _ := json.Marshal(reply)
+		fmt.Fprintf(w, "event: message\ndata: %s\n\n", data)
+	}))
+	defer server.Close()
+
+	adapter := startAdapter(t, ServerConfig{
+		Name:      "fixture",
+		Transport: TransportHTTP,
+		URL:       server.URL,
+		Headers:   map[string]string{"Authorization": "Bearer secret"},
+	})
+	checkTools(t, adapter)
+}
@@ -0,0 +1,280 @@
+package mcp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	headerSessionID       = "Mcp-Session-Id"
+	headerProtocolVersion = "MCP-Protocol-Version"
+)
+
+// httpTransport talks to an MCP server over the streamable HTTP transport: every message is POSTed
+// to one endpoint, and responses come back as JSON or as a server-sent event stream
+type httpTransport struct {
+	name     string
+	url      string
+	headers  map[string]string
+	client   *http.Client
+	onNotify notificationHandler
+
+	nextID          atomic.Int64
+	mu              sync.RWMutex
+	sessionID       string // Assigned by the server during initialization, if it keeps sessions
+	protocolVersion string // Negotiated during initialization
+
+	closed    chan struct{}
+	closeOnce sync.Once
+	err       error
+}
+
+func newHTTPTransport(name, url string, headers map[string]string, onNotify notificationHandler) *httpTransport {
+	return &httpTransport{
+		name:     name,
+		url:      url,
+		headers:  headers,
+		client:   &http.Client{},
+		onNotify: onNotify,
+		closed:   make(chan struct{}),
+	}
+}
+
+func (t *httpTransport) call(ctx context.Context, method string, params, result interface{}) error {
+	select {
+	case <-t.closed:
+		return t.err
+	default:
+	}
+
+	id := t.nextID.Add(1)
+	msg, err := newRequest(id, method, params)
+	if err != nil {
+		return err
+	}
+
+	resp, err := t.post(ctx, msg)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if method == "initialize" {
+		t.mu.Lock()
+		t.sessionID = resp.Header.Get(headerSessionID)
+		t.mu.Unlock()
+	}
+
+	reply, err := t.readResponse(ctx, resp, msg.ID)
+	if err != nil {
+		return fmt.Errorf("failed to read %s response from MCP server %s: %w", method, t.name, err)
+	}
+	if reply.Error != nil {
+		return reply.Error
+	}
+
+	if method == "initialize" {
+		var init initializeResult
+		if err := json.Unmarshal(reply.Result, &init); err == nil {
+			t.mu.Lock()
+			t.protocolVersion = init.ProtocolVersion
+			t.mu.Unlock()
+		}
+	}
+
+	if result == nil {
+		return nil
+	}
+	if err := json.Unmarshal(reply.Result, result); err != nil {
+		return fmt.Errorf("failed to decode %s result: %w", method, err)
+	}
+	return nil
+}
+
+func (t *httpTransport) notify(ctx context.Context, method string, params interface{}) error {
+	msg, err := newRequest(0, method, params)
+	if err != nil {
+		return err
+	}
+
+	resp, err := t.post(ctx, msg)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// post sends a message. A 404 for an established session means the server dropped it,
+// which closes the transport so the client reconnects.
+func (t *httpTransport) post(ctx context.Context, msg *rpcMessage) (*http.Response, error) {
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal message: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json, text/event-stream")
+	sessionID := t.setHeaders(req)
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach MCP server %s: %w", t.name, err)
+	}
+
+	if resp.StatusCode == http.StatusNotFound && sessionID != "" {
+		resp.Body.Close()
+		t.shutdown(fmt.Errorf("MCP server %s ended session %s", t.name, sessionID))
+		return nil, t.err
+	}
+	if resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("MCP server %s returned %s: %s", t.name, resp.Status, strings.TrimSpace(string(detail)))
+	}
+
+	return resp, nil
+}
+
+// setHeaders adds the configured, session and protocol headers, returning the session ID
+func (t *httpTransport) setHeaders(req *http.Request) string {
+	for key, value := range t.headers {
+		req.Header.Set(key, value)
+	}
+
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	if t.sessionID != "" {
+		req.Header.Set(headerSessionID, t.sessionID)
+	}
+	if t.protocolVersion != "" {
+		req.Header.Set(headerProtocolVersion, t.protocolVersion)
+	}
+	return t.sessionID
+}
+
+// readResponse returns the response to the request with the given ID, reading an event stream
+// until it arrives
+func (t *httpTransport) readResponse(ctx context.Context, resp *http.Response, id json.RawMessage) (*rpcMessage, error) {
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if mediaType != "text/event-stream" {
+		var msg rpcMessage
+		if err := json.NewDecoder(io.LimitReader(resp.Body, maxMessageSize)).Decode(&msg); err != nil {
+			return nil, err
+		}
+		return &msg, nil
+	}
+
+	reader := bufio.NewReader(resp.Body)
+	var data bytes.Buffer
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, err
+		}
+		line = strings.TrimRight(line, "\r\n")
+
+		switch {
+		case strings.HasPrefix(line, "data:"):
+			data.WriteString(strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+			if data.Len() > maxMessageSize {
+				return nil, fmt.Errorf("event larger than %d bytes", maxMessageSize)
+			}
+		case line == "" && data.Len() > 0:
+			// End of an event
+			var msg rpcMessage
+			if err := json.Unmarshal(data.Bytes(), &msg); err != nil {
+				return nil, fmt.Errorf("invalid event: %w", err)
+			}
+			data.Reset()
+
+			if msg.isResponse() && bytes.Equal(msg.ID, id) {
+				return &msg, nil
+			}
+			t.handleServerMessage(ctx, &msg)
+		}
+	}
+}
+
+// handleServerMessage handles notifications and requests the server interleaves with a response
+func (t *httpTransport) handleServerMessage(ctx context.Context, msg *rpcMessage) {
+	if len(msg.ID) == 0 {
+		if t.onNotify != nil {
+			t.onNotify(msg.Method)
+		}
+		return
+	}
+	if msg.isResponse() {
+		return
+	}
+
+	reply := &rpcMessage{JSONRPC: "2.0", ID: msg.ID}
+	if msg.Method == "ping" {
+		reply.Result = json.RawMessage("{}")
+	} else {
+		reply.Error = &rpcError{Code: codeMethodNotFound, Message: "method not supported by client: " + msg.Method}
+	}
+	resp, err := t.post(ctx, reply)
+	if err != nil {
+		log.Printf("Failed to answer %s from MCP server %s: %v", msg.Method, t.name, err)
+		return
+	}
+	resp.Body.Close()
+}
+
+func (t *httpTransport) done() <-chan struct{} {
+	return t.closed
+}
+
+func (t *httpTransport) shutdown(err error) {
+	t.closeOnce.Do(func() {
+		t.err = err
+		close(t.closed)
+	})
+}
+
+// close ends the session on the server, if there is one
+func (t *httpTransport) close() error {
+	t.shutdown(errTransportClosed)
+
+	t.mu.RLock()
+	sessionID := t.sessionID
+	t.mu.RUnlock()
+	if sessionID == "" {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownGrace)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, t.url, nil)
+	if err != nil {
+		return err
+	}
+	t.setHeaders(req)
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to end session with MCP server %s: %w", t.name, err)
+	}
+	resp.Body.Close()
+	return nil
+}
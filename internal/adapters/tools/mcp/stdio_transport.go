@@ -0,0 +1,269 @@
+package mcp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// maxMessageSize bounds a single newline-delimited message from a stdio server
+	maxMessageSize = 16 * 1024 * 1024
+
+	// shutdownGrace is how long a stdio server may take to exit after its input is closed
+	shutdownGrace = 2 * time.Second
+)
+
+// errTransportClosed is returned for calls on a closed connection
+var errTransportClosed = errors.New("MCP connection is closed")
+
+// transport carries JSON-RPC messages to one MCP server
+type transport interface {
+	// call sends a request and decodes its result into result
+	call(ctx context.Context, method string, params, result interface{}) error
+
+	// notify sends a notification, which has no response
+	notify(ctx context.Context, method string, params interface{}) error
+
+	// done is closed once the connection can no longer be used, e.g. when the server exits
+	done() <-chan struct{}
+
+	close() error
+}
+
+// notificationHandler receives the methods of notifications sent by a server
+type notificationHandler func(method string)
+
+// stdioTransport runs an MCP server as a subprocess, exchanging newline-delimited JSON over its stdin and stdout
+type stdioTransport struct {
+	name     string
+	cmd      *exec.Cmd
+	stdin    io.WriteCloser
+	writeMu  sync.Mutex
+	onNotify notificationHandler
+
+	nextID  atomic.Int64
+	pending map[int64]chan *rpcMessage
+	mu      sync.Mutex
+
+	closed    chan struct{}
+	closeOnce sync.Once
+	err       error // Why the connection closed
+}
+
+// startStdio launches the server process
+func startStdio(name, command string, args []string, env map[string]string, onNotify notificationHandler) (*stdioTransport, error) {
+	cmd := exec.Command(command, args...)
+	cmd.Env = os.Environ()
+	for key, value := range env {
+		cmd.Env = append(cmd.Env, key+"="+value)
+	}
+	cmd.Stderr = &logWriter{prefix: fmt.Sprintf("MCP server %s: ", name)}
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open stdin of MCP server %s: %w", name, err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open stdout of MCP server %s: %w", name, err)
+	}
+
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start MCP server %s: %w", name, err)
+	}
+
+	t := &stdioTransport{
+		name:     name,
+		cmd:      cmd,
+		stdin:    stdin,
+		onNotify: onNotify,
+		pending:  make(map[int64]chan *rpcMessage),
+		closed:   make(chan struct{}),
+	}
+	go t.readLoop(stdout)
+
+	return t, nil
+}
+
+// readLoop dispatches messages from the server until its output ends, then reaps the process
+func (t *stdioTransport) readLoop(stdout io.Reader) {
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 64*1024), maxMessageSize)
+
+	for scanner.Scan() {
+		var msg rpcMessage
+		if err := json.Unmarshal(scanner.Bytes(), &msg); err != nil {
+			log.Printf("MCP server %s sent an invalid message: %v", t.name, err)
+			continue
+		}
+		t.dispatch(&msg)
+	}
+
+	err := scanner.Err()
+	if waitErr := t.cmd.Wait(); err == nil {
+		err = waitErr
+	}
+	if err == nil {
+		err = errors.New("process exited")
+	}
+	t.shutdown(fmt.Errorf("MCP server %s stopped: %w", t.name, err))
+}
+
+func (t *stdioTransport) dispatch(msg *rpcMessage) {
+	switch {
+	case msg.isResponse():
+		id, err := strconv.ParseInt(string(msg.ID), 10, 64)
+		if err != nil {
+			log.Printf("MCP server %s answered unknown request %s", t.name, msg.ID)
+			return
+		}
+		t.mu.Lock()
+		ch, ok := t.pending[id]
+		delete(t.pending, id)
+		t.mu.Unlock()
+		if ok {
+			ch <- msg
+		}
+
+	case len(msg.ID) > 0:
+		// Requests from the server: only ping is supported
+		reply := &rpcMessage{JSONRPC: "2.0", ID: msg.ID}
+		if msg.Method == "ping" {
+			reply.Result = json.RawMessage("{}")
+		} else {
+			reply.Error = &rpcError{Code: codeMethodNotFound, Message: "method not supported by client: " + msg.Method}
+		}
+		if err := t.write(reply); err != nil {
+			log.Printf("Failed to answer %s from MCP server %s: %v", msg.Method, t.name, err)
+		}
+
+	default:
+		if t.onNotify != nil {
+			t.onNotify(msg.Method)
+		}
+	}
+}
+
+func (t *stdioTransport) write(msg *rpcMessage) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("failed to marshal message: %w", err)
+	}
+
+	t.writeMu.Lock()
+	defer t.writeMu.Unlock()
+
+	if _, err := t.stdin.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write to MCP server %s: %w", t.name, err)
+	}
+	return nil
+}
+
+func (t *stdioTransport) call(ctx context.Context, method string, params, result interface{}) error {
+	id := t.nextID.Add(1)
+	msg, err := newRequest(id, method, params)
+	if err != nil {
+		return err
+	}
+
+	ch := make(chan *rpcMessage, 1)
+	t.mu.Lock()
+	t.pending[id] = ch
+	t.mu.Unlock()
+
+	if err := t.write(msg); err != nil {
+		t.forget(id)
+		return err
+	}
+
+	select {
+	case resp := <-ch:
+		if resp.Error != nil {
+			return resp.Error
+		}
+		if result == nil {
+			return nil
+		}
+		if err := json.Unmarshal(resp.Result, result); err != nil {
+			return fmt.Errorf("failed to decode %s result: %w", method, err)
+		}
+		return nil
+
+	case <-ctx.Done():
+		t.forget(id)
+		t.notify(context.Background(), "notifications/cancelled", cancelledParams{RequestID: id, Reason: ctx.Err().Error()})
+		return ctx.Err()
+
+	case <-t.closed:
+		return t.err
+	}
+}
+
+func (t *stdioTransport) forget(id int64) {
+	t.mu.Lock()
+	delete(t.pending, id)
+	t.mu.Unlock()
+}
+
+func (t *stdioTransport) notify(ctx context.Context, method string, params interface{}) error {
+	msg, err := newRequest(0, method, params)
+	if err != nil {
+		return err
+	}
+	return t.write(msg)
+}
+
+func (t *stdioTransport) done() <-chan struct{} {
+	return t.closed
+}
+
+func (t *stdioTransport) shutdown(err error) {
+	t.closeOnce.Do(func() {
+		t.err = err
+		close(t.closed)
+	})
+}
+
+// close asks the server to exit by closing its input, killing it if it doesn't
+func (t *stdioTransport) close() error {
+	t.stdin.Close()
+
+	select {
+	case <-t.closed:
+	case <-time.After(shutdownGrace):
+		t.cmd.Process.Kill()
+		<-t.closed
+	}
+	return nil
+}
+
+// logWriter logs each line a server writes to stderr
+type logWriter struct {
+	prefix string
+	buf    []byte
+}
+
+func (w *logWriter) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			break
+		}
+		log.Printf("%s%s", w.prefix, w.buf[:i])
+		w.buf = w.buf[i+1:]
+	}
+	return len(p), nil
+}
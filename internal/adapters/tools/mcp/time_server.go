@@ -3,131 +3,67 @@ package mcp
 import (
 	"context"
 	"fmt"
+	"slices"
 	"time"
 
-	"github.com/username/hexarag/internal/domain/ports"
+	"github.com/username/hexarag/pkg/toolkit"
 )
 
-// TimeServerAdapter implements a simple MCP-compatible time server
+// TimeServerAdapter implements a simple MCP-compatible time server. Its tools are written with
+// the toolkit package and serve as the reference example for typed tools.
 type TimeServerAdapter struct {
+	*toolkit.Toolkit
 	enabled   bool
 	timezones []string
 }
 
-// NewTimeServerAdapter creates a new MCP time server adapter
-func NewTimeServerAdapter(enabled bool, timezones []string) *TimeServerAdapter {
-	if len(timezones) == 0 {
-		timezones = []string{"UTC"}
-	}
-
-	return &TimeServerAdapter{
-		enabled:   enabled,
-		timezones: timezones,
-	}
+// currentTimeArgs are the arguments of get_current_time
+type currentTimeArgs struct {
+	Format string `json:"format,omitempty" jsonschema:"enum=iso|unix|human,default=iso" description:"Time format (optional): 'iso', 'unix', 'human'"`
 }
 
-// Execute runs a tool with the given arguments
-func (t *TimeServerAdapter) Execute(ctx context.Context, name string, arguments map[string]interface{}) (*ports.ToolResult, error) {
-	if !t.enabled {
-		return &ports.ToolResult{
-			Success: false,
-			Error:   "MCP time server is disabled",
-		}, nil
-	}
+// timezoneTimeArgs are the arguments of get_time_in_timezone
+type timezoneTimeArgs struct {
+	Timezone string `json:"timezone" jsonschema:"required" description:"Timezone name (e.g., 'America/New_York', 'Europe/London', 'UTC')"`
+	Format   string `json:"format,omitempty" jsonschema:"enum=iso|unix|human,default=iso" description:"Time format (optional): 'iso', 'unix', 'human'"`
+}
 
-	switch name {
-	case "get_current_time":
-		return t.getCurrentTime(arguments)
-	case "get_time_in_timezone":
-		return t.getTimeInTimezone(arguments)
-	case "list_supported_timezones":
-		return t.listSupportedTimezones()
-	default:
-		return &ports.ToolResult{
-			Success: false,
-			Error:   fmt.Sprintf("unknown tool: %s", name),
-		}, nil
-	}
+// timeResult is the time reported by the time tools
+type timeResult struct {
+	Timestamp interface{} `json:"timestamp"` // A string, or seconds for the unix format
+	Timezone  string      `json:"timezone"`
+	UTCOffset string      `json:"utc_offset"`
+	IsDST     *bool       `json:"is_dst,omitempty"` // Only reported for a requested timezone
 }
 
-// GetAvailableTools returns the list of available tools
-func (t *TimeServerAdapter) GetAvailableTools(ctx context.Context) ([]ports.Tool, error) {
-	if !t.enabled {
-		return []ports.Tool{}, nil
-	}
+// timezonesResult lists the configured timezones
+type timezonesResult struct {
+	Timezones []string `json:"timezones"`
+	Count     int      `json:"count"`
+}
 
-	tools := []ports.Tool{
-		{
-			Type: "function",
-			Function: ports.ToolFunction{
-				Name:        "get_current_time",
-				Description: "Get the current local system time",
-				Parameters: map[string]interface{}{
-					"type": "object",
-					"properties": map[string]interface{}{
-						"format": map[string]interface{}{
-							"type":        "string",
-							"description": "Time format (optional): 'iso', 'unix', 'human'",
-							"enum":        []string{"iso", "unix", "human"},
-							"default":     "iso",
-						},
-					},
-				},
-			},
-		},
-		{
-			Type: "function",
-			Function: ports.ToolFunction{
-				Name:        "get_time_in_timezone",
-				Description: "Get the current time in a specific timezone",
-				Parameters: map[string]interface{}{
-					"type": "object",
-					"properties": map[string]interface{}{
-						"timezone": map[string]interface{}{
-							"type":        "string",
-							"description": "Timezone name (e.g., 'America/New_York', 'Europe/London', 'UTC')",
-						},
-						"format": map[string]interface{}{
-							"type":        "string",
-							"description": "Time format (optional): 'iso', 'unix', 'human'",
-							"enum":        []string{"iso", "unix", "human"},
-							"default":     "iso",
-						},
-					},
-					"required": []string{"timezone"},
-				},
-			},
-		},
-		{
-			Type: "function",
-			Function: ports.ToolFunction{
-				Name:        "list_supported_timezones",
-				Description: "List all supported timezones configured for this server",
-				Parameters: map[string]interface{}{
-					"type":       "object",
-					"properties": map[string]interface{}{},
-				},
-			},
-		},
+// NewTimeServerAdapter creates a new MCP time server adapter. A disabled server offers no tools.
+func NewTimeServerAdapter(enabled bool, timezones []string) *TimeServerAdapter {
+	if len(timezones) == 0 {
+		timezones = []string{"UTC"}
 	}
 
-	return tools, nil
-}
-
-// GetTool returns information about a specific tool
-func (t *TimeServerAdapter) GetTool(ctx context.Context, name string) (*ports.Tool, error) {
-	tools, err := t.GetAvailableTools(ctx)
-	if err != nil {
-		return nil, err
+	t := &TimeServerAdapter{
+		enabled:   enabled,
+		timezones: timezones,
 	}
 
-	for _, tool := range tools {
-		if tool.Function.Name == name {
-			return &tool, nil
+	var tools []*toolkit.Tool
+	if enabled {
+		tools = []*toolkit.Tool{
+			toolkit.Must(toolkit.NewTool("get_current_time", "Get the current local system time", t.getCurrentTime)),
+			toolkit.Must(toolkit.NewTool("get_time_in_timezone", "Get the current time in a specific timezone", t.getTimeInTimezone)),
+			toolkit.Must(toolkit.NewTool("list_supported_timezones", "List all supported timezones configured for this server", t.listSupportedTimezones)),
 		}
 	}
+	t.Toolkit = toolkit.Must(toolkit.New(tools...))
 
-	return nil, fmt.Errorf("tool not found: %s", name)
+	return t
 }
 
 // Ping checks tool connectivity
@@ -140,99 +76,47 @@ func (t *TimeServerAdapter) Ping(ctx context.Context) error {
 
 // Tool implementation methods
 
-func (t *TimeServerAdapter) getCurrentTime(arguments map[string]interface{}) (*ports.ToolResult, error) {
-	format := t.getFormat(arguments)
+func (t *TimeServerAdapter) getCurrentTime(ctx context.Context, args currentTimeArgs) (timeResult, error) {
 	now := time.Now()
 
-	timeData := map[string]interface{}{
-		"timestamp":  t.formatTime(now, format),
-		"timezone":   now.Location().String(),
-		"utc_offset": now.Format("-07:00"),
-	}
-
-	return &ports.ToolResult{
-		Success: true,
-		Data:    timeData,
-		Metadata: map[string]interface{}{
-			"tool":   "get_current_time",
-			"format": format,
-		},
+	return timeResult{
+		Timestamp: t.formatTime(now, args.Format),
+		Timezone:  now.Location().String(),
+		UTCOffset: now.Format("-07:00"),
 	}, nil
 }
 
-func (t *TimeServerAdapter) getTimeInTimezone(arguments map[string]interface{}) (*ports.ToolResult, error) {
-	timezone, ok := arguments["timezone"].(string)
-	if !ok {
-		return &ports.ToolResult{
-			Success: false,
-			Error:   "timezone parameter is required and must be a string",
-		}, nil
-	}
-
+func (t *TimeServerAdapter) getTimeInTimezone(ctx context.Context, args timezoneTimeArgs) (timeResult, error) {
 	// Check if timezone is in our allowed list
-	if !t.isTimezoneSupported(timezone) {
-		return &ports.ToolResult{
-			Success: false,
-			Error:   fmt.Sprintf("timezone '%s' is not supported. Use list_supported_timezones to see available options", timezone),
-		}, nil
+	if !slices.Contains(t.timezones, args.Timezone) {
+		return timeResult{}, fmt.Errorf("timezone '%s' is not supported. Use list_supported_timezones to see available options", args.Timezone)
 	}
 
-	location, err := time.LoadLocation(timezone)
+	location, err := time.LoadLocation(args.Timezone)
 	if err != nil {
-		return &ports.ToolResult{
-			Success: false,
-			Error:   fmt.Sprintf("invalid timezone: %s", timezone),
-		}, nil
+		return timeResult{}, fmt.Errorf("invalid timezone: %s", args.Timezone)
 	}
 
-	format := t.getFormat(arguments)
 	now := time.Now().In(location)
+	isDST := now.IsDST()
 
-	timeData := map[string]interface{}{
-		"timestamp":  t.formatTime(now, format),
-		"timezone":   timezone,
-		"utc_offset": now.Format("-07:00"),
-		"is_dst":     now.IsDST(),
-	}
-
-	return &ports.ToolResult{
-		Success: true,
-		Data:    timeData,
-		Metadata: map[string]interface{}{
-			"tool":     "get_time_in_timezone",
-			"timezone": timezone,
-			"format":   format,
-		},
+	return timeResult{
+		Timestamp: t.formatTime(now, args.Format),
+		Timezone:  args.Timezone,
+		UTCOffset: now.Format("-07:00"),
+		IsDST:     &isDST,
 	}, nil
 }
 
-func (t *TimeServerAdapter) listSupportedTimezones() (*ports.ToolResult, error) {
-	timezoneData := map[string]interface{}{
-		"timezones": t.timezones,
-		"count":     len(t.timezones),
-	}
-
-	return &ports.ToolResult{
-		Success: true,
-		Data:    timezoneData,
-		Metadata: map[string]interface{}{
-			"tool": "list_supported_timezones",
-		},
+func (t *TimeServerAdapter) listSupportedTimezones(ctx context.Context, args struct{}) (timezonesResult, error) {
+	return timezonesResult{
+		Timezones: t.timezones,
+		Count:     len(t.timezones),
 	}, nil
 }
 
 // Helper methods
 
-func (t *TimeServerAdapter) getFormat(arguments map[string]interface{}) string {
-	if format, ok := arguments["format"].(string); ok {
-		switch format {
-		case "iso", "unix", "human":
-			return format
-		}
-	}
-	return "iso" // default
-}
-
 func (t *TimeServerAdapter) formatTime(timestamp time.Time, format string) interface{} {
 	switch format {
 	case "unix":
@@ -244,15 +128,6 @@ func (t *TimeServerAdapter) formatTime(timestamp time.Time, format string) inter
 	}
 }
 
-func (t *TimeServerAdapter) isTimezoneSupported(timezone string) bool {
-	for _, tz := range t.timezones {
-		if tz == timezone {
-			return true
-		}
-	}
-	return false
-}
-
 // GetStatus returns the current status of the time server
 func (t *TimeServerAdapter) GetStatus() map[string]interface{} {
 	status := map[string]interface{}{
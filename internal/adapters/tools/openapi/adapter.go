@@ -0,0 +1,273 @@
+// Package openapi exposes the operations of REST APIs described by OpenAPI 3 documents as tools
+package openapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/username/hexarag/internal/adapters/tools/registry"
+	"github.com/username/hexarag/internal/domain/ports"
+)
+
+const (
+	defaultTimeout          = 30 * time.Second
+	defaultMaxResponseBytes = 1 << 20
+)
+
+// Config describes an API whose operations are offered as tools
+type Config struct {
+	Name             string
+	Spec             string            // Path or http(s) URL of the OpenAPI 3 document, in JSON or YAML
+	BaseURL          string            // Overrides the document's first server URL
+	Operations       []string          // Operation IDs to expose; all operations when empty
+	Headers          map[string]string // Sent with every call, e.g. Authorization
+	Timeout          time.Duration     // Per call; defaults to 30 seconds
+	MaxResponseBytes int64             // Larger responses fail the call; defaults to 1 MiB
+}
+
+// Adapter implements the tool port on top of the operations of an OpenAPI document.
+// Each operation is a tool named after its operationId, taking its parameters, and its JSON
+// request body as "body", as arguments.
+type Adapter struct {
+	config     Config
+	client     *http.Client
+	baseURL    string
+	operations map[string]*operation
+	tools      []ports.Tool
+}
+
+// NewAdapter creates an adapter for an API. Call Load to read its document before use.
+func NewAdapter(config Config) *Adapter {
+	if config.Timeout <= 0 {
+		config.Timeout = defaultTimeout
+	}
+	if config.MaxResponseBytes <= 0 {
+		config.MaxResponseBytes = defaultMaxResponseBytes
+	}
+	return &Adapter{
+		config: config,
+		client: &http.Client{Timeout: config.Timeout},
+	}
+}
+
+// Load reads the OpenAPI document and builds a tool for each selected operation
+func (a *Adapter) Load(ctx context.Context) error {
+	doc, base, err := loadDocument(ctx, a.client, a.config.Spec)
+	if err != nil {
+		return err
+	}
+
+	baseURL := a.config.BaseURL
+	if baseURL == "" {
+		baseURL = serverURL(doc, base)
+	}
+	if baseURL == "" {
+		return fmt.Errorf("OpenAPI document has no servers; set a base URL")
+	}
+
+	// The registry prefixes tool names with the API's namespace, which counts towards the limit
+	maxName := maxToolNameLength - len(a.config.Name) - len(registry.Separator)
+	operations, err := parseOperations(doc, a.config.Operations, maxName)
+	if err != nil {
+		return err
+	}
+
+	names := make([]string, 0, len(operations))
+	for name := range operations {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	tools := make([]ports.Tool, 0, len(names))
+	for _, name := range names {
+		tools = append(tools, operations[name].tool)
+	}
+
+	a.baseURL = strings.TrimRight(baseURL, "/")
+	a.operations = operations
+	a.tools = tools
+	return nil
+}
+
+// Execute calls the operation behind a tool. HTTP error statuses are failed results carrying the response.
+func (a *Adapter) Execute(ctx context.Context, name string, arguments map[string]interface{}) (*ports.ToolResult, error) {
+	op, ok := a.operations[name]
+	if !ok {
+		return &ports.ToolResult{
+			Success: false,
+			Error:   fmt.Sprintf("unknown tool: %s", name),
+		}, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, a.config.Timeout)
+	defer cancel()
+
+	req, err := a.buildRequest(ctx, op, arguments)
+	if err != nil {
+		return &ports.ToolResult{Success: false, Error: err.Error()}, nil
+	}
+
+	metadata := map[string]interface{}{"method": op.method, "path": op.path}
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return &ports.ToolResult{
+			Success:  false,
+			Error:    fmt.Sprintf("request to %s %s failed: %v", op.method, op.path, err),
+			Metadata: metadata,
+		}, nil
+	}
+	defer resp.Body.Close()
+	metadata["status_code"] = resp.StatusCode
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, a.config.MaxResponseBytes+1))
+	if err != nil {
+		return &ports.ToolResult{
+			Success:  false,
+			Error:    fmt.Sprintf("failed to read response: %v", err),
+			Metadata: metadata,
+		}, nil
+	}
+	if int64(len(body)) > a.config.MaxResponseBytes {
+		return &ports.ToolResult{
+			Success:  false,
+			Error:    fmt.Sprintf("response exceeds %d bytes", a.config.MaxResponseBytes),
+			Metadata: metadata,
+		}, nil
+	}
+
+	data := decodeBody(resp.Header.Get("Content-Type"), body)
+	if resp.StatusCode >= 400 {
+		return &ports.ToolResult{
+			Success:  false,
+			Data:     data,
+			Error:    fmt.Sprintf("%s %s returned HTTP %d", op.method, op.path, resp.StatusCode),
+			Metadata: metadata,
+		}, nil
+	}
+	return &ports.ToolResult{Success: true, Data: data, Metadata: metadata}, nil
+}
+
+// buildRequest places the arguments in the operation's path, query, headers, cookies and body
+func (a *Adapter) buildRequest(ctx context.Context, op *operation, arguments map[string]interface{}) (*http.Request, error) {
+	path := op.path
+	query := url.Values{}
+	headers := http.Header{}
+	var cookies []*http.Cookie
+
+	for _, param := range op.parameters {
+		value, ok := arguments[param.name]
+		if !ok || value == nil {
+			if param.required {
+				return nil, fmt.Errorf("missing required parameter %s", param.name)
+			}
+			continue
+		}
+
+		switch param.in {
+		case "path":
+			path = strings.ReplaceAll(path, "{"+param.name+"}", url.PathEscape(formatValue(value)))
+		case "query":
+			if items, ok := value.([]interface{}); ok {
+				for _, item := range items {
+					query.Add(param.name, formatValue(item))
+				}
+			} else {
+				query.Set(param.name, formatValue(value))
+			}
+		case "header":
+			headers.Set(param.name, formatValue(value))
+		case "cookie":
+			cookies = append(cookies, &http.Cookie{Name: param.name, Value: formatValue(value)})
+		}
+	}
+
+	target := a.baseURL + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+
+	var body io.Reader
+	if value, ok := arguments[bodyProperty]; ok && op.hasBody {
+		data, err := json.Marshal(value)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode request body: %w", err)
+		}
+		body = bytes.NewReader(data)
+	} else if op.bodyRequired {
+		return nil, fmt.Errorf("missing required request body")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, op.method, target, body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	for name, values := range headers {
+		req.Header[name] = values
+	}
+	for _, cookie := range cookies {
+		req.AddCookie(cookie)
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for name, value := range a.config.Headers {
+		req.Header.Set(name, value)
+	}
+	return req, nil
+}
+
+// decodeBody returns JSON responses decoded and other responses as text
+func decodeBody(contentType string, body []byte) interface{} {
+	if len(body) == 0 {
+		return nil
+	}
+	if strings.Contains(contentType, "json") {
+		var decoded interface{}
+		if err := json.Unmarshal(body, &decoded); err == nil {
+			return decoded
+		}
+	}
+	return string(body)
+}
+
+// GetAvailableTools returns a tool for each selected operation
+func (a *Adapter) GetAvailableTools(ctx context.Context) ([]ports.Tool, error) {
+	return a.tools, nil
+}
+
+// GetTool returns the tool for one operation
+func (a *Adapter) GetTool(ctx context.Context, name string) (*ports.Tool, error) {
+	op, ok := a.operations[name]
+	if !ok {
+		return nil, fmt.Errorf("tool not found: %s", name)
+	}
+	tool := op.tool
+	return &tool, nil
+}
+
+// Ping checks that the document has been loaded
+func (a *Adapter) Ping(ctx context.Context) error {
+	if a.operations == nil {
+		return fmt.Errorf("OpenAPI document %s is not loaded", a.config.Spec)
+	}
+	return nil
+}
+
+// GetStatus returns where the API is and how many operations it exposes
+func (a *Adapter) GetStatus() map[string]interface{} {
+	return map[string]interface{}{
+		"spec":       a.config.Spec,
+		"base_url":   a.baseURL,
+		"operations": len(a.operations),
+		"loaded":     a.operations != nil,
+	}
+}
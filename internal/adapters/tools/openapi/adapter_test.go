@@ -0,0 +1,243 @@
+package openapi
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const usersSpec = `
+openapi: 3.0.3
+info:
+  title: Users
+  version: "1.0"
+servers:
+  - url: /api
+paths:
+  /users/{id}:
+    parameters:
+      - name: id
+        in: path
+        schema: {type: integer}
+    get:
+      operationId: getUser
+      summary: Get a user
+      parameters:
+        - name: fields
+          in: query
+          description: Fields to return
+          schema: {type: array, items: {type: string}}
+        - name: X-Request-Tag
+          in: header
+          schema: {type: string}
+      responses:
+        200:
+          description: The user
+  /users:
+    post:
+      operationId: createUser
+      requestBody:
+        required: true
+        content:
+          application/json:
+            schema:
+              $ref: '#/components/schemas/NewUser'
+      responses:
+        201:
+          description: Created
+  /export:
+    get:
+      operationId: exportUsers
+      responses:
+        200:
+          description: Every user
+  /slow:
+    get:
+      operationId: slowReport
+      responses:
+        200:
+          description: Eventually a report
+components:
+  schemas:
+    NewUser:
+      type: object
+      required: [name]
+      properties:
+        name: {type: string}
+        nickname: {type: string, nullable: true}
+`
+
+func newUsersServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/openapi.yaml", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(usersSpec))
+	})
+	mux.HandleFunc("/api/", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/api/users/7":
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"id":     7,
+				"fields": r.URL.Query()["fields"],
+				"tag":    r.Header.Get("X-Request-Tag"),
+			})
+		case r.Method == http.MethodPost && r.URL.Path == "/api/users":
+			var user map[string]interface{}
+			json.NewDecoder(r.Body).Decode(&user)
+			user["id"] = 8
+			w.WriteHeader(http.StatusCreated)
+			json.NewEncoder(w).Encode(user)
+		case r.URL.Path == "/api/export":
+			w.Write([]byte(`"` + strings.Repeat("x", 2048) + `"`))
+		case r.URL.Path == "/api/slow":
+			select {
+			case <-time.After(time.Second):
+			case <-r.Context().Done():
+			}
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"error": "no such user"}`))
+		}
+	})
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestAdapter_ExecutesOperations(t *testing.T) {
+	ctx := context.Background()
+	server := newUsersServer(t)
+
+	adapter := NewAdapter(Config{
+		Name:             "users",
+		Spec:             server.URL + "/openapi.yaml",
+		Headers:          map[string]string{"Authorization": "Bearer secret"},
+		Timeout:          100 * time.Millisecond,
+		MaxResponseBytes: 1024,
+	})
+	if err := adapter.Load(ctx); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	tools, _ := adapter.GetAvailableTools(ctx)
+	var names []string
+	for _, tool := range tools {
+		names = append(names, tool.Function.Name)
+	}
+	if want := []string{"createUser", "exportUsers", "getUser", "slowReport"}; !reflect.DeepEqual(names, want) {
+		t.Fatalf("Expected a tool per operation, got %v", names)
+	}
+
+	getUser, err := adapter.GetTool(ctx, "getUser")
+	if err != nil {
+		t.Fatalf("GetTool() error = %v", err)
+	}
+	params := getUser.Function.Parameters
+	if required := params["required"]; !reflect.DeepEqual(required, []string{"id"}) {
+		t.Errorf("Expected the path parameter to be required, got %v", required)
+	}
+	properties := params["properties"].(map[string]interface{})
+	if fields := properties["fields"].(map[string]interface{}); fields["description"] != "Fields to return" {
+		t.Errorf("Expected the parameter description on its schema, got %v", fields)
+	}
+
+	createUser, _ := adapter.GetTool(ctx, "createUser")
+	body := createUser.Function.Parameters["properties"].(map[string]interface{})["body"].(map[string]interface{})
+	nickname := body["properties"].(map[string]interface{})["nickname"].(map[string]interface{})
+	if !reflect.DeepEqual(nickname["type"], []interface{}{"string", "null"}) {
+		t.Errorf("Expected the referenced body schema with a nullable nickname, got %v", body)
+	}
+
+	result, err := adapter.Execute(ctx, "getUser", map[string]interface{}{
+		"id":            float64(7),
+		"fields":        []interface{}{"name", "email"},
+		"X-Request-Tag": "audit",
+	})
+	if err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	want := map[string]interface{}{"id": float64(7), "fields": []interface{}{"name", "email"}, "tag": "audit"}
+	if !result.Success || !reflect.DeepEqual(result.Data, want) || result.Metadata["status_code"] != http.StatusOK {
+		t.Errorf("Expected the user, got %+v", result)
+	}
+
+	result, _ = adapter.Execute(ctx, "createUser", map[string]interface{}{"body": map[string]interface{}{"name": "Ada"}})
+	if !result.Success || !reflect.DeepEqual(result.Data, map[string]interface{}{"id": float64(8), "name": "Ada"}) {
+		t.Errorf("Expected the created user, got %+v", result)
+	}
+
+	result, _ = adapter.Execute(ctx, "getUser", map[string]interface{}{"id": float64(9)})
+	if result.Success || !reflect.DeepEqual(result.Data, map[string]interface{}{"error": "no such user"}) {
+		t.Errorf("Expected the HTTP error to fail the call with its response, got %+v", result)
+	}
+
+	result, _ = adapter.Execute(ctx, "exportUsers", nil)
+	if result.Success || !strings.Contains(result.Error, "exceeds 1024 bytes") {
+		t.Errorf("Expected the oversized response to fail the call, got %+v", result)
+	}
+
+	result, _ = adapter.Execute(ctx, "slowReport", nil)
+	if result.Success || result.Metadata["status_code"] != nil {
+		t.Errorf("Expected the slow call to time out, got %+v", result)
+	}
+}
+
+func TestAdapter_SelectsOperations(t *testing.T) {
+	ctx := context.Background()
+	server := newUsersServer(t)
+
+	adapter := NewAdapter(Config{Name: "users", Spec: server.URL + "/openapi.yaml", Operations: []string{"getUser"}})
+	if err := adapter.Load(ctx); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if tools, _ := adapter.GetAvailableTools(ctx); len(tools) != 1 || tools[0].Function.Name != "getUser" {
+		t.Errorf("Expected only the selected operation, got %+v", tools)
+	}
+
+	// Calls without the configured auth header are rejected by the API
+	result, _ := adapter.Execute(ctx, "getUser", map[string]interface{}{"id": float64(7)})
+	if result.Success || result.Metadata["status_code"] != http.StatusUnauthorized {
+		t.Errorf("Expected the unauthenticated call to fail, got %+v", result)
+	}
+
+	missing := NewAdapter(Config{Name: "users", Spec: server.URL + "/openapi.yaml", Operations: []string{"deleteUser"}})
+	if err := missing.Load(ctx); err == nil {
+		t.Error("Expected an unknown operation to fail loading")
+	}
+}
+
+func TestParseOperations_FitsNamespacedNames(t *testing.T) {
+	doc := map[string]interface{}{
+		"paths": map[string]interface{}{
+			"/report": map[string]interface{}{
+				"get": map[string]interface{}{"operationId": strings.Repeat("report", 20)},
+			},
+		},
+	}
+
+	maxName := maxToolNameLength - len("users__")
+	operations, err := parseOperations(doc, nil, maxName)
+	if err != nil {
+		t.Fatalf("parseOperations() error = %v", err)
+	}
+	for name := range operations {
+		if len("users__"+name) != maxToolNameLength {
+			t.Errorf("Expected the namespaced name to be cut to %d characters, got %q", maxToolNameLength, name)
+		}
+	}
+	if len(operations) != 1 {
+		t.Errorf("Expected one operation, got %d", len(operations))
+	}
+}
@@ -0,0 +1,438 @@
+package openapi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/username/hexarag/internal/domain/ports"
+)
+
+// maxSpecBytes bounds the size of OpenAPI documents fetched over HTTP
+const maxSpecBytes = 10 << 20
+
+// maxRefDepth bounds $ref resolution, so recursive schemas end in an unconstrained schema
+const maxRefDepth = 8
+
+// bodyProperty is the tool argument carrying an operation's JSON request body
+const bodyProperty = "body"
+
+// methods lists the HTTP methods an OpenAPI path item may define operations for
+var methods = []string{"get", "put", "post", "delete", "patch", "head", "options"}
+
+// maxToolNameLength is the longest function name LLM function calling accepts
+const maxToolNameLength = 64
+
+// invalidNameChars are replaced in tool names, which LLM function calling restricts to [a-zA-Z0-9_-]
+var invalidNameChars = regexp.MustCompile(`[^a-zA-Z0-9_-]+`)
+
+// operation is an API operation exposed as a tool
+type operation struct {
+	method       string
+	path         string
+	parameters   []parameter
+	hasBody      bool
+	bodyRequired bool
+	tool         ports.Tool
+}
+
+// parameter is a path, query, header or cookie parameter of an operation
+type parameter struct {
+	name     string
+	in       string
+	required bool
+}
+
+// loadDocument reads an OpenAPI document from a file or an http(s) URL, in JSON or YAML,
+// returning it with the URL relative server URLs are resolved against
+func loadDocument(ctx context.Context, client *http.Client, location string) (map[string]interface{}, *url.URL, error) {
+	var data []byte
+	var base *url.URL
+
+	if strings.HasPrefix(location, "http://") || strings.HasPrefix(location, "https://") {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, location, nil)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to create request: %w", err)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to fetch OpenAPI document: %w", err)
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			return nil, nil, fmt.Errorf("failed to fetch OpenAPI document: HTTP %d", resp.StatusCode)
+		}
+		data, err = io.ReadAll(io.LimitReader(resp.Body, maxSpecBytes+1))
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read OpenAPI document: %w", err)
+		}
+		if len(data) > maxSpecBytes {
+			return nil, nil, fmt.Errorf("OpenAPI document exceeds %d bytes", maxSpecBytes)
+		}
+		base = resp.Request.URL
+	} else {
+		var err error
+		data, err = os.ReadFile(location)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read OpenAPI document: %w", err)
+		}
+	}
+
+	var raw interface{}
+	if err := yaml.Unmarshal(data, &raw); err != nil { // YAML is a superset of JSON
+		return nil, nil, fmt.Errorf("failed to parse OpenAPI document: %w", err)
+	}
+	doc, ok := normalize(raw).(map[string]interface{})
+	if !ok {
+		return nil, nil, fmt.Errorf("OpenAPI document is not an object")
+	}
+
+	version, _ := doc["openapi"].(string)
+	if !strings.HasPrefix(version, "3.") {
+		return nil, nil, fmt.Errorf("unsupported OpenAPI version %q: only OpenAPI 3 documents are supported", version)
+	}
+	return doc, base, nil
+}
+
+// normalize converts YAML mappings with non-string keys, such as response codes, into JSON objects
+func normalize(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, item := range v {
+			v[key] = normalize(item)
+		}
+		return v
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			m[fmt.Sprint(key)] = normalize(item)
+		}
+		return m
+	case []interface{}:
+		for i, item := range v {
+			v[i] = normalize(item)
+		}
+		return v
+	default:
+		return v
+	}
+}
+
+// serverURL returns the first server URL of a document, resolved against the document's own URL
+func serverURL(doc map[string]interface{}, base *url.URL) string {
+	servers, _ := doc["servers"].([]interface{})
+	if len(servers) == 0 {
+		return ""
+	}
+	server, _ := servers[0].(map[string]interface{})
+	rawURL, _ := server["url"].(string)
+
+	if base != nil {
+		if ref, err := url.Parse(rawURL); err == nil {
+			return base.ResolveReference(ref).String()
+		}
+	}
+	return rawURL
+}
+
+// parseOperations returns the document's operations by tool name, cut to maxName characters.
+// With selected operation IDs, only those are returned and each must exist.
+func parseOperations(doc map[string]interface{}, selected []string, maxName int) (map[string]*operation, error) {
+	want := make(map[string]bool, len(selected))
+	for _, id := range selected {
+		want[id] = true
+	}
+
+	paths, _ := doc["paths"].(map[string]interface{})
+	pathNames := make([]string, 0, len(paths))
+	for path := range paths {
+		pathNames = append(pathNames, path)
+	}
+	sort.Strings(pathNames) // Stable tool order
+
+	operations := make(map[string]*operation)
+	found := make(map[string]bool)
+	for _, path := range pathNames {
+		item, _ := resolve(doc, paths[path]).(map[string]interface{})
+		for _, method := range methods {
+			op, ok := item[method].(map[string]interface{})
+			if !ok {
+				continue
+			}
+
+			id, _ := op["operationId"].(string)
+			if len(want) > 0 && !want[id] {
+				continue
+			}
+			found[id] = true
+
+			parsed, err := parseOperation(doc, item, op, method, path, id, maxName)
+			if err != nil {
+				if len(want) > 0 {
+					return nil, err
+				}
+				log.Printf("Skipping OpenAPI operation %s %s: %v", strings.ToUpper(method), path, err)
+				continue
+			}
+			if _, exists := operations[parsed.tool.Function.Name]; exists {
+				return nil, fmt.Errorf("duplicate tool name %s for %s %s", parsed.tool.Function.Name, strings.ToUpper(method), path)
+			}
+			operations[parsed.tool.Function.Name] = parsed
+		}
+	}
+
+	for _, id := range selected {
+		if !found[id] {
+			return nil, fmt.Errorf("operation %s not found in OpenAPI document", id)
+		}
+	}
+	return operations, nil
+}
+
+// parseOperation describes an operation as a tool whose arguments are its parameters and JSON body
+func parseOperation(doc, item, op map[string]interface{}, method, path, id string, maxName int) (*operation, error) {
+	name := id
+	if name == "" {
+		name = method + "_" + path
+	}
+	name = strings.Trim(invalidNameChars.ReplaceAllString(name, "_"), "_")
+	if len(name) > maxName {
+		name = name[:maxName]
+	}
+
+	parsed := &operation{method: strings.ToUpper(method), path: path}
+	properties := make(map[string]interface{})
+	var required []string
+
+	// Operation parameters override path item parameters with the same name and location
+	params := make(map[string]map[string]interface{})
+	var order []string
+	for _, source := range []interface{}{item["parameters"], op["parameters"]} {
+		list, _ := source.([]interface{})
+		for _, raw := range list {
+			param, ok := resolve(doc, raw).(map[string]interface{})
+			if !ok {
+				continue
+			}
+			paramName, _ := param["name"].(string)
+			in, _ := param["in"].(string)
+			key := in + ":" + paramName
+			if _, seen := params[key]; !seen {
+				order = append(order, key)
+			}
+			params[key] = param
+		}
+	}
+
+	for _, key := range order {
+		param := params[key]
+		paramName, _ := param["name"].(string)
+		in, _ := param["in"].(string)
+		if paramName == "" || in == "" {
+			continue
+		}
+		if in == "header" && isReservedHeader(paramName) {
+			continue // Set by the HTTP client or by configuration
+		}
+
+		isRequired, _ := param["required"].(bool)
+		isRequired = isRequired || in == "path"
+		parsed.parameters = append(parsed.parameters, parameter{name: paramName, in: in, required: isRequired})
+
+		schema := map[string]interface{}{}
+		if raw, ok := param["schema"].(map[string]interface{}); ok {
+			schema = resolveSchema(doc, raw, 0)
+		}
+		if description, ok := param["description"].(string); ok && schema["description"] == nil {
+			schema["description"] = description
+		}
+		properties[paramName] = schema
+		if isRequired {
+			required = append(required, paramName)
+		}
+	}
+
+	if body, ok := resolve(doc, op["requestBody"]).(map[string]interface{}); ok {
+		schema, ok := jsonBodySchema(doc, body)
+		if !ok {
+			return nil, fmt.Errorf("request body has no JSON content")
+		}
+		if _, exists := properties[bodyProperty]; exists {
+			return nil, fmt.Errorf("parameter %q clashes with the request body argument", bodyProperty)
+		}
+		parsed.hasBody = true
+		parsed.bodyRequired, _ = body["required"].(bool)
+		properties[bodyProperty] = schema
+		if parsed.bodyRequired {
+			required = append(required, bodyProperty)
+		}
+	}
+
+	parameters := map[string]interface{}{
+		"type":       "object",
+		"properties": properties,
+	}
+	if len(required) > 0 {
+		parameters["required"] = required
+	}
+
+	parsed.tool = ports.Tool{
+		Type: "function",
+		Function: ports.ToolFunction{
+			Name:        name,
+			Description: describe(op, parsed.method, path),
+			Parameters:  parameters,
+		},
+	}
+	return parsed, nil
+}
+
+// jsonBodySchema returns the schema of a request body's JSON content
+func jsonBodySchema(doc, body map[string]interface{}) (map[string]interface{}, bool) {
+	content, _ := body["content"].(map[string]interface{})
+	mediaTypes := make([]string, 0, len(content))
+	for mediaType := range content {
+		mediaTypes = append(mediaTypes, mediaType)
+	}
+	sort.Strings(mediaTypes)
+
+	for _, mediaType := range mediaTypes {
+		if mediaType != "application/json" && !strings.HasSuffix(mediaType, "+json") {
+			continue
+		}
+		media, _ := content[mediaType].(map[string]interface{})
+		schema := map[string]interface{}{}
+		if raw, ok := media["schema"].(map[string]interface{}); ok {
+			schema = resolveSchema(doc, raw, 0)
+		}
+		if description, ok := body["description"].(string); ok && schema["description"] == nil {
+			schema["description"] = description
+		}
+		return schema, true
+	}
+	return nil, false
+}
+
+// describe builds a tool description from an operation's summary and description
+func describe(op map[string]interface{}, method, path string) string {
+	var parts []string
+	for _, key := range []string{"summary", "description"} {
+		if text, ok := op[key].(string); ok && strings.TrimSpace(text) != "" {
+			parts = append(parts, strings.TrimSpace(text))
+		}
+	}
+	if len(parts) == 0 {
+		return method + " " + path
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+// resolve follows a local $ref, returning other values unchanged
+func resolve(doc map[string]interface{}, value interface{}) interface{} {
+	for depth := 0; depth < maxRefDepth; depth++ {
+		m, ok := value.(map[string]interface{})
+		if !ok {
+			return value
+		}
+		ref, ok := m["$ref"].(string)
+		if !ok {
+			return value
+		}
+		value = lookup(doc, ref)
+	}
+	return nil
+}
+
+// lookup finds the value a local reference such as "#/components/schemas/User" points to
+func lookup(doc map[string]interface{}, ref string) interface{} {
+	if !strings.HasPrefix(ref, "#/") {
+		return nil // Only references within the document are supported
+	}
+
+	var current interface{} = doc
+	for _, token := range strings.Split(ref[2:], "/") {
+		token = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+		m, ok := current.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		current = m[token]
+	}
+	return current
+}
+
+// resolveSchema returns a copy of an OpenAPI schema with its references inlined, as JSON Schema.
+// OpenAPI 3.0's nullable becomes a "null" type.
+func resolveSchema(doc map[string]interface{}, schema map[string]interface{}, depth int) map[string]interface{} {
+	if ref, ok := schema["$ref"].(string); ok {
+		target, ok := lookup(doc, ref).(map[string]interface{})
+		if !ok || depth >= maxRefDepth {
+			return map[string]interface{}{}
+		}
+		return resolveSchema(doc, target, depth+1)
+	}
+
+	resolved := make(map[string]interface{}, len(schema))
+	for key, value := range schema {
+		resolved[key] = resolveSchemaValue(doc, value, depth)
+	}
+
+	if nullable, _ := resolved["nullable"].(bool); nullable {
+		if t, ok := resolved["type"].(string); ok {
+			resolved["type"] = []interface{}{t, "null"}
+		}
+		delete(resolved, "nullable")
+	}
+	return resolved
+}
+
+func resolveSchemaValue(doc map[string]interface{}, value interface{}, depth int) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		return resolveSchema(doc, v, depth)
+	case []interface{}:
+		items := make([]interface{}, len(v))
+		for i, item := range v {
+			items[i] = resolveSchemaValue(doc, item, depth)
+		}
+		return items
+	default:
+		return v
+	}
+}
+
+// isReservedHeader reports headers that tool arguments may not set
+func isReservedHeader(name string) bool {
+	switch strings.ToLower(name) {
+	case "accept", "content-type", "authorization", "host", "content-length":
+		return true
+	}
+	return false
+}
+
+// formatValue renders an argument as a path, query, header or cookie value
+func formatValue(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case nil:
+		return ""
+	default:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Sprint(v)
+		}
+		return string(data)
+	}
+}
@@ -0,0 +1,187 @@
+package registry
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/username/hexarag/internal/domain/ports"
+)
+
+// Separator joins a provider's namespace and a tool name, e.g. "time__get_current_time"
+const Separator = "__"
+
+// Provider kinds reported in ports.ToolInfo
+const (
+	KindBuiltin = "builtin"
+	KindMCP     = "mcp"
+	KindOpenAPI = "openapi"
+)
+
+// namespacePattern keeps namespaced tool names within what LLM function calling accepts.
+// The config package checks configured provider names against the same pattern.
+var namespacePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*(_[a-z0-9-]+)*$`)
+
+// Registry is a ToolPort that aggregates many providers. Each provider is registered under a
+// namespace that prefixes its tool names, so providers can't collide, and calls are routed
+// back to the provider that owns the tool.
+type Registry struct {
+	mu        sync.RWMutex
+	providers []*provider
+}
+
+type provider struct {
+	namespace string
+	kind      string
+	tools     ports.ToolPort
+}
+
+// NewRegistry creates an empty tool registry
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// Register adds a provider under a namespace of lowercase letters, digits, hyphens and single underscores
+func (r *Registry) Register(namespace, kind string, tools ports.ToolPort) error {
+	if !namespacePattern.MatchString(namespace) {
+		return fmt.Errorf("invalid tool namespace %q", namespace)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, p := range r.providers {
+		if p.namespace == namespace {
+			return fmt.Errorf("tool namespace %s is already registered", namespace)
+		}
+	}
+	r.providers = append(r.providers, &provider{namespace: namespace, kind: kind, tools: tools})
+	return nil
+}
+
+// Execute runs a namespaced tool on the provider that owns it
+func (r *Registry) Execute(ctx context.Context, name string, arguments map[string]interface{}) (*ports.ToolResult, error) {
+	p, toolName := r.resolve(name)
+	if p == nil {
+		return &ports.ToolResult{
+			Success: false,
+			Error:   fmt.Sprintf("unknown tool: %s", name),
+		}, nil
+	}
+
+	result, err := p.tools.Execute(ctx, toolName, arguments)
+	if err != nil {
+		return nil, fmt.Errorf("tool provider %s failed: %w", p.namespace, err)
+	}
+
+	if result.Metadata == nil {
+		result.Metadata = make(map[string]interface{})
+	}
+	result.Metadata["provider"] = p.namespace
+	return result, nil
+}
+
+// GetAvailableTools returns the namespaced tools of every provider.
+// A failing provider is logged and skipped so the others stay available.
+func (r *Registry) GetAvailableTools(ctx context.Context) ([]ports.Tool, error) {
+	infos, err := r.ListTools(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	tools := make([]ports.Tool, 0, len(infos))
+	for _, info := range infos {
+		tools = append(tools, info.Tool)
+	}
+	return tools, nil
+}
+
+// ListTools returns the namespaced tools of every provider with their provenance
+func (r *Registry) ListTools(ctx context.Context) ([]ports.ToolInfo, error) {
+	infos := []ports.ToolInfo{}
+	for _, p := range r.snapshot() {
+		tools, err := p.tools.GetAvailableTools(ctx)
+		if err != nil {
+			log.Printf("Warning: failed to list tools of provider %s: %v", p.namespace, err)
+			continue
+		}
+
+		for _, tool := range tools {
+			infos = append(infos, ports.ToolInfo{
+				Tool:         p.namespaced(tool),
+				Provider:     p.namespace,
+				ProviderKind: p.kind,
+				OriginalName: tool.Function.Name,
+			})
+		}
+	}
+	return infos, nil
+}
+
+// GetTool returns information about a specific namespaced tool
+func (r *Registry) GetTool(ctx context.Context, name string) (*ports.Tool, error) {
+	p, toolName := r.resolve(name)
+	if p == nil {
+		return nil, fmt.Errorf("tool not found: %s", name)
+	}
+
+	tool, err := p.tools.GetTool(ctx, toolName)
+	if err != nil {
+		return nil, err
+	}
+	namespaced := p.namespaced(*tool)
+	return &namespaced, nil
+}
+
+// Ping checks every provider
+func (r *Registry) Ping(ctx context.Context) error {
+	for _, p := range r.snapshot() {
+		if err := p.tools.Ping(ctx); err != nil {
+			return fmt.Errorf("tool provider %s: %w", p.namespace, err)
+		}
+	}
+	return nil
+}
+
+// GetStatus returns the status of providers that report one
+func (r *Registry) GetStatus() map[string]interface{} {
+	status := make(map[string]interface{})
+	for _, p := range r.snapshot() {
+		providerStatus := map[string]interface{}{"kind": p.kind}
+		if reporter, ok := p.tools.(interface{ GetStatus() map[string]interface{} }); ok {
+			providerStatus["status"] = reporter.GetStatus()
+		}
+		status[p.namespace] = providerStatus
+	}
+	return status
+}
+
+// resolve splits a namespaced name into its provider and the provider's tool name
+func (r *Registry) resolve(name string) (*provider, string) {
+	namespace, toolName, ok := strings.Cut(name, Separator)
+	if !ok {
+		return nil, ""
+	}
+
+	for _, p := range r.snapshot() {
+		if p.namespace == namespace {
+			return p, toolName
+		}
+	}
+	return nil, ""
+}
+
+func (r *Registry) snapshot() []*provider {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.providers
+}
+
+// namespaced returns a copy of the tool with its name prefixed by the provider's namespace
+func (p *provider) namespaced(tool ports.Tool) ports.Tool {
+	tool.Function.Name = p.namespace + Separator + tool.Function.Name
+	return tool
+}
@@ -0,0 +1,95 @@
+package registry
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/username/hexarag/internal/domain/ports"
+)
+
+// lookupTools offers one "lookup" tool that answers with its provider's name
+type lookupTools struct {
+	name string
+}
+
+func (l *lookupTools) Execute(ctx context.Context, name string, arguments map[string]interface{}) (*ports.ToolResult, error) {
+	if name != "lookup" {
+		return &ports.ToolResult{Success: false, Error: fmt.Sprintf("unknown tool: %s", name)}, nil
+	}
+	return &ports.ToolResult{Success: true, Data: l.name}, nil
+}
+
+func (l *lookupTools) GetAvailableTools(ctx context.Context) ([]ports.Tool, error) {
+	return []ports.Tool{{Type: "function", Function: ports.ToolFunction{Name: "lookup", Description: "Look something up"}}}, nil
+}
+
+func (l *lookupTools) GetTool(ctx context.Context, name string) (*ports.Tool, error) {
+	tools, _ := l.GetAvailableTools(ctx)
+	if name != "lookup" {
+		return nil, fmt.Errorf("tool not found: %s", name)
+	}
+	return &tools[0], nil
+}
+
+func (l *lookupTools) Ping(ctx context.Context) error {
+	return nil
+}
+
+func TestRegistry_NamespacesAndRoutes(t *testing.T) {
+	ctx := context.Background()
+	r := NewRegistry()
+	if err := r.Register("docs", KindBuiltin, &lookupTools{name: "docs"}); err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+	if err := r.Register("wiki", KindMCP, &lookupTools{name: "wiki"}); err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+
+	infos, err := r.ListTools(ctx)
+	if err != nil {
+		t.Fatalf("ListTools() error = %v", err)
+	}
+	if len(infos) != 2 || infos[0].Function.Name != "docs__lookup" || infos[1].Function.Name != "wiki__lookup" {
+		t.Fatalf("Expected both lookup tools under their namespaces, got %+v", infos)
+	}
+	if infos[1].Provider != "wiki" || infos[1].ProviderKind != KindMCP || infos[1].OriginalName != "lookup" {
+		t.Errorf("Expected provenance of the wiki tool, got %+v", infos[1])
+	}
+
+	result, err := r.Execute(ctx, "wiki__lookup", nil)
+	if err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	if result.Data != "wiki" || result.Metadata["provider"] != "wiki" {
+		t.Errorf("Expected the wiki provider to run the call, got %+v", result)
+	}
+
+	tool, err := r.GetTool(ctx, "docs__lookup")
+	if err != nil || tool.Function.Name != "docs__lookup" {
+		t.Errorf("GetTool() = %+v, %v", tool, err)
+	}
+
+	for _, name := range []string{"lookup", "other__lookup", "docs__missing"} {
+		result, err := r.Execute(ctx, name, nil)
+		if err != nil {
+			t.Fatalf("Execute(%s) error = %v", name, err)
+		}
+		if result.Success {
+			t.Errorf("Expected %s to fail, got %+v", name, result)
+		}
+	}
+}
+
+func TestRegistry_RejectsInvalidNamespaces(t *testing.T) {
+	r := NewRegistry()
+	if err := r.Register("docs", KindBuiltin, &lookupTools{}); err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+
+	for _, namespace := range []string{"docs", "", "Docs", "my__docs", "docs_", "a.b"} {
+		if err := r.Register(namespace, KindBuiltin, &lookupTools{}); err == nil {
+			t.Errorf("Expected namespace %q to be rejected", namespace)
+		}
+	}
+}
@@ -0,0 +1,333 @@
+// Package sandbox offers a code-execution tool that runs Starlark, a Python dialect, in an embedded interpreter
+package sandbox
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	starlarkjson "go.starlark.net/lib/json"
+	starlarkmath "go.starlark.net/lib/math"
+	"go.starlark.net/starlark"
+	"go.starlark.net/syntax"
+
+	"github.com/username/hexarag/internal/domain/ports"
+)
+
+// RunToolName is the name of the code-execution tool
+const RunToolName = "run_starlark"
+
+const (
+	defaultTimeout        = 5 * time.Second
+	defaultMaxSteps       = 10_000_000
+	defaultMaxAllocBytes  = 256 << 20
+	defaultMaxOutputBytes = 64 << 10
+	maxCodeBytes          = 64 << 10
+	workerGracePeriod     = 2 * time.Second // Allowed beyond the timeout for a worker to start and report
+	maxWorkerStderrBytes  = 4 << 10
+)
+
+// Config limits what a single run may use
+type Config struct {
+	Timeout        time.Duration // Wall-clock limit; defaults to 5 seconds
+	MaxSteps       uint64        // Interpreter steps, a proxy for CPU; defaults to 10 million
+	MaxAllocBytes  uint64        // Memory the program may hold; defaults to 256 MiB
+	MaxOutputBytes int           // Printed output kept; the rest is dropped; defaults to 64 KiB
+}
+
+// Adapter implements the tool port with a Starlark interpreter. Programs have no load statement,
+// filesystem, network or clock; they get the print, json and math builtins only. Each program runs
+// in a worker process, a copy of the current binary whose memory is limited by the operating system,
+// so the binary must call RunWorkerIfRequested.
+type Adapter struct {
+	config Config
+}
+
+// NewAdapter creates a code-execution adapter with the given limits
+func NewAdapter(config Config) *Adapter {
+	if config.Timeout <= 0 {
+		config.Timeout = defaultTimeout
+	}
+	if config.MaxSteps == 0 {
+		config.MaxSteps = defaultMaxSteps
+	}
+	if config.MaxAllocBytes == 0 {
+		config.MaxAllocBytes = defaultMaxAllocBytes
+	}
+	if config.MaxOutputBytes <= 0 {
+		config.MaxOutputBytes = defaultMaxOutputBytes
+	}
+	return &Adapter{config: config}
+}
+
+// fileOptions enables the Python features Starlark leaves out by default
+var fileOptions = &syntax.FileOptions{
+	Set:             true,
+	While:           true,
+	TopLevelControl: true,
+	GlobalReassign:  true,
+	Recursion:       true,
+}
+
+// Execute runs a program. Its result is the value of a lone expression, or else of its "result" global.
+func (a *Adapter) Execute(ctx context.Context, name string, arguments map[string]interface{}) (*ports.ToolResult, error) {
+	if name != RunToolName {
+		return &ports.ToolResult{
+			Success: false,
+			Error:   fmt.Sprintf("unknown tool: %s", name),
+		}, nil
+	}
+
+	code, _ := arguments["code"].(string)
+	if strings.TrimSpace(code) == "" {
+		return &ports.ToolResult{Success: false, Error: "code is required"}, nil
+	}
+	if len(code) > maxCodeBytes {
+		return &ports.ToolResult{Success: false, Error: fmt.Sprintf("code exceeds %d bytes", maxCodeBytes)}, nil
+	}
+
+	return a.run(ctx, code), nil
+}
+
+// run executes code in a worker process and reports the limit that stopped it, if any
+func (a *Adapter) run(ctx context.Context, code string) *ports.ToolResult {
+	input, err := json.Marshal(job{Code: code, Config: a.config})
+	if err != nil {
+		return &ports.ToolResult{Success: false, Error: fmt.Sprintf("failed to encode program: %v", err)}
+	}
+	executable, err := os.Executable()
+	if err != nil {
+		return &ports.ToolResult{Success: false, Error: fmt.Sprintf("failed to start sandbox: %v", err)}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, a.config.Timeout+workerGracePeriod)
+	defer cancel()
+
+	var stdout bytes.Buffer
+	stderr := &output{limit: maxWorkerStderrBytes}
+	cmd := exec.CommandContext(ctx, executable)
+	cmd.Env = append(os.Environ(), workerEnv+"=1")
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stdout = &stdout
+	cmd.Stderr = stderr
+
+	if err = cmd.Run(); err == nil {
+		var result *ports.ToolResult
+		if result, err = workerResult(stdout.Bytes()); err == nil {
+			return result
+		}
+	}
+
+	switch {
+	case strings.Contains(stderr.String(), "out of memory"):
+		return &ports.ToolResult{Success: false, Error: fmt.Sprintf("allocated more than %d bytes", a.config.MaxAllocBytes)}
+	case ctx.Err() != nil:
+		return &ports.ToolResult{Success: false, Error: fmt.Sprintf("timed out after %s", a.config.Timeout)}
+	default:
+		return &ports.ToolResult{Success: false, Error: fmt.Sprintf("sandbox failed: %v: %s", err, strings.TrimSpace(stderr.String()))}
+	}
+}
+
+// interpret executes code on a fresh thread within the step and time limits. It runs in a worker process.
+func interpret(code string, config Config) *ports.ToolResult {
+	stdout := &output{limit: config.MaxOutputBytes}
+	thread := &starlark.Thread{
+		Name:  RunToolName,
+		Print: func(_ *starlark.Thread, msg string) { stdout.writeLine(msg) },
+		Load: func(_ *starlark.Thread, module string) (starlark.StringDict, error) {
+			return nil, fmt.Errorf("load is not available in the sandbox")
+		},
+	}
+	thread.SetMaxExecutionSteps(config.MaxSteps)
+
+	timer := time.AfterFunc(config.Timeout, func() {
+		thread.Cancel(fmt.Sprintf("timed out after %s", config.Timeout))
+	})
+	defer timer.Stop()
+
+	predeclared := starlark.StringDict{
+		"json": starlarkjson.Module,
+		"math": starlarkmath.Module,
+	}
+
+	started := time.Now()
+	var value starlark.Value
+	var err error
+	if expr, parseErr := fileOptions.ParseExpr(RunToolName, code, 0); parseErr == nil {
+		value, err = starlark.EvalExprOptions(fileOptions, thread, expr, predeclared)
+	} else {
+		var globals starlark.StringDict
+		globals, err = starlark.ExecFileOptions(fileOptions, thread, RunToolName, code, predeclared)
+		value = globals["result"]
+	}
+
+	metadata := map[string]interface{}{
+		"steps":       thread.ExecutionSteps(),
+		"duration_ms": time.Since(started).Milliseconds(),
+	}
+	if stdout.truncated {
+		metadata["stdout_truncated"] = true
+	}
+	data := map[string]interface{}{"stdout": stdout.String()}
+
+	if err != nil {
+		var evalErr *starlark.EvalError
+		message := err.Error()
+		if errors.As(err, &evalErr) {
+			message = evalErr.Backtrace()
+		}
+		return &ports.ToolResult{Success: false, Data: data, Error: message, Metadata: metadata}
+	}
+
+	if value != nil {
+		data["result"] = toGo(value)
+	}
+	return &ports.ToolResult{Success: true, Data: data, Metadata: metadata}
+}
+
+// output collects printed lines, or any written bytes, up to a size limit
+type output struct {
+	strings.Builder
+	limit     int
+	truncated bool
+}
+
+func (o *output) writeLine(line string) {
+	if o.truncated {
+		return
+	}
+	if o.Len()+len(line)+1 > o.limit {
+		o.WriteString(line[:max(0, o.limit-o.Len())])
+		o.truncated = true
+		return
+	}
+	o.WriteString(line)
+	o.WriteByte('\n')
+}
+
+// Write keeps what fits within the limit, so a worker's stderr can't grow without bound
+func (o *output) Write(p []byte) (int, error) {
+	if room := o.limit - o.Len(); room > 0 {
+		o.Builder.Write(p[:min(len(p), room)])
+	}
+	return len(p), nil
+}
+
+// toGo converts a Starlark value to its JSON-compatible Go equivalent
+func toGo(value starlark.Value) interface{} {
+	switch v := value.(type) {
+	case starlark.NoneType:
+		return nil
+	case starlark.Bool:
+		return bool(v)
+	case starlark.Int:
+		if i, ok := v.Int64(); ok {
+			return i
+		}
+		// Beyond int64, keep every digit rather than round through a float
+		return v.BigInt().String()
+	case starlark.Float:
+		// JSON has no NaN or infinities
+		if math.IsNaN(float64(v)) || math.IsInf(float64(v), 0) {
+			return v.String()
+		}
+		return float64(v)
+	case starlark.String:
+		return string(v)
+	case *starlark.List:
+		return iterableToGo(v)
+	case starlark.Tuple:
+		return iterableToGo(v)
+	case *starlark.Set:
+		return iterableToGo(v)
+	case *starlark.Dict:
+		result := make(map[string]interface{}, v.Len())
+		for _, item := range v.Items() {
+			key, ok := starlark.AsString(item[0])
+			if !ok {
+				key = item[0].String()
+			}
+			result[key] = toGo(item[1])
+		}
+		return result
+	default:
+		return value.String()
+	}
+}
+
+func iterableToGo(iterable starlark.Iterable) []interface{} {
+	result := []interface{}{}
+	iter := iterable.Iterate()
+	defer iter.Done()
+	var item starlark.Value
+	for iter.Next(&item) {
+		result = append(result, toGo(item))
+	}
+	return result
+}
+
+// GetAvailableTools returns the code-execution tool
+func (a *Adapter) GetAvailableTools(ctx context.Context) ([]ports.Tool, error) {
+	return []ports.Tool{a.tool()}, nil
+}
+
+// GetTool returns the code-execution tool
+func (a *Adapter) GetTool(ctx context.Context, name string) (*ports.Tool, error) {
+	if name != RunToolName {
+		return nil, fmt.Errorf("tool not found: %s", name)
+	}
+	tool := a.tool()
+	return &tool, nil
+}
+
+func (a *Adapter) tool() ports.Tool {
+	return ports.Tool{
+		Type: "function",
+		Function: ports.ToolFunction{
+			Name: RunToolName,
+			Description: "Run a Starlark program (a Python dialect) and return its printed output and result. " +
+				"Use it for arithmetic, unit conversions, string and list processing, or anything else that must be computed exactly. " +
+				"The result is the value of the program if it is a single expression, otherwise of its \"result\" variable. " +
+				"Integers have arbitrary precision. Unlike Python there is no ** operator (use math.pow, or << for powers of two) and no sum builtin. " +
+				"The math and json modules are available; there are no imports, files, network or clock.",
+			Parameters: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"code": map[string]interface{}{
+						"type":        "string",
+						"description": "Starlark source, e.g. \"sorted([x * x % 7 for x in range(10)])\"",
+						"maxLength":   maxCodeBytes,
+					},
+				},
+				"required": []string{"code"},
+			},
+		},
+	}
+}
+
+// Ping checks that a worker process can be started and run a trivial program
+func (a *Adapter) Ping(ctx context.Context) error {
+	if result := a.run(ctx, "1"); !result.Success {
+		return fmt.Errorf("sandbox worker unavailable: %s", result.Error)
+	}
+	return nil
+}
+
+// GetStatus returns the limits applied to each run
+func (a *Adapter) GetStatus() map[string]interface{} {
+	return map[string]interface{}{
+		"language":         "starlark",
+		"timeout":          a.config.Timeout.String(),
+		"max_steps":        a.config.MaxSteps,
+		"max_alloc_bytes":  a.config.MaxAllocBytes,
+		"max_output_bytes": a.config.MaxOutputBytes,
+		"modules":          []string{"json", "math"},
+	}
+}
@@ -0,0 +1,96 @@
+package sandbox
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestMain lets the test binary serve as the sandbox's worker process
+func TestMain(m *testing.M) {
+	RunWorkerIfRequested()
+	os.Exit(m.Run())
+}
+
+func TestAdapter_RunsPrograms(t *testing.T) {
+	ctx := context.Background()
+	adapter := NewAdapter(Config{})
+
+	if err := adapter.Ping(ctx); err != nil {
+		t.Fatalf("Ping() error = %v", err)
+	}
+
+	result, err := adapter.Execute(ctx, RunToolName, map[string]interface{}{"code": "1 << 100"})
+	if err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	if !result.Success || result.Data.(map[string]interface{})["result"] != "1267650600228229401496703205376" {
+		t.Errorf("Expected the exact value of the expression, got %+v", result)
+	}
+
+	code := `
+def fib(n):
+    return n if n < 2 else fib(n - 1) + fib(n - 2)
+
+for i in range(3):
+    print("fib", i, fib(i))
+result = {"fib": [fib(n) for n in range(10)], "sqrt": math.sqrt(16), "json": json.decode("[1]")}
+`
+	result, _ = adapter.Execute(ctx, RunToolName, map[string]interface{}{"code": code})
+	got, _ := json.Marshal(result.Data)
+	want := `{"result":{"fib":[0,1,1,2,3,5,8,13,21,34],"json":[1],"sqrt":4},"stdout":"fib 0 0\nfib 1 1\nfib 2 1\n"}`
+	if !result.Success || string(got) != want {
+		t.Errorf("Expected the printed output and the result global, got %s", got)
+	}
+
+	result, _ = adapter.Execute(ctx, RunToolName, map[string]interface{}{"code": "(1 << 62) + 1"})
+	if got, _ := json.Marshal(result.Data); string(got) != `{"result":4611686018427387905,"stdout":""}` {
+		t.Errorf("Expected large integers to stay exact, got %s", got)
+	}
+
+	result, _ = adapter.Execute(ctx, RunToolName, map[string]interface{}{"code": "print('before')\n1 // 0"})
+	data := result.Data.(map[string]interface{})
+	if result.Success || !strings.Contains(result.Error, "division by zero") || data["stdout"] != "before\n" {
+		t.Errorf("Expected the error with the output printed before it, got %+v", result)
+	}
+
+	result, _ = adapter.Execute(ctx, RunToolName, map[string]interface{}{"code": `load("os.star", "system")`})
+	if result.Success || !strings.Contains(result.Error, "load is not available") {
+		t.Errorf("Expected load to fail, got %+v", result)
+	}
+}
+
+func TestAdapter_EnforcesLimits(t *testing.T) {
+	ctx := context.Background()
+
+	steps := NewAdapter(Config{MaxSteps: 1000})
+	result, _ := steps.Execute(ctx, RunToolName, map[string]interface{}{"code": "len([i for i in range(100000)])"})
+	if result.Success || !strings.Contains(result.Error, "too many steps") {
+		t.Errorf("Expected the step limit to stop the program, got %+v", result)
+	}
+
+	timeout := NewAdapter(Config{Timeout: 50 * time.Millisecond, MaxSteps: 1 << 40, MaxAllocBytes: 1 << 40})
+	started := time.Now()
+	result, _ = timeout.Execute(ctx, RunToolName, map[string]interface{}{"code": "while True:\n    pass"})
+	if result.Success || !strings.Contains(result.Error, "timed out") || time.Since(started) > 5*time.Second {
+		t.Errorf("Expected the timeout to stop the program, got %+v", result)
+	}
+
+	// Memory is limited for the program's process, whether it grows slowly or in one step
+	memory := NewAdapter(Config{MaxAllocBytes: 32 << 20, MaxSteps: 1 << 40})
+	for _, code := range []string{"x = []\nwhile True:\n    x.append('y' * 1024)", "len([0] * (1 << 29))"} {
+		result, _ = memory.Execute(ctx, RunToolName, map[string]interface{}{"code": code})
+		if result.Success || !strings.Contains(result.Error, "allocated more than") {
+			t.Errorf("Expected the memory limit to stop %q, got %+v", code, result)
+		}
+	}
+
+	output := NewAdapter(Config{MaxOutputBytes: 10})
+	result, _ = output.Execute(ctx, RunToolName, map[string]interface{}{"code": "for i in range(100):\n    print(i)"})
+	if !result.Success || len(result.Data.(map[string]interface{})["stdout"].(string)) != 10 || result.Metadata["stdout_truncated"] != true {
+		t.Errorf("Expected the output to be truncated, got %+v", result)
+	}
+}
@@ -0,0 +1,10 @@
+//go:build !unix
+
+package sandbox
+
+import "fmt"
+
+// limitMemory is unavailable without rlimits, so programs are not run at all
+func limitMemory(bytes uint64) error {
+	return fmt.Errorf("memory limits are not supported on this platform")
+}
@@ -0,0 +1,11 @@
+//go:build unix
+
+package sandbox
+
+import "syscall"
+
+// limitMemory caps the process's data segment, which holds the Go heap, so that allocating past
+// it fails and ends the process
+func limitMemory(bytes uint64) error {
+	return syscall.Setrlimit(syscall.RLIMIT_DATA, &syscall.Rlimit{Cur: bytes, Max: bytes})
+}
@@ -0,0 +1,70 @@
+package sandbox
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"runtime/debug"
+
+	"github.com/username/hexarag/internal/domain/ports"
+)
+
+// workerEnv marks a process started to run a single program
+const workerEnv = "HEXARAG_SANDBOX_WORKER"
+
+// workerOverheadBytes is the memory a worker needs besides the program's own: the Go runtime,
+// the interpreter and the encoded result
+const workerOverheadBytes = 64 << 20
+
+// job is the program a worker runs, with the limits to run it under
+type job struct {
+	Code   string `json:"code"`
+	Config Config `json:"config"`
+}
+
+// RunWorkerIfRequested runs the program it is given on stdin and exits, if the process was started
+// as a sandbox worker. Binaries that offer the code-execution tool must call it first thing in main.
+func RunWorkerIfRequested() {
+	if os.Getenv(workerEnv) != "1" {
+		return
+	}
+	if err := runWorker(os.Stdin, os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+// runWorker interprets one program within the job's memory budget and writes its result
+func runWorker(r io.Reader, w io.Writer) error {
+	var j job
+	if err := json.NewDecoder(r).Decode(&j); err != nil {
+		return fmt.Errorf("failed to read sandbox job: %w", err)
+	}
+
+	// Collect garbage hard near the budget, so only memory the program holds counts against it
+	debug.SetMemoryLimit(int64(j.Config.MaxAllocBytes))
+	if err := limitMemory(j.Config.MaxAllocBytes + workerOverheadBytes); err != nil {
+		return fmt.Errorf("failed to limit sandbox memory: %w", err)
+	}
+
+	result := interpret(j.Code, j.Config)
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		return fmt.Errorf("failed to write sandbox result: %w", err)
+	}
+	return nil
+}
+
+// workerResult decodes a worker's output, keeping numbers as written so large integers stay exact
+func workerResult(output []byte) (*ports.ToolResult, error) {
+	decoder := json.NewDecoder(bytes.NewReader(output))
+	decoder.UseNumber()
+
+	var result ports.ToolResult
+	if err := decoder.Decode(&result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
@@ -3,6 +3,7 @@ package websocket
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"log"
 	"net/http"
 	"sync"
@@ -10,6 +11,8 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/websocket"
+
+	"github.com/username/hexarag/internal/domain/ports"
 )
 
 // Event represents a real-time event to be broadcast
@@ -25,7 +28,8 @@ type Client struct {
 	Conn   *websocket.Conn
 	Send   chan Event
 	Hub    *Hub
-	Room   string // For targeting specific clients (e.g., "dev-dashboard")
+	Room   string          // For targeting specific clients (e.g., "dev-dashboard")
+	Events map[string]bool // Event types the client subscribed to, e.g. "tool_approval_requested"; guarded by Hub.mu
 }
 
 // Hub manages WebSocket connections and broadcasts
@@ -36,6 +40,7 @@ type Hub struct {
 	broadcast  chan Event
 	rooms      map[string]map[*Client]bool
 	mu         sync.RWMutex
+	messaging  ports.MessagingPort // Publishes requests from clients, such as cancellations
 }
 
 // WebSocketUpgrader configures the WebSocket upgrader
@@ -50,13 +55,14 @@ var upgrader = websocket.Upgrader{
 }
 
 // NewHub creates a new WebSocket hub
-func NewHub() *Hub {
+func NewHub(messaging ports.MessagingPort) *Hub {
 	return &Hub{
 		clients:    make(map[*Client]bool),
 		register:   make(chan *Client),
 		unregister: make(chan *Client),
 		broadcast:  make(chan Event),
 		rooms:      make(map[string]map[*Client]bool),
+		messaging:  messaging,
 	}
 }
 
@@ -163,6 +169,53 @@ func (h *Hub) Broadcast(event Event) {
 	h.broadcast <- event
 }
 
+// BroadcastToSubscribers sends an event to the clients subscribed to its type.
+// Clients too slow to take it miss the event.
+func (h *Hub) BroadcastToSubscribers(event Event) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	for client := range h.clients {
+		if !client.Events[event.Type] {
+			continue
+		}
+		select {
+		case client.Send <- event:
+		default:
+			log.Printf("Dropped %s event for slow WebSocket client %s", event.Type, client.ID)
+		}
+	}
+}
+
+// Start subscribes the hub to the tool approval events it pushes to subscribed clients
+func (h *Hub) Start(ctx context.Context) error {
+	subjects := map[string]string{
+		ports.SubjectToolApprovalRequested: "tool_approval_requested",
+		ports.SubjectToolApprovalResolved:  "tool_approval_resolved",
+	}
+
+	for subject, eventType := range subjects {
+		eventType := eventType
+		err := h.messaging.Subscribe(ctx, subject, func(ctx context.Context, subject string, data []byte) error {
+			var payload map[string]interface{}
+			if err := json.Unmarshal(data, &payload); err != nil {
+				return ports.Terminate(fmt.Errorf("failed to unmarshal %s event: %w", eventType, err)) // Malformed payloads never succeed
+			}
+
+			h.BroadcastToSubscribers(Event{
+				Type:      eventType,
+				Data:      payload,
+				Timestamp: time.Now(),
+			})
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("failed to subscribe to %s: %w", subject, err)
+		}
+	}
+	return nil
+}
+
 // GetStats returns connection statistics
 func (h *Hub) GetStats() map[string]interface{} {
 	h.mu.RLock()
@@ -195,11 +248,12 @@ func (h *Hub) HandleWebSocket(c *gin.Context) {
 
 	clientID := generateClientID()
 	client := &Client{
-		ID:   clientID,
-		Conn: conn,
-		Send: make(chan Event, 256),
-		Hub:  h,
-		Room: room,
+		ID:     clientID,
+		Conn:   conn,
+		Send:   make(chan Event, 256),
+		Hub:    h,
+		Room:   room,
+		Events: make(map[string]bool),
 	}
 
 	client.Hub.register <- client
@@ -300,8 +354,59 @@ func (c *Client) handleMessage(msg map[string]interface{}) {
 		// Handle subscription to specific event types
 		if eventTypes, ok := msg["events"].([]interface{}); ok {
 			log.Printf("Client %s subscribed to events: %v", c.ID, eventTypes)
-			// Store subscription preferences (implement as needed)
+			c.Hub.mu.Lock()
+			for _, eventType := range eventTypes {
+				if name, ok := eventType.(string); ok {
+					c.Events[name] = true
+				}
+			}
+			c.Hub.mu.Unlock()
+		}
+
+	case "cancel":
+		// Stop generating responses in a conversation
+		c.cancelGeneration(msg)
+	}
+}
+
+// cancelGeneration publishes a cancellation for a conversation; message_id limits it to
+// the reply to one message
+func (c *Client) cancelGeneration(msg map[string]interface{}) {
+	conversationID, _ := msg["conversation_id"].(string)
+	messageID, _ := msg["message_id"].(string)
+	reason, _ := msg["reason"].(string)
+
+	if conversationID == "" {
+		c.Send <- Event{
+			Type:      "error",
+			Data:      map[string]interface{}{"error": "conversation_id is required to cancel"},
+			Timestamp: time.Now(),
 		}
+		return
+	}
+
+	cancelRequest := &ports.CancelRequest{
+		ConversationID: conversationID,
+		MessageID:      messageID,
+		Reason:         reason,
+	}
+
+	ctx := ports.WithSource(context.Background(), "websocket")
+	subject := fmt.Sprintf(ports.SubjectConversationCancel, conversationID)
+	if err := c.Hub.messaging.PublishJSON(ctx, subject, cancelRequest); err != nil {
+		log.Printf("Failed to publish cancellation for conversation %s: %v", conversationID, err)
+		c.Send <- Event{
+			Type:      "error",
+			Data:      map[string]interface{}{"error": "Failed to cancel generation", "conversation_id": conversationID},
+			Timestamp: time.Now(),
+		}
+		return
+	}
+
+	c.Send <- Event{
+		Type:      "cancelling",
+		Data:      map[string]interface{}{"conversation_id": conversationID, "message_id": messageID},
+		Timestamp: time.Now(),
 	}
 }
 
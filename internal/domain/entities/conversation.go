@@ -1,18 +1,27 @@
 package entities
 
 import (
+	"sort"
+	"strings"
 	"time"
 )
 
 // Conversation represents a chat conversation
 type Conversation struct {
-	ID             string    `json:"id"`
-	Title          string    `json:"title"`
-	SystemPromptID string    `json:"system_prompt_id"`
-	Model          string    `json:"model,omitempty"` // Preferred model for this conversation
-	MessageIDs     []string  `json:"message_ids"`     // Ordered list of message IDs
-	CreatedAt      time.Time `json:"created_at"`
-	UpdatedAt      time.Time `json:"updated_at"`
+	ID             string       `json:"id"`
+	Title          string       `json:"title"`
+	SystemPromptID string       `json:"system_prompt_id"`
+	Model          string       `json:"model,omitempty"` // Preferred model for this conversation
+	MessageIDs     []string     `json:"message_ids"`     // Ordered list of message IDs
+	FolderID       string       `json:"folder_id,omitempty"`
+	Tags           []string     `json:"tags"`
+	Pinned         bool         `json:"pinned"`  // Pinned conversations are exempt from retention
+	Starred        bool         `json:"starred"` // Starred conversations are favourites
+	Tools          ToolSettings `json:"tools"`   // Overrides the system prompt's tool settings
+	ArchivedAt     *time.Time   `json:"archived_at,omitempty"`
+	DeletedAt      *time.Time   `json:"deleted_at,omitempty"` // Set while the conversation is in the trash
+	CreatedAt      time.Time    `json:"created_at"`
+	UpdatedAt      time.Time    `json:"updated_at"`
 }
 
 // NewConversation creates a new conversation with the given system prompt
@@ -23,6 +32,7 @@ func NewConversation(title, systemPromptID string) *Conversation {
 		Title:          title,
 		SystemPromptID: systemPromptID,
 		MessageIDs:     make([]string, 0),
+		Tags:           make([]string, 0),
 		CreatedAt:      now,
 		UpdatedAt:      now,
 	}
@@ -52,6 +62,91 @@ func (c *Conversation) SetModel(model string) {
 	c.UpdatedAt = time.Now()
 }
 
+// SetPinned pins or unpins the conversation
+func (c *Conversation) SetPinned(pinned bool) {
+	c.Pinned = pinned
+	c.UpdatedAt = time.Now()
+}
+
+// SetStarred stars or unstars the conversation
+func (c *Conversation) SetStarred(starred bool) {
+	c.Starred = starred
+	c.UpdatedAt = time.Now()
+}
+
+// SetTools replaces the conversation's tool settings
+func (c *Conversation) SetTools(tools ToolSettings) {
+	c.Tools = tools.Normalize()
+	c.UpdatedAt = time.Now()
+}
+
+// SetFolder moves the conversation into a folder; an empty ID removes it from its folder
+func (c *Conversation) SetFolder(folderID string) {
+	c.FolderID = folderID
+	c.UpdatedAt = time.Now()
+}
+
+// SetTags replaces the conversation tags with a normalized, de-duplicated set
+func (c *Conversation) SetTags(tags []string) {
+	c.Tags = NormalizeTags(tags)
+	c.UpdatedAt = time.Now()
+}
+
+// HasTag returns true if the conversation carries the given tag
+func (c *Conversation) HasTag(tag string) bool {
+	tag = NormalizeTag(tag)
+	for _, t := range c.Tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// NormalizeTag trims and lowercases a tag
+func NormalizeTag(tag string) string {
+	return strings.ToLower(strings.TrimSpace(tag))
+}
+
+// NormalizeTags normalizes, de-duplicates and sorts tags, dropping empty ones
+func NormalizeTags(tags []string) []string {
+	seen := make(map[string]bool, len(tags))
+	normalized := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		tag = NormalizeTag(tag)
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		normalized = append(normalized, tag)
+	}
+	sort.Strings(normalized)
+	return normalized
+}
+
+// Archive marks the conversation as archived
+func (c *Conversation) Archive() {
+	now := time.Now()
+	c.ArchivedAt = &now
+	c.UpdatedAt = now
+}
+
+// Unarchive returns the conversation to the active list
+func (c *Conversation) Unarchive() {
+	c.ArchivedAt = nil
+	c.UpdatedAt = time.Now()
+}
+
+// IsArchived returns true if the conversation has been archived
+func (c *Conversation) IsArchived() bool {
+	return c.ArchivedAt != nil
+}
+
+// IsDeleted returns true if the conversation is in the trash
+func (c *Conversation) IsDeleted() bool {
+	return c.DeletedAt != nil
+}
+
 // MessageCount returns the number of messages in the conversation
 func (c *Conversation) MessageCount() int {
 	return len(c.MessageIDs)
@@ -0,0 +1,324 @@
+package entities
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Event types recorded in a conversation's event log
+const (
+	EventConversationCreated    = "conversation.created"
+	EventTitleChanged           = "conversation.title_changed"
+	EventSystemPromptChanged    = "conversation.system_prompt_changed"
+	EventModelSwitched          = "conversation.model_switched"
+	EventConversationMoved      = "conversation.moved"
+	EventTagsChanged            = "conversation.tags_changed"
+	EventPinnedChanged          = "conversation.pinned_changed"
+	EventStarredChanged         = "conversation.starred_changed"
+	EventToolSettingsChanged    = "conversation.tools_changed"
+	EventConversationArchived   = "conversation.archived"
+	EventConversationUnarchived = "conversation.unarchived"
+	EventConversationDeleted    = "conversation.deleted"
+	EventConversationRestored   = "conversation.restored"
+	EventMessageAdded           = "message.added"
+	EventToolCallCompleted      = "tool_call.completed"
+	EventToolApprovalRequested  = "tool_call.approval_requested"
+	EventToolCallApproved       = "tool_call.approved"
+	EventToolCallRejected       = "tool_call.rejected"
+)
+
+// ErrUnknownEventType is returned when decoding an event that is not a domain event
+var ErrUnknownEventType = errors.New("unknown event type")
+
+// DomainEvent is a state change recorded in a conversation's event log
+type DomainEvent interface {
+	EventType() string
+}
+
+// ConversationCreated starts a conversation's event log with a snapshot of its initial state.
+// Imported conversations start with a new snapshot, which supersedes any earlier history.
+type ConversationCreated struct {
+	Title          string        `json:"title"`
+	SystemPromptID string        `json:"system_prompt_id"`
+	Model          string        `json:"model,omitempty"`
+	FolderID       string        `json:"folder_id,omitempty"`
+	Tags           []string      `json:"tags,omitempty"`
+	Pinned         bool          `json:"pinned,omitempty"`
+	Starred        bool          `json:"starred,omitempty"`
+	Tools          *ToolSettings `json:"tools,omitempty"`
+	ArchivedAt     *time.Time    `json:"archived_at,omitempty"`
+	CreatedAt      time.Time     `json:"created_at"`
+}
+
+// TitleChanged records a new conversation title
+type TitleChanged struct {
+	Title string `json:"title"`
+}
+
+// SystemPromptChanged records a new system prompt for a conversation
+type SystemPromptChanged struct {
+	SystemPromptID string `json:"system_prompt_id"`
+}
+
+// ModelSwitched records a new preferred model for a conversation
+type ModelSwitched struct {
+	Model string `json:"model"`
+}
+
+// ConversationMoved records a conversation moving into a folder; an empty ID means no folder
+type ConversationMoved struct {
+	FolderID string `json:"folder_id,omitempty"`
+}
+
+// TagsChanged records the full set of tags after a change
+type TagsChanged struct {
+	Tags []string `json:"tags"`
+}
+
+// PinnedChanged records a conversation being pinned or unpinned
+type PinnedChanged struct {
+	Pinned bool `json:"pinned"`
+}
+
+// StarredChanged records a conversation being starred or unstarred
+type StarredChanged struct {
+	Starred bool `json:"starred"`
+}
+
+// ToolSettingsChanged records the full tool settings of a conversation after a change
+type ToolSettingsChanged struct {
+	Tools ToolSettings `json:"tools"`
+}
+
+// ConversationArchived records a conversation being archived
+type ConversationArchived struct {
+	ArchivedAt time.Time `json:"archived_at"`
+}
+
+// ConversationUnarchived records a conversation returning to the active list
+type ConversationUnarchived struct{}
+
+// ConversationDeleted records a conversation being moved to the trash
+type ConversationDeleted struct {
+	DeletedAt time.Time `json:"deleted_at"`
+}
+
+// ConversationRestored records a conversation being moved out of the trash
+type ConversationRestored struct{}
+
+// MessageAdded records a message, including its tool calls as they were when saved
+type MessageAdded struct {
+	Message Message `json:"message"`
+}
+
+// ToolCallCompleted records the final state of a tool call
+type ToolCallCompleted struct {
+	ToolCall ToolCall `json:"tool_call"`
+}
+
+// ToolApprovalRequested records a tool call being held for a user's approval
+type ToolApprovalRequested struct {
+	ToolCall ToolCall `json:"tool_call"`
+}
+
+// ToolCallApproved records a user approving a tool call
+type ToolCallApproved struct {
+	ToolCall ToolCall `json:"tool_call"`
+}
+
+// ToolCallRejected records a user rejecting a tool call, with the reason in its result
+type ToolCallRejected struct {
+	ToolCall ToolCall `json:"tool_call"`
+}
+
+func (ConversationCreated) EventType() string    { return EventConversationCreated }
+func (TitleChanged) EventType() string           { return EventTitleChanged }
+func (SystemPromptChanged) EventType() string    { return EventSystemPromptChanged }
+func (ModelSwitched) EventType() string          { return EventModelSwitched }
+func (ConversationMoved) EventType() string      { return EventConversationMoved }
+func (TagsChanged) EventType() string            { return EventTagsChanged }
+func (PinnedChanged) EventType() string          { return EventPinnedChanged }
+func (StarredChanged) EventType() string         { return EventStarredChanged }
+func (ToolSettingsChanged) EventType() string    { return EventToolSettingsChanged }
+func (ConversationArchived) EventType() string   { return EventConversationArchived }
+func (ConversationUnarchived) EventType() string { return EventConversationUnarchived }
+func (ConversationDeleted) EventType() string    { return EventConversationDeleted }
+func (ConversationRestored) EventType() string   { return EventConversationRestored }
+func (MessageAdded) EventType() string           { return EventMessageAdded }
+func (ToolCallCompleted) EventType() string      { return EventToolCallCompleted }
+func (ToolApprovalRequested) EventType() string  { return EventToolApprovalRequested }
+func (ToolCallApproved) EventType() string       { return EventToolCallApproved }
+func (ToolCallRejected) EventType() string       { return EventToolCallRejected }
+
+// ToolCallEvent returns the event recording a tool call's new status, or nil for pending calls
+func ToolCallEvent(toolCall ToolCall) DomainEvent {
+	switch toolCall.Status {
+	case ToolCallStatusAwaitingApproval:
+		return ToolApprovalRequested{ToolCall: toolCall}
+	case ToolCallStatusApproved:
+		return ToolCallApproved{ToolCall: toolCall}
+	case ToolCallStatusRejected:
+		return ToolCallRejected{ToolCall: toolCall}
+	case ToolCallStatusSuccess, ToolCallStatusError:
+		return ToolCallCompleted{ToolCall: toolCall}
+	default:
+		return nil
+	}
+}
+
+// NewConversationCreated snapshots a conversation for the start of its event log
+func NewConversationCreated(c *Conversation) ConversationCreated {
+	var tools *ToolSettings
+	if settings := c.Tools.Normalize(); !settings.IsEmpty() {
+		tools = &settings
+	}
+
+	return ConversationCreated{
+		Title:          c.Title,
+		SystemPromptID: c.SystemPromptID,
+		Model:          c.Model,
+		FolderID:       c.FolderID,
+		Tags:           NormalizeTags(c.Tags),
+		Pinned:         c.Pinned,
+		Starred:        c.Starred,
+		Tools:          tools,
+		ArchivedAt:     c.ArchivedAt,
+		CreatedAt:      c.CreatedAt,
+	}
+}
+
+// Conversation returns the conversation described by the snapshot
+func (e ConversationCreated) Conversation(id string) *Conversation {
+	tags := e.Tags
+	if tags == nil {
+		tags = make([]string, 0)
+	}
+
+	var toolSettings ToolSettings
+	if e.Tools != nil {
+		toolSettings = *e.Tools
+	}
+
+	return &Conversation{
+		ID:             id,
+		Title:          e.Title,
+		SystemPromptID: e.SystemPromptID,
+		Model:          e.Model,
+		MessageIDs:     make([]string, 0),
+		FolderID:       e.FolderID,
+		Tags:           tags,
+		Pinned:         e.Pinned,
+		Starred:        e.Starred,
+		Tools:          toolSettings,
+		ArchivedAt:     e.ArchivedAt,
+		CreatedAt:      e.CreatedAt,
+		UpdatedAt:      e.CreatedAt,
+	}
+}
+
+// DiffConversation returns the events that turn before into after.
+// Message IDs and timestamps are ignored; messages are recorded with MessageAdded.
+func DiffConversation(before, after *Conversation) []DomainEvent {
+	var events []DomainEvent
+
+	if before.Title != after.Title {
+		events = append(events, TitleChanged{Title: after.Title})
+	}
+	if before.SystemPromptID != after.SystemPromptID {
+		events = append(events, SystemPromptChanged{SystemPromptID: after.SystemPromptID})
+	}
+	if before.Model != after.Model {
+		events = append(events, ModelSwitched{Model: after.Model})
+	}
+	if before.FolderID != after.FolderID {
+		events = append(events, ConversationMoved{FolderID: after.FolderID})
+	}
+	if tags := NormalizeTags(after.Tags); !sameTags(NormalizeTags(before.Tags), tags) {
+		events = append(events, TagsChanged{Tags: tags})
+	}
+	if before.Pinned != after.Pinned {
+		events = append(events, PinnedChanged{Pinned: after.Pinned})
+	}
+	if before.Starred != after.Starred {
+		events = append(events, StarredChanged{Starred: after.Starred})
+	}
+	if tools := after.Tools.Normalize(); !before.Tools.Normalize().Equal(tools) {
+		events = append(events, ToolSettingsChanged{Tools: tools})
+	}
+	if before.IsArchived() != after.IsArchived() {
+		if after.IsArchived() {
+			events = append(events, ConversationArchived{ArchivedAt: *after.ArchivedAt})
+		} else {
+			events = append(events, ConversationUnarchived{})
+		}
+	}
+
+	return events
+}
+
+// sameTags compares two normalized tag sets
+func sameTags(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// DecodeEvent decodes a stored event payload into a pointer to its typed domain event
+func DecodeEvent(eventType string, payload []byte) (DomainEvent, error) {
+	var event DomainEvent
+
+	switch eventType {
+	case EventConversationCreated:
+		event = &ConversationCreated{}
+	case EventTitleChanged:
+		event = &TitleChanged{}
+	case EventSystemPromptChanged:
+		event = &SystemPromptChanged{}
+	case EventModelSwitched:
+		event = &ModelSwitched{}
+	case EventConversationMoved:
+		event = &ConversationMoved{}
+	case EventTagsChanged:
+		event = &TagsChanged{}
+	case EventPinnedChanged:
+		event = &PinnedChanged{}
+	case EventStarredChanged:
+		event = &StarredChanged{}
+	case EventToolSettingsChanged:
+		event = &ToolSettingsChanged{}
+	case EventConversationArchived:
+		event = &ConversationArchived{}
+	case EventConversationUnarchived:
+		event = &ConversationUnarchived{}
+	case EventConversationDeleted:
+		event = &ConversationDeleted{}
+	case EventConversationRestored:
+		event = &ConversationRestored{}
+	case EventMessageAdded:
+		event = &MessageAdded{}
+	case EventToolCallCompleted:
+		event = &ToolCallCompleted{}
+	case EventToolApprovalRequested:
+		event = &ToolApprovalRequested{}
+	case EventToolCallApproved:
+		event = &ToolCallApproved{}
+	case EventToolCallRejected:
+		event = &ToolCallRejected{}
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnknownEventType, eventType)
+	}
+
+	if err := json.Unmarshal(payload, event); err != nil {
+		return nil, fmt.Errorf("failed to decode %s event: %w", eventType, err)
+	}
+
+	return event, nil
+}
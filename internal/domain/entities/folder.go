@@ -0,0 +1,38 @@
+package entities
+
+import (
+	"time"
+)
+
+// Folder groups conversations; folders can be nested through ParentID
+type Folder struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	ParentID  string    `json:"parent_id,omitempty"` // Empty for top-level folders
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// NewFolder creates a new folder under the given parent
+func NewFolder(name, parentID string) *Folder {
+	now := time.Now()
+	return &Folder{
+		ID:        generateID(),
+		Name:      name,
+		ParentID:  parentID,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+}
+
+// Rename changes the folder name
+func (f *Folder) Rename(name string) {
+	f.Name = name
+	f.UpdatedAt = time.Now()
+}
+
+// Move places the folder under a new parent; an empty parent makes it top-level
+func (f *Folder) Move(parentID string) {
+	f.ParentID = parentID
+	f.UpdatedAt = time.Now()
+}
@@ -14,16 +14,21 @@ const (
 	RoleTool      MessageRole = "tool"
 )
 
+// FinishReasonCancelled marks an assistant message whose generation was cancelled
+const FinishReasonCancelled = "cancelled"
+
 // Message represents a single message in a conversation
 type Message struct {
 	ID             string      `json:"id"`
 	ConversationID string      `json:"conversation_id"`
 	Role           MessageRole `json:"role"`
 	Content        string      `json:"content"`
-	ParentID       *string     `json:"parent_id,omitempty"` // For future branching support
+	ParentID       *string     `json:"parent_id,omitempty"` // The message an assistant reply answers
 	TokenCount     int         `json:"token_count"`
 	Model          string      `json:"model,omitempty"`
 	ToolCalls      []ToolCall  `json:"tool_calls,omitempty"`
+	FinishReason   string      `json:"finish_reason,omitempty"`   // Why generation stopped, for assistant messages
+	IdempotencyKey string      `json:"idempotency_key,omitempty"` // Client key deduplicating retried submissions
 	CreatedAt      time.Time   `json:"created_at"`
 }
 
@@ -6,11 +6,13 @@ import (
 
 // SystemPrompt represents a reusable system prompt
 type SystemPrompt struct {
-	ID        string    `json:"id"`
-	Name      string    `json:"name"`
-	Content   string    `json:"content"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID        string       `json:"id"`
+	Name      string       `json:"name"`
+	Content   string       `json:"content"`
+	Tools     ToolSettings `json:"tools"`                // Tools offered in conversations using the prompt
+	DeletedAt *time.Time   `json:"deleted_at,omitempty"` // Set while the prompt is in the trash
+	CreatedAt time.Time    `json:"created_at"`
+	UpdatedAt time.Time    `json:"updated_at"`
 }
 
 // NewSystemPrompt creates a new system prompt
@@ -32,11 +34,22 @@ func (sp *SystemPrompt) Update(name, content string) {
 	sp.UpdatedAt = time.Now()
 }
 
+// SetTools replaces the prompt's tool settings
+func (sp *SystemPrompt) SetTools(tools ToolSettings) {
+	sp.Tools = tools.Normalize()
+	sp.UpdatedAt = time.Now()
+}
+
 // IsEmpty returns true if the prompt content is empty
 func (sp *SystemPrompt) IsEmpty() bool {
 	return len(sp.Content) == 0
 }
 
+// IsDeleted returns true if the prompt is in the trash
+func (sp *SystemPrompt) IsDeleted() bool {
+	return sp.DeletedAt != nil
+}
+
 // DefaultSystemPrompts returns a set of default system prompts
 func DefaultSystemPrompts() []*SystemPrompt {
 	return []*SystemPrompt{
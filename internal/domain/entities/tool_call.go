@@ -9,27 +9,33 @@ import (
 type ToolCallStatus string
 
 const (
-	ToolCallStatusPending ToolCallStatus = "pending"
-	ToolCallStatusSuccess ToolCallStatus = "success"
-	ToolCallStatusError   ToolCallStatus = "error"
+	ToolCallStatusPending          ToolCallStatus = "pending"
+	ToolCallStatusAwaitingApproval ToolCallStatus = "awaiting_approval" // Waiting for a user to approve or reject the call
+	ToolCallStatusApproved         ToolCallStatus = "approved"          // Approved and not yet finished
+	ToolCallStatusRejected         ToolCallStatus = "rejected"          // Rejected by a user; never runs
+	ToolCallStatusSuccess          ToolCallStatus = "success"
+	ToolCallStatusError            ToolCallStatus = "error"
 )
 
 // ToolCall represents a function call made by the LLM
 type ToolCall struct {
-	ID        string                 `json:"id"`
-	MessageID string                 `json:"message_id"`
-	Name      string                 `json:"name"`
-	Arguments map[string]interface{} `json:"arguments"`
-	Result    *ToolCallResult        `json:"result,omitempty"`
-	Status    ToolCallStatus         `json:"status"`
-	CreatedAt time.Time              `json:"created_at"`
+	ID          string                 `json:"id"`
+	MessageID   string                 `json:"message_id"`
+	Name        string                 `json:"name"`
+	Arguments   map[string]interface{} `json:"arguments"`
+	Result      *ToolCallResult        `json:"result,omitempty"`
+	Status      ToolCallStatus         `json:"status"`
+	CreatedAt   time.Time              `json:"created_at"`
+	CompletedAt *time.Time             `json:"completed_at,omitempty"` // Set once the call succeeds, fails or is rejected
+	DurationMs  int64                  `json:"duration_ms,omitempty"`  // Time the tool took to run
 }
 
 // ToolCallResult represents the result of a tool call
 type ToolCallResult struct {
-	Data      interface{} `json:"data,omitempty"`
-	Error     string      `json:"error,omitempty"`
-	Timestamp time.Time   `json:"timestamp"`
+	Data      interface{}            `json:"data,omitempty"`
+	Error     string                 `json:"error,omitempty"`
+	Metadata  map[string]interface{} `json:"metadata,omitempty"` // Reported by the tool or executor, e.g. truncation
+	Timestamp time.Time              `json:"timestamp"`
 }
 
 // NewToolCall creates a new tool call
@@ -51,6 +57,7 @@ func (tc *ToolCall) SetResult(data interface{}) {
 		Timestamp: time.Now(),
 	}
 	tc.Status = ToolCallStatusSuccess
+	tc.CompletedAt = &tc.Result.Timestamp
 }
 
 // SetError sets an error result for the tool call
@@ -60,11 +67,36 @@ func (tc *ToolCall) SetError(err string) {
 		Timestamp: time.Now(),
 	}
 	tc.Status = ToolCallStatusError
+	tc.CompletedAt = &tc.Result.Timestamp
 }
 
-// IsCompleted returns true if the tool call has finished (success or error)
+// RequestApproval holds the tool call until a user approves or rejects it
+func (tc *ToolCall) RequestApproval() {
+	tc.Status = ToolCallStatusAwaitingApproval
+}
+
+// Approve lets a tool call awaiting approval run
+func (tc *ToolCall) Approve() {
+	tc.Status = ToolCallStatusApproved
+}
+
+// Reject ends a tool call awaiting approval without running it
+func (tc *ToolCall) Reject(reason string) {
+	message := "rejected by user"
+	if reason != "" {
+		message += ": " + reason
+	}
+	tc.Result = &ToolCallResult{
+		Error:     message,
+		Timestamp: time.Now(),
+	}
+	tc.Status = ToolCallStatusRejected
+	tc.CompletedAt = &tc.Result.Timestamp
+}
+
+// IsCompleted returns true if the tool call has finished (success, error or rejected)
 func (tc *ToolCall) IsCompleted() bool {
-	return tc.Status == ToolCallStatusSuccess || tc.Status == ToolCallStatusError
+	return tc.Status == ToolCallStatusSuccess || tc.Status == ToolCallStatusError || tc.Status == ToolCallStatusRejected
 }
 
 // IsPending returns true if the tool call is still pending
@@ -72,6 +104,11 @@ func (tc *ToolCall) IsPending() bool {
 	return tc.Status == ToolCallStatusPending
 }
 
+// IsAwaitingApproval returns true if the tool call waits for a user's decision
+func (tc *ToolCall) IsAwaitingApproval() bool {
+	return tc.Status == ToolCallStatusAwaitingApproval
+}
+
 // HasError returns true if the tool call resulted in an error
 func (tc *ToolCall) HasError() bool {
 	return tc.Status == ToolCallStatusError
@@ -0,0 +1,61 @@
+package entities
+
+import (
+	"fmt"
+	"path"
+)
+
+// ToolPolicy decides whether calls to a tool run unattended
+type ToolPolicy string
+
+const (
+	ToolPolicyAuto            ToolPolicy = "auto"             // Calls run as soon as the model makes them
+	ToolPolicyRequireApproval ToolPolicy = "require_approval" // Calls wait for a user to approve or reject them
+	ToolPolicyDeny            ToolPolicy = "deny"             // Calls always fail
+)
+
+// IsValid returns true for a known policy
+func (p ToolPolicy) IsValid() bool {
+	return p == ToolPolicyAuto || p == ToolPolicyRequireApproval || p == ToolPolicyDeny
+}
+
+// ToolPolicyRule applies a policy to the tools matching a pattern, which may contain * wildcards
+type ToolPolicyRule struct {
+	Tool   string     `json:"tool"`
+	Policy ToolPolicy `json:"policy"`
+}
+
+// ToolPolicies assigns a policy to every tool: the first matching rule's, or the default
+type ToolPolicies struct {
+	Default ToolPolicy       `json:"default,omitempty"` // Empty means auto
+	Rules   []ToolPolicyRule `json:"rules,omitempty"`
+}
+
+// PolicyFor returns the policy for calls to a tool
+func (p ToolPolicies) PolicyFor(name string) ToolPolicy {
+	for _, rule := range p.Rules {
+		if matched, _ := path.Match(rule.Tool, name); matched {
+			return rule.Policy
+		}
+	}
+	if p.Default == "" {
+		return ToolPolicyAuto
+	}
+	return p.Default
+}
+
+// Validate checks that every rule has a well-formed pattern and a known policy
+func (p ToolPolicies) Validate() error {
+	if p.Default != "" && !p.Default.IsValid() {
+		return fmt.Errorf("invalid default tool policy %q", p.Default)
+	}
+	for _, rule := range p.Rules {
+		if _, err := path.Match(rule.Tool, ""); err != nil || rule.Tool == "" {
+			return fmt.Errorf("invalid tool pattern %q in tool policy", rule.Tool)
+		}
+		if !rule.Policy.IsValid() {
+			return fmt.Errorf("invalid tool policy %q for %s", rule.Policy, rule.Tool)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package entities
+
+import (
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+)
+
+// ToolSettings enables or disables tools by name for a conversation or system prompt.
+// Patterns are tool names that may contain * wildcards, e.g. "time__*" for every tool of one provider.
+type ToolSettings struct {
+	Enabled  []string `json:"enabled,omitempty"`  // When set, only matching tools are offered
+	Disabled []string `json:"disabled,omitempty"` // Matching tools are never offered
+}
+
+// IsEmpty returns true if the settings don't restrict any tool
+func (s ToolSettings) IsEmpty() bool {
+	return len(s.Enabled) == 0 && len(s.Disabled) == 0
+}
+
+// Equal compares two normalized settings
+func (s ToolSettings) Equal(other ToolSettings) bool {
+	return slices.Equal(s.Enabled, other.Enabled) && slices.Equal(s.Disabled, other.Disabled)
+}
+
+// Normalize trims, de-duplicates and sorts the patterns
+func (s ToolSettings) Normalize() ToolSettings {
+	return ToolSettings{Enabled: normalizePatterns(s.Enabled), Disabled: normalizePatterns(s.Disabled)}
+}
+
+func normalizePatterns(patterns []string) []string {
+	var normalized []string
+	for _, pattern := range patterns {
+		pattern = strings.TrimSpace(pattern)
+		if pattern != "" && !slices.Contains(normalized, pattern) {
+			normalized = append(normalized, pattern)
+		}
+	}
+	slices.Sort(normalized)
+	return normalized
+}
+
+// Validate checks that every pattern is well formed
+func (s ToolSettings) Validate() error {
+	for _, pattern := range append(slices.Clone(s.Enabled), s.Disabled...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid tool pattern %q: %w", pattern, err)
+		}
+	}
+	return nil
+}
+
+// decide reports whether the settings enable a tool, and whether they say anything about it at all
+func (s ToolSettings) decide(name string) (enabled, decided bool) {
+	if matchesAny(s.Disabled, name) {
+		return false, true
+	}
+	if matchesAny(s.Enabled, name) {
+		return true, true
+	}
+	if len(s.Enabled) > 0 {
+		return false, true
+	}
+	return true, false
+}
+
+func matchesAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if matched, _ := path.Match(pattern, name); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// ToolEnabled reports whether a tool may be offered, given settings from the most specific
+// scope to the least, e.g. a conversation's and then its system prompt's.
+// The first settings that enable or disable the tool decide; tools are enabled by default.
+func ToolEnabled(name string, settings ...ToolSettings) bool {
+	for _, s := range settings {
+		if enabled, decided := s.decide(name); decided {
+			return enabled
+		}
+	}
+	return true
+}
@@ -0,0 +1,199 @@
+package ports
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+)
+
+// DefaultDeadLetterPrefix is the subject prefix under which failed messages are dead-lettered
+const DefaultDeadLetterPrefix = "system.dlq"
+
+// Defaults for the delivery limits a policy leaves unset
+const (
+	DefaultMaxDeliveries = 5
+	DefaultAckWait       = time.Minute
+)
+
+// DeliveryPolicy controls how messaging adapters redeliver messages whose handlers fail
+type DeliveryPolicy struct {
+	MaxDeliveries    int             // Attempts before a message is dead-lettered; 0 uses DefaultMaxDeliveries
+	Backoff          []time.Duration // Delay before each redelivery; the last value repeats
+	AckWait          time.Duration   // How long a broker waits without progress before redelivering; 0 uses DefaultAckWait
+	DeadLetterPrefix string          // Subject prefix for dead letters, e.g. system.dlq
+	MaxInFlight      int             // Handlers a queue subscription runs at once; 1 or less runs them one at a time
+}
+
+// DefaultDeliveryPolicy returns the delivery policy used when none is configured
+func DefaultDeliveryPolicy() DeliveryPolicy {
+	return DeliveryPolicy{
+		MaxDeliveries:    DefaultMaxDeliveries,
+		Backoff:          []time.Duration{time.Second, 5 * time.Second, 30 * time.Second},
+		AckWait:          DefaultAckWait,
+		DeadLetterPrefix: DefaultDeadLetterPrefix,
+		MaxInFlight:      32,
+	}
+}
+
+// WithDefaults returns the policy with an unset MaxDeliveries or AckWait replaced by its default.
+// Messaging adapters apply it to the policy they are created with.
+func (p DeliveryPolicy) WithDefaults() DeliveryPolicy {
+	if p.MaxDeliveries <= 0 {
+		p.MaxDeliveries = DefaultMaxDeliveries
+	}
+	if p.AckWait <= 0 {
+		p.AckWait = DefaultAckWait
+	}
+	return p
+}
+
+// QueueDispatcher returns the function a messaging adapter hands each queue subscription delivery to.
+// Deliveries run concurrently, at most MaxInFlight at once; the function blocks while that many are running,
+// so unacked messages stay with the broker. Plain subscriptions keep delivering in order and don't use it.
+func (p DeliveryPolicy) QueueDispatcher() func(handle func()) {
+	if p.MaxInFlight <= 1 {
+		return func(handle func()) { handle() }
+	}
+
+	slots := make(chan struct{}, p.MaxInFlight)
+	return func(handle func()) {
+		slots <- struct{}{}
+		go func() {
+			defer func() { <-slots }()
+			handle()
+		}()
+	}
+}
+
+// AckAction tells a messaging adapter what to do with a message after its handler returns
+type AckAction int
+
+const (
+	AckActionAck       AckAction = iota // Processing succeeded
+	AckActionNack                       // Redeliver after a delay
+	AckActionTerminate                  // Never redeliver; dead-letter the message
+)
+
+// String returns the action name
+func (a AckAction) String() string {
+	switch a {
+	case AckActionAck:
+		return "ack"
+	case AckActionNack:
+		return "nack"
+	case AckActionTerminate:
+		return "terminate"
+	default:
+		return "unknown"
+	}
+}
+
+// handlerResult is an error that carries an explicit ack decision
+type handlerResult struct {
+	err    error
+	action AckAction
+	delay  time.Duration
+}
+
+func (r *handlerResult) Error() string {
+	return r.err.Error()
+}
+
+func (r *handlerResult) Unwrap() error {
+	return r.err
+}
+
+// Nack wraps a handler error to request redelivery after delay.
+// A zero delay uses the policy backoff.
+func Nack(err error, delay time.Duration) error {
+	return &handlerResult{err: err, action: AckActionNack, delay: delay}
+}
+
+// Terminate wraps a handler error to stop redelivery, e.g. for malformed payloads.
+// The message is dead-lettered immediately.
+func Terminate(err error) error {
+	return &handlerResult{err: err, action: AckActionTerminate}
+}
+
+// Decide returns the ack action and redelivery delay for a handler result.
+// delivered is the number of times the message has been delivered, including this one.
+// Plain errors are retried with backoff until MaxDeliveries is reached.
+func (p DeliveryPolicy) Decide(err error, delivered int) (AckAction, time.Duration) {
+	if err == nil {
+		return AckActionAck, 0
+	}
+
+	var result *handlerResult
+	if errors.As(err, &result) && result.action == AckActionTerminate {
+		return AckActionTerminate, 0
+	}
+
+	if delivered >= p.WithDefaults().MaxDeliveries {
+		return AckActionTerminate, 0
+	}
+
+	if result != nil && result.delay > 0 {
+		return AckActionNack, result.delay
+	}
+
+	return AckActionNack, p.BackoffFor(delivered)
+}
+
+// BackoffFor returns the delay before redelivering a message that has been delivered the given number of times
+func (p DeliveryPolicy) BackoffFor(delivered int) time.Duration {
+	if len(p.Backoff) == 0 {
+		return 0
+	}
+	if delivered < 1 {
+		delivered = 1
+	}
+	if delivered > len(p.Backoff) {
+		return p.Backoff[len(p.Backoff)-1]
+	}
+	return p.Backoff[delivered-1]
+}
+
+// DeadLetterSubject returns the subject a failed message on subject is dead-lettered to.
+// It returns an empty string for dead letters themselves, which are never dead-lettered again.
+func (p DeliveryPolicy) DeadLetterSubject(subject string) string {
+	prefix := p.DeadLetterPrefix
+	if prefix == "" {
+		prefix = DefaultDeadLetterPrefix
+	}
+	if strings.HasPrefix(subject, prefix+".") {
+		return ""
+	}
+	return prefix + "." + subject
+}
+
+// DeadLetter records a message that could not be processed
+type DeadLetter struct {
+	Subject    string          `json:"subject"`
+	Payload    json.RawMessage `json:"payload,omitempty"`     // Set when the payload is valid JSON
+	RawPayload []byte          `json:"raw_payload,omitempty"` // Set otherwise, base64 encoded
+	Error      string          `json:"error"`
+	Deliveries int             `json:"deliveries"`
+	FailedAt   time.Time       `json:"failed_at"`
+}
+
+// NewDeadLetter creates a dead letter for a failed message
+func NewDeadLetter(subject string, data []byte, err error, deliveries int) *DeadLetter {
+	letter := &DeadLetter{
+		Subject:    subject,
+		Deliveries: deliveries,
+		FailedAt:   time.Now(),
+	}
+
+	if err != nil {
+		letter.Error = err.Error()
+	}
+
+	if json.Valid(data) {
+		letter.Payload = json.RawMessage(data)
+	} else {
+		letter.RawPayload = data
+	}
+
+	return letter
+}
@@ -0,0 +1,102 @@
+package ports
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestDeliveryPolicy_Decide(t *testing.T) {
+	policy := DeliveryPolicy{
+		MaxDeliveries: 3,
+		Backoff:       []time.Duration{time.Second, 10 * time.Second},
+	}
+	failure := errors.New("boom")
+
+	tests := []struct {
+		name       string
+		err        error
+		delivered  int
+		wantAction AckAction
+		wantDelay  time.Duration
+	}{
+		{"success acks", nil, 1, AckActionAck, 0},
+		{"first failure uses first backoff", failure, 1, AckActionNack, time.Second},
+		{"second failure uses second backoff", failure, 2, AckActionNack, 10 * time.Second},
+		{"last delivery terminates", failure, 3, AckActionTerminate, 0},
+		{"explicit delay overrides backoff", Nack(failure, time.Minute), 1, AckActionNack, time.Minute},
+		{"explicit nack still honours max deliveries", Nack(failure, time.Minute), 3, AckActionTerminate, 0},
+		{"terminate skips retries", Terminate(failure), 1, AckActionTerminate, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			action, delay := policy.Decide(tt.err, tt.delivered)
+			if action != tt.wantAction || delay != tt.wantDelay {
+				t.Errorf("Decide() = %s, %s; want %s, %s", action, delay, tt.wantAction, tt.wantDelay)
+			}
+		})
+	}
+
+	unset := DeliveryPolicy{Backoff: policy.Backoff}
+	if action, _ := unset.Decide(failure, DefaultMaxDeliveries-1); action != AckActionNack {
+		t.Errorf("Expected an unset limit to retry before the default limit, got %s", action)
+	}
+	if action, _ := unset.Decide(failure, DefaultMaxDeliveries); action != AckActionTerminate {
+		t.Errorf("Expected an unset limit to use the default limit, got %s", action)
+	}
+
+	if !errors.Is(Terminate(failure), failure) {
+		t.Error("Expected wrapped errors to unwrap to the handler error")
+	}
+}
+
+func TestDeliveryPolicy_BackoffRepeatsLastValue(t *testing.T) {
+	policy := DeliveryPolicy{MaxDeliveries: 1000, Backoff: []time.Duration{time.Second, 5 * time.Second}}
+
+	if got := policy.BackoffFor(10); got != 5*time.Second {
+		t.Errorf("BackoffFor(10) = %s, want 5s", got)
+	}
+	if _, delay := policy.Decide(errors.New("boom"), 100); delay != 5*time.Second {
+		t.Errorf("Expected later retries to keep using the last backoff, got %s", delay)
+	}
+}
+
+func TestDeliveryPolicy_DeadLetterSubject(t *testing.T) {
+	policy := DeliveryPolicy{}
+
+	if got := policy.DeadLetterSubject("inference.request"); got != "system.dlq.inference.request" {
+		t.Errorf("DeadLetterSubject() = %q", got)
+	}
+	if got := policy.DeadLetterSubject("system.dlq.inference.request"); got != "" {
+		t.Errorf("Expected dead letters not to be dead-lettered again, got %q", got)
+	}
+}
+
+func TestNewDeadLetter(t *testing.T) {
+	letter := NewDeadLetter("tool.execute", []byte(`{"name":"get_time"}`), errors.New("boom"), 5)
+
+	data, err := json.Marshal(letter)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+
+	var decoded map[string]interface{}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+
+	payload, ok := decoded["payload"].(map[string]interface{})
+	if !ok || payload["name"] != "get_time" {
+		t.Errorf("Expected JSON payload to be embedded as-is, got %v", decoded["payload"])
+	}
+	if decoded["error"] != "boom" {
+		t.Errorf("Expected error to be recorded, got %v", decoded["error"])
+	}
+
+	raw := NewDeadLetter("tool.execute", []byte("not json"), nil, 1)
+	if raw.Payload != nil || string(raw.RawPayload) != "not json" {
+		t.Errorf("Expected non-JSON payload to be kept raw, got %+v", raw)
+	}
+}
@@ -0,0 +1,161 @@
+package ports
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// EnvelopeSchemaVersion is the envelope format written by this build
+const EnvelopeSchemaVersion = 1
+
+// Envelope wraps every message published with PublishJSON so a single user action
+// can be traced across services
+type Envelope struct {
+	ID            string          `json:"id"`
+	SchemaVersion int             `json:"schema_version"`
+	Subject       string          `json:"subject"`
+	Source        string          `json:"source,omitempty"`       // Service that published the message
+	CorrelationID string          `json:"correlation_id"`         // Shared by every message caused by the same request
+	CausationID   string          `json:"causation_id,omitempty"` // ID of the envelope being handled when this one was published
+	Timestamp     time.Time       `json:"timestamp"`
+	Payload       json.RawMessage `json:"payload"`
+}
+
+type sourceKey struct{}
+type correlationIDKey struct{}
+type envelopeKey struct{}
+
+// WithSource returns a context whose published messages name source as their publisher
+func WithSource(ctx context.Context, source string) context.Context {
+	return context.WithValue(ctx, sourceKey{}, source)
+}
+
+// WithCorrelationID returns a context whose published messages carry the given correlation ID
+func WithCorrelationID(ctx context.Context, correlationID string) context.Context {
+	return context.WithValue(ctx, correlationIDKey{}, correlationID)
+}
+
+// WithEnvelope returns a context for handling the given envelope.
+// Messaging adapters call it before invoking a handler.
+func WithEnvelope(ctx context.Context, envelope *Envelope) context.Context {
+	return context.WithValue(ctx, envelopeKey{}, envelope)
+}
+
+// EnvelopeFromContext returns the envelope of the message being handled, if any
+func EnvelopeFromContext(ctx context.Context) (*Envelope, bool) {
+	envelope, ok := ctx.Value(envelopeKey{}).(*Envelope)
+	return envelope, ok
+}
+
+// CorrelationID returns the correlation ID set on ctx, or that of the message being handled
+func CorrelationID(ctx context.Context) string {
+	if correlationID, ok := ctx.Value(correlationIDKey{}).(string); ok && correlationID != "" {
+		return correlationID
+	}
+	if envelope, ok := EnvelopeFromContext(ctx); ok {
+		return envelope.CorrelationID
+	}
+	return ""
+}
+
+// NewCorrelationID returns a new random correlation ID
+func NewCorrelationID() string {
+	return newEnvelopeID()
+}
+
+// NewEnvelope wraps obj for publishing on subject. The correlation ID and causation ID
+// are taken from ctx; without a correlation ID the envelope starts a new chain.
+func NewEnvelope(ctx context.Context, subject string, obj interface{}) (*Envelope, error) {
+	payload, err := json.Marshal(obj)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal payload: %w", err)
+	}
+
+	envelope := &Envelope{
+		ID:            newEnvelopeID(),
+		SchemaVersion: EnvelopeSchemaVersion,
+		Subject:       subject,
+		CorrelationID: CorrelationID(ctx),
+		Timestamp:     time.Now(),
+		Payload:       payload,
+	}
+
+	if source, ok := ctx.Value(sourceKey{}).(string); ok {
+		envelope.Source = source
+	}
+	if parent, ok := EnvelopeFromContext(ctx); ok {
+		envelope.CausationID = parent.ID
+	}
+	if envelope.CorrelationID == "" {
+		envelope.CorrelationID = envelope.ID
+	}
+
+	return envelope, nil
+}
+
+// MarshalEnvelope wraps obj in an envelope and encodes it; adapters use it to implement PublishJSON
+func MarshalEnvelope(ctx context.Context, subject string, obj interface{}) ([]byte, error) {
+	envelope, err := NewEnvelope(ctx, subject, obj)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := json.Marshal(envelope)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal envelope: %w", err)
+	}
+
+	return data, nil
+}
+
+// OpenEnvelope decodes data published with MarshalEnvelope.
+// It returns false for data that isn't an envelope, such as raw Publish payloads.
+func OpenEnvelope(data []byte) (*Envelope, bool, error) {
+	var envelope Envelope
+	if err := json.Unmarshal(data, &envelope); err != nil || envelope.SchemaVersion == 0 || envelope.ID == "" || envelope.Payload == nil {
+		return nil, false, nil
+	}
+
+	if envelope.SchemaVersion > EnvelopeSchemaVersion {
+		return nil, true, fmt.Errorf("unsupported envelope schema version %d", envelope.SchemaVersion)
+	}
+
+	return &envelope, true, nil
+}
+
+// MarshalDeadLetter encodes the dead letter for a message that failed on subject, for publishing on dlqSubject.
+// When the failed message was an envelope, the dead letter keeps its correlation ID.
+func MarshalDeadLetter(dlqSubject, subject string, data []byte, cause error, deliveries int) ([]byte, error) {
+	ctx := context.Background()
+	if envelope, ok, err := OpenEnvelope(data); ok && err == nil {
+		ctx = WithEnvelope(ctx, envelope)
+	}
+
+	return MarshalEnvelope(ctx, dlqSubject, NewDeadLetter(subject, data, cause, deliveries))
+}
+
+// UnwrapEnvelopes returns a handler that receives envelope payloads, with the envelope in its context.
+// Messages that aren't envelopes are passed through unchanged.
+func UnwrapEnvelopes(handler MessageHandler) MessageHandler {
+	return func(ctx context.Context, subject string, data []byte) error {
+		envelope, ok, err := OpenEnvelope(data)
+		if err != nil {
+			return Terminate(err) // Newer publishers can't be understood by retrying
+		}
+		if !ok {
+			return handler(ctx, subject, data)
+		}
+
+		return handler(WithEnvelope(ctx, envelope), subject, envelope.Payload)
+	}
+}
+
+// newEnvelopeID creates a unique identifier using timestamp and random bytes
+func newEnvelopeID() string {
+	randomBytes := make([]byte, 4)
+	rand.Read(randomBytes)
+	return fmt.Sprintf("%x_%x", time.Now().UnixNano(), randomBytes)
+}
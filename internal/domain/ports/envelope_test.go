@@ -0,0 +1,57 @@
+package ports
+
+import (
+	"context"
+	"testing"
+)
+
+func TestUnwrapEnvelopes(t *testing.T) {
+	var gotData string
+	var gotEnvelope *Envelope
+	handler := UnwrapEnvelopes(func(ctx context.Context, subject string, data []byte) error {
+		gotData = string(data)
+		gotEnvelope, _ = EnvelopeFromContext(ctx)
+		return nil
+	})
+
+	ctx := WithCorrelationID(WithSource(context.Background(), "api"), "abc")
+	data, err := MarshalEnvelope(ctx, "context.request", map[string]string{"conversation_id": "conv_1"})
+	if err != nil {
+		t.Fatalf("MarshalEnvelope() error = %v", err)
+	}
+
+	if err := handler(context.Background(), "context.request", data); err != nil {
+		t.Fatalf("handler error = %v", err)
+	}
+	if gotData != `{"conversation_id":"conv_1"}` {
+		t.Errorf("Expected the bare payload, got %s", gotData)
+	}
+	if gotEnvelope == nil || gotEnvelope.CorrelationID != "abc" || gotEnvelope.Source != "api" || gotEnvelope.CausationID != "" {
+		t.Errorf("Unexpected envelope: %+v", gotEnvelope)
+	}
+
+	// Raw payloads are passed through unchanged
+	if err := handler(context.Background(), "context.request", []byte(`{"conversation_id":"conv_2"}`)); err != nil {
+		t.Fatalf("handler error = %v", err)
+	}
+	if gotData != `{"conversation_id":"conv_2"}` || gotEnvelope != nil {
+		t.Errorf("Expected raw payload without an envelope, got %s (%+v)", gotData, gotEnvelope)
+	}
+
+	// Envelopes from newer publishers are dead-lettered rather than retried
+	future := []byte(`{"id":"1","schema_version":99,"subject":"context.request","correlation_id":"1","payload":{}}`)
+	err = handler(context.Background(), "context.request", future)
+	if action, _ := DefaultDeliveryPolicy().Decide(err, 1); action != AckActionTerminate {
+		t.Errorf("Expected unsupported schema version to terminate, got %v (err = %v)", action, err)
+	}
+}
+
+func TestNewEnvelope_StartsNewChain(t *testing.T) {
+	envelope, err := NewEnvelope(context.Background(), "system.health", nil)
+	if err != nil {
+		t.Fatalf("NewEnvelope() error = %v", err)
+	}
+	if envelope.CorrelationID != envelope.ID {
+		t.Errorf("Expected an uncorrelated envelope to start its own chain, got %+v", envelope)
+	}
+}
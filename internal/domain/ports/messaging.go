@@ -2,9 +2,12 @@ package ports
 
 import (
 	"context"
+	"errors"
 )
 
-// MessageHandler defines a function type for handling incoming messages
+// MessageHandler defines a function type for handling incoming messages.
+// A nil result acks the message. Errors are redelivered according to the adapter's
+// DeliveryPolicy; wrap them with Nack or Terminate to control redelivery explicitly.
 type MessageHandler func(ctx context.Context, subject string, data []byte) error
 
 // MessagingPort defines the interface for event bus operations
@@ -18,7 +21,13 @@ type MessagingPort interface {
 	// Subscribe listens for messages on the specified subject
 	Subscribe(ctx context.Context, subject string, handler MessageHandler) error
 
-	// SubscribeQueue creates a queue subscription for load balancing
+	// SubscribeEphemeral listens for the messages published on a subject from now on, on this
+	// connection only: unlike Subscribe it shares no durable state with other instances, so each
+	// instance sees every message. It is stopped with Unsubscribe like a Subscribe subscription.
+	SubscribeEphemeral(ctx context.Context, subject string, handler MessageHandler) error
+
+	// SubscribeQueue creates a queue subscription for load balancing. Its handler may run
+	// concurrently, for up to the delivery policy's MaxInFlight messages at once.
 	SubscribeQueue(ctx context.Context, subject, queue string, handler MessageHandler) error
 
 	// Unsubscribe stops listening to a subject
@@ -34,25 +43,62 @@ type MessagingPort interface {
 	Ping() error
 }
 
+// ErrNoReplyExpected is returned by Respond when the message being handled is not a request
+var ErrNoReplyExpected = errors.New("message does not expect a reply")
+
+// ReplyFunc sends a response to the requester of the message being handled
+type ReplyFunc func(data []byte) error
+
+type replyFuncKey struct{}
+
+// WithReplyFunc returns a context that lets handlers answer the current request with Respond.
+// Messaging adapters call it before invoking a handler for a request.
+func WithReplyFunc(ctx context.Context, reply ReplyFunc) context.Context {
+	return context.WithValue(ctx, replyFuncKey{}, reply)
+}
+
+// Respond answers the request being handled in ctx
+func Respond(ctx context.Context, data []byte) error {
+	reply, ok := ctx.Value(replyFuncKey{}).(ReplyFunc)
+	if !ok {
+		return ErrNoReplyExpected
+	}
+	return reply(data)
+}
+
 // Standard subjects used across the system
 const (
 	// Conversation events
 	SubjectConversationMessageNew = "conversation.%s.message.new" // conversation_id
 	SubjectConversationUpdated    = "conversation.%s.updated"     // conversation_id
+	SubjectConversationCancel     = "conversation.%s.cancel"      // conversation_id
+	SubjectConversationCancelAll  = "conversation.*.cancel"
 
 	// Inference events
 	SubjectInferenceRequest  = "inference.request"
 	SubjectInferenceResponse = "inference.response"
 
 	// Tool events
-	SubjectToolExecute = "tool.execute"
-	SubjectToolResult  = "tool.result"
+	SubjectToolExecute           = "tool.execute"
+	SubjectToolResult            = "tool.result"
+	SubjectToolApprovalRequested = "tool.approval.requested"
+	SubjectToolApprovalResolved  = "tool.approval.resolved"
 
 	// Context construction events
 	SubjectContextRequest = "context.request"
 	SubjectContextReady   = "context.ready"
 
 	// System events
-	SubjectSystemHealth = "system.health"
-	SubjectSystemError  = "system.error"
+	SubjectSystemHealth    = "system.health"
+	SubjectSystemError     = "system.error"
+	SubjectSystemRetention = "system.retention"
+	SubjectSystemDLQ       = "system.dlq.>" // Dead letters, published as system.dlq.<original subject>
 )
+
+// CancelRequest asks the inference engine to stop generating for a conversation.
+// An empty MessageID cancels every generation running for the conversation.
+type CancelRequest struct {
+	ConversationID string `json:"conversation_id"`
+	MessageID      string `json:"message_id,omitempty"` // The user message being answered
+	Reason         string `json:"reason,omitempty"`
+}
@@ -2,10 +2,30 @@ package ports
 
 import (
 	"context"
+	"errors"
+	"time"
 
 	"github.com/username/hexarag/internal/domain/entities"
 )
 
+var (
+	// ErrConversationNotFound is returned by conversation lookups that find nothing
+	ErrConversationNotFound = errors.New("conversation not found")
+
+	// ErrConversationExists is returned when importing a conversation whose ID is already taken
+	ErrConversationExists = errors.New("conversation already exists")
+
+	// ErrMessageNotFound is returned by message lookups that find nothing
+	ErrMessageNotFound = errors.New("message not found")
+
+	// ErrToolCallNotFound is returned by tool call lookups that find nothing
+	ErrToolCallNotFound = errors.New("tool call not found")
+
+	// ErrDuplicateIdempotencyKey is returned when saving a message whose idempotency key
+	// is already used in its conversation
+	ErrDuplicateIdempotencyKey = errors.New("idempotency key already used in this conversation")
+)
+
 // StoragePort defines the interface for persistent storage operations
 type StoragePort interface {
 	// Message operations
@@ -13,30 +33,74 @@ type StoragePort interface {
 	GetMessage(ctx context.Context, id string) (*entities.Message, error)
 	GetMessages(ctx context.Context, conversationID string, limit int) ([]*entities.Message, error)
 	GetMessagesAfter(ctx context.Context, conversationID string, afterID string, limit int) ([]*entities.Message, error)
+	GetMessageByIdempotencyKey(ctx context.Context, conversationID, key string) (*entities.Message, error) // ErrMessageNotFound if the key is unused
+	GetReply(ctx context.Context, messageID string) (*entities.Message, error)                             // Latest assistant reply; ErrMessageNotFound if none yet
+	SearchMessages(ctx context.Context, search MessageSearch) ([]MessageHit, error)                        // Best matches first; excludes conversations in the trash
 
 	// Conversation operations
 	SaveConversation(ctx context.Context, conversation *entities.Conversation) error
-	GetConversation(ctx context.Context, id string) (*entities.Conversation, error)
-	GetConversations(ctx context.Context, limit int, offset int) ([]*entities.Conversation, error)
+	GetConversation(ctx context.Context, id string) (*entities.Conversation, error)                // Excludes conversations in the trash
+	GetConversations(ctx context.Context, limit int, offset int) ([]*entities.Conversation, error) // Excludes conversations in the trash
+	// ImportConversation stores a conversation, its messages and, unless nil, its system prompt in one transaction.
+	// A non-nil history is stored verbatim as the conversation's event log, instead of the events saving it records.
+	// It returns ErrConversationExists if the ID is taken, even by a conversation in the trash.
+	ImportConversation(ctx context.Context, conversation *entities.Conversation, prompt *entities.SystemPrompt, messages []*entities.Message, history []Event) error
+	// GetAllConversations lists every conversation, including those in the trash, in ID order after afterID
+	GetAllConversations(ctx context.Context, afterID string, limit int) ([]*entities.Conversation, error)
 	UpdateConversation(ctx context.Context, conversation *entities.Conversation) error
-	DeleteConversation(ctx context.Context, id string) error
+	DeleteConversation(ctx context.Context, id string) error // Moves the conversation to the trash
+	ListConversations(ctx context.Context, filter ConversationFilter) ([]*entities.Conversation, error)
+	GetTags(ctx context.Context) ([]TagCount, error) // Tags in use by conversations outside the trash
+
+	// Folder operations
+	SaveFolder(ctx context.Context, folder *entities.Folder) error
+	GetFolder(ctx context.Context, id string) (*entities.Folder, error)
+	GetFolders(ctx context.Context) ([]*entities.Folder, error)
+	UpdateFolder(ctx context.Context, folder *entities.Folder) error
+	DeleteFolder(ctx context.Context, id string) error // Moves child folders and conversations to the parent folder
+
+	// Trash operations
+	GetDeletedConversations(ctx context.Context, limit int, offset int) ([]*entities.Conversation, error)
+	RestoreConversation(ctx context.Context, id string) error
+	PurgeConversations(ctx context.Context, deletedBefore time.Time) (int, error)
+	GetDeletedSystemPrompts(ctx context.Context) ([]*entities.SystemPrompt, error)
+	RestoreSystemPrompt(ctx context.Context, id string) error
+	PurgeSystemPrompts(ctx context.Context, deletedBefore time.Time) (int, error) // Keeps prompts still referenced by conversations
 
 	// System prompt operations
 	SaveSystemPrompt(ctx context.Context, prompt *entities.SystemPrompt) error
-	GetSystemPrompt(ctx context.Context, id string) (*entities.SystemPrompt, error)
-	GetSystemPrompts(ctx context.Context) ([]*entities.SystemPrompt, error)
+	GetSystemPrompt(ctx context.Context, id string) (*entities.SystemPrompt, error) // Includes prompts in the trash
+	GetSystemPrompts(ctx context.Context) ([]*entities.SystemPrompt, error)         // Excludes prompts in the trash
 	UpdateSystemPrompt(ctx context.Context, prompt *entities.SystemPrompt) error
-	DeleteSystemPrompt(ctx context.Context, id string) error
+	DeleteSystemPrompt(ctx context.Context, id string) error // Moves the prompt to the trash
+	CountConversationsForSystemPrompt(ctx context.Context, id string) (int, error)
 
 	// Tool call operations
 	SaveToolCall(ctx context.Context, toolCall *entities.ToolCall) error
 	GetToolCall(ctx context.Context, id string) (*entities.ToolCall, error)
 	GetToolCallsForMessage(ctx context.Context, messageID string) ([]*entities.ToolCall, error)
 	UpdateToolCall(ctx context.Context, toolCall *entities.ToolCall) error
+	// UpdateToolCallFromStatus updates a tool call only while its stored status is from, reporting whether it did
+	UpdateToolCallFromStatus(ctx context.Context, toolCall *entities.ToolCall, from entities.ToolCallStatus) (bool, error)
+	ListToolCalls(ctx context.Context, filter ToolCallFilter) ([]*entities.ToolCall, error) // Newest first
+	GetToolCallStats(ctx context.Context, filter ToolCallFilter) ([]ToolCallStats, error)   // Per tool, most used first
 
 	// Event operations (for event sourcing)
 	SaveEvent(ctx context.Context, conversationID, eventType string, payload map[string]interface{}) error
-	GetEvents(ctx context.Context, conversationID string, limit int) ([]Event, error)
+	GetEvents(ctx context.Context, conversationID string, limit int) ([]Event, error)               // Newest first
+	GetEventLog(ctx context.Context, conversationID string, limit int, offset int) ([]Event, error) // Oldest first; limit <= 0 returns all
+	ImportEvent(ctx context.Context, event Event) (bool, error)                                     // Stores an event verbatim unless its ID is taken, reporting whether it did
+	// GetUnattachedEvents lists the events that belong to no conversation, in ID order after afterID
+	GetUnattachedEvents(ctx context.Context, afterID string, limit int) ([]Event, error)
+
+	// ReplaceConversation overwrites a conversation and its messages without recording events,
+	// applying a projection rebuilt from the event log
+	ReplaceConversation(ctx context.Context, conversation *entities.Conversation, messages []*entities.Message) error
+
+	// Retention operations
+	PruneConversations(ctx context.Context, olderThan time.Time, archive bool) (int, error) // Archives or trashes, skipping pinned conversations
+	PruneEvents(ctx context.Context, maxPerConversation int) (int, error)                   // Keeps the newest events
+	Vacuum(ctx context.Context) error
 
 	// Health check
 	Ping(ctx context.Context) error
@@ -53,3 +117,70 @@ type Event struct {
 	Payload        map[string]interface{} `json:"payload"`
 	CreatedAt      string                 `json:"created_at"` // ISO 8601 timestamp
 }
+
+// Conversation sort fields accepted by ConversationFilter.SortBy
+const (
+	SortByUpdatedAt = "updated_at"
+	SortByCreatedAt = "created_at"
+	SortByTitle     = "title"
+)
+
+// ConversationFilter selects and orders conversations for ListConversations.
+// Nil pointer fields don't filter. Pinned conversations always sort first.
+type ConversationFilter struct {
+	Tags              []string // Conversations must carry every tag
+	FolderID          *string  // Empty string selects conversations outside any folder
+	IncludeSubfolders bool     // Also match conversations in folders nested under FolderID
+	Pinned            *bool
+	Starred           *bool
+	Archived          *bool
+	SortBy            string // One of the SortBy constants, defaults to SortByUpdatedAt
+	Descending        bool
+	Limit             int
+	Offset            int
+}
+
+// MessageSearch selects user and assistant messages matching free text for SearchMessages
+type MessageSearch struct {
+	Text           string   // Words to look for; messages with more of them, and rarer ones, rank higher
+	ConversationID string   // Limits the search to one conversation when set
+	ExcludeIDs     []string // Messages left out of the results
+	Limit          int
+}
+
+// MessageHit is a message matching a search
+type MessageHit struct {
+	Message           *entities.Message
+	ConversationTitle string
+	Snippet           string  // Excerpt around the matching words
+	Score             float64 // BM25 relevance; higher is better
+}
+
+// ToolCallFilter selects tool calls for ListToolCalls and GetToolCallStats. Empty fields don't filter.
+type ToolCallFilter struct {
+	Tool           string
+	Status         entities.ToolCallStatus
+	ConversationID string
+	Since          *time.Time // Calls made at or after this time
+	Until          *time.Time // Calls made before this time
+	Limit          int        // Ignored by GetToolCallStats
+	Offset         int
+}
+
+// ToolCallStats aggregates the calls to one tool
+type ToolCallStats struct {
+	Tool      string  `json:"tool"`
+	Count     int     `json:"count"`
+	Succeeded int     `json:"succeeded"`
+	Failed    int     `json:"failed"`
+	Rejected  int     `json:"rejected"`
+	ErrorRate float64 `json:"error_rate"` // Failed share of the calls that ran to completion
+	P50Ms     int64   `json:"p50_ms"`     // Median time the tool took to run
+	P95Ms     int64   `json:"p95_ms"`
+}
+
+// TagCount reports how many conversations carry a tag
+type TagCount struct {
+	Tag   string `json:"tag"`
+	Count int    `json:"count"`
+}
@@ -0,0 +1,63 @@
+package ports
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var (
+	// ErrStreamsNotSupported is returned when the messaging adapter doesn't keep message history
+	ErrStreamsNotSupported = errors.New("message streams are not supported by this messaging adapter")
+
+	// ErrStreamNotFound is returned when inspecting a stream that doesn't exist
+	ErrStreamNotFound = errors.New("stream not found")
+
+	// ErrStreamMessageNotFound is returned when a stream holds no message with the requested sequence
+	ErrStreamMessageNotFound = errors.New("stream message not found")
+)
+
+// StreamInspector is implemented by messaging adapters that keep published messages in streams,
+// so their history can be inspected and replayed
+type StreamInspector interface {
+	// ListStreams returns the streams and their statistics
+	ListStreams(ctx context.Context) ([]StreamInfo, error)
+
+	// GetStreamMessages returns stored messages from a stream, oldest first
+	GetStreamMessages(ctx context.Context, stream string, query StreamQuery) ([]StreamMessage, error)
+
+	// GetStreamMessage returns the message stored in a stream under a sequence
+	GetStreamMessage(ctx context.Context, stream string, sequence uint64) (*StreamMessage, error)
+}
+
+// StreamInfo describes a stream and the messages it currently holds
+type StreamInfo struct {
+	Name          string        `json:"name"`
+	Subjects      []string      `json:"subjects"`
+	Messages      uint64        `json:"messages"`
+	Bytes         uint64        `json:"bytes"`
+	FirstSequence uint64        `json:"first_sequence"`
+	LastSequence  uint64        `json:"last_sequence"`
+	FirstTime     time.Time     `json:"first_time"`
+	LastTime      time.Time     `json:"last_time"`
+	Consumers     int           `json:"consumers"`
+	MaxAge        time.Duration `json:"max_age"`
+}
+
+// StreamQuery selects messages from a stream. Zero values don't filter.
+type StreamQuery struct {
+	Subject       string    // Subject filter, wildcards allowed
+	StartSequence uint64    // First sequence to return
+	Since         time.Time // Only messages stored at or after this time
+	Until         time.Time // Only messages stored before this time
+	Limit         int       // Maximum number of messages
+}
+
+// StreamMessage is a message stored in a stream
+type StreamMessage struct {
+	Stream    string    `json:"stream"`
+	Sequence  uint64    `json:"sequence"`
+	Subject   string    `json:"subject"`
+	Timestamp time.Time `json:"timestamp"`
+	Data      []byte    `json:"-"`
+}
@@ -2,11 +2,14 @@ package ports
 
 import (
 	"context"
+
+	"github.com/username/hexarag/internal/domain/entities"
 )
 
 // ToolPort defines the interface for tool execution
 type ToolPort interface {
-	// Execute runs a tool with the given arguments
+	// Execute runs a tool with the given arguments. It must return soon after ctx is cancelled,
+	// which happens when the call times out.
 	Execute(ctx context.Context, name string, arguments map[string]interface{}) (*ToolResult, error)
 
 	// GetAvailableTools returns the list of available tools
@@ -25,6 +28,19 @@ type Tool struct {
 	Function ToolFunction `json:"function"`
 }
 
+// ToolInfo describes a tool together with the provider it comes from
+type ToolInfo struct {
+	Tool
+	Provider     string `json:"provider"`      // Namespace the providing ToolPort is registered under
+	ProviderKind string `json:"provider_kind"` // Kind of provider, e.g. "builtin" or "mcp"
+	OriginalName string `json:"original_name"` // Name of the tool within its provider
+}
+
+// ToolCatalog lists the tools of every provider with their provenance
+type ToolCatalog interface {
+	ListTools(ctx context.Context) ([]ToolInfo, error)
+}
+
 // ToolFunction represents the function definition
 type ToolFunction struct {
 	Name        string                 `json:"name"`
@@ -49,10 +65,36 @@ type ToolExecutionRequest struct {
 	ConversationID string                 `json:"conversation_id"`
 }
 
+type toolCallKey struct{}
+
+// WithToolCall returns a context for executing a tool call, so tools can tell where they were called from.
+// The tool executor calls it before running a tool.
+func WithToolCall(ctx context.Context, request *ToolExecutionRequest) context.Context {
+	return context.WithValue(ctx, toolCallKey{}, request)
+}
+
+// ToolCallFromContext returns the tool call being executed, if any
+func ToolCallFromContext(ctx context.Context) (*ToolExecutionRequest, bool) {
+	request, ok := ctx.Value(toolCallKey{}).(*ToolExecutionRequest)
+	return request, ok
+}
+
+// ToolApprovalEvent announces a tool call awaiting a user's approval, or the user's decision on it
+type ToolApprovalEvent struct {
+	ToolCallID     string                  `json:"tool_call_id"`
+	Name           string                  `json:"name"`
+	Arguments      map[string]interface{}  `json:"arguments"`
+	MessageID      string                  `json:"message_id"`
+	ConversationID string                  `json:"conversation_id"`
+	Status         entities.ToolCallStatus `json:"status"`           // awaiting_approval, approved or rejected
+	Reason         string                  `json:"reason,omitempty"` // Given when rejecting
+}
+
 // ToolExecutionResponse represents the response from tool execution
 type ToolExecutionResponse struct {
 	ToolCallID     string      `json:"tool_call_id"`
 	Result         *ToolResult `json:"result"`
 	MessageID      string      `json:"message_id"`
 	ConversationID string      `json:"conversation_id"`
+	DurationMs     int64       `json:"duration_ms,omitempty"` // Time the tool took to run
 }
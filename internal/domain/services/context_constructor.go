@@ -3,6 +3,7 @@ package services
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"time"
@@ -18,10 +19,12 @@ type ContextConstructor struct {
 	messaging ports.MessagingPort
 	tokenizer *tokenizer.Tokenizer
 	maxTokens int
+	pool      *WorkerPool
+	handled   *messageDeduper
 }
 
 // NewContextConstructor creates a new context constructor service
-func NewContextConstructor(storage ports.StoragePort, messaging ports.MessagingPort, model string, maxTokens int) (*ContextConstructor, error) {
+func NewContextConstructor(storage ports.StoragePort, messaging ports.MessagingPort, model string, maxTokens int, workers WorkerPoolConfig) (*ContextConstructor, error) {
 	tokenizer, err := tokenizer.NewTokenizer(model)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create tokenizer: %w", err)
@@ -32,6 +35,8 @@ func NewContextConstructor(storage ports.StoragePort, messaging ports.MessagingP
 		messaging: messaging,
 		tokenizer: tokenizer,
 		maxTokens: maxTokens,
+		pool:      NewWorkerPool("context-constructor", workers),
+		handled:   newMessageDeduper(dedupeWindow),
 	}, nil
 }
 
@@ -48,6 +53,7 @@ type ContextResponse struct {
 	ConversationID   string                 `json:"conversation_id"`
 	MessageID        string                 `json:"message_id"`
 	SystemPrompt     string                 `json:"system_prompt"`
+	Model            string                 `json:"model,omitempty"` // Conversation's preferred model, if any
 	Messages         []*entities.Message    `json:"messages"`
 	TokenCount       int                    `json:"token_count"`
 	TruncatedHistory bool                   `json:"truncated_history"`
@@ -56,6 +62,9 @@ type ContextResponse struct {
 
 // StartListening starts the context constructor service by subscribing to relevant events
 func (cc *ContextConstructor) StartListening(ctx context.Context) error {
+	ctx = ports.WithSource(ctx, "context-constructor")
+	cc.pool.Start(ctx)
+
 	// Subscribe to context requests
 	err := cc.messaging.SubscribeQueue(ctx, ports.SubjectContextRequest, "context-constructor", cc.handleContextRequest)
 	if err != nil {
@@ -66,17 +75,42 @@ func (cc *ContextConstructor) StartListening(ctx context.Context) error {
 	return nil
 }
 
-// handleContextRequest processes incoming context construction requests
+// handleContextRequest builds and publishes the context for a request on the worker pool.
+// Requests are acked once their context is published; failures are returned so the broker
+// redelivers or dead-letters them, and a full queue is nacked so the broker holds the backlog.
+// Redelivered requests for a message that is being or has been answered are dropped.
 func (cc *ContextConstructor) handleContextRequest(ctx context.Context, subject string, data []byte) error {
 	var request ContextRequest
 	if err := json.Unmarshal(data, &request); err != nil {
-		return fmt.Errorf("failed to unmarshal context request: %w", err)
+		return ports.Terminate(fmt.Errorf("failed to unmarshal context request: %w", err)) // Malformed payloads never succeed
+	}
+
+	claimed, err := cc.handled.claimUnanswered(ctx, cc.storage, request.MessageID)
+	if err != nil {
+		return fmt.Errorf("failed to check for a reply to message %s: %w", request.MessageID, err)
+	}
+	if !claimed {
+		log.Printf("Skipping duplicate context request for message %s", request.MessageID)
+		return nil
 	}
 
+	err = cc.pool.Run(ctx, func() error { return cc.processContextRequest(ctx, &request) })
+	if err != nil {
+		cc.handled.release(request.MessageID)
+		if errors.Is(err, ErrQueueFull) {
+			return ports.Nack(fmt.Errorf("failed to queue context request for conversation %s: %w", request.ConversationID, err), 0)
+		}
+		return err
+	}
+	return nil
+}
+
+// processContextRequest builds and publishes the context for a request
+func (cc *ContextConstructor) processContextRequest(ctx context.Context, request *ContextRequest) error {
 	log.Printf("Processing context request for conversation %s", request.ConversationID)
 
 	// Build the context
-	response, err := cc.BuildContext(ctx, &request)
+	response, err := cc.BuildContext(ctx, request)
 	if err != nil {
 		log.Printf("Failed to build context for conversation %s: %v", request.ConversationID, err)
 
@@ -88,18 +122,16 @@ func (cc *ContextConstructor) handleContextRequest(ctx context.Context, subject
 			"timestamp":       time.Now(),
 		}
 		cc.messaging.PublishJSON(ctx, ports.SubjectSystemError, errorEvent)
-		return err
+		return fmt.Errorf("failed to build context for conversation %s: %w", request.ConversationID, err)
 	}
 
 	// Publish the constructed context
-	err = cc.messaging.PublishJSON(ctx, ports.SubjectContextReady, response)
-	if err != nil {
-		return fmt.Errorf("failed to publish context response: %w", err)
+	if err := cc.messaging.PublishJSON(ctx, ports.SubjectContextReady, response); err != nil {
+		return fmt.Errorf("failed to publish context response for conversation %s: %w", request.ConversationID, err)
 	}
 
 	log.Printf("Context built successfully for conversation %s (%d tokens)",
 		request.ConversationID, response.TokenCount)
-
 	return nil
 }
 
@@ -141,6 +173,7 @@ func (cc *ContextConstructor) BuildContext(ctx context.Context, request *Context
 		ConversationID:   request.ConversationID,
 		MessageID:        request.MessageID,
 		SystemPrompt:     systemPrompt.Content,
+		Model:            conversation.Model,
 		Messages:         messages,
 		TokenCount:       totalTokens,
 		TruncatedHistory: truncated,
@@ -286,6 +319,21 @@ func (cc *ContextConstructor) AnalyzeConversation(ctx context.Context, conversat
 	return analysis, nil
 }
 
+// WorkerStats returns the load on the context constructor's worker pool
+func (cc *ContextConstructor) WorkerStats() WorkerPoolStats {
+	return cc.pool.Stats()
+}
+
+// Saturated reports whether new context requests would be rejected
+func (cc *ContextConstructor) Saturated() bool {
+	return cc.pool.Full()
+}
+
+// Busy reports whether new context requests would wait for a worker
+func (cc *ContextConstructor) Busy() bool {
+	return cc.pool.Busy()
+}
+
 // UpdateTokenizer updates the tokenizer when the model changes
 func (cc *ContextConstructor) UpdateTokenizer(model string) error {
 	newTokenizer, err := tokenizer.NewTokenizer(model)
@@ -0,0 +1,354 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/username/hexarag/internal/domain/entities"
+	"github.com/username/hexarag/internal/domain/ports"
+)
+
+// ExportFormatVersion identifies the layout of ConversationExport documents
+const ExportFormatVersion = 1
+
+// Supported export formats
+const (
+	ExportFormatJSON        = "json"
+	ExportFormatMarkdown    = "markdown"
+	ExportFormatOpenAIJSONL = "openai-jsonl"
+)
+
+var (
+	// ErrConversationExists is returned when importing a conversation whose ID is already taken
+	ErrConversationExists = ports.ErrConversationExists
+
+	// ErrInvalidExport is returned when importing a document that isn't a valid conversation export
+	ErrInvalidExport = errors.New("invalid conversation export")
+)
+
+// ConversationExport is the portable, lossless representation of a conversation
+type ConversationExport struct {
+	Version      int                    `json:"version"`
+	ExportedAt   time.Time              `json:"exported_at"`
+	Conversation *entities.Conversation `json:"conversation"`
+	SystemPrompt *entities.SystemPrompt `json:"system_prompt,omitempty"`
+	Messages     []*entities.Message    `json:"messages"`
+}
+
+// ConversationTransfer exports conversations to shareable formats and imports them back
+type ConversationTransfer struct {
+	storage ports.StoragePort
+}
+
+// NewConversationTransfer creates a new conversation transfer service
+func NewConversationTransfer(storage ports.StoragePort) *ConversationTransfer {
+	return &ConversationTransfer{
+		storage: storage,
+	}
+}
+
+// Export collects a conversation with its system prompt, messages and tool calls
+func (ct *ConversationTransfer) Export(ctx context.Context, conversationID string) (*ConversationExport, error) {
+	conversation, err := ct.storage.GetConversation(ctx, conversationID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get conversation: %w", err)
+	}
+
+	return ct.exportConversation(ctx, conversation)
+}
+
+// exportConversation collects the system prompt, messages and tool calls of a loaded conversation,
+// which may be in the trash
+func (ct *ConversationTransfer) exportConversation(ctx context.Context, conversation *entities.Conversation) (*ConversationExport, error) {
+	prompt, err := ct.storage.GetSystemPrompt(ctx, conversation.SystemPromptID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get system prompt: %w", err)
+	}
+
+	// Ask for one more message than the conversation knows about so nothing is cut off
+	messages, err := ct.storage.GetMessages(ctx, conversation.ID, len(conversation.MessageIDs)+1)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get messages: %w", err)
+	}
+	if messages == nil {
+		messages = []*entities.Message{}
+	}
+
+	return &ConversationExport{
+		Version:      ExportFormatVersion,
+		ExportedAt:   time.Now(),
+		Conversation: conversation,
+		SystemPrompt: prompt,
+		Messages:     messages,
+	}, nil
+}
+
+// Import stores an exported conversation, preserving all IDs and timestamps. Nothing is stored unless
+// the whole conversation is.
+func (ct *ConversationTransfer) Import(ctx context.Context, export *ConversationExport) (*entities.Conversation, error) {
+	return ct.importConversation(ctx, export, nil)
+}
+
+// importConversation imports a conversation as Import does. A non-nil history becomes the
+// conversation's event log in place of the events recorded by storing it.
+func (ct *ConversationTransfer) importConversation(ctx context.Context, export *ConversationExport, history []ports.Event) (*entities.Conversation, error) {
+	if err := validateExport(export); err != nil {
+		return nil, err
+	}
+	conversation := export.Conversation
+
+	var prompt *entities.SystemPrompt
+	if export.SystemPrompt != nil {
+		var err error
+		prompt, conversation.SystemPromptID, err = ct.matchSystemPrompt(ctx, export.SystemPrompt)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Folders are local to an instance; keep the conversation only if its folder exists here
+	if conversation.FolderID != "" {
+		if _, err := ct.storage.GetFolder(ctx, conversation.FolderID); err != nil {
+			conversation.FolderID = ""
+		}
+	}
+
+	messageIDs := make([]string, 0, len(export.Messages))
+	for _, message := range export.Messages {
+		message.ConversationID = conversation.ID
+		for i := range message.ToolCalls {
+			message.ToolCalls[i].MessageID = message.ID
+		}
+		messageIDs = append(messageIDs, message.ID)
+	}
+
+	if err := ct.storage.ImportConversation(ctx, conversation, prompt, export.Messages, history); err != nil {
+		return nil, fmt.Errorf("failed to import conversation: %w", err)
+	}
+	conversation.MessageIDs = messageIDs
+
+	return conversation, nil
+}
+
+// validateExport checks an export before anything is stored, since it may come from a user
+func validateExport(export *ConversationExport) error {
+	if export == nil || export.Conversation == nil {
+		return fmt.Errorf("%w: it does not contain a conversation", ErrInvalidExport)
+	}
+	if export.Version > ExportFormatVersion {
+		return fmt.Errorf("%w: unsupported version %d", ErrInvalidExport, export.Version)
+	}
+	if export.Conversation.ID == "" {
+		return fmt.Errorf("%w: conversation ID cannot be empty", ErrInvalidExport)
+	}
+
+	seen := make(map[string]bool, len(export.Messages))
+	for i, message := range export.Messages {
+		if message == nil {
+			return fmt.Errorf("%w: message %d is empty", ErrInvalidExport, i)
+		}
+		if message.ID == "" {
+			return fmt.Errorf("%w: message %d has no ID", ErrInvalidExport, i)
+		}
+		if seen[message.ID] {
+			return fmt.Errorf("%w: duplicate message ID %s", ErrInvalidExport, message.ID)
+		}
+		seen[message.ID] = true
+
+		switch message.Role {
+		case entities.RoleUser, entities.RoleAssistant, entities.RoleSystem, entities.RoleTool:
+		default:
+			return fmt.Errorf("%w: message %s has invalid role %q", ErrInvalidExport, message.ID, message.Role)
+		}
+	}
+	return nil
+}
+
+// matchSystemPrompt finds the local prompt with the content of an exported system prompt, preferring
+// one with its ID. Without one, the exported prompt is returned to be imported, under a new ID if its
+// ID is taken and with a new name if its name is.
+func (ct *ConversationTransfer) matchSystemPrompt(ctx context.Context, exported *entities.SystemPrompt) (*entities.SystemPrompt, string, error) {
+	prompts, err := ct.storage.GetSystemPrompts(ctx)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to get system prompts: %w", err)
+	}
+	deleted, err := ct.storage.GetDeletedSystemPrompts(ctx)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to get deleted system prompts: %w", err)
+	}
+	prompts = append(prompts, deleted...)
+
+	names := make(map[string]bool, len(prompts))
+	idTaken := false
+	var sameContent *entities.SystemPrompt
+	for _, prompt := range prompts {
+		names[prompt.Name] = true
+		if prompt.ID == exported.ID {
+			if prompt.Content == exported.Content {
+				return nil, prompt.ID, nil
+			}
+			idTaken = true
+		} else if prompt.Content == exported.Content && sameContent == nil {
+			sameContent = prompt
+		}
+	}
+	if sameContent != nil {
+		return nil, sameContent.ID, nil
+	}
+
+	imported := *exported
+	if idTaken {
+		imported.ID = entities.NewSystemPrompt(exported.Name, exported.Content).ID
+	}
+	for i := 1; names[imported.Name]; i++ {
+		if i == 1 {
+			imported.Name = fmt.Sprintf("%s (imported)", exported.Name)
+		} else {
+			imported.Name = fmt.Sprintf("%s (imported %d)", exported.Name, i)
+		}
+	}
+	return &imported, imported.ID, nil
+}
+
+// RenderJSON renders an export as indented JSON
+func (ct *ConversationTransfer) RenderJSON(export *ConversationExport) ([]byte, error) {
+	return json.MarshalIndent(export, "", "  ")
+}
+
+// RenderMarkdown renders an export as a human-readable Markdown transcript
+func (ct *ConversationTransfer) RenderMarkdown(export *ConversationExport) []byte {
+	var b strings.Builder
+
+	title := export.Conversation.Title
+	if title == "" {
+		title = "Untitled conversation"
+	}
+
+	fmt.Fprintf(&b, "# %s\n\n", title)
+	fmt.Fprintf(&b, "- **Conversation ID:** `%s`\n", export.Conversation.ID)
+	if export.Conversation.Model != "" {
+		fmt.Fprintf(&b, "- **Model:** `%s`\n", export.Conversation.Model)
+	}
+	fmt.Fprintf(&b, "- **Created:** %s\n", export.Conversation.CreatedAt.Format(time.RFC3339))
+	fmt.Fprintf(&b, "- **Exported:** %s\n\n", export.ExportedAt.Format(time.RFC3339))
+
+	if export.SystemPrompt != nil {
+		fmt.Fprintf(&b, "## System Prompt: %s\n\n", export.SystemPrompt.Name)
+		fmt.Fprintf(&b, "> %s\n\n", strings.ReplaceAll(export.SystemPrompt.Content, "\n", "\n> "))
+	}
+
+	for _, message := range export.Messages {
+		heading := string(message.Role)
+		if heading == "" {
+			heading = "unknown"
+		}
+		heading = strings.ToUpper(heading[:1]) + heading[1:]
+		if message.Model != "" {
+			heading = fmt.Sprintf("%s (%s)", heading, message.Model)
+		}
+		fmt.Fprintf(&b, "## %s\n\n", heading)
+		fmt.Fprintf(&b, "_%s_\n\n", message.CreatedAt.Format(time.RFC3339))
+
+		if message.Content != "" {
+			fmt.Fprintf(&b, "%s\n\n", message.Content)
+		}
+
+		for _, toolCall := range message.ToolCalls {
+			fmt.Fprintf(&b, "**Tool call:** `%s` (%s)\n\n", toolCall.Name, toolCall.Status)
+
+			args, _ := json.MarshalIndent(toolCall.Arguments, "", "  ")
+			fmt.Fprintf(&b, "```json\n%s\n```\n\n", args)
+
+			if toolCall.Result != nil {
+				result, _ := json.MarshalIndent(toolCall.Result, "", "  ")
+				fmt.Fprintf(&b, "**Result:**\n\n```json\n%s\n```\n\n", result)
+			}
+		}
+	}
+
+	return []byte(b.String())
+}
+
+// openAIMessage is a single chat message in the OpenAI fine-tuning format
+type openAIMessage struct {
+	Role       string           `json:"role"`
+	Content    string           `json:"content"`
+	ToolCalls  []openAIToolCall `json:"tool_calls,omitempty"`
+	ToolCallID string           `json:"tool_call_id,omitempty"`
+}
+
+// openAIToolCall is a function call in the OpenAI fine-tuning format
+type openAIToolCall struct {
+	ID       string `json:"id"`
+	Type     string `json:"type"`
+	Function struct {
+		Name      string `json:"name"`
+		Arguments string `json:"arguments"`
+	} `json:"function"`
+}
+
+// RenderOpenAIJSONL renders an export as one OpenAI chat fine-tuning example.
+// Tool results are emitted as "tool" messages following the assistant turn that requested them.
+func (ct *ConversationTransfer) RenderOpenAIJSONL(exports ...*ConversationExport) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+
+	for _, export := range exports {
+		var messages []openAIMessage
+
+		if export.SystemPrompt != nil && !export.SystemPrompt.IsEmpty() {
+			messages = append(messages, openAIMessage{
+				Role:    string(entities.RoleSystem),
+				Content: export.SystemPrompt.Content,
+			})
+		}
+
+		for _, message := range export.Messages {
+			converted := openAIMessage{
+				Role:    string(message.Role),
+				Content: message.Content,
+			}
+
+			for _, toolCall := range message.ToolCalls {
+				args, err := toolCall.ArgumentsJSON()
+				if err != nil {
+					return nil, fmt.Errorf("failed to marshal arguments for tool call %s: %w", toolCall.ID, err)
+				}
+
+				call := openAIToolCall{ID: toolCall.ID, Type: "function"}
+				call.Function.Name = toolCall.Name
+				call.Function.Arguments = args
+				converted.ToolCalls = append(converted.ToolCalls, call)
+			}
+			messages = append(messages, converted)
+
+			for _, toolCall := range message.ToolCalls {
+				if toolCall.Result == nil {
+					continue
+				}
+
+				content, err := json.Marshal(toolCall.Result)
+				if err != nil {
+					return nil, fmt.Errorf("failed to marshal result for tool call %s: %w", toolCall.ID, err)
+				}
+
+				messages = append(messages, openAIMessage{
+					Role:       string(entities.RoleTool),
+					Content:    string(content),
+					ToolCallID: toolCall.ID,
+				})
+			}
+		}
+
+		if err := encoder.Encode(map[string]interface{}{"messages": messages}); err != nil {
+			return nil, fmt.Errorf("failed to encode conversation %s: %w", export.Conversation.ID, err)
+		}
+	}
+
+	return buf.Bytes(), nil
+}
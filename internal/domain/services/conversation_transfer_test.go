@@ -0,0 +1,255 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/username/hexarag/internal/adapters/storage/sqlite"
+	"github.com/username/hexarag/internal/domain/entities"
+	"github.com/username/hexarag/internal/domain/ports"
+)
+
+// newTestStorage creates a migrated SQLite database in a temporary directory
+func newTestStorage(t *testing.T) *sqlite.Adapter {
+	t.Helper()
+
+	storage, err := sqlite.NewAdapter(filepath.Join(t.TempDir(), "test.db"), "../../adapters/storage/sqlite/migrations")
+	if err != nil {
+		t.Fatalf("NewAdapter() error = %v", err)
+	}
+	t.Cleanup(func() { storage.Close() })
+
+	if err := storage.Migrate(context.Background()); err != nil {
+		t.Fatalf("Migrate() error = %v", err)
+	}
+
+	return storage
+}
+
+func newTestExport() *ConversationExport {
+	created := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	conversation := &entities.Conversation{
+		ID:             "conv_1",
+		Title:          "Time zones",
+		SystemPromptID: "shared",
+		Model:          "llama3.2:3b",
+		CreatedAt:      created,
+		UpdatedAt:      created,
+	}
+
+	user := &entities.Message{
+		ID:             "msg_1",
+		ConversationID: conversation.ID,
+		Role:           entities.RoleUser,
+		Content:        "What time is it in Tokyo?",
+		CreatedAt:      created,
+	}
+
+	assistant := &entities.Message{
+		ID:             "msg_2",
+		ConversationID: conversation.ID,
+		Role:           entities.RoleAssistant,
+		Content:        "Let me check.",
+		Model:          "llama3.2:3b",
+		CreatedAt:      created.Add(time.Second),
+	}
+	assistant.AddToolCall(entities.ToolCall{
+		ID:        "call_1",
+		MessageID: assistant.ID,
+		Name:      "get_time_in_timezone",
+		Arguments: map[string]interface{}{"timezone": "Asia/Tokyo"},
+		Status:    entities.ToolCallStatusSuccess,
+		Result: &entities.ToolCallResult{
+			Data:      map[string]interface{}{"timestamp": "2025-01-02T12:04:05+09:00"},
+			Timestamp: created.Add(2 * time.Second),
+		},
+		CreatedAt: created.Add(time.Second),
+	})
+
+	return &ConversationExport{
+		Version:      ExportFormatVersion,
+		ExportedAt:   created.Add(time.Hour),
+		Conversation: conversation,
+		SystemPrompt: &entities.SystemPrompt{
+			ID:        "shared",
+			Name:      "Shared Prompt",
+			Content:   "You are terse.",
+			CreatedAt: created,
+			UpdatedAt: created,
+		},
+		Messages: []*entities.Message{user, assistant},
+	}
+}
+
+func TestConversationTransfer_RoundTrip(t *testing.T) {
+	ctx := context.Background()
+	source := NewConversationTransfer(newTestStorage(t))
+	target := NewConversationTransfer(newTestStorage(t))
+
+	if _, err := source.Import(ctx, newTestExport()); err != nil {
+		t.Fatalf("Import() error = %v", err)
+	}
+
+	exported, err := source.Export(ctx, "conv_1")
+	if err != nil {
+		t.Fatalf("Export() error = %v", err)
+	}
+
+	// Ship the export between instances as JSON
+	data, err := source.RenderJSON(exported)
+	if err != nil {
+		t.Fatalf("RenderJSON() error = %v", err)
+	}
+	var shipped ConversationExport
+	if err := json.Unmarshal(data, &shipped); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+
+	if _, err := target.Import(ctx, &shipped); err != nil {
+		t.Fatalf("Import() into second instance error = %v", err)
+	}
+
+	roundTripped, err := target.Export(ctx, "conv_1")
+	if err != nil {
+		t.Fatalf("Export() from second instance error = %v", err)
+	}
+
+	if roundTripped.Conversation.Model != "llama3.2:3b" {
+		t.Errorf("Expected conversation model to survive, got %q", roundTripped.Conversation.Model)
+	}
+	if roundTripped.SystemPrompt.Content != "You are terse." {
+		t.Errorf("Expected system prompt to survive, got %q", roundTripped.SystemPrompt.Content)
+	}
+	if len(roundTripped.Messages) != 2 {
+		t.Fatalf("Expected 2 messages, got %d", len(roundTripped.Messages))
+	}
+
+	assistant := roundTripped.Messages[1]
+	if assistant.Model != "llama3.2:3b" {
+		t.Errorf("Expected message model to survive, got %q", assistant.Model)
+	}
+	if len(assistant.ToolCalls) != 1 || assistant.ToolCalls[0].Result == nil {
+		t.Fatalf("Expected tool call with result, got %+v", assistant.ToolCalls)
+	}
+	if assistant.ToolCalls[0].Arguments["timezone"] != "Asia/Tokyo" {
+		t.Errorf("Expected tool call arguments to survive, got %v", assistant.ToolCalls[0].Arguments)
+	}
+
+	if _, err := target.Import(ctx, &shipped); !errors.Is(err, ErrConversationExists) {
+		t.Errorf("Expected ErrConversationExists on second import, got %v", err)
+	}
+}
+
+func TestConversationTransfer_ImportConflicts(t *testing.T) {
+	ctx := context.Background()
+	storage := newTestStorage(t)
+	transfer := NewConversationTransfer(storage)
+
+	// A local prompt holds both the exported prompt's ID and its name, with other content
+	local := &entities.SystemPrompt{ID: "shared", Name: "Shared Prompt", Content: "You are verbose.", CreatedAt: time.Now(), UpdatedAt: time.Now()}
+	if err := storage.SaveSystemPrompt(ctx, local); err != nil {
+		t.Fatalf("SaveSystemPrompt() error = %v", err)
+	}
+
+	// A failing import stores nothing, so it can be retried
+	broken := newTestExport()
+	broken.Messages = append(broken.Messages, broken.Messages[0])
+	if _, err := transfer.Import(ctx, broken); err == nil {
+		t.Fatal("Expected an import with duplicate messages to fail")
+	}
+	if _, err := storage.GetConversation(ctx, "conv_1"); !errors.Is(err, ports.ErrConversationNotFound) {
+		t.Fatalf("Expected the failed import to be rolled back, got %v", err)
+	}
+
+	conversation, err := transfer.Import(ctx, newTestExport())
+	if err != nil {
+		t.Fatalf("Import() error = %v", err)
+	}
+	if conversation.SystemPromptID == "shared" {
+		t.Fatal("Expected the differing prompt to be imported under a new ID")
+	}
+	prompt, err := storage.GetSystemPrompt(ctx, conversation.SystemPromptID)
+	if err != nil {
+		t.Fatalf("GetSystemPrompt() error = %v", err)
+	}
+	if prompt.Content != "You are terse." || prompt.Name != "Shared Prompt (imported)" {
+		t.Errorf("Expected the exported prompt under a new name, got %+v", prompt)
+	}
+
+	// The same prompt is reused by later imports
+	again := newTestExport()
+	again.Conversation.ID = "conv_2"
+	for _, message := range again.Messages {
+		message.ID += "_again"
+	}
+	again.Messages[1].ToolCalls[0].ID = "call_2"
+	if conversation, err := transfer.Import(ctx, again); err != nil || conversation.SystemPromptID != prompt.ID {
+		t.Errorf("Expected the imported prompt to be reused, got %+v (%v)", conversation, err)
+	}
+
+	// Messages without a role still render
+	export := newTestExport()
+	export.Messages[0].Role = ""
+	if !strings.Contains(string(transfer.RenderMarkdown(export)), "## Unknown") {
+		t.Error("Expected a message without a role to render as Unknown")
+	}
+}
+
+func TestConversationTransfer_RenderOpenAIJSONL(t *testing.T) {
+	transfer := NewConversationTransfer(nil)
+
+	data, err := transfer.RenderOpenAIJSONL(newTestExport())
+	if err != nil {
+		t.Fatalf("RenderOpenAIJSONL() error = %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("Expected one JSONL line per conversation, got %d", len(lines))
+	}
+
+	var example struct {
+		Messages []openAIMessage `json:"messages"`
+	}
+	if err := json.Unmarshal([]byte(lines[0]), &example); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+
+	roles := make([]string, 0, len(example.Messages))
+	for _, m := range example.Messages {
+		roles = append(roles, m.Role)
+	}
+	if got := strings.Join(roles, ","); got != "system,user,assistant,tool" {
+		t.Errorf("Expected roles system,user,assistant,tool, got %s", got)
+	}
+
+	if example.Messages[2].ToolCalls[0].Function.Arguments != `{"timezone":"Asia/Tokyo"}` {
+		t.Errorf("Unexpected tool call arguments: %s", example.Messages[2].ToolCalls[0].Function.Arguments)
+	}
+	if example.Messages[3].ToolCallID != "call_1" {
+		t.Errorf("Expected tool message to reference call_1, got %s", example.Messages[3].ToolCallID)
+	}
+}
+
+func TestConversationTransfer_RenderMarkdown(t *testing.T) {
+	transfer := NewConversationTransfer(nil)
+
+	markdown := string(transfer.RenderMarkdown(newTestExport()))
+
+	for _, want := range []string{
+		"# Time zones",
+		"## System Prompt: Shared Prompt",
+		"## Assistant (llama3.2:3b)",
+		"**Tool call:** `get_time_in_timezone` (success)",
+	} {
+		if !strings.Contains(markdown, want) {
+			t.Errorf("Expected markdown to contain %q", want)
+		}
+	}
+}
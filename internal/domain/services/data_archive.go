@@ -0,0 +1,334 @@
+package services
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/username/hexarag/internal/domain/entities"
+	"github.com/username/hexarag/internal/domain/ports"
+)
+
+// ArchiveFormatVersion identifies the layout of data archives
+const ArchiveFormatVersion = 1
+
+// Archive record kinds, one JSON record per line
+const (
+	archiveKindManifest     = "manifest"
+	archiveKindSystemPrompt = "system_prompt"
+	archiveKindFolder       = "folder"
+	archiveKindConversation = "conversation"
+	archiveKindEvents       = "events" // Events that belong to no conversation
+)
+
+// archivePageSize is the number of conversations read per storage round-trip
+const archivePageSize = 100
+
+// archiveEventPageSize is the number of events read per storage round-trip, and written per
+// record of events without a conversation
+const archiveEventPageSize = 1000
+
+// ArchiveManifest describes a data archive
+type ArchiveManifest struct {
+	Version   int       `json:"version"`
+	CreatedAt time.Time `json:"created_at"`
+	Source    string    `json:"source,omitempty"`
+}
+
+// ArchiveSummary reports what was written to or read from an archive
+type ArchiveSummary struct {
+	SystemPrompts int `json:"system_prompts"`
+	Folders       int `json:"folders"`
+	Conversations int `json:"conversations"`
+	Messages      int `json:"messages"`
+	ToolCalls     int `json:"tool_calls"`
+	Events        int `json:"events"`
+	Skipped       int `json:"skipped"`
+}
+
+// archiveRecord is a single line of a data archive
+type archiveRecord struct {
+	Kind         string                 `json:"kind"`
+	Manifest     *ArchiveManifest       `json:"manifest,omitempty"`
+	SystemPrompt *entities.SystemPrompt `json:"system_prompt,omitempty"`
+	Folder       *entities.Folder       `json:"folder,omitempty"`
+	Conversation *ConversationExport    `json:"conversation,omitempty"`
+	Events       []ports.Event          `json:"events,omitempty"`
+}
+
+// DataArchiver moves the full contents of a storage adapter to and from an archive of
+// gzip-compressed JSON lines. Archives hold entities rather than database rows, so they can be
+// restored into a database created by a later schema version.
+type DataArchiver struct {
+	storage  ports.StoragePort
+	transfer *ConversationTransfer
+}
+
+// NewDataArchiver creates a new data archiver for the given storage
+func NewDataArchiver(storage ports.StoragePort) *DataArchiver {
+	return &DataArchiver{
+		storage:  storage,
+		transfer: NewConversationTransfer(storage),
+	}
+}
+
+// Export writes every system prompt, folder, conversation, message, tool call and event to w.
+// Prompts and conversations in the trash are included, with their deletion times.
+func (da *DataArchiver) Export(ctx context.Context, w io.Writer, source string) (*ArchiveSummary, error) {
+	gz := gzip.NewWriter(w)
+	encoder := json.NewEncoder(gz)
+	summary := &ArchiveSummary{}
+
+	manifest := &ArchiveManifest{
+		Version:   ArchiveFormatVersion,
+		CreatedAt: time.Now(),
+		Source:    source,
+	}
+	if err := encoder.Encode(archiveRecord{Kind: archiveKindManifest, Manifest: manifest}); err != nil {
+		return nil, fmt.Errorf("failed to write archive manifest: %w", err)
+	}
+
+	prompts, err := da.storage.GetSystemPrompts(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get system prompts: %w", err)
+	}
+	deletedPrompts, err := da.storage.GetDeletedSystemPrompts(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get deleted system prompts: %w", err)
+	}
+	prompts = append(prompts, deletedPrompts...)
+	for _, prompt := range prompts {
+		if err := encoder.Encode(archiveRecord{Kind: archiveKindSystemPrompt, SystemPrompt: prompt}); err != nil {
+			return nil, fmt.Errorf("failed to write system prompt %s: %w", prompt.ID, err)
+		}
+		summary.SystemPrompts++
+	}
+
+	folders, err := da.storage.GetFolders(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get folders: %w", err)
+	}
+	for _, folder := range parentsFirst(folders) {
+		if err := encoder.Encode(archiveRecord{Kind: archiveKindFolder, Folder: folder}); err != nil {
+			return nil, fmt.Errorf("failed to write folder %s: %w", folder.ID, err)
+		}
+		summary.Folders++
+	}
+
+	for afterID := ""; ; {
+		conversations, err := da.storage.GetAllConversations(ctx, afterID, archivePageSize)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get conversations: %w", err)
+		}
+
+		for _, conversation := range conversations {
+			export, err := da.transfer.exportConversation(ctx, conversation)
+			if err != nil {
+				return nil, fmt.Errorf("failed to export conversation %s: %w", conversation.ID, err)
+			}
+
+			events, err := da.conversationEvents(ctx, conversation.ID)
+			if err != nil {
+				return nil, err
+			}
+
+			if err := encoder.Encode(archiveRecord{Kind: archiveKindConversation, Conversation: export, Events: events}); err != nil {
+				return nil, fmt.Errorf("failed to write conversation %s: %w", conversation.ID, err)
+			}
+
+			summary.add(export, len(events))
+		}
+
+		if len(conversations) < archivePageSize {
+			break
+		}
+		afterID = conversations[len(conversations)-1].ID
+	}
+
+	for afterID := ""; ; {
+		events, err := da.storage.GetUnattachedEvents(ctx, afterID, archiveEventPageSize)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get events: %w", err)
+		}
+		if len(events) == 0 {
+			break
+		}
+
+		if err := encoder.Encode(archiveRecord{Kind: archiveKindEvents, Events: events}); err != nil {
+			return nil, fmt.Errorf("failed to write events: %w", err)
+		}
+		summary.Events += len(events)
+		afterID = events[len(events)-1].ID
+	}
+
+	if err := gz.Close(); err != nil {
+		return nil, fmt.Errorf("failed to finish archive: %w", err)
+	}
+
+	return summary, nil
+}
+
+// conversationEvents returns a conversation's whole event log, oldest first
+func (da *DataArchiver) conversationEvents(ctx context.Context, conversationID string) ([]ports.Event, error) {
+	var events []ports.Event
+	for {
+		page, err := da.storage.GetEventLog(ctx, conversationID, archiveEventPageSize, len(events))
+		if err != nil {
+			return nil, fmt.Errorf("failed to get events for conversation %s: %w", conversationID, err)
+		}
+		events = append(events, page...)
+
+		if len(page) < archiveEventPageSize {
+			return events, nil
+		}
+	}
+}
+
+// Restore reads an archive produced by Export and writes its contents to storage.
+// Records that already exist in the target storage are skipped. A conversation's archived events
+// become its event log as they are, without the events recorded when importing it.
+func (da *DataArchiver) Restore(ctx context.Context, r io.Reader) (*ArchiveSummary, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open archive: %w", err)
+	}
+	defer gz.Close()
+
+	decoder := json.NewDecoder(bufio.NewReader(gz))
+	summary := &ArchiveSummary{}
+
+	for {
+		var record archiveRecord
+		if err := decoder.Decode(&record); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, fmt.Errorf("failed to read archive record: %w", err)
+		}
+
+		if err := record.validate(); err != nil {
+			return nil, err
+		}
+
+		switch record.Kind {
+		case archiveKindManifest:
+			if record.Manifest.Version > ArchiveFormatVersion {
+				return nil, fmt.Errorf("unsupported archive manifest")
+			}
+
+		case archiveKindSystemPrompt:
+			if _, err := da.storage.GetSystemPrompt(ctx, record.SystemPrompt.ID); err == nil {
+				summary.Skipped++
+				continue
+			}
+			if err := da.storage.SaveSystemPrompt(ctx, record.SystemPrompt); err != nil {
+				return nil, fmt.Errorf("failed to restore system prompt %s: %w", record.SystemPrompt.ID, err)
+			}
+			summary.SystemPrompts++
+
+		case archiveKindFolder:
+			if _, err := da.storage.GetFolder(ctx, record.Folder.ID); err == nil {
+				summary.Skipped++
+				continue
+			}
+			if err := da.storage.SaveFolder(ctx, record.Folder); err != nil {
+				return nil, fmt.Errorf("failed to restore folder %s: %w", record.Folder.ID, err)
+			}
+			summary.Folders++
+
+		case archiveKindConversation:
+			if _, err := da.transfer.importConversation(ctx, record.Conversation, record.Events); err != nil {
+				if errors.Is(err, ErrConversationExists) {
+					summary.Skipped++
+					continue
+				}
+				return nil, fmt.Errorf("failed to restore conversation: %w", err)
+			}
+
+			summary.add(record.Conversation, len(record.Events))
+
+		case archiveKindEvents:
+			for _, event := range record.Events {
+				imported, err := da.storage.ImportEvent(ctx, event)
+				if err != nil {
+					return nil, fmt.Errorf("failed to restore event %s: %w", event.ID, err)
+				}
+				if !imported {
+					summary.Skipped++
+					continue
+				}
+				summary.Events++
+			}
+		}
+	}
+
+	return summary, nil
+}
+
+// validate checks that a record read from an archive carries the payload of its kind
+func (r *archiveRecord) validate() error {
+	var valid bool
+	switch r.Kind {
+	case archiveKindManifest:
+		valid = r.Manifest != nil
+	case archiveKindSystemPrompt:
+		valid = r.SystemPrompt != nil && r.SystemPrompt.ID != ""
+	case archiveKindFolder:
+		valid = r.Folder != nil && r.Folder.ID != ""
+	case archiveKindConversation:
+		valid = validateExport(r.Conversation) == nil
+	case archiveKindEvents:
+		valid = true
+	default:
+		return fmt.Errorf("unknown archive record kind: %s", r.Kind)
+	}
+
+	if !valid {
+		return fmt.Errorf("invalid %s record", r.Kind)
+	}
+	return nil
+}
+
+// parentsFirst orders folders so that every parent precedes its children
+func parentsFirst(folders []*entities.Folder) []*entities.Folder {
+	children := make(map[string][]*entities.Folder)
+	known := make(map[string]bool, len(folders))
+	for _, folder := range folders {
+		known[folder.ID] = true
+	}
+	for _, folder := range folders {
+		parent := folder.ParentID
+		if !known[parent] {
+			parent = ""
+		}
+		children[parent] = append(children[parent], folder)
+	}
+
+	ordered := make([]*entities.Folder, 0, len(folders))
+	queue := []string{""}
+	for len(queue) > 0 {
+		parent := queue[0]
+		queue = queue[1:]
+		for _, folder := range children[parent] {
+			ordered = append(ordered, folder)
+			queue = append(queue, folder.ID)
+		}
+	}
+
+	return ordered
+}
+
+// add accumulates counts for one conversation
+func (s *ArchiveSummary) add(export *ConversationExport, events int) {
+	s.Conversations++
+	s.Messages += len(export.Messages)
+	for _, message := range export.Messages {
+		s.ToolCalls += len(message.ToolCalls)
+	}
+	s.Events += events
+}
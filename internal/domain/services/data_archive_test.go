@@ -0,0 +1,156 @@
+package services
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/username/hexarag/internal/domain/ports"
+)
+
+func TestDataArchiver_ExportRestore(t *testing.T) {
+	ctx := context.Background()
+	source := newTestStorage(t)
+	target := newTestStorage(t)
+
+	if _, err := NewConversationTransfer(source).Import(ctx, newTestExport()); err != nil {
+		t.Fatalf("Import() error = %v", err)
+	}
+	if err := source.SaveEvent(ctx, "conv_1", "title.changed", map[string]interface{}{"title": "Time zones"}); err != nil {
+		t.Fatalf("SaveEvent() error = %v", err)
+	}
+	if _, err := source.ImportEvent(ctx, ports.Event{ID: "evt_system", EventType: "system.maintenance", Payload: map[string]interface{}{}, CreatedAt: "2025-01-02T03:04:05Z"}); err != nil {
+		t.Fatalf("ImportEvent() error = %v", err)
+	}
+	// Trashed data is archived too
+	if err := source.DeleteConversation(ctx, "conv_1"); err != nil {
+		t.Fatalf("DeleteConversation() error = %v", err)
+	}
+	if err := source.DeleteSystemPrompt(ctx, "shared"); err != nil {
+		t.Fatalf("DeleteSystemPrompt() error = %v", err)
+	}
+
+	var archive bytes.Buffer
+	exported, err := NewDataArchiver(source).Export(ctx, &archive, "test")
+	if err != nil {
+		t.Fatalf("Export() error = %v", err)
+	}
+	if exported.Conversations != 1 || exported.Messages != 2 || exported.ToolCalls != 1 || exported.Events != 6 {
+		t.Errorf("Unexpected export summary: %+v", exported)
+	}
+
+	restored, err := NewDataArchiver(target).Restore(ctx, bytes.NewReader(archive.Bytes()))
+	if err != nil {
+		t.Fatalf("Restore() error = %v", err)
+	}
+	// The import recorded conversation.created and message.added, so the archive holds 5 conversation events
+	// and the event without a conversation
+	if restored.Conversations != 1 || restored.Events != 6 || restored.SystemPrompts != 1 {
+		t.Errorf("Unexpected restore summary: %+v", restored)
+	}
+	trashed, err := target.GetDeletedConversations(ctx, 10, 0)
+	if err != nil {
+		t.Fatalf("GetDeletedConversations() error = %v", err)
+	}
+	if len(trashed) != 1 || trashed[0].ID != "conv_1" || trashed[0].DeletedAt == nil {
+		t.Errorf("Expected the conversation to be restored to the trash, got %+v", trashed)
+	}
+	if prompt, err := target.GetSystemPrompt(ctx, "shared"); err != nil || !prompt.IsDeleted() {
+		t.Errorf("Expected the system prompt to be restored to the trash, got %+v (%v)", prompt, err)
+	}
+
+	events, err := target.GetEventLog(ctx, "conv_1", 0, 0)
+	if err != nil {
+		t.Fatalf("GetEventLog() error = %v", err)
+	}
+	// The archived history is restored as it was, without the events of importing the conversation again
+	var types []string
+	for _, event := range events {
+		types = append(types, event.EventType)
+	}
+	want := []string{"conversation.created", "message.added", "message.added", "title.changed", "conversation.deleted"}
+	if !slices.Equal(types, want) {
+		t.Errorf("Expected the archived event log %v, got %v", want, types)
+	}
+	if unattached, err := target.GetUnattachedEvents(ctx, "", 10); err != nil || len(unattached) != 1 || unattached[0].ID != "evt_system" {
+		t.Errorf("Expected the event without a conversation to be restored, got %+v (%v)", unattached, err)
+	}
+
+	projection, err := Project("conv_1", events)
+	if err != nil {
+		t.Fatalf("Project() error = %v", err)
+	}
+	if projection.Conversation.Title != "Time zones" || len(projection.Messages) != 2 {
+		t.Errorf("Unexpected projection: %+v", projection)
+	}
+
+	// Restoring again skips everything
+	again, err := NewDataArchiver(target).Restore(ctx, bytes.NewReader(archive.Bytes()))
+	if err != nil {
+		t.Fatalf("Restore() again error = %v", err)
+	}
+	if skipped := restored.Skipped + restored.SystemPrompts + restored.Conversations + 1; again.Conversations != 0 || again.Events != 0 || again.Skipped != skipped {
+		t.Errorf("Expected the second restore to skip every record, got %+v", again)
+	}
+}
+
+func TestDataArchiver_ExportsWholeEventLogs(t *testing.T) {
+	ctx := context.Background()
+	source := newTestStorage(t)
+
+	if _, err := NewConversationTransfer(source).Import(ctx, newTestExport()); err != nil {
+		t.Fatalf("Import() error = %v", err)
+	}
+	// More events than one page, after the 3 recorded by the import
+	for i := 0; i < archiveEventPageSize; i++ {
+		if err := source.SaveEvent(ctx, "conv_1", "title.changed", map[string]interface{}{"title": fmt.Sprintf("Title %d", i)}); err != nil {
+			t.Fatalf("SaveEvent() error = %v", err)
+		}
+	}
+
+	var archive bytes.Buffer
+	exported, err := NewDataArchiver(source).Export(ctx, &archive, "test")
+	if err != nil {
+		t.Fatalf("Export() error = %v", err)
+	}
+	if want := archiveEventPageSize + 3; exported.Events != want {
+		t.Errorf("Expected all %d events to be exported, got %d", want, exported.Events)
+	}
+
+	target := newTestStorage(t)
+	if _, err := NewDataArchiver(target).Restore(ctx, &archive); err != nil {
+		t.Fatalf("Restore() error = %v", err)
+	}
+	events, err := target.GetEventLog(ctx, "conv_1", 0, 0)
+	if err != nil {
+		t.Fatalf("GetEventLog() error = %v", err)
+	}
+	if len(events) != archiveEventPageSize+3 || events[0].EventType != "conversation.created" {
+		t.Errorf("Expected the whole event log from conversation.created, got %d events", len(events))
+	}
+}
+
+func TestDataArchiver_RejectsMalformedRecords(t *testing.T) {
+	ctx := context.Background()
+
+	for _, line := range []string{
+		`{"kind":"folder"}`,
+		`{"kind":"system_prompt","system_prompt":{}}`,
+		`{"kind":"conversation"}`,
+		`{"kind":"conversation","conversation":{"conversation":{"id":"conv_1"},"messages":[null]}}`,
+		`{"kind":"manifest"}`,
+		`{"kind":"unknown"}`,
+	} {
+		var archive bytes.Buffer
+		gz := gzip.NewWriter(&archive)
+		gz.Write([]byte(line + "\n"))
+		gz.Close()
+
+		if _, err := NewDataArchiver(newTestStorage(t)).Restore(ctx, &archive); err == nil {
+			t.Errorf("Expected restoring %s to fail", line)
+		}
+	}
+}
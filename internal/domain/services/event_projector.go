@@ -0,0 +1,186 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/username/hexarag/internal/domain/entities"
+	"github.com/username/hexarag/internal/domain/ports"
+)
+
+// ErrIncompleteEventLog is returned when a conversation's event log doesn't start with its creation,
+// e.g. for conversations created before event recording or logs capped by retention
+var ErrIncompleteEventLog = errors.New("event log is incomplete")
+
+// Projection is the state of a conversation rebuilt from its event log
+type Projection struct {
+	Conversation  *entities.Conversation `json:"conversation"`
+	Messages      []*entities.Message    `json:"messages"`
+	EventsApplied int                    `json:"events_applied"`
+}
+
+// EventProjector rebuilds conversations and their messages by replaying domain events
+type EventProjector struct {
+	storage ports.StoragePort
+}
+
+// NewEventProjector creates a new event projector
+func NewEventProjector(storage ports.StoragePort) *EventProjector {
+	return &EventProjector{
+		storage: storage,
+	}
+}
+
+// Replay projects a conversation from its stored event log without changing storage
+func (ep *EventProjector) Replay(ctx context.Context, conversationID string) (*Projection, error) {
+	events, err := ep.storage.GetEventLog(ctx, conversationID, 0, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load event log: %w", err)
+	}
+
+	return Project(conversationID, events)
+}
+
+// Rebuild replays a conversation's event log and overwrites the stored conversation and messages with the result
+func (ep *EventProjector) Rebuild(ctx context.Context, conversationID string) (*Projection, error) {
+	projection, err := ep.Replay(ctx, conversationID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := ep.storage.ReplaceConversation(ctx, projection.Conversation, projection.Messages); err != nil {
+		return nil, fmt.Errorf("failed to store projection: %w", err)
+	}
+
+	return projection, nil
+}
+
+// Project folds an event log, oldest first, into the conversation it describes.
+// Events that aren't domain events are skipped.
+func Project(conversationID string, events []ports.Event) (*Projection, error) {
+	var conversation *entities.Conversation
+	var messages []*entities.Message
+	applied := 0
+
+	for _, event := range events {
+		payload, err := json.Marshal(event.Payload)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode event %s: %w", event.ID, err)
+		}
+
+		domainEvent, err := entities.DecodeEvent(event.EventType, payload)
+		if errors.Is(err, entities.ErrUnknownEventType) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode event %s: %w", event.ID, err)
+		}
+
+		// A creation snapshot supersedes everything before it, e.g. when a conversation is re-imported
+		if created, ok := domainEvent.(*entities.ConversationCreated); ok {
+			conversation = created.Conversation(conversationID)
+			messages = nil
+			applied++
+			continue
+		}
+
+		if conversation == nil {
+			return nil, fmt.Errorf("%w: %s has %s before conversation.created", ErrIncompleteEventLog, conversationID, event.EventType)
+		}
+
+		switch e := domainEvent.(type) {
+		case *entities.TitleChanged:
+			conversation.Title = e.Title
+		case *entities.SystemPromptChanged:
+			conversation.SystemPromptID = e.SystemPromptID
+		case *entities.ModelSwitched:
+			conversation.Model = e.Model
+		case *entities.ConversationMoved:
+			conversation.FolderID = e.FolderID
+		case *entities.TagsChanged:
+			conversation.Tags = entities.NormalizeTags(e.Tags)
+		case *entities.PinnedChanged:
+			conversation.Pinned = e.Pinned
+		case *entities.StarredChanged:
+			conversation.Starred = e.Starred
+		case *entities.ToolSettingsChanged:
+			conversation.Tools = e.Tools
+		case *entities.ConversationArchived:
+			archivedAt := e.ArchivedAt
+			conversation.ArchivedAt = &archivedAt
+		case *entities.ConversationUnarchived:
+			conversation.ArchivedAt = nil
+		case *entities.ConversationDeleted:
+			deletedAt := e.DeletedAt
+			conversation.DeletedAt = &deletedAt
+		case *entities.ConversationRestored:
+			conversation.DeletedAt = nil
+		case *entities.MessageAdded:
+			message := e.Message
+			messages = append(messages, &message)
+		case *entities.ToolCallCompleted:
+			applyToolCall(messages, e.ToolCall)
+		case *entities.ToolApprovalRequested:
+			applyToolCall(messages, e.ToolCall)
+		case *entities.ToolCallApproved:
+			applyToolCall(messages, e.ToolCall)
+		case *entities.ToolCallRejected:
+			applyToolCall(messages, e.ToolCall)
+		}
+
+		if touchesUpdatedAt(domainEvent) {
+			if occurredAt, err := time.Parse(time.RFC3339Nano, event.CreatedAt); err == nil {
+				conversation.UpdatedAt = occurredAt
+			}
+		}
+		applied++
+	}
+
+	if conversation == nil {
+		return nil, fmt.Errorf("%w: no conversation.created event for %s", ErrIncompleteEventLog, conversationID)
+	}
+
+	conversation.MessageIDs = make([]string, 0, len(messages))
+	for _, message := range messages {
+		conversation.MessageIDs = append(conversation.MessageIDs, message.ID)
+	}
+
+	return &Projection{
+		Conversation:  conversation,
+		Messages:      messages,
+		EventsApplied: applied,
+	}, nil
+}
+
+// touchesUpdatedAt reports whether an event counts as activity on the conversation.
+// Lifecycle changes made by retention and tool results arriving later don't, so that
+// rebuilt conversations keep their place in retention and recency ordering.
+func touchesUpdatedAt(event entities.DomainEvent) bool {
+	switch event.(type) {
+	case *entities.ConversationArchived, *entities.ConversationUnarchived,
+		*entities.ConversationDeleted, *entities.ConversationRestored,
+		*entities.ToolCallCompleted, *entities.ToolApprovalRequested,
+		*entities.ToolCallApproved, *entities.ToolCallRejected:
+		return false
+	default:
+		return true
+	}
+}
+
+// applyToolCall replaces the matching tool call on its message with its latest state
+func applyToolCall(messages []*entities.Message, toolCall entities.ToolCall) {
+	for _, message := range messages {
+		if message.ID != toolCall.MessageID {
+			continue
+		}
+		for i := range message.ToolCalls {
+			if message.ToolCalls[i].ID == toolCall.ID {
+				message.ToolCalls[i] = toolCall
+				return
+			}
+		}
+	}
+}
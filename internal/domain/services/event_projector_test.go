@@ -0,0 +1,130 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/username/hexarag/internal/domain/entities"
+)
+
+func TestEventProjector_Rebuild(t *testing.T) {
+	ctx := context.Background()
+	storage := newTestStorage(t)
+	projector := NewEventProjector(storage)
+
+	conversation := entities.NewConversation("Draft", "default")
+	if err := storage.SaveConversation(ctx, conversation); err != nil {
+		t.Fatalf("SaveConversation() error = %v", err)
+	}
+
+	question := entities.NewMessage(conversation.ID, entities.RoleUser, "What time is it in Tokyo?")
+	if err := storage.SaveMessage(ctx, question); err != nil {
+		t.Fatalf("SaveMessage() error = %v", err)
+	}
+
+	answer := entities.NewMessage(conversation.ID, entities.RoleAssistant, "Let me check.")
+	toolCall := entities.NewToolCall(answer.ID, "get_time_in_timezone", map[string]interface{}{"timezone": "Asia/Tokyo"})
+	answer.AddToolCall(*toolCall)
+	if err := storage.SaveMessage(ctx, answer); err != nil {
+		t.Fatalf("SaveMessage() error = %v", err)
+	}
+
+	toolCall.SetResult(map[string]interface{}{"time": "12:00"})
+	if err := storage.UpdateToolCall(ctx, toolCall); err != nil {
+		t.Fatalf("UpdateToolCall() error = %v", err)
+	}
+
+	conversation.SetTitle("Tokyo time")
+	conversation.SetModel("llama3.2:3b")
+	conversation.SetTags([]string{"Travel"})
+	conversation.SetStarred(true)
+	if err := storage.UpdateConversation(ctx, conversation); err != nil {
+		t.Fatalf("UpdateConversation() error = %v", err)
+	}
+
+	events, err := storage.GetEventLog(ctx, conversation.ID, 0, 0)
+	if err != nil {
+		t.Fatalf("GetEventLog() error = %v", err)
+	}
+	want := []string{
+		entities.EventConversationCreated,
+		entities.EventMessageAdded,
+		entities.EventMessageAdded,
+		entities.EventToolCallCompleted,
+		entities.EventTitleChanged,
+		entities.EventModelSwitched,
+		entities.EventTagsChanged,
+		entities.EventStarredChanged,
+	}
+	if len(events) != len(want) {
+		t.Fatalf("Expected %d events, got %+v", len(want), events)
+	}
+	for i, event := range events {
+		if event.EventType != want[i] {
+			t.Errorf("Event %d: expected %s, got %s", i, want[i], event.EventType)
+		}
+	}
+
+	// Drift the stored state away from the log, then rebuild it
+	drifted := *conversation
+	drifted.Title = "Corrupted"
+	if err := storage.ReplaceConversation(ctx, &drifted, []*entities.Message{question}); err != nil {
+		t.Fatalf("ReplaceConversation() error = %v", err)
+	}
+
+	projection, err := projector.Rebuild(ctx, conversation.ID)
+	if err != nil {
+		t.Fatalf("Rebuild() error = %v", err)
+	}
+	if projection.EventsApplied != len(want) {
+		t.Errorf("Expected %d events applied, got %d", len(want), projection.EventsApplied)
+	}
+
+	rebuilt, err := storage.GetConversation(ctx, conversation.ID)
+	if err != nil {
+		t.Fatalf("GetConversation() error = %v", err)
+	}
+	if rebuilt.Title != "Tokyo time" || rebuilt.Model != "llama3.2:3b" || !rebuilt.Starred || !rebuilt.HasTag("travel") {
+		t.Errorf("Unexpected rebuilt conversation: %+v", rebuilt)
+	}
+	if len(rebuilt.MessageIDs) != 2 || rebuilt.MessageIDs[1] != answer.ID {
+		t.Errorf("Expected both messages after rebuild, got %v", rebuilt.MessageIDs)
+	}
+
+	rebuiltCall, err := storage.GetToolCall(ctx, toolCall.ID)
+	if err != nil {
+		t.Fatalf("GetToolCall() error = %v", err)
+	}
+	if rebuiltCall.Status != entities.ToolCallStatusSuccess {
+		t.Errorf("Expected completed tool call after rebuild, got %s", rebuiltCall.Status)
+	}
+
+	// Rebuilding doesn't record events of its own
+	after, err := storage.GetEventLog(ctx, conversation.ID, 0, 0)
+	if err != nil || len(after) != len(events) {
+		t.Errorf("Expected event log to be unchanged, got %d events (err = %v)", len(after), err)
+	}
+}
+
+func TestEventProjector_IncompleteLog(t *testing.T) {
+	ctx := context.Background()
+	storage := newTestStorage(t)
+
+	conversation := entities.NewConversation("Capped", "default")
+	if err := storage.SaveConversation(ctx, conversation); err != nil {
+		t.Fatalf("SaveConversation() error = %v", err)
+	}
+	if err := storage.SaveMessage(ctx, entities.NewMessage(conversation.ID, entities.RoleUser, "Hello")); err != nil {
+		t.Fatalf("SaveMessage() error = %v", err)
+	}
+
+	// Capping the log drops conversation.created
+	if _, err := storage.PruneEvents(ctx, 1); err != nil {
+		t.Fatalf("PruneEvents() error = %v", err)
+	}
+
+	if _, err := NewEventProjector(storage).Replay(ctx, conversation.ID); !errors.Is(err, ErrIncompleteEventLog) {
+		t.Errorf("Expected ErrIncompleteEventLog, got %v", err)
+	}
+}
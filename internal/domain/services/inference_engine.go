@@ -3,29 +3,59 @@ package services
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/username/hexarag/internal/domain/entities"
 	"github.com/username/hexarag/internal/domain/ports"
 )
 
+// ErrGenerationCancelled is the cause of a generation's context being cancelled on request
+var ErrGenerationCancelled = errors.New("generation cancelled")
+
+// ErrToolCallNotAwaitingApproval is returned when deciding on a tool call that isn't waiting for approval
+var ErrToolCallNotAwaitingApproval = errors.New("tool call is not awaiting approval")
+
+// maxToolSteps bounds how many consecutive tool-calling replies answer one user message
+const maxToolSteps = 5
+
 // InferenceEngine orchestrates LLM inference and tool execution
 type InferenceEngine struct {
 	storage   ports.StoragePort
 	messaging ports.MessagingPort
 	llm       ports.LLMPort
 	tools     ports.ToolPort
+	pool      *WorkerPool
+	models    *modelLimiter
+	handled   *messageDeduper
+
+	generations      map[string][]*generation // conversation_id -> queued and running generations
+	generationsMutex sync.Mutex
+}
+
+// generation is an inference request that can still be cancelled
+type generation struct {
+	messageID string
+	cancel    context.CancelCauseFunc
 }
 
-// NewInferenceEngine creates a new inference engine service
-func NewInferenceEngine(storage ports.StoragePort, messaging ports.MessagingPort, llm ports.LLMPort, tools ports.ToolPort) *InferenceEngine {
+// NewInferenceEngine creates a new inference engine service.
+// maxInFlightPerModel bounds concurrent LLM calls to each model; 0 is unlimited.
+func NewInferenceEngine(storage ports.StoragePort, messaging ports.MessagingPort, llm ports.LLMPort, tools ports.ToolPort, workers WorkerPoolConfig, maxInFlightPerModel int) *InferenceEngine {
 	return &InferenceEngine{
 		storage:   storage,
 		messaging: messaging,
 		llm:       llm,
 		tools:     tools,
+		pool:      NewWorkerPool("inference-engine", workers),
+		models:    newModelLimiter(maxInFlightPerModel),
+		handled:   newMessageDeduper(dedupeWindow),
+
+		generations: make(map[string][]*generation),
 	}
 }
 
@@ -50,23 +80,39 @@ type InferenceResponse struct {
 	FinishReason    string                 `json:"finish_reason"`
 	TokenUsage      *ports.TokenUsage      `json:"token_usage,omitempty"`
 	ProcessingTime  time.Duration          `json:"processing_time"`
+	CorrelationID   string                 `json:"correlation_id,omitempty"` // Correlation ID of the request that caused this response
 	Metadata        map[string]interface{} `json:"metadata"`
 }
 
 // StartListening starts the inference engine by subscribing to relevant events
 func (ie *InferenceEngine) StartListening(ctx context.Context) error {
+	ctx = ports.WithSource(ctx, "inference-engine")
+	ie.pool.Start(ctx)
+
 	// Subscribe to inference requests
 	err := ie.messaging.SubscribeQueue(ctx, ports.SubjectInferenceRequest, "inference-engine", ie.handleInferenceRequest)
 	if err != nil {
 		return fmt.Errorf("failed to subscribe to inference requests: %w", err)
 	}
 
+	// Subscribe to constructed contexts
+	err = ie.messaging.SubscribeQueue(ctx, ports.SubjectContextReady, "inference-engine", ie.handleContextReady)
+	if err != nil {
+		return fmt.Errorf("failed to subscribe to context responses: %w", err)
+	}
+
 	// Subscribe to tool execution results
 	err = ie.messaging.SubscribeQueue(ctx, ports.SubjectToolResult, "inference-engine", ie.handleToolResult)
 	if err != nil {
 		return fmt.Errorf("failed to subscribe to tool results: %w", err)
 	}
 
+	// Every instance must see cancellations, since any of them may be running the generation
+	err = ie.messaging.SubscribeEphemeral(ctx, ports.SubjectConversationCancelAll, ie.handleCancel)
+	if err != nil {
+		return fmt.Errorf("failed to subscribe to cancellations: %w", err)
+	}
+
 	log.Println("Inference Engine service started and listening for events")
 	return nil
 }
@@ -75,13 +121,141 @@ func (ie *InferenceEngine) StartListening(ctx context.Context) error {
 func (ie *InferenceEngine) handleInferenceRequest(ctx context.Context, subject string, data []byte) error {
 	var request InferenceRequest
 	if err := json.Unmarshal(data, &request); err != nil {
-		return fmt.Errorf("failed to unmarshal inference request: %w", err)
+		return ports.Terminate(fmt.Errorf("failed to unmarshal inference request: %w", err)) // Malformed payloads never succeed
+	}
+
+	return ie.queueInference(ctx, &request)
+}
+
+// handleContextReady runs inference on a context built by the context constructor
+func (ie *InferenceEngine) handleContextReady(ctx context.Context, subject string, data []byte) error {
+	var contextResponse ContextResponse
+	if err := json.Unmarshal(data, &contextResponse); err != nil {
+		return ports.Terminate(fmt.Errorf("failed to unmarshal context response: %w", err)) // Malformed payloads never succeed
+	}
+
+	return ie.queueInference(ctx, &InferenceRequest{
+		ConversationID: contextResponse.ConversationID,
+		MessageID:      contextResponse.MessageID,
+		SystemPrompt:   contextResponse.SystemPrompt,
+		Messages:       contextResponse.Messages,
+		Model:          contextResponse.Model,
+		EnableTools:    toolSteps(contextResponse.Messages) < maxToolSteps,
+	})
+}
+
+// toolSteps counts the tool-calling assistant replies since the last user message
+func toolSteps(messages []*entities.Message) int {
+	steps := 0
+	for i := len(messages) - 1; i >= 0 && messages[i].Role != entities.RoleUser; i-- {
+		if messages[i].Role == entities.RoleAssistant && len(messages[i].ToolCalls) > 0 {
+			steps++
+		}
+	}
+	return steps
+}
+
+// queueInference runs an inference request on the worker pool and waits for it to finish.
+// Requests are acked once answered; failures are returned so the broker redelivers or
+// dead-letters them, and a full queue is nacked so the broker holds the backlog.
+// Queued requests can already be cancelled. Redelivered requests for a message that is being
+// or has been answered are dropped.
+func (ie *InferenceEngine) queueInference(ctx context.Context, request *InferenceRequest) error {
+	claimed, err := ie.handled.claimUnanswered(ctx, ie.storage, request.MessageID)
+	if err != nil {
+		return fmt.Errorf("failed to check for a reply to message %s: %w", request.MessageID, err)
+	}
+	if !claimed {
+		log.Printf("Skipping duplicate inference request for message %s", request.MessageID)
+		return nil
 	}
 
-	log.Printf("Processing inference request for conversation %s", request.ConversationID)
+	genCtx, done := ie.trackGeneration(ctx, request)
+	defer done()
+
+	err = ie.pool.Run(ctx, func() error { return ie.runInference(genCtx, request) })
+	if err != nil {
+		ie.handled.release(request.MessageID)
+		if errors.Is(err, ErrQueueFull) {
+			return ports.Nack(fmt.Errorf("failed to queue inference for conversation %s: %w", request.ConversationID, err), 0)
+		}
+		return err
+	}
+	return nil
+}
+
+// trackGeneration returns a context cancelled when the request's generation is cancelled,
+// and the function to call once the generation ends
+func (ie *InferenceEngine) trackGeneration(ctx context.Context, request *InferenceRequest) (context.Context, func()) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	gen := &generation{messageID: request.MessageID, cancel: cancel}
+
+	ie.generationsMutex.Lock()
+	ie.generations[request.ConversationID] = append(ie.generations[request.ConversationID], gen)
+	ie.generationsMutex.Unlock()
+
+	return ctx, func() {
+		ie.generationsMutex.Lock()
+		defer ie.generationsMutex.Unlock()
+
+		gens := ie.generations[request.ConversationID]
+		for i, g := range gens {
+			if g == gen {
+				gens = append(gens[:i], gens[i+1:]...)
+				break
+			}
+		}
+		if len(gens) == 0 {
+			delete(ie.generations, request.ConversationID)
+		} else {
+			ie.generations[request.ConversationID] = gens
+		}
+		cancel(nil)
+	}
+}
+
+// handleCancel cancels the generations named by a cancellation event
+func (ie *InferenceEngine) handleCancel(ctx context.Context, subject string, data []byte) error {
+	var request ports.CancelRequest
+	if err := json.Unmarshal(data, &request); err != nil {
+		return ports.Terminate(fmt.Errorf("failed to unmarshal cancel request: %w", err)) // Malformed payloads never succeed
+	}
+
+	if cancelled := ie.CancelGeneration(request.ConversationID, request.MessageID); cancelled > 0 {
+		log.Printf("Cancelled %d generation(s) for conversation %s", cancelled, request.ConversationID)
+	}
+	return nil
+}
+
+// CancelGeneration cancels the queued and running generations for a conversation, or only the one
+// answering messageID when it is set, and returns how many were cancelled
+func (ie *InferenceEngine) CancelGeneration(conversationID, messageID string) int {
+	ie.generationsMutex.Lock()
+	defer ie.generationsMutex.Unlock()
+
+	cancelled := 0
+	for _, gen := range ie.generations[conversationID] {
+		if messageID == "" || gen.messageID == messageID {
+			gen.cancel(ErrGenerationCancelled)
+			cancelled++
+		}
+	}
+	return cancelled
+}
+
+// cancelled reports whether ctx was cancelled by a cancellation request
+func cancelled(ctx context.Context) bool {
+	return errors.Is(context.Cause(ctx), ErrGenerationCancelled)
+}
+
+// runInference executes an inference request and publishes its response or error.
+// The reply is stored before the response is published, so a redelivery after a failed
+// publish finds it and is skipped rather than generating again.
+func (ie *InferenceEngine) runInference(ctx context.Context, request *InferenceRequest) error {
+	log.Printf("Processing inference request for conversation %s (correlation %s)", request.ConversationID, ports.CorrelationID(ctx))
 
 	// Execute inference
-	response, err := ie.ExecuteInference(ctx, &request)
+	response, err := ie.ExecuteInference(ctx, request)
 	if err != nil {
 		log.Printf("Failed to execute inference for conversation %s: %v", request.ConversationID, err)
 
@@ -93,18 +267,16 @@ func (ie *InferenceEngine) handleInferenceRequest(ctx context.Context, subject s
 			"timestamp":       time.Now(),
 		}
 		ie.messaging.PublishJSON(ctx, ports.SubjectSystemError, errorEvent)
-		return err
+		return fmt.Errorf("failed to execute inference for conversation %s: %w", request.ConversationID, err)
 	}
 
-	// Publish the inference response
-	err = ie.messaging.PublishJSON(ctx, ports.SubjectInferenceResponse, response)
-	if err != nil {
-		return fmt.Errorf("failed to publish inference response: %w", err)
+	// Publish the inference response, even when the generation was cancelled
+	if err := ie.messaging.PublishJSON(context.WithoutCancel(ctx), ports.SubjectInferenceResponse, response); err != nil {
+		return fmt.Errorf("failed to publish inference response for conversation %s: %w", request.ConversationID, err)
 	}
 
 	log.Printf("Inference completed for conversation %s (finish_reason: %s)",
 		request.ConversationID, response.FinishReason)
-
 	return nil
 }
 
@@ -123,7 +295,7 @@ func (ie *InferenceEngine) ExecuteInference(ctx context.Context, request *Infere
 
 	// Add tools if enabled
 	if request.EnableTools {
-		tools, err := ie.tools.GetAvailableTools(ctx)
+		tools, err := ie.availableTools(ctx, request.ConversationID)
 		if err != nil {
 			log.Printf("Warning: failed to get available tools: %v", err)
 		} else {
@@ -131,15 +303,32 @@ func (ie *InferenceEngine) ExecuteInference(ctx context.Context, request *Infere
 		}
 	}
 
-	// Execute completion
+	// Execute completion once the model has capacity
+	release, err := ie.models.acquire(ctx, request.Model)
+	if err != nil {
+		if cancelled(ctx) {
+			return ie.cancelledResponse(ctx, request, "", startTime)
+		}
+		return nil, fmt.Errorf("failed to wait for model %s: %w", request.Model, err)
+	}
 	completionResponse, err := ie.llm.Complete(ctx, completionRequest)
+	release()
 	if err != nil {
+		if cancelled(ctx) {
+			partial := ""
+			if completionResponse != nil && completionResponse.Message != nil {
+				partial = completionResponse.Message.Content
+			}
+			return ie.cancelledResponse(ctx, request, partial, startTime)
+		}
 		return nil, fmt.Errorf("failed to execute LLM completion: %w", err)
 	}
 
 	// Create response message
 	responseMessage := entities.NewMessage(request.ConversationID, entities.RoleAssistant, completionResponse.Message.Content)
 	responseMessage.Model = completionResponse.Model
+	responseMessage.FinishReason = completionResponse.FinishReason
+	responseMessage.ParentID = replyTo(request)
 	responseMessage.TokenCount = 0 // Will be calculated by tokenizer if needed
 
 	// Handle tool calls if present
@@ -152,18 +341,20 @@ func (ie *InferenceEngine) ExecuteInference(ctx context.Context, request *Infere
 			tc.MessageID = responseMessage.ID
 			responseMessage.AddToolCall(*tc)
 		}
-
-		// Execute tool calls
-		if err := ie.executeToolCalls(ctx, toolCalls); err != nil {
-			log.Printf("Warning: tool execution failed: %v", err)
-		}
 	}
 
-	// Save the response message
+	// Save the response message, with its tool calls, before any of them can be executed
 	if err := ie.storage.SaveMessage(ctx, responseMessage); err != nil {
 		return nil, fmt.Errorf("failed to save response message: %w", err)
 	}
 
+	// Execute tool calls
+	if len(toolCalls) > 0 {
+		if err := ie.executeToolCalls(ctx, request.ConversationID, toolCalls); err != nil {
+			log.Printf("Warning: tool execution failed: %v", err)
+		}
+	}
+
 	processingTime := time.Since(startTime)
 
 	// Create inference response
@@ -175,6 +366,7 @@ func (ie *InferenceEngine) ExecuteInference(ctx context.Context, request *Infere
 		FinishReason:    completionResponse.FinishReason,
 		TokenUsage:      completionResponse.Usage,
 		ProcessingTime:  processingTime,
+		CorrelationID:   ports.CorrelationID(ctx),
 		Metadata: map[string]interface{}{
 			"model":              completionResponse.Model,
 			"tools_enabled":      request.EnableTools,
@@ -187,8 +379,62 @@ func (ie *InferenceEngine) ExecuteInference(ctx context.Context, request *Infere
 	return response, nil
 }
 
-// executeToolCalls executes tool calls asynchronously
-func (ie *InferenceEngine) executeToolCalls(ctx context.Context, toolCalls []*entities.ToolCall) error {
+// cancelledResponse saves the content generated before a cancellation as an assistant message
+// marked with the cancelled finish reason
+func (ie *InferenceEngine) cancelledResponse(ctx context.Context, request *InferenceRequest, content string, startTime time.Time) (*InferenceResponse, error) {
+	ctx = context.WithoutCancel(ctx)
+
+	responseMessage := entities.NewMessage(request.ConversationID, entities.RoleAssistant, content)
+	responseMessage.Model = request.Model
+	responseMessage.FinishReason = entities.FinishReasonCancelled
+	responseMessage.ParentID = replyTo(request)
+
+	if err := ie.storage.SaveMessage(ctx, responseMessage); err != nil {
+		return nil, fmt.Errorf("failed to save cancelled message: %w", err)
+	}
+
+	processingTime := time.Since(startTime)
+	return &InferenceResponse{
+		ConversationID:  request.ConversationID,
+		MessageID:       request.MessageID,
+		ResponseMessage: responseMessage,
+		FinishReason:    entities.FinishReasonCancelled,
+		ProcessingTime:  processingTime,
+		CorrelationID:   ports.CorrelationID(ctx),
+		Metadata: map[string]interface{}{
+			"model":              request.Model,
+			"tools_enabled":      request.EnableTools,
+			"processing_time_ms": processingTime.Milliseconds(),
+			"cancelled_at":       time.Now(),
+		},
+	}, nil
+}
+
+// replyTo returns the ID of the message a request answers, linking the reply to it
+func replyTo(request *InferenceRequest) *string {
+	if request.MessageID == "" {
+		return nil
+	}
+	messageID := request.MessageID
+	return &messageID
+}
+
+// availableTools returns the tools enabled for a conversation
+func (ie *InferenceEngine) availableTools(ctx context.Context, conversationID string) ([]ports.Tool, error) {
+	tools, err := ie.tools.GetAvailableTools(ctx)
+	if err != nil || len(tools) == 0 {
+		return tools, err
+	}
+
+	settings, err := conversationToolSettings(ctx, ie.storage, conversationID)
+	if err != nil {
+		return nil, err
+	}
+	return enabledTools(tools, settings), nil
+}
+
+// executeToolCalls publishes tool calls for the tool executor
+func (ie *InferenceEngine) executeToolCalls(ctx context.Context, conversationID string, toolCalls []*entities.ToolCall) error {
 	for _, toolCall := range toolCalls {
 		// Publish tool execution request
 		toolRequest := &ports.ToolExecutionRequest{
@@ -196,7 +442,7 @@ func (ie *InferenceEngine) executeToolCalls(ctx context.Context, toolCalls []*en
 			Name:           toolCall.Name,
 			Arguments:      toolCall.Arguments,
 			MessageID:      toolCall.MessageID,
-			ConversationID: "", // Will be determined by tool executor
+			ConversationID: conversationID,
 		}
 
 		if err := ie.messaging.PublishJSON(ctx, ports.SubjectToolExecute, toolRequest); err != nil {
@@ -215,7 +461,7 @@ func (ie *InferenceEngine) executeToolCalls(ctx context.Context, toolCalls []*en
 func (ie *InferenceEngine) handleToolResult(ctx context.Context, subject string, data []byte) error {
 	var toolResponse ports.ToolExecutionResponse
 	if err := json.Unmarshal(data, &toolResponse); err != nil {
-		return fmt.Errorf("failed to unmarshal tool response: %w", err)
+		return ports.Terminate(fmt.Errorf("failed to unmarshal tool response: %w", err)) // Malformed payloads never succeed
 	}
 
 	// Get the tool call
@@ -229,7 +475,10 @@ func (ie *InferenceEngine) handleToolResult(ctx context.Context, subject string,
 		toolCall.SetResult(toolResponse.Result.Data)
 	} else {
 		toolCall.SetError(toolResponse.Result.Error)
+		toolCall.Result.Data = toolResponse.Result.Data // Details such as validation errors, for the model to correct
 	}
+	toolCall.Result.Metadata = toolResponse.Result.Metadata
+	toolCall.DurationMs = toolResponse.DurationMs
 
 	// Save updated tool call
 	if err := ie.storage.UpdateToolCall(ctx, toolCall); err != nil {
@@ -238,13 +487,123 @@ func (ie *InferenceEngine) handleToolResult(ctx context.Context, subject string,
 
 	log.Printf("Tool call %s completed with status: %s", toolCall.Name, toolCall.Status)
 
-	// Check if we need to continue inference (for multi-step tool calling)
-	// This would be implemented in a more sophisticated version
+	return ie.continueAfterTools(ctx, toolResponse.ConversationID, toolCall.MessageID)
+}
+
+// continueAfterTools requests the next step once every tool call of a message has completed,
+// so the model can answer with the results. Duplicate requests are dropped by the context
+// constructor, since the next step replies to the tool-calling message. A rejected tool call
+// aborts the flow: the model isn't asked to continue.
+func (ie *InferenceEngine) continueAfterTools(ctx context.Context, conversationID, messageID string) error {
+	if conversationID == "" {
+		return nil
+	}
+
+	toolCalls, err := ie.storage.GetToolCallsForMessage(ctx, messageID)
+	if err != nil {
+		return fmt.Errorf("failed to get tool calls for message %s: %w", messageID, err)
+	}
+	for _, toolCall := range toolCalls {
+		if !toolCall.IsCompleted() {
+			return nil
+		}
+		if toolCall.Status == entities.ToolCallStatusRejected {
+			log.Printf("Not continuing after message %s: tool call %s was rejected", messageID, toolCall.ID)
+			return nil
+		}
+	}
+
+	request := &ContextRequest{
+		ConversationID: conversationID,
+		MessageID:      messageID,
+	}
+	if err := ie.messaging.PublishJSON(ctx, ports.SubjectContextRequest, request); err != nil {
+		return fmt.Errorf("failed to request the next step for message %s: %w", messageID, err)
+	}
 	return nil
 }
 
-// ExecuteStreamingInference performs streaming LLM inference
+// ApproveToolCall lets a tool call awaiting approval run. The inference flow resumes once its result arrives.
+func (ie *InferenceEngine) ApproveToolCall(ctx context.Context, toolCallID string) (*entities.ToolCall, error) {
+	toolCall, conversationID, err := ie.resolveApproval(ctx, toolCallID, func(tc *entities.ToolCall) { tc.Approve() }, "")
+	if err != nil {
+		return nil, err
+	}
+
+	toolRequest := &ports.ToolExecutionRequest{
+		ToolCallID:     toolCall.ID,
+		Name:           toolCall.Name,
+		Arguments:      toolCall.Arguments,
+		MessageID:      toolCall.MessageID,
+		ConversationID: conversationID,
+	}
+	if err := ie.messaging.PublishJSON(ctx, ports.SubjectToolExecute, toolRequest); err != nil {
+		// Mark tool call as failed, so that the flow doesn't wait for it forever
+		toolCall.SetError(fmt.Sprintf("Failed to execute tool: %v", err))
+		if updateErr := ie.storage.UpdateToolCall(ctx, toolCall); updateErr != nil {
+			log.Printf("Failed to update tool call %s: %v", toolCall.ID, updateErr)
+		}
+		return nil, fmt.Errorf("failed to publish tool execution request: %w", err)
+	}
+	return toolCall, nil
+}
+
+// RejectToolCall ends a tool call awaiting approval without running it, which aborts the inference flow
+func (ie *InferenceEngine) RejectToolCall(ctx context.Context, toolCallID, reason string) (*entities.ToolCall, error) {
+	toolCall, _, err := ie.resolveApproval(ctx, toolCallID, func(tc *entities.ToolCall) { tc.Reject(reason) }, reason)
+	return toolCall, err
+}
+
+// resolveApproval records a user's decision on a tool call awaiting approval and announces it,
+// returning the tool call and its conversation's ID
+func (ie *InferenceEngine) resolveApproval(ctx context.Context, toolCallID string, decide func(*entities.ToolCall), reason string) (*entities.ToolCall, string, error) {
+	toolCall, err := ie.storage.GetToolCall(ctx, toolCallID)
+	if err != nil {
+		return nil, "", err
+	}
+	if !toolCall.IsAwaitingApproval() {
+		return nil, "", fmt.Errorf("%w: %s is %s", ErrToolCallNotAwaitingApproval, toolCallID, toolCall.Status)
+	}
+
+	message, err := ie.storage.GetMessage(ctx, toolCall.MessageID)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to get message of tool call: %w", err)
+	}
+
+	decide(toolCall)
+	updated, err := ie.storage.UpdateToolCallFromStatus(ctx, toolCall, entities.ToolCallStatusAwaitingApproval)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to update tool call: %w", err)
+	}
+	if !updated {
+		return nil, "", fmt.Errorf("%w: %s was decided concurrently", ErrToolCallNotAwaitingApproval, toolCallID)
+	}
+
+	event := &ports.ToolApprovalEvent{
+		ToolCallID:     toolCall.ID,
+		Name:           toolCall.Name,
+		Arguments:      toolCall.Arguments,
+		MessageID:      toolCall.MessageID,
+		ConversationID: message.ConversationID,
+		Status:         toolCall.Status,
+		Reason:         reason,
+	}
+	if err := ie.messaging.PublishJSON(ctx, ports.SubjectToolApprovalResolved, event); err != nil {
+		log.Printf("Failed to announce decision on tool call %s: %v", toolCall.ID, err)
+	}
+
+	log.Printf("Tool call %s (%s) was %s", toolCall.ID, toolCall.Name, toolCall.Status)
+	return toolCall, message.ConversationID, nil
+}
+
+// ExecuteStreamingInference performs streaming LLM inference.
+// If the generation is cancelled, the content streamed so far is saved as a cancelled message
+// and the handler receives a final chunk with the cancelled finish reason.
 func (ie *InferenceEngine) ExecuteStreamingInference(ctx context.Context, request *InferenceRequest, handler ports.StreamHandler) error {
+	startTime := time.Now()
+	ctx, done := ie.trackGeneration(ctx, request)
+	defer done()
+
 	// Build completion request
 	completionRequest := &ports.CompletionRequest{
 		Messages:     request.Messages,
@@ -257,7 +616,7 @@ func (ie *InferenceEngine) ExecuteStreamingInference(ctx context.Context, reques
 
 	// Add tools if enabled
 	if request.EnableTools {
-		tools, err := ie.tools.GetAvailableTools(ctx)
+		tools, err := ie.availableTools(ctx, request.ConversationID)
 		if err != nil {
 			log.Printf("Warning: failed to get available tools: %v", err)
 		} else {
@@ -265,15 +624,35 @@ func (ie *InferenceEngine) ExecuteStreamingInference(ctx context.Context, reques
 		}
 	}
 
-	// Execute streaming completion
-	return ie.llm.CompleteStream(ctx, completionRequest, handler)
+	// Execute streaming completion once the model has capacity
+	var partial strings.Builder
+	release, err := ie.models.acquire(ctx, request.Model)
+	if err == nil {
+		err = ie.llm.CompleteStream(ctx, completionRequest, func(chunk *ports.StreamChunk) error {
+			partial.WriteString(chunk.Delta)
+			return handler(chunk)
+		})
+		release()
+	} else if !cancelled(ctx) {
+		return fmt.Errorf("failed to wait for model %s: %w", request.Model, err)
+	}
+
+	if err != nil && cancelled(ctx) {
+		if _, err := ie.cancelledResponse(ctx, request, partial.String(), startTime); err != nil {
+			return err
+		}
+		return handler(&ports.StreamChunk{FinishReason: entities.FinishReasonCancelled, Done: true})
+	}
+	return err
 }
 
 // GetInferenceStatus returns current status of the inference engine
 func (ie *InferenceEngine) GetInferenceStatus(ctx context.Context) (map[string]interface{}, error) {
 	status := map[string]interface{}{
-		"status":    "running",
-		"timestamp": time.Now(),
+		"status":              "running",
+		"timestamp":           time.Now(),
+		"workers":             ie.pool.Stats(),
+		"in_flight_per_model": ie.models.inFlight(),
 	}
 
 	// Check LLM connectivity
@@ -306,3 +685,23 @@ func (ie *InferenceEngine) GetInferenceStatus(ctx context.Context) (map[string]i
 
 	return status, nil
 }
+
+// WorkerStats returns the load on the inference engine's worker pool
+func (ie *InferenceEngine) WorkerStats() WorkerPoolStats {
+	return ie.pool.Stats()
+}
+
+// InFlightPerModel returns the number of running LLM calls per model
+func (ie *InferenceEngine) InFlightPerModel() map[string]int {
+	return ie.models.inFlight()
+}
+
+// Saturated reports whether new inference requests would be rejected
+func (ie *InferenceEngine) Saturated() bool {
+	return ie.pool.Full()
+}
+
+// Busy reports whether new inference requests would wait for a worker
+func (ie *InferenceEngine) Busy() bool {
+	return ie.pool.Busy()
+}
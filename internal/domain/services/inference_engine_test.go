@@ -0,0 +1,255 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/username/hexarag/internal/adapters/messaging/memory"
+	"github.com/username/hexarag/internal/domain/entities"
+	"github.com/username/hexarag/internal/domain/ports"
+)
+
+// blockingLLM streams a first chunk and then generates until its context is cancelled
+type blockingLLM struct {
+	ports.LLMPort
+	started chan struct{}
+}
+
+func (l *blockingLLM) Complete(ctx context.Context, request *ports.CompletionRequest) (*ports.CompletionResponse, error) {
+	close(l.started)
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func (l *blockingLLM) CompleteStream(ctx context.Context, request *ports.CompletionRequest, handler ports.StreamHandler) error {
+	if err := handler(&ports.StreamChunk{Delta: "Partial answer"}); err != nil {
+		return err
+	}
+	close(l.started)
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+// noTools offers no tools to the model
+type noTools struct {
+	ports.ToolPort
+}
+
+func (noTools) GetAvailableTools(ctx context.Context) ([]ports.Tool, error) {
+	return nil, nil
+}
+
+// newTestConversation saves a conversation holding one user message
+func newTestConversation(t *testing.T, storage ports.StoragePort) (*entities.Conversation, *entities.Message) {
+	t.Helper()
+	ctx := context.Background()
+
+	prompt := entities.NewSystemPrompt("Default", "You are helpful.")
+	if err := storage.SaveSystemPrompt(ctx, prompt); err != nil {
+		t.Fatalf("SaveSystemPrompt() error = %v", err)
+	}
+	conversation := entities.NewConversation("Test", prompt.ID)
+	if err := storage.SaveConversation(ctx, conversation); err != nil {
+		t.Fatalf("SaveConversation() error = %v", err)
+	}
+	message := entities.NewMessage(conversation.ID, entities.RoleUser, "What time is it?")
+	if err := storage.SaveMessage(ctx, message); err != nil {
+		t.Fatalf("SaveMessage() error = %v", err)
+	}
+	return conversation, message
+}
+
+func TestInferenceEngine_CancelKeepsPartialStream(t *testing.T) {
+	storage := newTestStorage(t)
+	conversation, message := newTestConversation(t, storage)
+	llm := &blockingLLM{started: make(chan struct{})}
+	engine := NewInferenceEngine(storage, discardMessaging{}, llm, noTools{}, WorkerPoolConfig{Workers: 1}, 0)
+
+	request := &InferenceRequest{ConversationID: conversation.ID, MessageID: message.ID, Model: "llama3.2:3b"}
+	chunks := make(chan *ports.StreamChunk, 4)
+	errs := make(chan error, 1)
+	go func() {
+		errs <- engine.ExecuteStreamingInference(context.Background(), request, func(chunk *ports.StreamChunk) error {
+			chunks <- chunk
+			return nil
+		})
+	}()
+
+	<-llm.started
+	if n := engine.CancelGeneration(conversation.ID, "msg_other"); n != 0 {
+		t.Errorf("Expected other messages' generations to be left running, cancelled %d", n)
+	}
+	if n := engine.CancelGeneration(conversation.ID, message.ID); n != 1 {
+		t.Fatalf("Expected 1 generation to be cancelled, got %d", n)
+	}
+
+	select {
+	case err := <-errs:
+		if err != nil {
+			t.Fatalf("ExecuteStreamingInference() error = %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for the generation to stop")
+	}
+
+	<-chunks // The partial delta
+	if last := <-chunks; last.FinishReason != entities.FinishReasonCancelled || !last.Done {
+		t.Errorf("Expected a final cancelled chunk, got %+v", last)
+	}
+
+	reply, err := storage.GetReply(context.Background(), message.ID)
+	if err != nil {
+		t.Fatalf("GetReply() error = %v", err)
+	}
+	if reply.Content != "Partial answer" || reply.FinishReason != entities.FinishReasonCancelled {
+		t.Errorf("Expected the partial answer to be saved as cancelled, got %+v", reply)
+	}
+}
+
+func TestInferenceEngine_CancelEvent(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	storage := newTestStorage(t)
+	conversation, message := newTestConversation(t, storage)
+	bus := memory.NewAdapter(ports.DefaultDeliveryPolicy())
+	defer bus.Close()
+
+	llm := &blockingLLM{started: make(chan struct{})}
+	engine := NewInferenceEngine(storage, bus, llm, noTools{}, WorkerPoolConfig{Workers: 1}, 0)
+	if err := engine.StartListening(ctx); err != nil {
+		t.Fatalf("StartListening() error = %v", err)
+	}
+
+	responses := make(chan InferenceResponse, 1)
+	err := bus.Subscribe(ctx, ports.SubjectInferenceResponse, func(ctx context.Context, subject string, data []byte) error {
+		var response InferenceResponse
+		if err := json.Unmarshal(data, &response); err != nil {
+			return err
+		}
+		responses <- response
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Subscribe() error = %v", err)
+	}
+
+	contextReady := &ContextResponse{ConversationID: conversation.ID, MessageID: message.ID}
+	if err := bus.PublishJSON(ctx, ports.SubjectContextReady, contextReady); err != nil {
+		t.Fatalf("PublishJSON() error = %v", err)
+	}
+	<-llm.started
+
+	subject := fmt.Sprintf(ports.SubjectConversationCancel, conversation.ID)
+	if err := bus.PublishJSON(ctx, subject, &ports.CancelRequest{ConversationID: conversation.ID}); err != nil {
+		t.Fatalf("PublishJSON() error = %v", err)
+	}
+
+	select {
+	case response := <-responses:
+		if response.FinishReason != entities.FinishReasonCancelled || response.ResponseMessage.FinishReason != entities.FinishReasonCancelled {
+			t.Errorf("Expected a cancelled response, got %+v", response)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for the cancelled response")
+	}
+}
+
+// countingLLM answers every completion, counting the calls
+type countingLLM struct {
+	ports.LLMPort
+	calls atomic.Int32
+}
+
+func (l *countingLLM) Complete(ctx context.Context, request *ports.CompletionRequest) (*ports.CompletionResponse, error) {
+	l.calls.Add(1)
+	return &ports.CompletionResponse{
+		Message:      entities.NewMessage("", entities.RoleAssistant, "It is noon."),
+		FinishReason: "stop",
+	}, nil
+}
+
+func TestInferenceEngine_SkipsRedeliveredRequests(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	storage := newTestStorage(t)
+	conversation, message := newTestConversation(t, storage)
+	llm := &countingLLM{}
+	engine := NewInferenceEngine(storage, discardMessaging{}, llm, noTools{}, WorkerPoolConfig{Workers: 1, QueueSize: 1}, 0)
+	engine.pool.Start(ctx)
+
+	request := &InferenceRequest{ConversationID: conversation.ID, MessageID: message.ID}
+	if err := engine.queueInference(ctx, request); err != nil {
+		t.Fatalf("queueInference() error = %v", err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for engine.WorkerStats().Completed < 1 {
+		if time.Now().After(deadline) {
+			t.Fatal("Timed out waiting for the reply")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if _, err := storage.GetReply(ctx, message.ID); err != nil {
+		t.Fatalf("GetReply() error = %v", err)
+	}
+
+	// A redelivery, and a request reaching another instance after the dedupe window, both see the reply
+	if err := engine.queueInference(ctx, request); err != nil {
+		t.Fatalf("queueInference() error = %v", err)
+	}
+	other := NewInferenceEngine(storage, discardMessaging{}, llm, noTools{}, WorkerPoolConfig{Workers: 1, QueueSize: 1}, 0)
+	if err := other.queueInference(ctx, request); err != nil {
+		t.Fatalf("queueInference() error = %v", err)
+	}
+
+	if stats := engine.WorkerStats(); stats.Queued != 0 || stats.Completed != 1 {
+		t.Errorf("Expected only the first request to run, got %+v", stats)
+	}
+	if stats := other.WorkerStats(); stats.Queued != 0 {
+		t.Errorf("Expected the other instance to skip the answered message, got %+v", stats)
+	}
+	if calls := llm.calls.Load(); calls != 1 {
+		t.Errorf("Expected 1 completion, got %d", calls)
+	}
+}
+
+// failingLLM fails every completion, counting the calls
+type failingLLM struct {
+	ports.LLMPort
+	calls atomic.Int32
+}
+
+func (l *failingLLM) Complete(ctx context.Context, request *ports.CompletionRequest) (*ports.CompletionResponse, error) {
+	l.calls.Add(1)
+	return nil, errors.New("model unavailable")
+}
+
+func TestInferenceEngine_ReturnsFailuresForRedelivery(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	storage := newTestStorage(t)
+	conversation, message := newTestConversation(t, storage)
+	llm := &failingLLM{}
+	engine := NewInferenceEngine(storage, discardMessaging{}, llm, noTools{}, WorkerPoolConfig{Workers: 1, QueueSize: 1}, 0)
+	engine.pool.Start(ctx)
+
+	// The message is only acked once answered, so each failure goes back to the broker and a redelivery runs again
+	request := &InferenceRequest{ConversationID: conversation.ID, MessageID: message.ID}
+	for attempt := 1; attempt <= 2; attempt++ {
+		if err := engine.queueInference(ctx, request); err == nil || !strings.Contains(err.Error(), "model unavailable") {
+			t.Fatalf("Expected attempt %d to return the LLM failure, got %v", attempt, err)
+		}
+	}
+	if calls := llm.calls.Load(); calls != 2 {
+		t.Errorf("Expected each delivery to call the model, got %d calls", calls)
+	}
+}
@@ -0,0 +1,74 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/username/hexarag/internal/domain/ports"
+)
+
+// dedupeWindow is how long a handled message ID is remembered. Replies saved to storage
+// catch repeats after that.
+const dedupeWindow = 10 * time.Minute
+
+// messageDeduper remembers the message IDs a service is handling or has recently handled,
+// so redelivered events don't start the same work twice
+type messageDeduper struct {
+	window time.Duration
+	mu     sync.Mutex
+	seen   map[string]time.Time
+}
+
+func newMessageDeduper(window time.Duration) *messageDeduper {
+	return &messageDeduper{
+		window: window,
+		seen:   make(map[string]time.Time),
+	}
+}
+
+// claim records a message ID, returning false if it was already claimed within the window
+func (d *messageDeduper) claim(id string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := time.Now()
+	for seenID, at := range d.seen {
+		if now.Sub(at) > d.window {
+			delete(d.seen, seenID)
+		}
+	}
+
+	if _, ok := d.seen[id]; ok {
+		return false
+	}
+	d.seen[id] = now
+	return true
+}
+
+// release forgets a message ID whose handling failed, so a retry can claim it again
+func (d *messageDeduper) release(id string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.seen, id)
+}
+
+// claimUnanswered claims a message ID for processing, returning false for messages that are
+// already claimed or already have an assistant reply. Requests without a message ID are always processed.
+func (d *messageDeduper) claimUnanswered(ctx context.Context, storage ports.StoragePort, messageID string) (bool, error) {
+	if messageID == "" {
+		return true, nil
+	}
+	if !d.claim(messageID) {
+		return false, nil
+	}
+
+	if _, err := storage.GetReply(ctx, messageID); err == nil {
+		return false, nil
+	} else if !errors.Is(err, ports.ErrMessageNotFound) {
+		d.release(messageID)
+		return false, err
+	}
+	return true, nil
+}
@@ -0,0 +1,137 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/username/hexarag/internal/domain/ports"
+)
+
+// RetentionPolicy describes what the retention manager prunes and how often
+type RetentionPolicy struct {
+	Interval                 time.Duration
+	ConversationMaxAge       time.Duration // 0 keeps conversations forever
+	ArchiveConversations     bool          // Archive old conversations instead of moving them to the trash
+	MaxEventsPerConversation int           // 0 keeps all events
+	VacuumInterval           time.Duration // 0 disables vacuuming
+	TrashGracePeriod         time.Duration // 0 keeps deleted items until restored
+}
+
+// RetentionReport summarizes a single retention run
+type RetentionReport struct {
+	ConversationsArchived int       `json:"conversations_archived"`
+	ConversationsDeleted  int       `json:"conversations_deleted"`
+	ConversationsPurged   int       `json:"conversations_purged"`
+	SystemPromptsPurged   int       `json:"system_prompts_purged"`
+	EventsPruned          int       `json:"events_pruned"`
+	Vacuumed              bool      `json:"vacuumed"`
+	StartedAt             time.Time `json:"started_at"`
+	DurationMs            int64     `json:"duration_ms"`
+}
+
+// RetentionManager periodically prunes old data according to a retention policy
+type RetentionManager struct {
+	storage    ports.StoragePort
+	messaging  ports.MessagingPort
+	policy     RetentionPolicy
+	lastVacuum time.Time
+}
+
+// NewRetentionManager creates a new retention manager service
+func NewRetentionManager(storage ports.StoragePort, messaging ports.MessagingPort, policy RetentionPolicy) *RetentionManager {
+	return &RetentionManager{
+		storage:    storage,
+		messaging:  messaging,
+		policy:     policy,
+		lastVacuum: time.Now(),
+	}
+}
+
+// Start runs retention on the configured interval until the context is cancelled
+func (rm *RetentionManager) Start(ctx context.Context) {
+	ticker := time.NewTicker(rm.policy.Interval)
+
+	go func() {
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				if _, err := rm.RunOnce(ctx); err != nil {
+					log.Printf("Retention run failed: %v", err)
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	log.Printf("Retention manager started (interval: %s)", rm.policy.Interval)
+}
+
+// RunOnce applies the retention policy and publishes a report of what was pruned
+func (rm *RetentionManager) RunOnce(ctx context.Context) (*RetentionReport, error) {
+	report := &RetentionReport{StartedAt: time.Now()}
+
+	if rm.policy.ConversationMaxAge > 0 {
+		cutoff := time.Now().Add(-rm.policy.ConversationMaxAge)
+
+		pruned, err := rm.storage.PruneConversations(ctx, cutoff, rm.policy.ArchiveConversations)
+		if err != nil {
+			return nil, fmt.Errorf("failed to prune conversations: %w", err)
+		}
+
+		if rm.policy.ArchiveConversations {
+			report.ConversationsArchived = pruned
+		} else {
+			report.ConversationsDeleted = pruned
+		}
+	}
+
+	if rm.policy.TrashGracePeriod > 0 {
+		cutoff := time.Now().Add(-rm.policy.TrashGracePeriod)
+
+		purged, err := rm.storage.PurgeConversations(ctx, cutoff)
+		if err != nil {
+			return nil, fmt.Errorf("failed to purge conversations: %w", err)
+		}
+		report.ConversationsPurged = purged
+
+		// Purge prompts after conversations so prompts only they referenced can go too
+		purged, err = rm.storage.PurgeSystemPrompts(ctx, cutoff)
+		if err != nil {
+			return nil, fmt.Errorf("failed to purge system prompts: %w", err)
+		}
+		report.SystemPromptsPurged = purged
+	}
+
+	if rm.policy.MaxEventsPerConversation > 0 {
+		pruned, err := rm.storage.PruneEvents(ctx, rm.policy.MaxEventsPerConversation)
+		if err != nil {
+			return nil, fmt.Errorf("failed to prune events: %w", err)
+		}
+		report.EventsPruned = pruned
+	}
+
+	if rm.policy.VacuumInterval > 0 && time.Since(rm.lastVacuum) >= rm.policy.VacuumInterval {
+		if err := rm.storage.Vacuum(ctx); err != nil {
+			return nil, fmt.Errorf("failed to vacuum storage: %w", err)
+		}
+		rm.lastVacuum = time.Now()
+		report.Vacuumed = true
+	}
+
+	report.DurationMs = time.Since(report.StartedAt).Milliseconds()
+
+	if err := rm.messaging.PublishJSON(ctx, ports.SubjectSystemRetention, report); err != nil {
+		log.Printf("Failed to publish retention report: %v", err)
+	}
+
+	log.Printf("Retention run completed: %d archived, %d deleted, %d conversations and %d prompts purged, %d events pruned, vacuumed: %t",
+		report.ConversationsArchived, report.ConversationsDeleted, report.ConversationsPurged, report.SystemPromptsPurged,
+		report.EventsPruned, report.Vacuumed)
+
+	return report, nil
+}
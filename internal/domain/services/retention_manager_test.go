@@ -0,0 +1,251 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/username/hexarag/internal/domain/entities"
+	"github.com/username/hexarag/internal/domain/ports"
+)
+
+// discardMessaging drops everything published to it
+type discardMessaging struct {
+	ports.MessagingPort
+}
+
+func (discardMessaging) PublishJSON(ctx context.Context, subject string, obj interface{}) error {
+	return nil
+}
+
+func TestRetentionManager_TrashLifecycle(t *testing.T) {
+	ctx := context.Background()
+	storage := newTestStorage(t)
+
+	prompt := entities.NewSystemPrompt("Temporary", "You are temporary.")
+	if err := storage.SaveSystemPrompt(ctx, prompt); err != nil {
+		t.Fatalf("SaveSystemPrompt() error = %v", err)
+	}
+
+	kept := entities.NewConversation("Kept", prompt.ID)
+	trashed := entities.NewConversation("Trashed", prompt.ID)
+	for _, conversation := range []*entities.Conversation{kept, trashed} {
+		if err := storage.SaveConversation(ctx, conversation); err != nil {
+			t.Fatalf("SaveConversation() error = %v", err)
+		}
+	}
+
+	if err := storage.DeleteConversation(ctx, trashed.ID); err != nil {
+		t.Fatalf("DeleteConversation() error = %v", err)
+	}
+	if _, err := storage.GetConversation(ctx, trashed.ID); err == nil {
+		t.Fatal("Expected deleted conversation to be hidden")
+	}
+
+	// Restore and delete again to exercise undo
+	if err := storage.RestoreConversation(ctx, trashed.ID); err != nil {
+		t.Fatalf("RestoreConversation() error = %v", err)
+	}
+	if _, err := storage.GetConversation(ctx, trashed.ID); err != nil {
+		t.Fatalf("Expected restored conversation to be visible, got %v", err)
+	}
+	if err := storage.DeleteConversation(ctx, trashed.ID); err != nil {
+		t.Fatalf("DeleteConversation() error = %v", err)
+	}
+
+	count, err := storage.CountConversationsForSystemPrompt(ctx, prompt.ID)
+	if err != nil {
+		t.Fatalf("CountConversationsForSystemPrompt() error = %v", err)
+	}
+	if count != 1 {
+		t.Errorf("Expected 1 conversation outside the trash to use the prompt, got %d", count)
+	}
+
+	if err := storage.DeleteSystemPrompt(ctx, prompt.ID); err != nil {
+		t.Fatalf("DeleteSystemPrompt() error = %v", err)
+	}
+	if _, err := storage.GetSystemPrompt(ctx, prompt.ID); err != nil {
+		t.Errorf("Expected deleted prompt to stay readable for its conversations, got %v", err)
+	}
+
+	manager := NewRetentionManager(storage, discardMessaging{}, RetentionPolicy{
+		Interval:         time.Hour,
+		TrashGracePeriod: time.Nanosecond,
+	})
+
+	report, err := manager.RunOnce(ctx)
+	if err != nil {
+		t.Fatalf("RunOnce() error = %v", err)
+	}
+
+	if report.ConversationsPurged != 1 {
+		t.Errorf("Expected 1 conversation purged, got %d", report.ConversationsPurged)
+	}
+	if report.SystemPromptsPurged != 0 {
+		t.Errorf("Expected prompt referenced by a live conversation to be kept, got %d purged", report.SystemPromptsPurged)
+	}
+
+	deleted, err := storage.GetDeletedConversations(ctx, 10, 0)
+	if err != nil {
+		t.Fatalf("GetDeletedConversations() error = %v", err)
+	}
+	if len(deleted) != 0 {
+		t.Errorf("Expected trash to be empty after purge, got %d conversations", len(deleted))
+	}
+	if _, err := storage.GetConversation(ctx, kept.ID); err != nil {
+		t.Errorf("Expected live conversation to survive purge, got %v", err)
+	}
+}
+
+// reportRecorder keeps the retention reports published to it
+type reportRecorder struct {
+	ports.MessagingPort
+	subjects []string
+	reports  []*RetentionReport
+}
+
+func (r *reportRecorder) PublishJSON(ctx context.Context, subject string, obj interface{}) error {
+	r.subjects = append(r.subjects, subject)
+	if report, ok := obj.(*RetentionReport); ok {
+		r.reports = append(r.reports, report)
+	}
+	return nil
+}
+
+func TestRetentionManager_PrunesOldConversations(t *testing.T) {
+	ctx := context.Background()
+
+	for _, archive := range []bool{true, false} {
+		storage := newTestStorage(t)
+
+		old := entities.NewConversation("Old", "default")
+		old.UpdatedAt = time.Now().Add(-48 * time.Hour)
+		pinned := entities.NewConversation("Pinned", "default")
+		pinned.UpdatedAt = old.UpdatedAt
+		pinned.SetPinned(true)
+		recent := entities.NewConversation("Recent", "default")
+		for _, conversation := range []*entities.Conversation{old, pinned, recent} {
+			if err := storage.SaveConversation(ctx, conversation); err != nil {
+				t.Fatalf("SaveConversation() error = %v", err)
+			}
+		}
+
+		messaging := &reportRecorder{}
+		manager := NewRetentionManager(storage, messaging, RetentionPolicy{
+			Interval:             time.Hour,
+			ConversationMaxAge:   24 * time.Hour,
+			ArchiveConversations: archive,
+		})
+		report, err := manager.RunOnce(ctx)
+		if err != nil {
+			t.Fatalf("RunOnce() error = %v", err)
+		}
+
+		if archive {
+			if report.ConversationsArchived != 1 || report.ConversationsDeleted != 0 {
+				t.Errorf("Expected 1 conversation archived, got %+v", report)
+			}
+			if conversation, err := storage.GetConversation(ctx, old.ID); err != nil || !conversation.IsArchived() {
+				t.Errorf("Expected the old conversation to be archived, got %+v (%v)", conversation, err)
+			}
+		} else {
+			if report.ConversationsDeleted != 1 || report.ConversationsArchived != 0 {
+				t.Errorf("Expected 1 conversation deleted, got %+v", report)
+			}
+			if trashed, err := storage.GetDeletedConversations(ctx, 10, 0); err != nil || len(trashed) != 1 || trashed[0].ID != old.ID {
+				t.Errorf("Expected the old conversation in the trash, got %+v (%v)", trashed, err)
+			}
+		}
+
+		for _, kept := range []*entities.Conversation{pinned, recent} {
+			if conversation, err := storage.GetConversation(ctx, kept.ID); err != nil || conversation.IsArchived() {
+				t.Errorf("Expected %s to be kept, got %+v (%v)", kept.Title, conversation, err)
+			}
+		}
+
+		if len(messaging.reports) != 1 || messaging.subjects[0] != ports.SubjectSystemRetention || messaging.reports[0] != report {
+			t.Errorf("Expected the report to be published on %s, got %v", ports.SubjectSystemRetention, messaging.subjects)
+		}
+	}
+}
+
+func TestRetentionManager_CapsEventsPerConversation(t *testing.T) {
+	ctx := context.Background()
+	storage := newTestStorage(t)
+
+	busy := entities.NewConversation("Busy", "default")
+	quiet := entities.NewConversation("Quiet", "default")
+	for _, conversation := range []*entities.Conversation{busy, quiet} {
+		if err := storage.SaveConversation(ctx, conversation); err != nil {
+			t.Fatalf("SaveConversation() error = %v", err)
+		}
+	}
+	for i := 0; i < 5; i++ {
+		if err := storage.SaveEvent(ctx, busy.ID, "title.changed", map[string]interface{}{"title": fmt.Sprintf("Title %d", i)}); err != nil {
+			t.Fatalf("SaveEvent() error = %v", err)
+		}
+	}
+
+	before, err := storage.GetEventLog(ctx, busy.ID, 0, 0)
+	if err != nil {
+		t.Fatalf("GetEventLog() error = %v", err)
+	}
+	quietBefore, err := storage.GetEventLog(ctx, quiet.ID, 0, 0)
+	if err != nil {
+		t.Fatalf("GetEventLog() error = %v", err)
+	}
+	const maxEvents = 3
+	if len(quietBefore) > maxEvents {
+		t.Fatalf("Expected the quiet conversation to be under the cap, got %d events", len(quietBefore))
+	}
+
+	messaging := &reportRecorder{}
+	manager := NewRetentionManager(storage, messaging, RetentionPolicy{
+		Interval:                 time.Hour,
+		MaxEventsPerConversation: maxEvents,
+	})
+	report, err := manager.RunOnce(ctx)
+	if err != nil {
+		t.Fatalf("RunOnce() error = %v", err)
+	}
+
+	if want := len(before) - maxEvents; report.EventsPruned != want {
+		t.Errorf("Expected %d events pruned, got %d", want, report.EventsPruned)
+	}
+	after, err := storage.GetEventLog(ctx, busy.ID, 0, 0)
+	if err != nil {
+		t.Fatalf("GetEventLog() error = %v", err)
+	}
+	// The newest events are kept
+	if len(after) != maxEvents || after[0].ID != before[len(before)-maxEvents].ID {
+		t.Errorf("Expected the %d newest events to be kept, got %+v", maxEvents, after)
+	}
+	if quietAfter, _ := storage.GetEventLog(ctx, quiet.ID, 0, 0); len(quietAfter) != len(quietBefore) {
+		t.Errorf("Expected the quiet conversation's events to be kept, got %d of %d", len(quietAfter), len(quietBefore))
+	}
+	if len(messaging.reports) != 1 || messaging.reports[0].EventsPruned != report.EventsPruned {
+		t.Errorf("Expected the published report to count the pruned events, got %+v", messaging.reports)
+	}
+}
+
+func TestRetentionManager_VacuumsAfterInterval(t *testing.T) {
+	ctx := context.Background()
+	manager := NewRetentionManager(newTestStorage(t), discardMessaging{}, RetentionPolicy{
+		Interval:       time.Hour,
+		VacuumInterval: 24 * time.Hour,
+	})
+
+	// The interval counts from when the manager was created
+	if report, err := manager.RunOnce(ctx); err != nil || report.Vacuumed {
+		t.Fatalf("Expected no vacuum before the interval, got %+v (%v)", report, err)
+	}
+
+	manager.lastVacuum = time.Now().Add(-25 * time.Hour)
+	if report, err := manager.RunOnce(ctx); err != nil || !report.Vacuumed {
+		t.Fatalf("Expected a vacuum once the interval passed, got %+v (%v)", report, err)
+	}
+	if report, err := manager.RunOnce(ctx); err != nil || report.Vacuumed {
+		t.Errorf("Expected the next vacuum to wait for another interval, got %+v (%v)", report, err)
+	}
+}
@@ -0,0 +1,402 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"path"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/username/hexarag/internal/domain/entities"
+	"github.com/username/hexarag/internal/domain/ports"
+	"github.com/username/hexarag/pkg/jsonschema"
+)
+
+// ToolExecutionConfig sets how the tool executor runs calls
+type ToolExecutionConfig struct {
+	Workers         WorkerPoolConfig // Calls running at once, so the calls of one reply run side by side
+	Timeout         time.Duration    // Per call; 0 waits for the tool
+	Timeouts        []ToolTimeout    // Overrides Timeout for the tools matching a pattern; the first match wins
+	MaxResultTokens int              // Larger results are cut to this many tokens; 0 keeps them whole
+}
+
+// ToolTimeout sets the timeout of the tools matching a pattern, which may contain * wildcards
+type ToolTimeout struct {
+	Tool    string
+	Timeout time.Duration
+}
+
+// TokenCounter measures and cuts text in model tokens; *tokenizer.Tokenizer implements it
+type TokenCounter interface {
+	CountTokens(text string) int
+	TruncateToTokenLimit(text string, maxTokens int) string
+}
+
+// ToolExecutor runs the tool calls requested by the inference engine on a worker pool and publishes their results.
+// Calls to tools whose policy requires approval are held until a user approves them.
+type ToolExecutor struct {
+	storage   ports.StoragePort
+	messaging ports.MessagingPort
+	tools     ports.ToolPort
+	policies  entities.ToolPolicies
+	execution ToolExecutionConfig
+	tokens    TokenCounter
+	pool      *WorkerPool
+	running   *messageDeduper
+
+	unpublishedMutex sync.Mutex
+	unpublished      map[string]*ports.ToolExecutionResponse // Results whose publish failed, by tool call ID
+	overrunning      int64
+}
+
+// NewToolExecutor creates a new tool executor service. Results are only capped when tokens is set.
+func NewToolExecutor(storage ports.StoragePort, messaging ports.MessagingPort, tools ports.ToolPort, policies entities.ToolPolicies, execution ToolExecutionConfig, tokens TokenCounter) *ToolExecutor {
+	return &ToolExecutor{
+		storage:   storage,
+		messaging: messaging,
+		tools:     tools,
+		policies:  policies,
+		execution: execution,
+		tokens:    tokens,
+		pool:      NewWorkerPool("tool-executor", execution.Workers),
+		running:   newMessageDeduper(dedupeWindow),
+
+		unpublished: make(map[string]*ports.ToolExecutionResponse),
+	}
+}
+
+// StartListening starts the tool executor service by subscribing to tool execution requests
+func (te *ToolExecutor) StartListening(ctx context.Context) error {
+	ctx = ports.WithSource(ctx, "tool-executor")
+	te.pool.Start(ctx)
+
+	err := te.messaging.SubscribeQueue(ctx, ports.SubjectToolExecute, "tool-executor", te.handleToolExecute)
+	if err != nil {
+		return fmt.Errorf("failed to subscribe to tool execution requests: %w", err)
+	}
+
+	log.Println("Tool Executor service started and listening for events")
+	return nil
+}
+
+// handleToolExecute runs a requested tool call on the worker pool, or holds it for approval if its
+// policy requires one. Requests are acked once the call's result is published; a full pool is nacked
+// so the broker holds the backlog. Redelivered requests for a running or completed call are dropped.
+func (te *ToolExecutor) handleToolExecute(ctx context.Context, subject string, data []byte) error {
+	var request ports.ToolExecutionRequest
+	if err := json.Unmarshal(data, &request); err != nil {
+		return ports.Terminate(fmt.Errorf("failed to unmarshal tool execution request: %w", err)) // Malformed payloads never succeed
+	}
+
+	if response := te.takeUnpublished(request.ToolCallID); response != nil {
+		return te.publishResult(ctx, response) // The call already ran; only its result was lost
+	}
+
+	toolCall, err := te.storage.GetToolCall(ctx, request.ToolCallID)
+	if err != nil {
+		return fmt.Errorf("failed to get tool call: %w", err)
+	}
+	if toolCall.IsCompleted() {
+		log.Printf("Skipping duplicate execution of tool call %s", request.ToolCallID)
+		return nil
+	}
+
+	needsApproval := te.policies.PolicyFor(request.Name) == entities.ToolPolicyRequireApproval
+	if toolCall.IsAwaitingApproval() || (needsApproval && toolCall.Status != entities.ToolCallStatusApproved) {
+		return te.requestApproval(ctx, toolCall, &request)
+	}
+
+	if !te.running.claim(request.ToolCallID) {
+		log.Printf("Skipping duplicate execution of tool call %s", request.ToolCallID)
+		return nil
+	}
+	err = te.pool.Run(ctx, func() error {
+		return te.runToolCall(ctx, &request)
+	})
+	if errors.Is(err, ErrQueueFull) {
+		te.running.release(request.ToolCallID)
+		return ports.Nack(fmt.Errorf("failed to queue tool call %s: %w", request.ToolCallID, err), 0)
+	}
+	return err
+}
+
+// runToolCall executes a tool call and publishes its result. Calls that could not be attempted are
+// reported as failed, so the reply waiting on them can go on. A tool that overruns its timeout has
+// its call failed at once, but keeps its worker until it returns, so the pool still bounds the tools
+// running at once.
+func (te *ToolExecutor) runToolCall(ctx context.Context, request *ports.ToolExecutionRequest) error {
+	started := time.Now()
+	result, finished, err := te.execute(ctx, request)
+	duration := time.Since(started)
+	if err != nil {
+		log.Printf("Failed to execute tool call %s: %v", request.ToolCallID, err)
+		result = &ports.ToolResult{Success: false, Error: err.Error()}
+	}
+
+	response := &ports.ToolExecutionResponse{
+		ToolCallID:     request.ToolCallID,
+		Result:         result,
+		MessageID:      request.MessageID,
+		ConversationID: request.ConversationID,
+		DurationMs:     duration.Milliseconds(),
+	}
+	err = te.publishResult(ctx, response)
+
+	if finished != nil {
+		atomic.AddInt64(&te.overrunning, 1)
+		<-finished
+		atomic.AddInt64(&te.overrunning, -1)
+	}
+	return err
+}
+
+// publishResult publishes the result of a tool call. A result that fails to publish is kept, so the
+// redelivered request publishes it instead of running the tool again. Results are kept in memory:
+// a request redelivered to another instance runs the call again.
+func (te *ToolExecutor) publishResult(ctx context.Context, response *ports.ToolExecutionResponse) error {
+	if err := te.messaging.PublishJSON(ctx, ports.SubjectToolResult, response); err != nil {
+		te.unpublishedMutex.Lock()
+		te.unpublished[response.ToolCallID] = response
+		te.unpublishedMutex.Unlock()
+		return fmt.Errorf("failed to publish result of tool call %s: %w", response.ToolCallID, err)
+	}
+	return nil
+}
+
+// takeUnpublished removes and returns the kept result of a tool call, if its publish failed
+func (te *ToolExecutor) takeUnpublished(toolCallID string) *ports.ToolExecutionResponse {
+	te.unpublishedMutex.Lock()
+	defer te.unpublishedMutex.Unlock()
+
+	response := te.unpublished[toolCallID]
+	delete(te.unpublished, toolCallID)
+	return response
+}
+
+// Execute runs a tool call if the tool is enabled for its conversation, its policy doesn't deny it,
+// and its arguments match the tool's parameter schema, after filling in the schema's defaults.
+// Calls fail once they exceed their timeout, and results above the token cap are truncated.
+// Approval is not asked for here; calls are held for approval as they arrive from the inference engine.
+// Tool failures are returned as unsuccessful results; errors mean the call could not be attempted.
+// Execute returns once the tool has returned, even if it overran its timeout.
+func (te *ToolExecutor) Execute(ctx context.Context, request *ports.ToolExecutionRequest) (*ports.ToolResult, error) {
+	result, finished, err := te.execute(ctx, request)
+	if finished != nil {
+		<-finished
+	}
+	return result, err
+}
+
+// execute runs a tool call as Execute does, but returns as soon as the call fails on its timeout.
+// The returned channel is then closed once the overrunning tool returns; it is nil otherwise.
+func (te *ToolExecutor) execute(ctx context.Context, request *ports.ToolExecutionRequest) (*ports.ToolResult, <-chan struct{}, error) {
+	settings, err := conversationToolSettings(ctx, te.storage, request.ConversationID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !entities.ToolEnabled(request.Name, settings...) {
+		return &ports.ToolResult{
+			Success: false,
+			Error:   fmt.Sprintf("tool %s is disabled for this conversation", request.Name),
+		}, nil, nil
+	}
+
+	if te.policies.PolicyFor(request.Name) == entities.ToolPolicyDeny {
+		return &ports.ToolResult{
+			Success: false,
+			Error:   fmt.Sprintf("tool %s is denied by policy", request.Name),
+		}, nil, nil
+	}
+
+	if result := te.validateArguments(ctx, request); result != nil {
+		return result, nil, nil
+	}
+
+	result, finished := te.runTool(ctx, request)
+	te.capResult(result)
+	return result, finished, nil
+}
+
+// runTool calls the tool within its timeout. A tool that overruns has its call failed while it is
+// left to return; the returned channel is closed once it has. Tools must honour ctx, which is
+// cancelled at the timeout, or they hold their worker until they finish. Errors are not retried,
+// since the tool may already have had side effects.
+func (te *ToolExecutor) runTool(ctx context.Context, request *ports.ToolExecutionRequest) (*ports.ToolResult, <-chan struct{}) {
+	timeout := te.timeoutFor(request.Name)
+	cancel := context.CancelFunc(func() {})
+	if timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+	}
+
+	type outcome struct {
+		result *ports.ToolResult
+		err    error
+	}
+	done := make(chan outcome, 1)
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		result, err := te.tools.Execute(ports.WithToolCall(ctx, request), request.Name, request.Arguments)
+		done <- outcome{result, err}
+	}()
+
+	select {
+	case out := <-done:
+		cancel()
+		if out.err != nil {
+			return &ports.ToolResult{Success: false, Error: out.err.Error()}, nil
+		}
+		return out.result, nil
+	case <-ctx.Done():
+		err := ctx.Err()
+		cancel()
+		if errors.Is(err, context.DeadlineExceeded) {
+			return &ports.ToolResult{Success: false, Error: fmt.Sprintf("tool %s timed out after %s", request.Name, timeout)}, finished
+		}
+		return &ports.ToolResult{Success: false, Error: fmt.Sprintf("tool %s was cancelled: %v", request.Name, err)}, finished
+	}
+}
+
+// timeoutFor returns the timeout of calls to a tool
+func (te *ToolExecutor) timeoutFor(name string) time.Duration {
+	for _, override := range te.execution.Timeouts {
+		if matched, _ := path.Match(override.Tool, name); matched {
+			return override.Timeout
+		}
+	}
+	return te.execution.Timeout
+}
+
+// capResult truncates a result whose data is longer than the token cap, recording the cut in its
+// metadata. Structured data is cut as JSON text.
+func (te *ToolExecutor) capResult(result *ports.ToolResult) {
+	maxTokens := te.execution.MaxResultTokens
+	if maxTokens <= 0 || te.tokens == nil || result == nil || result.Data == nil {
+		return
+	}
+
+	text, ok := result.Data.(string)
+	if !ok {
+		data, err := json.Marshal(result.Data)
+		if err != nil {
+			return
+		}
+		text = string(data)
+	}
+
+	tokens := te.tokens.CountTokens(text)
+	if tokens <= maxTokens {
+		return
+	}
+
+	result.Data = te.tokens.TruncateToTokenLimit(text, maxTokens) +
+		fmt.Sprintf("\n[truncated: showing %d of %d tokens]", maxTokens, tokens)
+	if result.Metadata == nil {
+		result.Metadata = make(map[string]interface{})
+	}
+	result.Metadata["truncated"] = true
+	result.Metadata["original_tokens"] = tokens
+	result.Metadata["max_result_tokens"] = maxTokens
+}
+
+// WorkerStats returns the load on the tool executor's worker pool, counting the workers held by
+// tools that overran their timeout
+func (te *ToolExecutor) WorkerStats() WorkerPoolStats {
+	stats := te.pool.Stats()
+	stats.Overrunning = atomic.LoadInt64(&te.overrunning)
+	return stats
+}
+
+// requestApproval holds a tool call for a user's decision and announces it.
+// Redelivered requests announce it again, in case the first announcement was lost.
+func (te *ToolExecutor) requestApproval(ctx context.Context, toolCall *entities.ToolCall, request *ports.ToolExecutionRequest) error {
+	if !toolCall.IsAwaitingApproval() {
+		toolCall.RequestApproval()
+		if err := te.storage.UpdateToolCall(ctx, toolCall); err != nil {
+			return fmt.Errorf("failed to update tool call: %w", err)
+		}
+	}
+
+	event := &ports.ToolApprovalEvent{
+		ToolCallID:     toolCall.ID,
+		Name:           toolCall.Name,
+		Arguments:      toolCall.Arguments,
+		MessageID:      toolCall.MessageID,
+		ConversationID: request.ConversationID,
+		Status:         toolCall.Status,
+	}
+	if err := te.messaging.PublishJSON(ctx, ports.SubjectToolApprovalRequested, event); err != nil {
+		return fmt.Errorf("failed to publish approval request: %w", err)
+	}
+
+	log.Printf("Tool call %s (%s) is awaiting approval", toolCall.ID, toolCall.Name)
+	return nil
+}
+
+// validateArguments applies the tool's parameter defaults to the request's arguments and returns
+// a failed result listing the violations if they don't match the schema. Unknown tools are left
+// for the provider to report.
+func (te *ToolExecutor) validateArguments(ctx context.Context, request *ports.ToolExecutionRequest) *ports.ToolResult {
+	tool, err := te.tools.GetTool(ctx, request.Name)
+	if err != nil || len(tool.Function.Parameters) == 0 {
+		return nil
+	}
+
+	if request.Arguments == nil {
+		request.Arguments = make(map[string]interface{})
+	}
+	jsonschema.ApplyDefaults(tool.Function.Parameters, request.Arguments)
+
+	errs := jsonschema.Validate(tool.Function.Parameters, request.Arguments)
+	if len(errs) == 0 {
+		return nil
+	}
+
+	messages := make([]string, 0, len(errs))
+	for _, e := range errs {
+		messages = append(messages, e.Error())
+	}
+	return &ports.ToolResult{
+		Success: false,
+		Error:   fmt.Sprintf("invalid arguments for tool %s: %s", request.Name, strings.Join(messages, "; ")),
+		Data:    map[string]interface{}{"validation_errors": errs},
+	}
+}
+
+// conversationToolSettings returns the tool settings that apply to a conversation, most specific first
+func conversationToolSettings(ctx context.Context, storage ports.StoragePort, conversationID string) ([]entities.ToolSettings, error) {
+	if conversationID == "" {
+		return nil, nil
+	}
+
+	conversation, err := storage.GetConversation(ctx, conversationID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get conversation: %w", err)
+	}
+	settings := []entities.ToolSettings{conversation.Tools}
+
+	if conversation.SystemPromptID != "" {
+		prompt, err := storage.GetSystemPrompt(ctx, conversation.SystemPromptID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get system prompt: %w", err)
+		}
+		settings = append(settings, prompt.Tools)
+	}
+	return settings, nil
+}
+
+// enabledTools filters tools by the settings of a conversation
+func enabledTools(tools []ports.Tool, settings []entities.ToolSettings) []ports.Tool {
+	enabled := make([]ports.Tool, 0, len(tools))
+	for _, tool := range tools {
+		if entities.ToolEnabled(tool.Function.Name, settings...) {
+			enabled = append(enabled, tool)
+		}
+	}
+	return enabled
+}
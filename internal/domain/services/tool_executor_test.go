@@ -0,0 +1,421 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/username/hexarag/internal/domain/entities"
+	"github.com/username/hexarag/internal/domain/ports"
+	"github.com/username/hexarag/pkg/jsonschema"
+)
+
+// recordingTools offers a fixed set of tools, all taking the same parameters, and records the calls it runs
+type recordingTools struct {
+	ports.ToolPort
+	names      []string
+	parameters map[string]interface{}
+	calls      []string
+	arguments  []map[string]interface{}
+}
+
+func (r *recordingTools) GetAvailableTools(ctx context.Context) ([]ports.Tool, error) {
+	tools := make([]ports.Tool, 0, len(r.names))
+	for _, name := range r.names {
+		tools = append(tools, ports.Tool{Type: "function", Function: ports.ToolFunction{Name: name, Parameters: r.parameters}})
+	}
+	return tools, nil
+}
+
+func (r *recordingTools) GetTool(ctx context.Context, name string) (*ports.Tool, error) {
+	tools, _ := r.GetAvailableTools(ctx)
+	for _, tool := range tools {
+		if tool.Function.Name == name {
+			return &tool, nil
+		}
+	}
+	return nil, fmt.Errorf("tool not found: %s", name)
+}
+
+func (r *recordingTools) Execute(ctx context.Context, name string, arguments map[string]interface{}) (*ports.ToolResult, error) {
+	r.calls = append(r.calls, name)
+	r.arguments = append(r.arguments, arguments)
+	return &ports.ToolResult{Success: true, Data: "ok"}, nil
+}
+
+func toolNames(tools []ports.Tool) []string {
+	names := make([]string, 0, len(tools))
+	for _, tool := range tools {
+		names = append(names, tool.Function.Name)
+	}
+	return names
+}
+
+// waitForToolCalls waits until the executor's workers have run n calls
+func waitForToolCalls(t *testing.T, executor *ToolExecutor, n int64) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for executor.WorkerStats().Completed < n {
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for %d tool calls, %d completed", n, executor.WorkerStats().Completed)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestToolExecutor_AppliesToolSettings(t *testing.T) {
+	ctx := context.Background()
+	storage := newTestStorage(t)
+	conversation, _ := newTestConversation(t, storage)
+
+	prompt, err := storage.GetSystemPrompt(ctx, conversation.SystemPromptID)
+	if err != nil {
+		t.Fatalf("GetSystemPrompt() error = %v", err)
+	}
+	prompt.SetTools(entities.ToolSettings{Disabled: []string{"time__*"}})
+	if err := storage.UpdateSystemPrompt(ctx, prompt); err != nil {
+		t.Fatalf("UpdateSystemPrompt() error = %v", err)
+	}
+
+	tools := &recordingTools{names: []string{"time__get_current_time", "time__list_supported_timezones", "docs__search"}}
+	engine := NewInferenceEngine(storage, discardMessaging{}, &countingLLM{}, tools, WorkerPoolConfig{Workers: 1}, 0)
+	executor := NewToolExecutor(storage, discardMessaging{}, tools, entities.ToolPolicies{}, ToolExecutionConfig{}, nil)
+
+	available, err := engine.availableTools(ctx, conversation.ID)
+	if err != nil {
+		t.Fatalf("availableTools() error = %v", err)
+	}
+	if names := toolNames(available); !slices.Equal(names, []string{"docs__search"}) {
+		t.Errorf("Expected the prompt to disable the time tools, got %v", names)
+	}
+
+	// The conversation's settings take precedence over the prompt's
+	conversation.SetTools(entities.ToolSettings{Enabled: []string{"time__get_current_time"}})
+	if err := storage.UpdateConversation(ctx, conversation); err != nil {
+		t.Fatalf("UpdateConversation() error = %v", err)
+	}
+
+	available, err = engine.availableTools(ctx, conversation.ID)
+	if err != nil {
+		t.Fatalf("availableTools() error = %v", err)
+	}
+	if names := toolNames(available); !slices.Equal(names, []string{"time__get_current_time"}) {
+		t.Errorf("Expected only the conversation's allowed tool, got %v", names)
+	}
+
+	result, err := executor.Execute(ctx, &ports.ToolExecutionRequest{ConversationID: conversation.ID, Name: "docs__search"})
+	if err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	if result.Success {
+		t.Errorf("Expected the disabled tool to be refused, got %+v", result)
+	}
+
+	result, err = executor.Execute(ctx, &ports.ToolExecutionRequest{ConversationID: conversation.ID, Name: "time__get_current_time"})
+	if err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	if !result.Success || !slices.Equal(tools.calls, []string{"time__get_current_time"}) {
+		t.Errorf("Expected only the enabled tool to run, got %+v and calls %v", result, tools.calls)
+	}
+}
+
+func TestToolExecutor_ValidatesArguments(t *testing.T) {
+	ctx := context.Background()
+	tools := &recordingTools{
+		names: []string{"time__get_current_time"},
+		parameters: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"timezone": map[string]interface{}{"type": "string"},
+				"format":   map[string]interface{}{"type": "string", "enum": []string{"iso", "unix"}, "default": "iso"},
+			},
+			"required": []string{"timezone"},
+		},
+	}
+	executor := NewToolExecutor(newTestStorage(t), discardMessaging{}, tools, entities.ToolPolicies{}, ToolExecutionConfig{}, nil)
+
+	result, err := executor.Execute(ctx, &ports.ToolExecutionRequest{
+		Name:      "time__get_current_time",
+		Arguments: map[string]interface{}{"format": "rfc"},
+	})
+	if err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	want := []jsonschema.ValidationError{
+		{Path: "/timezone", Message: "is required"},
+		{Path: "/format", Message: "must be one of \"iso\", \"unix\""},
+	}
+	data, _ := result.Data.(map[string]interface{})
+	if result.Success || !reflect.DeepEqual(data["validation_errors"], want) {
+		t.Errorf("Expected the validation errors as the result, got %+v", result)
+	}
+	if len(tools.calls) != 0 {
+		t.Errorf("Expected invalid arguments not to reach the tool, got calls %v", tools.calls)
+	}
+
+	result, err = executor.Execute(ctx, &ports.ToolExecutionRequest{
+		Name:      "time__get_current_time",
+		Arguments: map[string]interface{}{"timezone": "UTC"},
+	})
+	if err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	if !result.Success || len(tools.arguments) != 1 || tools.arguments[0]["format"] != "iso" {
+		t.Errorf("Expected the tool to run with the default format, got %+v and arguments %v", result, tools.arguments)
+	}
+}
+
+func TestToolExecutor_HoldsCallsForApproval(t *testing.T) {
+	ctx := context.Background()
+	storage := newTestStorage(t)
+	conversation, userMessage := newTestConversation(t, storage)
+
+	reply := entities.NewMessage(conversation.ID, entities.RoleAssistant, "")
+	reply.ParentID = &userMessage.ID
+	write := entities.NewToolCall(reply.ID, "files__write", map[string]interface{}{"path": "notes.txt"})
+	remove := entities.NewToolCall(reply.ID, "files__delete", map[string]interface{}{"path": "notes.txt"})
+	reply.AddToolCall(*write)
+	reply.AddToolCall(*remove)
+	if err := storage.SaveMessage(ctx, reply); err != nil {
+		t.Fatalf("SaveMessage() error = %v", err)
+	}
+
+	tools := &recordingTools{names: []string{"files__write", "files__delete"}}
+	policies := entities.ToolPolicies{Rules: []entities.ToolPolicyRule{{Tool: "files__*", Policy: entities.ToolPolicyRequireApproval}}}
+	executor := NewToolExecutor(storage, discardMessaging{}, tools, policies, ToolExecutionConfig{Workers: WorkerPoolConfig{Workers: 1, QueueSize: 2}}, nil)
+	executor.pool.Start(ctx)
+	engine := NewInferenceEngine(storage, discardMessaging{}, &countingLLM{}, tools, WorkerPoolConfig{Workers: 1}, 0)
+
+	for _, toolCall := range []*entities.ToolCall{write, remove} {
+		data, _ := json.Marshal(&ports.ToolExecutionRequest{
+			ToolCallID:     toolCall.ID,
+			Name:           toolCall.Name,
+			Arguments:      toolCall.Arguments,
+			MessageID:      reply.ID,
+			ConversationID: conversation.ID,
+		})
+		if err := executor.handleToolExecute(ctx, ports.SubjectToolExecute, data); err != nil {
+			t.Fatalf("handleToolExecute() error = %v", err)
+		}
+	}
+	if len(tools.calls) != 0 {
+		t.Fatalf("Expected calls to wait for approval, got calls %v", tools.calls)
+	}
+
+	approved, err := engine.ApproveToolCall(ctx, write.ID)
+	if err != nil {
+		t.Fatalf("ApproveToolCall() error = %v", err)
+	}
+	if approved.Status != entities.ToolCallStatusApproved {
+		t.Errorf("Expected the call to be approved, got %s", approved.Status)
+	}
+	if _, err := engine.ApproveToolCall(ctx, write.ID); !errors.Is(err, ErrToolCallNotAwaitingApproval) {
+		t.Errorf("Expected a second decision to be refused, got %v", err)
+	}
+
+	rejected, err := engine.RejectToolCall(ctx, remove.ID, "keep the file")
+	if err != nil {
+		t.Fatalf("RejectToolCall() error = %v", err)
+	}
+	if rejected.Status != entities.ToolCallStatusRejected || rejected.Result.Error != "rejected by user: keep the file" {
+		t.Errorf("Expected the call to be rejected with its reason, got %+v", rejected)
+	}
+
+	// The approved call runs when its request arrives again; the rejected one never does
+	for _, toolCall := range []*entities.ToolCall{write, remove} {
+		data, _ := json.Marshal(&ports.ToolExecutionRequest{ToolCallID: toolCall.ID, Name: toolCall.Name, MessageID: reply.ID, ConversationID: conversation.ID})
+		if err := executor.handleToolExecute(ctx, ports.SubjectToolExecute, data); err != nil {
+			t.Fatalf("handleToolExecute() error = %v", err)
+		}
+	}
+	waitForToolCalls(t, executor, 1)
+	if !slices.Equal(tools.calls, []string{"files__write"}) {
+		t.Errorf("Expected only the approved call to run, got %v", tools.calls)
+	}
+
+	events, err := storage.GetEventLog(ctx, conversation.ID, 0, 0)
+	if err != nil {
+		t.Fatalf("GetEventLog() error = %v", err)
+	}
+	var audit []string
+	for _, event := range events {
+		if strings.HasPrefix(event.EventType, "tool_call.") {
+			audit = append(audit, event.EventType)
+		}
+	}
+	want := []string{entities.EventToolApprovalRequested, entities.EventToolApprovalRequested, entities.EventToolCallApproved, entities.EventToolCallRejected}
+	if !slices.Equal(audit, want) {
+		t.Errorf("Expected the decisions to be audited, got %v", audit)
+	}
+}
+
+func TestInferenceEngine_DecidesApprovalsOnce(t *testing.T) {
+	ctx := context.Background()
+	storage := newTestStorage(t)
+	conversation, userMessage := newTestConversation(t, storage)
+
+	reply := entities.NewMessage(conversation.ID, entities.RoleAssistant, "")
+	reply.ParentID = &userMessage.ID
+	toolCall := entities.NewToolCall(reply.ID, "files__write", nil)
+	toolCall.RequestApproval()
+	reply.AddToolCall(*toolCall)
+	if err := storage.SaveMessage(ctx, reply); err != nil {
+		t.Fatalf("SaveMessage() error = %v", err)
+	}
+	engine := NewInferenceEngine(storage, discardMessaging{}, &countingLLM{}, noTools{}, WorkerPoolConfig{Workers: 1}, 0)
+
+	// Approvals and rejections race; only one of them may take effect
+	start := make(chan struct{})
+	errs := make(chan error, 16)
+	for i := 0; i < cap(errs); i++ {
+		go func() {
+			<-start
+			var err error
+			if i%2 == 0 {
+				_, err = engine.ApproveToolCall(ctx, toolCall.ID)
+			} else {
+				_, err = engine.RejectToolCall(ctx, toolCall.ID, "no")
+			}
+			errs <- err
+		}()
+	}
+	close(start)
+	decided := 0
+	for i := 0; i < cap(errs); i++ {
+		err := <-errs
+		switch {
+		case err == nil:
+			decided++
+		case !errors.Is(err, ErrToolCallNotAwaitingApproval):
+			t.Fatalf("Expected losing decisions to be refused, got %v", err)
+		}
+	}
+	if decided != 1 {
+		t.Errorf("Expected exactly one decision to take effect, got %d", decided)
+	}
+
+	events, err := storage.GetEventLog(ctx, conversation.ID, 0, 0)
+	if err != nil {
+		t.Fatalf("GetEventLog() error = %v", err)
+	}
+	decisions := 0
+	for _, event := range events {
+		if event.EventType == entities.EventToolCallApproved || event.EventType == entities.EventToolCallRejected {
+			decisions++
+		}
+	}
+	if decisions != 1 {
+		t.Errorf("Expected one decision in the event log, got %d", decisions)
+	}
+}
+
+// blockingTools holds every call until as many calls as it expects are running at once
+type blockingTools struct {
+	ports.ToolPort
+	expected int
+	mu       sync.Mutex
+	running  int
+	all      chan struct{}
+}
+
+func (b *blockingTools) GetTool(ctx context.Context, name string) (*ports.Tool, error) {
+	return nil, fmt.Errorf("tool not found: %s", name)
+}
+
+func (b *blockingTools) Execute(ctx context.Context, name string, arguments map[string]interface{}) (*ports.ToolResult, error) {
+	if name == "slow__wait" {
+		<-ctx.Done()
+		time.Sleep(10 * time.Millisecond) // Finishes after its call has been abandoned
+		return &ports.ToolResult{Success: true, Data: "late"}, nil
+	}
+	if name == "docs__dump" {
+		return &ports.ToolResult{Success: true, Data: map[string]interface{}{"text": strings.Repeat("word ", 100)}}, nil
+	}
+
+	b.mu.Lock()
+	b.running++
+	if b.running == b.expected {
+		close(b.all)
+	}
+	b.mu.Unlock()
+
+	select {
+	case <-b.all:
+		return &ports.ToolResult{Success: true, Data: name}, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// wordCounter counts whitespace-separated words as tokens
+type wordCounter struct{}
+
+func (wordCounter) CountTokens(text string) int {
+	return len(strings.Fields(text))
+}
+
+func (wordCounter) TruncateToTokenLimit(text string, maxTokens int) string {
+	return strings.Join(strings.Fields(text)[:maxTokens], " ")
+}
+
+func TestToolExecutor_RunsCallsConcurrentlyWithLimits(t *testing.T) {
+	ctx := context.Background()
+	storage := newTestStorage(t)
+	conversation, userMessage := newTestConversation(t, storage)
+
+	reply := entities.NewMessage(conversation.ID, entities.RoleAssistant, "")
+	reply.ParentID = &userMessage.ID
+	for _, name := range []string{"a__one", "a__two", "a__three"} {
+		reply.AddToolCall(*entities.NewToolCall(reply.ID, name, nil))
+	}
+	if err := storage.SaveMessage(ctx, reply); err != nil {
+		t.Fatalf("SaveMessage() error = %v", err)
+	}
+
+	tools := &blockingTools{expected: 3, all: make(chan struct{})}
+	executor := NewToolExecutor(storage, discardMessaging{}, tools, entities.ToolPolicies{}, ToolExecutionConfig{
+		Workers:         WorkerPoolConfig{Workers: 3, QueueSize: 3},
+		Timeout:         5 * time.Second,
+		Timeouts:        []ToolTimeout{{Tool: "slow__*", Timeout: 20 * time.Millisecond}},
+		MaxResultTokens: 10,
+	}, wordCounter{})
+	executor.pool.Start(ctx)
+
+	// The calls of one reply only finish once all of them are running, and each request is held until its call has
+	errs := make(chan error, len(reply.ToolCalls))
+	for _, toolCall := range reply.ToolCalls {
+		data, _ := json.Marshal(&ports.ToolExecutionRequest{ToolCallID: toolCall.ID, Name: toolCall.Name, MessageID: reply.ID, ConversationID: conversation.ID})
+		go func() {
+			errs <- executor.handleToolExecute(ctx, ports.SubjectToolExecute, data)
+		}()
+	}
+	for range reply.ToolCalls {
+		if err := <-errs; err != nil {
+			t.Fatalf("handleToolExecute() error = %v", err)
+		}
+	}
+	waitForToolCalls(t, executor, 3)
+
+	started := time.Now()
+	result, err := executor.Execute(ctx, &ports.ToolExecutionRequest{ConversationID: conversation.ID, Name: "slow__wait"})
+	if err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	if result.Success || result.Error != "tool slow__wait timed out after 20ms" || time.Since(started) > time.Second {
+		t.Errorf("Expected the per-tool timeout to fail the call, got %+v", result)
+	}
+
+	result, _ = executor.Execute(ctx, &ports.ToolExecutionRequest{ConversationID: conversation.ID, Name: "docs__dump"})
+	text, _ := result.Data.(string)
+	if !result.Success || !strings.HasSuffix(text, "[truncated: showing 10 of 101 tokens]") || result.Metadata["truncated"] != true || result.Metadata["original_tokens"] != 101 {
+		t.Errorf("Expected the result to be truncated to 10 tokens, got %+v", result)
+	}
+}
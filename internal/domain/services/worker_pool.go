@@ -0,0 +1,190 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+)
+
+// ErrQueueFull is returned when a worker pool has no room for another job
+var ErrQueueFull = errors.New("worker queue is full")
+
+// WorkerPoolConfig sizes a service's worker pool
+type WorkerPoolConfig struct {
+	Workers   int // Jobs running at once; at least one
+	QueueSize int // Jobs waiting for a worker before new ones are rejected
+}
+
+// WorkerPoolStats describes the load on a worker pool
+type WorkerPoolStats struct {
+	Name      string `json:"name"`
+	Workers   int    `json:"workers"`
+	Active    int64  `json:"active"`
+	Queued    int    `json:"queued"`
+	QueueSize int    `json:"queue_size"`
+	Completed int64  `json:"completed"`
+	Rejected  int64  `json:"rejected"`
+
+	Overrunning int64 `json:"overrunning,omitempty"` // Active jobs held by work that outlived its timeout
+}
+
+// WorkerPool runs jobs on a fixed number of workers with a bounded backlog, so bursts of
+// messages can't start unbounded concurrent work
+type WorkerPool struct {
+	name      string
+	workers   int
+	jobs      chan func()
+	startOnce sync.Once
+
+	active    int64
+	completed int64
+	rejected  int64
+}
+
+// NewWorkerPool creates a worker pool; its workers run once Start is called
+func NewWorkerPool(name string, config WorkerPoolConfig) *WorkerPool {
+	workers := config.Workers
+	if workers < 1 {
+		workers = 1
+	}
+	queueSize := config.QueueSize
+	if queueSize < 0 {
+		queueSize = 0
+	}
+
+	return &WorkerPool{
+		name:    name,
+		workers: workers,
+		jobs:    make(chan func(), queueSize),
+	}
+}
+
+// Start runs the workers until ctx is done
+func (p *WorkerPool) Start(ctx context.Context) {
+	p.startOnce.Do(func() {
+		for i := 0; i < p.workers; i++ {
+			go p.work(ctx)
+		}
+	})
+}
+
+func (p *WorkerPool) work(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case job := <-p.jobs:
+			atomic.AddInt64(&p.active, 1)
+			job()
+			atomic.AddInt64(&p.active, -1)
+			atomic.AddInt64(&p.completed, 1)
+		}
+	}
+}
+
+// Submit queues a job without blocking, returning ErrQueueFull when the backlog is full
+func (p *WorkerPool) Submit(job func()) error {
+	select {
+	case p.jobs <- job:
+		return nil
+	default:
+		atomic.AddInt64(&p.rejected, 1)
+		return ErrQueueFull
+	}
+}
+
+// Run queues a job and waits for it to finish, returning its error. It returns ErrQueueFull
+// without running the job when the backlog is full, and ctx's error if ctx ends first.
+func (p *WorkerPool) Run(ctx context.Context, job func() error) error {
+	done := make(chan error, 1)
+	if err := p.Submit(func() { done <- job() }); err != nil {
+		return err
+	}
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Full reports whether new jobs would be rejected
+func (p *WorkerPool) Full() bool {
+	if cap(p.jobs) == 0 {
+		return atomic.LoadInt64(&p.active) >= int64(p.workers)
+	}
+	return len(p.jobs) >= cap(p.jobs)
+}
+
+// Busy reports whether a new job would have to wait for a worker
+func (p *WorkerPool) Busy() bool {
+	return len(p.jobs) > 0 || atomic.LoadInt64(&p.active) >= int64(p.workers)
+}
+
+// Stats returns the current load on the pool
+func (p *WorkerPool) Stats() WorkerPoolStats {
+	return WorkerPoolStats{
+		Name:      p.name,
+		Workers:   p.workers,
+		Active:    atomic.LoadInt64(&p.active),
+		Queued:    len(p.jobs),
+		QueueSize: cap(p.jobs),
+		Completed: atomic.LoadInt64(&p.completed),
+		Rejected:  atomic.LoadInt64(&p.rejected),
+	}
+}
+
+// modelLimiter bounds the number of concurrent LLM calls per model
+type modelLimiter struct {
+	limit int // 0 is unlimited
+	mu    sync.Mutex
+	slots map[string]chan struct{}
+}
+
+func newModelLimiter(limit int) *modelLimiter {
+	return &modelLimiter{
+		limit: limit,
+		slots: make(map[string]chan struct{}),
+	}
+}
+
+// acquire waits for a free slot for model and returns the function that frees it
+func (l *modelLimiter) acquire(ctx context.Context, model string) (func(), error) {
+	if l.limit <= 0 {
+		return func() {}, nil
+	}
+
+	slots := l.slotsFor(model)
+	select {
+	case slots <- struct{}{}:
+		return func() { <-slots }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (l *modelLimiter) slotsFor(model string) chan struct{} {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	slots, ok := l.slots[model]
+	if !ok {
+		slots = make(chan struct{}, l.limit)
+		l.slots[model] = slots
+	}
+	return slots
+}
+
+// inFlight returns the number of running calls per model
+func (l *modelLimiter) inFlight() map[string]int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	counts := make(map[string]int, len(l.slots))
+	for model, slots := range l.slots {
+		counts[model] = len(slots)
+	}
+	return counts
+}
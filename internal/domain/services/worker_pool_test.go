@@ -0,0 +1,85 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestWorkerPool_RejectsWhenQueueIsFull(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	pool := NewWorkerPool("test", WorkerPoolConfig{Workers: 1, QueueSize: 1})
+	pool.Start(ctx)
+
+	started := make(chan struct{})
+	unblock := make(chan struct{})
+	if err := pool.Submit(func() { close(started); <-unblock }); err != nil {
+		t.Fatalf("Submit() error = %v", err)
+	}
+	<-started
+
+	// The only worker is busy, so the next job waits in the queue
+	done := make(chan struct{})
+	if err := pool.Submit(func() { close(done) }); err != nil {
+		t.Fatalf("Submit() error = %v", err)
+	}
+	if !pool.Busy() || !pool.Full() {
+		t.Errorf("Expected a busy, full pool, got %+v", pool.Stats())
+	}
+
+	if err := pool.Submit(func() {}); !errors.Is(err, ErrQueueFull) {
+		t.Errorf("Expected ErrQueueFull, got %v", err)
+	}
+
+	close(unblock)
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for the queued job")
+	}
+
+	stats := pool.Stats()
+	if stats.Rejected != 1 || stats.QueueSize != 1 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+
+	// Run waits for its job and returns the job's error
+	failure := errors.New("job failed")
+	if err := pool.Run(ctx, func() error { return failure }); !errors.Is(err, failure) {
+		t.Errorf("Expected the job's error from Run, got %v", err)
+	}
+}
+
+func TestModelLimiter_BoundsCallsPerModel(t *testing.T) {
+	limiter := newModelLimiter(1)
+
+	release, err := limiter.acquire(context.Background(), "llama3.2:3b")
+	if err != nil {
+		t.Fatalf("acquire() error = %v", err)
+	}
+
+	// Other models have their own slots
+	releaseOther, err := limiter.acquire(context.Background(), "qwen2.5:7b")
+	if err != nil {
+		t.Fatalf("acquire(other model) error = %v", err)
+	}
+	releaseOther()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := limiter.acquire(ctx, "llama3.2:3b"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected the second call to wait for a slot, got %v", err)
+	}
+
+	if got := limiter.inFlight()["llama3.2:3b"]; got != 1 {
+		t.Errorf("Expected 1 call in flight, got %d", got)
+	}
+
+	release()
+	if _, err := limiter.acquire(context.Background(), "llama3.2:3b"); err != nil {
+		t.Errorf("Expected a free slot after release, got %v", err)
+	}
+}
@@ -2,19 +2,24 @@ package config
 
 import (
 	"fmt"
+	"regexp"
 	"strings"
+	"time"
 
 	"github.com/spf13/viper"
 )
 
 // Config represents the application configuration
 type Config struct {
-	Server   ServerConfig   `mapstructure:"server"`
-	NATS     NATSConfig     `mapstructure:"nats"`
-	Database DatabaseConfig `mapstructure:"database"`
-	LLM      LLMConfig      `mapstructure:"llm"`
-	Tools    ToolsConfig    `mapstructure:"tools"`
-	Logging  LoggingConfig  `mapstructure:"logging"`
+	Server    ServerConfig    `mapstructure:"server"`
+	NATS      NATSConfig      `mapstructure:"nats"`
+	Database  DatabaseConfig  `mapstructure:"database"`
+	LLM       LLMConfig       `mapstructure:"llm"`
+	Tools     ToolsConfig     `mapstructure:"tools"`
+	Workers   WorkersConfig   `mapstructure:"workers"`
+	Retention RetentionConfig `mapstructure:"retention"`
+	Trash     TrashConfig     `mapstructure:"trash"`
+	Logging   LoggingConfig   `mapstructure:"logging"`
 }
 
 // ServerConfig holds HTTP server configuration
@@ -24,10 +29,51 @@ type ServerConfig struct {
 	CORSEnabled bool   `mapstructure:"cors_enabled"`
 }
 
+// toolNamespacePattern matches the names the tool registry accepts as namespaces for MCP servers and OpenAPI tools
+var toolNamespacePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*(_[a-z0-9-]+)*$`)
+
+// InProcessMessagingURL selects the in-process message bus instead of a NATS server
+const InProcessMessagingURL = "inproc"
+
 // NATSConfig holds NATS configuration
 type NATSConfig struct {
-	URL       string          `mapstructure:"url"`
-	JetStream JetStreamConfig `mapstructure:"jetstream"`
+	URL       string             `mapstructure:"url"` // A NATS or Redis server URL, or InProcessMessagingURL; ignored when embedded
+	JetStream JetStreamConfig    `mapstructure:"jetstream"`
+	Delivery  DeliveryConfig     `mapstructure:"delivery"`
+	Embedded  EmbeddedNATSConfig `mapstructure:"embedded"`
+}
+
+// UsesInProcess reports whether URL selects the in-process message bus
+func (c NATSConfig) UsesInProcess() bool {
+	return !c.Embedded.Enabled && c.URL == InProcessMessagingURL
+}
+
+// UsesRedis reports whether URL selects a Redis server, whose streams then replace NATS
+func (c NATSConfig) UsesRedis() bool {
+	return !c.Embedded.Enabled && (strings.HasPrefix(c.URL, "redis://") || strings.HasPrefix(c.URL, "rediss://"))
+}
+
+// UsesJetStream reports whether NATS messages are kept in JetStream streams, which the
+// embedded server always provides
+func (c NATSConfig) UsesJetStream() bool {
+	return c.JetStream.Enabled || c.Embedded.Enabled
+}
+
+// EmbeddedNATSConfig holds configuration for a NATS server started inside the HexaRAG process
+type EmbeddedNATSConfig struct {
+	Enabled bool   `mapstructure:"enabled"`  // Also enables JetStream
+	DataDir string `mapstructure:"data_dir"` // JetStream storage directory
+	Host    string `mapstructure:"host"`
+	Port    int    `mapstructure:"port"` // -1 picks a random free port
+}
+
+// DeliveryConfig holds message redelivery and dead-letter configuration
+type DeliveryConfig struct {
+	MaxDeliveries    int             `mapstructure:"max_deliveries"` // 0 uses the default of 5
+	Backoff          []time.Duration `mapstructure:"backoff"`        // The last value repeats
+	AckWait          time.Duration   `mapstructure:"ack_wait"`
+	DeadLetterPrefix string          `mapstructure:"dead_letter_prefix"`
+	MaxInFlight      int             `mapstructure:"max_in_flight"` // Messages a queue subscription handles at once
 }
 
 // JetStreamConfig holds JetStream-specific configuration
@@ -55,6 +101,57 @@ type LLMConfig struct {
 // ToolsConfig holds tool configuration
 type ToolsConfig struct {
 	MCPTimeServer MCPTimeServerConfig `mapstructure:"mcp_time_server"`
+	CodeExecution CodeExecutionConfig `mapstructure:"code_execution"`
+	Knowledge     KnowledgeConfig     `mapstructure:"knowledge"`
+	MCPServers    []MCPServerConfig   `mapstructure:"mcp_servers"`    // External MCP servers whose tools are offered to the model
+	OpenAPI       []OpenAPIConfig     `mapstructure:"openapi"`        // REST APIs whose operations are offered to the model
+	DefaultPolicy string              `mapstructure:"default_policy"` // Policy of tools no rule matches: auto, require_approval or deny
+	Policies      []ToolPolicyConfig  `mapstructure:"policies"`       // First matching rule wins
+	Execution     ToolExecutionConfig `mapstructure:"execution"`
+}
+
+// ToolExecutionConfig limits each tool call; how many run at once is set by workers.tools
+type ToolExecutionConfig struct {
+	Timeout         time.Duration       `mapstructure:"timeout"`           // Per call; 0 waits for the tool
+	Timeouts        []ToolTimeoutConfig `mapstructure:"timeouts"`          // Overrides by tool pattern; the first match wins
+	MaxResultTokens int                 `mapstructure:"max_result_tokens"` // Larger results are truncated; 0 keeps them whole
+}
+
+// ToolTimeoutConfig sets the timeout of the tools matching a pattern, e.g. "code__*"
+type ToolTimeoutConfig struct {
+	Tool    string        `mapstructure:"tool"`
+	Timeout time.Duration `mapstructure:"timeout"`
+}
+
+// CodeExecutionConfig limits the sandboxed Starlark interpreter offered as the code__run_starlark tool
+type CodeExecutionConfig struct {
+	Enabled        bool          `mapstructure:"enabled"`
+	Timeout        time.Duration `mapstructure:"timeout"`          // Wall-clock limit per run
+	MaxSteps       uint64        `mapstructure:"max_steps"`        // Interpreter steps per run, a proxy for CPU
+	MaxAllocBytes  uint64        `mapstructure:"max_alloc_bytes"`  // Memory each run's worker process may hold
+	MaxOutputBytes int           `mapstructure:"max_output_bytes"` // Printed output kept per run
+}
+
+// OpenAPIConfig selects operations of a REST API described by an OpenAPI 3 document
+type OpenAPIConfig struct {
+	Name             string            `mapstructure:"name"`
+	Spec             string            `mapstructure:"spec"`               // Path or http(s) URL of the document, JSON or YAML
+	BaseURL          string            `mapstructure:"base_url"`           // Overrides the document's first server URL
+	Operations       []string          `mapstructure:"operations"`         // Operation IDs to expose; all when empty
+	Headers          map[string]string `mapstructure:"headers"`            // Sent with every call, e.g. Authorization
+	Timeout          time.Duration     `mapstructure:"timeout"`            // Per call; 0 uses 30 seconds
+	MaxResponseBytes int64             `mapstructure:"max_response_bytes"` // Larger responses fail the call; 0 uses 1 MiB
+}
+
+// ToolPolicyConfig applies a policy to the tools matching a pattern, e.g. "filesystem__write_*"
+type ToolPolicyConfig struct {
+	Tool   string `mapstructure:"tool"`
+	Policy string `mapstructure:"policy"` // auto, require_approval or deny
+}
+
+// KnowledgeConfig holds settings of the knowledge__search_knowledge tool over stored conversations
+type KnowledgeConfig struct {
+	Enabled bool `mapstructure:"enabled"`
 }
 
 // MCPTimeServerConfig holds MCP time server configuration
@@ -63,6 +160,47 @@ type MCPTimeServerConfig struct {
 	Timezones []string `mapstructure:"timezones"`
 }
 
+// MCPServerConfig holds the connection settings of an external MCP server
+type MCPServerConfig struct {
+	Name      string            `mapstructure:"name"`
+	Transport string            `mapstructure:"transport"` // "stdio" or "http"
+	Command   string            `mapstructure:"command"`   // Executable for stdio servers
+	Args      []string          `mapstructure:"args"`
+	Env       map[string]string `mapstructure:"env"`
+	URL       string            `mapstructure:"url"`     // Endpoint of http servers
+	Headers   map[string]string `mapstructure:"headers"` // Sent with every request, e.g. Authorization
+	Timeout   time.Duration     `mapstructure:"timeout"` // Per request; 0 uses 30 seconds
+}
+
+// WorkersConfig holds concurrency limits for the pipeline services
+type WorkersConfig struct {
+	Context             WorkerPoolConfig `mapstructure:"context"`
+	Inference           WorkerPoolConfig `mapstructure:"inference"`
+	Tools               WorkerPoolConfig `mapstructure:"tools"`                   // Tool calls running at once, across replies
+	MaxInFlightPerModel int              `mapstructure:"max_in_flight_per_model"` // Concurrent LLM calls per model; 0 is unlimited
+}
+
+// WorkerPoolConfig holds the size of one service's worker pool
+type WorkerPoolConfig struct {
+	Concurrency int `mapstructure:"concurrency"` // Messages processed at once
+	QueueSize   int `mapstructure:"queue_size"`  // Messages waiting for a worker; more are redelivered later
+}
+
+// RetentionConfig holds data retention and pruning configuration
+type RetentionConfig struct {
+	Enabled                  bool          `mapstructure:"enabled"`
+	Interval                 time.Duration `mapstructure:"interval"`
+	ConversationMaxAgeDays   int           `mapstructure:"conversation_max_age_days"`   // 0 keeps conversations forever
+	ArchiveConversations     bool          `mapstructure:"archive_conversations"`       // Archive instead of delete
+	MaxEventsPerConversation int           `mapstructure:"max_events_per_conversation"` // 0 keeps all events
+	VacuumInterval           time.Duration `mapstructure:"vacuum_interval"`             // 0 disables vacuuming
+}
+
+// TrashConfig holds soft delete configuration
+type TrashConfig struct {
+	GracePeriodDays int `mapstructure:"grace_period_days"` // 0 keeps deleted items until restored
+}
+
 // LoggingConfig holds logging configuration
 type LoggingConfig struct {
 	Level  string `mapstructure:"level"`
@@ -83,6 +221,19 @@ func DefaultConfig() *Config {
 				Enabled:       true,
 				RetentionDays: 7,
 			},
+			Delivery: DeliveryConfig{
+				MaxDeliveries:    5,
+				Backoff:          []time.Duration{time.Second, 5 * time.Second, 30 * time.Second},
+				AckWait:          time.Minute,
+				DeadLetterPrefix: "system.dlq",
+				MaxInFlight:      32,
+			},
+			Embedded: EmbeddedNATSConfig{
+				Enabled: false,
+				DataDir: "./data/nats",
+				Host:    "127.0.0.1",
+				Port:    4222,
+			},
 		},
 		Database: DatabaseConfig{
 			Path:           "./data/hexarag.db",
@@ -100,6 +251,47 @@ func DefaultConfig() *Config {
 				Enabled:   true,
 				Timezones: []string{"UTC", "America/New_York", "Europe/London"},
 			},
+			CodeExecution: CodeExecutionConfig{
+				Enabled:        false,
+				Timeout:        5 * time.Second,
+				MaxSteps:       10000000,
+				MaxAllocBytes:  256 << 20,
+				MaxOutputBytes: 64 << 10,
+			},
+			Knowledge: KnowledgeConfig{
+				Enabled: true,
+			},
+			DefaultPolicy: "auto",
+			Execution: ToolExecutionConfig{
+				Timeout:         60 * time.Second,
+				MaxResultTokens: 4000,
+			},
+		},
+		Workers: WorkersConfig{
+			Context: WorkerPoolConfig{
+				Concurrency: 4,
+				QueueSize:   100,
+			},
+			Inference: WorkerPoolConfig{
+				Concurrency: 2,
+				QueueSize:   50,
+			},
+			Tools: WorkerPoolConfig{
+				Concurrency: 8,
+				QueueSize:   100,
+			},
+			MaxInFlightPerModel: 1,
+		},
+		Retention: RetentionConfig{
+			Enabled:                  false,
+			Interval:                 time.Hour,
+			ConversationMaxAgeDays:   0,
+			ArchiveConversations:     true,
+			MaxEventsPerConversation: 0,
+			VacuumInterval:           24 * time.Hour,
+		},
+		Trash: TrashConfig{
+			GracePeriodDays: 30,
 		},
 		Logging: LoggingConfig{
 			Level:  "info",
@@ -164,9 +356,118 @@ func (c *Config) Validate() error {
 		return fmt.Errorf("LLM model cannot be empty")
 	}
 
-	if c.NATS.URL == "" {
+	if c.NATS.Embedded.Enabled {
+		if c.NATS.Embedded.DataDir == "" {
+			return fmt.Errorf("embedded NATS data directory cannot be empty")
+		}
+	} else if c.NATS.URL == "" {
 		return fmt.Errorf("NATS URL cannot be empty")
 	}
 
+	if c.NATS.Delivery.MaxDeliveries < 0 {
+		return fmt.Errorf("max deliveries cannot be negative: %d", c.NATS.Delivery.MaxDeliveries)
+	}
+	if c.NATS.Delivery.MaxInFlight < 1 {
+		return fmt.Errorf("max in flight must be at least 1: %d", c.NATS.Delivery.MaxInFlight)
+	}
+
+	for name, pool := range map[string]WorkerPoolConfig{"context": c.Workers.Context, "inference": c.Workers.Inference, "tools": c.Workers.Tools} {
+		if pool.Concurrency < 1 {
+			return fmt.Errorf("%s worker concurrency must be at least 1: %d", name, pool.Concurrency)
+		}
+		if pool.QueueSize < 0 {
+			return fmt.Errorf("%s worker queue size cannot be negative: %d", name, pool.QueueSize)
+		}
+	}
+
+	names := make(map[string]bool)
+	for _, server := range c.Tools.MCPServers {
+		if server.Name == "" {
+			return fmt.Errorf("MCP server name cannot be empty")
+		}
+		if !toolNamespacePattern.MatchString(server.Name) {
+			return fmt.Errorf("MCP server name %q must be lowercase letters, digits, hyphens and single underscores", server.Name)
+		}
+		if names[server.Name] {
+			return fmt.Errorf("duplicate MCP server name: %s", server.Name)
+		}
+		names[server.Name] = true
+
+		switch server.Transport {
+		case "stdio":
+			if server.Command == "" {
+				return fmt.Errorf("MCP server %s needs a command", server.Name)
+			}
+		case "http":
+			if server.URL == "" {
+				return fmt.Errorf("MCP server %s needs a URL", server.Name)
+			}
+		default:
+			return fmt.Errorf("MCP server %s has unknown transport %q (use stdio or http)", server.Name, server.Transport)
+		}
+	}
+
+	for _, api := range c.Tools.OpenAPI {
+		if api.Name == "" {
+			return fmt.Errorf("OpenAPI tool name cannot be empty")
+		}
+		if !toolNamespacePattern.MatchString(api.Name) {
+			return fmt.Errorf("OpenAPI tool name %q must be lowercase letters, digits, hyphens and single underscores", api.Name)
+		}
+		if names[api.Name] {
+			return fmt.Errorf("duplicate tool provider name: %s", api.Name)
+		}
+		names[api.Name] = true
+
+		if api.Spec == "" {
+			return fmt.Errorf("OpenAPI tool %s needs a spec", api.Name)
+		}
+		if api.Timeout < 0 || api.MaxResponseBytes < 0 {
+			return fmt.Errorf("OpenAPI tool %s cannot have a negative timeout or response size limit", api.Name)
+		}
+	}
+
+	if code := c.Tools.CodeExecution; code.Timeout < 0 || code.MaxOutputBytes < 0 {
+		return fmt.Errorf("code execution cannot have a negative timeout or output limit")
+	}
+
+	if c.Tools.Execution.Timeout < 0 || c.Tools.Execution.MaxResultTokens < 0 {
+		return fmt.Errorf("tool call timeout and result token cap cannot be negative")
+	}
+	for _, override := range c.Tools.Execution.Timeouts {
+		if override.Tool == "" || override.Timeout <= 0 {
+			return fmt.Errorf("tool timeout override needs a tool pattern and a positive timeout")
+		}
+	}
+
+	if !validToolPolicy(c.Tools.DefaultPolicy) {
+		return fmt.Errorf("invalid default tool policy %q (use auto, require_approval or deny)", c.Tools.DefaultPolicy)
+	}
+	for _, rule := range c.Tools.Policies {
+		if rule.Tool == "" {
+			return fmt.Errorf("tool policy needs a tool pattern")
+		}
+		if !validToolPolicy(rule.Policy) {
+			return fmt.Errorf("invalid tool policy %q for %s (use auto, require_approval or deny)", rule.Policy, rule.Tool)
+		}
+	}
+
+	if c.Workers.MaxInFlightPerModel < 0 {
+		return fmt.Errorf("max in-flight inference per model cannot be negative: %d", c.Workers.MaxInFlightPerModel)
+	}
+
+	if (c.Retention.Enabled || c.Trash.GracePeriodDays > 0) && c.Retention.Interval <= 0 {
+		return fmt.Errorf("retention interval must be positive")
+	}
+
+	if c.Trash.GracePeriodDays < 0 {
+		return fmt.Errorf("trash grace period cannot be negative: %d", c.Trash.GracePeriodDays)
+	}
+
 	return nil
 }
+
+// validToolPolicy reports whether policy names a tool policy
+func validToolPolicy(policy string) bool {
+	return policy == "auto" || policy == "require_approval" || policy == "deny"
+}
@@ -0,0 +1,338 @@
+// Package jsonschema validates decoded JSON values against the subset of JSON Schema used to
+// describe tool parameters: types, enums, object properties, arrays, numeric and string bounds,
+// and the anyOf, oneOf and allOf combinators. Unsupported keywords are ignored.
+package jsonschema
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ValidationError describes one way a value violates a schema
+type ValidationError struct {
+	Path    string `json:"path"` // JSON Pointer to the invalid value; empty for the value itself
+	Message string `json:"message"`
+}
+
+func (e ValidationError) Error() string {
+	if e.Path == "" {
+		return e.Message
+	}
+	return e.Path + ": " + e.Message
+}
+
+// Validate checks value against schema, returning every violation found
+func Validate(schema map[string]interface{}, value interface{}) []ValidationError {
+	var errs []ValidationError
+	validate(schema, value, "", &errs)
+	return errs
+}
+
+// ApplyDefaults fills in missing object properties whose schema declares a default,
+// including inside nested objects and arrays. Values are changed in place.
+func ApplyDefaults(schema map[string]interface{}, value interface{}) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		properties, _ := schema["properties"].(map[string]interface{})
+		for name, raw := range properties {
+			propertySchema, ok := raw.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			if _, present := v[name]; !present {
+				if def, ok := propertySchema["default"]; ok {
+					v[name] = copyValue(def)
+				}
+			}
+			if property, present := v[name]; present {
+				ApplyDefaults(propertySchema, property)
+			}
+		}
+	case []interface{}:
+		if items, ok := schema["items"].(map[string]interface{}); ok {
+			for _, item := range v {
+				ApplyDefaults(items, item)
+			}
+		}
+	}
+}
+
+// copyValue deep-copies a default through JSON so later changes don't alter the schema
+func copyValue(value interface{}) interface{} {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return value
+	}
+	var copied interface{}
+	if err := json.Unmarshal(data, &copied); err != nil {
+		return value
+	}
+	return copied
+}
+
+func validate(schema map[string]interface{}, value interface{}, path string, errs *[]ValidationError) {
+	fail := func(format string, args ...interface{}) {
+		*errs = append(*errs, ValidationError{Path: path, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if types := stringList(schema["type"]); len(types) > 0 {
+		actual := typeOf(value)
+		if !matchesType(types, value, actual) {
+			fail("must be %s, got %s", strings.Join(types, " or "), actual)
+			return // Further keywords would only repeat the type error
+		}
+	}
+
+	if enum, ok := schema["enum"]; ok {
+		allowed := list(enum)
+		if !containsValue(allowed, value) {
+			fail("must be one of %s", formatValues(allowed))
+		}
+	}
+	if constant, ok := schema["const"]; ok && !equal(constant, value) {
+		fail("must be %s", formatValue(constant))
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		validateObject(schema, v, path, errs)
+	case string:
+		length := len([]rune(v))
+		if min, ok := number(schema["minLength"]); ok && float64(length) < min {
+			fail("must be at least %v characters long", min)
+		}
+		if max, ok := number(schema["maxLength"]); ok && float64(length) > max {
+			fail("must be at most %v characters long", max)
+		}
+		if pattern, ok := schema["pattern"].(string); ok {
+			if re, err := regexp.Compile(pattern); err == nil && !re.MatchString(v) {
+				fail("must match pattern %s", pattern)
+			}
+		}
+	default:
+		if n, ok := number(value); ok {
+			validateNumber(schema, n, fail)
+		} else if items := list(value); items != nil {
+			validateArray(schema, items, path, errs)
+		}
+	}
+
+	if allOf := list(schema["allOf"]); allOf != nil {
+		for _, raw := range allOf {
+			if sub, ok := raw.(map[string]interface{}); ok {
+				validate(sub, value, path, errs)
+			}
+		}
+	}
+	if anyOf := list(schema["anyOf"]); anyOf != nil && countMatches(anyOf, value, path) == 0 {
+		fail("must match at least one of the allowed schemas")
+	}
+	if oneOf := list(schema["oneOf"]); oneOf != nil && countMatches(oneOf, value, path) != 1 {
+		fail("must match exactly one of the allowed schemas")
+	}
+}
+
+func validateObject(schema map[string]interface{}, object map[string]interface{}, path string, errs *[]ValidationError) {
+	for _, name := range stringList(schema["required"]) {
+		if _, ok := object[name]; !ok {
+			*errs = append(*errs, ValidationError{Path: path + "/" + name, Message: "is required"})
+		}
+	}
+
+	properties, _ := schema["properties"].(map[string]interface{})
+	names := make([]string, 0, len(object))
+	for name := range object {
+		names = append(names, name)
+	}
+	sort.Strings(names) // Stable error order
+
+	for _, name := range names {
+		propertyPath := path + "/" + name
+		if raw, ok := properties[name]; ok {
+			if propertySchema, ok := raw.(map[string]interface{}); ok {
+				validate(propertySchema, object[name], propertyPath, errs)
+			}
+			continue
+		}
+
+		switch additional := schema["additionalProperties"].(type) {
+		case bool:
+			if !additional {
+				*errs = append(*errs, ValidationError{Path: propertyPath, Message: "is not an allowed property"})
+			}
+		case map[string]interface{}:
+			validate(additional, object[name], propertyPath, errs)
+		}
+	}
+}
+
+func validateArray(schema map[string]interface{}, items []interface{}, path string, errs *[]ValidationError) {
+	if min, ok := number(schema["minItems"]); ok && float64(len(items)) < min {
+		*errs = append(*errs, ValidationError{Path: path, Message: fmt.Sprintf("must have at least %v items", min)})
+	}
+	if max, ok := number(schema["maxItems"]); ok && float64(len(items)) > max {
+		*errs = append(*errs, ValidationError{Path: path, Message: fmt.Sprintf("must have at most %v items", max)})
+	}
+	if itemSchema, ok := schema["items"].(map[string]interface{}); ok {
+		for i, item := range items {
+			validate(itemSchema, item, path+"/"+strconv.Itoa(i), errs)
+		}
+	}
+}
+
+func validateNumber(schema map[string]interface{}, n float64, fail func(string, ...interface{})) {
+	if min, ok := number(schema["minimum"]); ok && n < min {
+		fail("must be at least %v", min)
+	}
+	if max, ok := number(schema["maximum"]); ok && n > max {
+		fail("must be at most %v", max)
+	}
+	if min, ok := number(schema["exclusiveMinimum"]); ok && n <= min {
+		fail("must be greater than %v", min)
+	}
+	if max, ok := number(schema["exclusiveMaximum"]); ok && n >= max {
+		fail("must be less than %v", max)
+	}
+}
+
+// countMatches returns how many of the schemas accept value
+func countMatches(schemas []interface{}, value interface{}, path string) int {
+	matches := 0
+	for _, raw := range schemas {
+		sub, ok := raw.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		var subErrs []ValidationError
+		validate(sub, value, path, &subErrs)
+		if len(subErrs) == 0 {
+			matches++
+		}
+	}
+	return matches
+}
+
+// typeOf names the JSON type of a decoded value
+func typeOf(value interface{}) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case map[string]interface{}:
+		return "object"
+	}
+	if _, ok := number(value); ok {
+		return "number"
+	}
+	if list(value) != nil {
+		return "array"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+func matchesType(types []string, value interface{}, actual string) bool {
+	for _, t := range types {
+		switch {
+		case t == actual:
+			return true
+		case t == "integer" && actual == "number":
+			if n, _ := number(value); n == math.Trunc(n) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// number converts the numeric types found in decoded JSON and Go-built schemas
+func number(value interface{}) (float64, bool) {
+	switch n := value.(type) {
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// list returns the elements of any slice, or nil if value is not one
+func list(value interface{}) []interface{} {
+	if items, ok := value.([]interface{}); ok {
+		return items
+	}
+	rv := reflect.ValueOf(value)
+	if !rv.IsValid() || rv.Kind() != reflect.Slice {
+		return nil
+	}
+	items := make([]interface{}, rv.Len())
+	for i := range items {
+		items[i] = rv.Index(i).Interface()
+	}
+	return items
+}
+
+// stringList reads a keyword holding a string or a list of strings
+func stringList(value interface{}) []string {
+	if s, ok := value.(string); ok {
+		return []string{s}
+	}
+	var strs []string
+	for _, item := range list(value) {
+		if s, ok := item.(string); ok {
+			strs = append(strs, s)
+		}
+	}
+	return strs
+}
+
+func containsValue(values []interface{}, value interface{}) bool {
+	for _, v := range values {
+		if equal(v, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// equal compares decoded JSON values, treating all numeric types alike
+func equal(a, b interface{}) bool {
+	if x, ok := number(a); ok {
+		y, ok := number(b)
+		return ok && x == y
+	}
+	return reflect.DeepEqual(copyValue(a), copyValue(b))
+}
+
+func formatValues(values []interface{}) string {
+	formatted := make([]string, 0, len(values))
+	for _, v := range values {
+		formatted = append(formatted, formatValue(v))
+	}
+	return strings.Join(formatted, ", ")
+}
+
+func formatValue(value interface{}) string {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(data)
+}
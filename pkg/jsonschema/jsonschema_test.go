@@ -0,0 +1,115 @@
+package jsonschema
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+// timeSchema mixes Go-built values, as tool adapters declare them, with nested objects
+var timeSchema = map[string]interface{}{
+	"type": "object",
+	"properties": map[string]interface{}{
+		"timezone": map[string]interface{}{"type": "string"},
+		"format": map[string]interface{}{
+			"type":    "string",
+			"enum":    []string{"iso", "unix", "human"},
+			"default": "iso",
+		},
+		"days": map[string]interface{}{"type": "integer", "minimum": 0, "maximum": 7},
+		"options": map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"precise": map[string]interface{}{"type": "boolean", "default": false},
+			},
+		},
+	},
+	"required":             []string{"timezone"},
+	"additionalProperties": false,
+}
+
+func decode(t *testing.T, data string) map[string]interface{} {
+	t.Helper()
+	var value map[string]interface{}
+	if err := json.Unmarshal([]byte(data), &value); err != nil {
+		t.Fatalf("json.Unmarshal() error = %v", err)
+	}
+	return value
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name      string
+		arguments string
+		want      []ValidationError
+	}{
+		{
+			name:      "valid",
+			arguments: `{"timezone": "UTC", "format": "unix", "days": 3, "options": {"precise": true}}`,
+		},
+		{
+			name:      "missing required property",
+			arguments: `{"format": "iso"}`,
+			want:      []ValidationError{{Path: "/timezone", Message: "is required"}},
+		},
+		{
+			name:      "wrong types and values",
+			arguments: `{"timezone": 5, "format": "rfc", "days": 2.5, "options": {"precise": "yes"}}`,
+			want: []ValidationError{
+				{Path: "/days", Message: "must be integer, got number"},
+				{Path: "/format", Message: `must be one of "iso", "unix", "human"`},
+				{Path: "/options/precise", Message: "must be boolean, got string"},
+				{Path: "/timezone", Message: "must be string, got number"},
+			},
+		},
+		{
+			name:      "out of range and unknown property",
+			arguments: `{"timezone": "UTC", "days": 9, "zone": "UTC"}`,
+			want: []ValidationError{
+				{Path: "/days", Message: "must be at most 7"},
+				{Path: "/zone", Message: "is not an allowed property"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Validate(timeSchema, decode(t, tt.arguments))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Validate() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyDefaults(t *testing.T) {
+	arguments := decode(t, `{"timezone": "UTC", "options": {}}`)
+	ApplyDefaults(timeSchema, arguments)
+
+	want := decode(t, `{"timezone": "UTC", "format": "iso", "options": {"precise": false}}`)
+	if !reflect.DeepEqual(arguments, want) {
+		t.Errorf("ApplyDefaults() = %v, want %v", arguments, want)
+	}
+	if errs := Validate(timeSchema, arguments); len(errs) != 0 {
+		t.Errorf("Expected defaults to be valid, got %v", errs)
+	}
+}
+
+func TestValidate_Combinators(t *testing.T) {
+	schema := map[string]interface{}{
+		"anyOf": []interface{}{
+			map[string]interface{}{"type": "string", "minLength": 2},
+			map[string]interface{}{"type": "null"},
+		},
+	}
+
+	if errs := Validate(schema, nil); len(errs) != 0 {
+		t.Errorf("Expected null to be accepted, got %v", errs)
+	}
+	if errs := Validate(schema, "ab"); len(errs) != 0 {
+		t.Errorf("Expected a long enough string to be accepted, got %v", errs)
+	}
+	if errs := Validate(schema, "a"); len(errs) != 1 {
+		t.Errorf("Expected a short string to be rejected, got %v", errs)
+	}
+}
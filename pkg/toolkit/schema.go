@@ -0,0 +1,196 @@
+package toolkit
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// schemaFor derives the JSON Schema of a Go type, following encoding/json's rules for field names
+func schemaFor(t reflect.Type, seen map[reflect.Type]bool) (map[string]interface{}, error) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == timeType {
+		return map[string]interface{}{"type": "string", "format": "date-time"}, nil
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}, nil
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}, nil
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}, nil
+	case reflect.Interface:
+		return map[string]interface{}{}, nil
+	case reflect.Slice, reflect.Array:
+		items, err := schemaFor(t.Elem(), seen)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"type": "array", "items": items}, nil
+	case reflect.Map:
+		if t.Key().Kind() != reflect.String {
+			return nil, fmt.Errorf("unsupported map key type %s", t.Key())
+		}
+		values, err := schemaFor(t.Elem(), seen)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"type": "object", "additionalProperties": values}, nil
+	case reflect.Struct:
+		return objectSchema(t, seen)
+	default:
+		return nil, fmt.Errorf("unsupported type %s", t)
+	}
+}
+
+// objectSchema describes a struct's fields as object properties
+func objectSchema(t reflect.Type, seen map[reflect.Type]bool) (map[string]interface{}, error) {
+	if seen[t] {
+		return nil, fmt.Errorf("recursive type %s is not supported", t)
+	}
+	seen[t] = true
+	defer delete(seen, t)
+
+	properties := make(map[string]interface{})
+	var required []string
+	for _, field := range fields(t) {
+		property, err := schemaFor(field.Type, seen)
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %w", field.Name, err)
+		}
+		if description := field.Tag.Get("description"); description != "" {
+			property["description"] = description
+		}
+
+		name := jsonName(field)
+		isRequired, err := applyOptions(property, field.Tag.Get("jsonschema"))
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %w", field.Name, err)
+		}
+		if isRequired {
+			required = append(required, name)
+		}
+		properties[name] = property
+	}
+
+	schema := map[string]interface{}{
+		"type":       "object",
+		"properties": properties,
+	}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema, nil
+}
+
+// fields returns the fields encoding/json encodes, with those of embedded structs promoted
+func fields(t reflect.Type) []reflect.StructField {
+	var result []reflect.StructField
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+
+		embedded := field.Type
+		if embedded.Kind() == reflect.Pointer {
+			embedded = embedded.Elem()
+		}
+		if field.Anonymous && embedded.Kind() == reflect.Struct && strings.Split(tag, ",")[0] == "" {
+			result = append(result, fields(embedded)...)
+			continue
+		}
+		if field.IsExported() {
+			result = append(result, field)
+		}
+	}
+	return result
+}
+
+func jsonName(field reflect.StructField) string {
+	if name := strings.Split(field.Tag.Get("json"), ",")[0]; name != "" {
+		return name
+	}
+	return field.Name
+}
+
+// applyOptions adds the keywords of a jsonschema tag to a property's schema and reports whether
+// the tag marks the property as required. Options are separated by commas; enum values by "|".
+func applyOptions(property map[string]interface{}, tag string) (bool, error) {
+	if tag == "" {
+		return false, nil
+	}
+
+	required := false
+	for _, option := range strings.Split(tag, ",") {
+		key, value, hasValue := strings.Cut(option, "=")
+		if key == "required" && !hasValue {
+			required = true
+			continue
+		}
+		if !hasValue {
+			return false, fmt.Errorf("jsonschema option %q needs a value", key)
+		}
+
+		switch key {
+		case "enum":
+			var values []interface{}
+			for _, raw := range strings.Split(value, "|") {
+				parsed, err := parseValue(property, raw)
+				if err != nil {
+					return false, fmt.Errorf("invalid enum value %q: %w", raw, err)
+				}
+				values = append(values, parsed)
+			}
+			property["enum"] = values
+		case "default":
+			parsed, err := parseValue(property, value)
+			if err != nil {
+				return false, fmt.Errorf("invalid default %q: %w", value, err)
+			}
+			property["default"] = parsed
+		case "minimum", "maximum":
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return false, fmt.Errorf("invalid %s %q", key, value)
+			}
+			property[key] = parsed
+		case "minLength", "maxLength", "minItems", "maxItems":
+			parsed, err := strconv.Atoi(value)
+			if err != nil || parsed < 0 {
+				return false, fmt.Errorf("invalid %s %q", key, value)
+			}
+			property[key] = parsed
+		default:
+			return false, fmt.Errorf("unknown jsonschema option %q", key)
+		}
+	}
+	return required, nil
+}
+
+// parseValue converts a tag value to the type of the property it belongs to
+func parseValue(property map[string]interface{}, raw string) (interface{}, error) {
+	switch property["type"] {
+	case "string":
+		return raw, nil
+	case "integer":
+		return strconv.ParseInt(raw, 10, 64)
+	case "number":
+		return strconv.ParseFloat(raw, 64)
+	case "boolean":
+		return strconv.ParseBool(raw)
+	default:
+		return nil, fmt.Errorf("only string, number and boolean fields take values in tags")
+	}
+}
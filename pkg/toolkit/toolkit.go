@@ -0,0 +1,149 @@
+// Package toolkit lets tools be written as typed Go functions. A tool's arguments are a struct whose
+// JSON Schema is derived from its fields and struct tags, and a Toolkit serves a set of tools as a
+// ToolPort, ready to be registered with the tool registry:
+//
+//	type weatherArgs struct {
+//		City  string `json:"city" jsonschema:"required" description:"City name"`
+//		Units string `json:"units,omitempty" jsonschema:"enum=metric|imperial,default=metric"`
+//	}
+//
+//	kit, err := toolkit.New(toolkit.Must(toolkit.NewTool("get_weather", "Get the weather in a city", getWeather)))
+//	err = toolRegistry.Register("weather", registry.KindBuiltin, kit)
+//
+// Properties are named like encoding/json names fields. The description tag describes a property,
+// and the jsonschema tag holds comma-separated options: required, enum (values separated by "|"),
+// default, minimum, maximum, minLength, maxLength, minItems and maxItems.
+package toolkit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"github.com/username/hexarag/internal/domain/ports"
+	"github.com/username/hexarag/pkg/jsonschema"
+)
+
+// Tool is a typed function together with the schema of its arguments
+type Tool struct {
+	definition ports.Tool
+	run        func(ctx context.Context, arguments map[string]interface{}) *ports.ToolResult
+}
+
+// NewTool creates a tool from a function taking its arguments as a struct of type A.
+// The function's result is the tool's data; an error fails the call with the error's message.
+func NewTool[A, R any](name, description string, fn func(ctx context.Context, args A) (R, error)) (*Tool, error) {
+	argsType := reflect.TypeOf((*A)(nil)).Elem()
+	for argsType.Kind() == reflect.Pointer {
+		argsType = argsType.Elem()
+	}
+	if argsType.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("arguments of tool %s must be a struct, got %s", name, argsType)
+	}
+
+	parameters, err := schemaFor(argsType, make(map[reflect.Type]bool))
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive schema of tool %s: %w", name, err)
+	}
+
+	run := func(ctx context.Context, arguments map[string]interface{}) *ports.ToolResult {
+		if arguments == nil {
+			arguments = make(map[string]interface{})
+		}
+		jsonschema.ApplyDefaults(parameters, arguments)
+
+		var args A
+		data, err := json.Marshal(arguments)
+		if err == nil {
+			err = json.Unmarshal(data, &args)
+		}
+		if err != nil {
+			return &ports.ToolResult{
+				Success: false,
+				Error:   fmt.Sprintf("invalid arguments for tool %s: %v", name, err),
+			}
+		}
+
+		result, err := fn(ctx, args)
+		if err != nil {
+			return &ports.ToolResult{Success: false, Error: err.Error()}
+		}
+		return &ports.ToolResult{Success: true, Data: result}
+	}
+
+	return &Tool{
+		definition: ports.Tool{
+			Type: "function",
+			Function: ports.ToolFunction{
+				Name:        name,
+				Description: description,
+				Parameters:  parameters,
+			},
+		},
+		run: run,
+	}, nil
+}
+
+// Must returns value, panicking if err is set. It suits tools whose definitions are fixed at compile time.
+func Must[T any](value T, err error) T {
+	if err != nil {
+		panic(err)
+	}
+	return value
+}
+
+// Toolkit implements the tool port for a fixed set of typed tools
+type Toolkit struct {
+	tools []*Tool
+	named map[string]*Tool
+}
+
+// New creates a toolkit serving tools, whose names must be unique
+func New(tools ...*Tool) (*Toolkit, error) {
+	named := make(map[string]*Tool, len(tools))
+	for _, tool := range tools {
+		name := tool.definition.Function.Name
+		if _, exists := named[name]; exists {
+			return nil, fmt.Errorf("tool %s is defined twice", name)
+		}
+		named[name] = tool
+	}
+	return &Toolkit{tools: tools, named: named}, nil
+}
+
+// Execute decodes the arguments into the tool's argument struct, after filling in the schema's defaults, and calls it
+func (k *Toolkit) Execute(ctx context.Context, name string, arguments map[string]interface{}) (*ports.ToolResult, error) {
+	tool, ok := k.named[name]
+	if !ok {
+		return &ports.ToolResult{
+			Success: false,
+			Error:   fmt.Sprintf("unknown tool: %s", name),
+		}, nil
+	}
+	return tool.run(ctx, arguments), nil
+}
+
+// GetAvailableTools returns the toolkit's tools in the order they were given
+func (k *Toolkit) GetAvailableTools(ctx context.Context) ([]ports.Tool, error) {
+	tools := make([]ports.Tool, 0, len(k.tools))
+	for _, tool := range k.tools {
+		tools = append(tools, tool.definition)
+	}
+	return tools, nil
+}
+
+// GetTool returns one of the toolkit's tools
+func (k *Toolkit) GetTool(ctx context.Context, name string) (*ports.Tool, error) {
+	tool, ok := k.named[name]
+	if !ok {
+		return nil, fmt.Errorf("tool not found: %s", name)
+	}
+	definition := tool.definition
+	return &definition, nil
+}
+
+// Ping always succeeds; the tools run in process
+func (k *Toolkit) Ping(ctx context.Context) error {
+	return nil
+}
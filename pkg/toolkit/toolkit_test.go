@@ -0,0 +1,126 @@
+package toolkit
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type Paging struct {
+	Limit int `json:"limit,omitempty" jsonschema:"minimum=1,maximum=50,default=10"`
+}
+
+type searchArgs struct {
+	Query   string            `json:"query" jsonschema:"required,minLength=1" description:"Words to look for"`
+	Sort    string            `json:"sort,omitempty" jsonschema:"enum=relevance|date"`
+	Tags    []string          `json:"tags,omitempty" jsonschema:"maxItems=3"`
+	Exact   *bool             `json:"exact,omitempty"`
+	Since   time.Time         `json:"since"`
+	Filters map[string]string `json:"filters"`
+	Paging
+	internal string
+	Ignored  string `json:"-"`
+}
+
+func TestNewTool_DerivesSchema(t *testing.T) {
+	tool, err := NewTool("search", "Search", func(ctx context.Context, args searchArgs) ([]string, error) {
+		return nil, nil
+	})
+	if err != nil {
+		t.Fatalf("NewTool() error = %v", err)
+	}
+
+	want := map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"query":   map[string]interface{}{"type": "string", "description": "Words to look for", "minLength": 1},
+			"sort":    map[string]interface{}{"type": "string", "enum": []interface{}{"relevance", "date"}},
+			"tags":    map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}, "maxItems": 3},
+			"exact":   map[string]interface{}{"type": "boolean"},
+			"since":   map[string]interface{}{"type": "string", "format": "date-time"},
+			"filters": map[string]interface{}{"type": "object", "additionalProperties": map[string]interface{}{"type": "string"}},
+			"limit":   map[string]interface{}{"type": "integer", "minimum": 1.0, "maximum": 50.0, "default": int64(10)},
+		},
+		"required": []string{"query"},
+	}
+	if got := tool.definition.Function.Parameters; !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected schema %+v, got %+v", want, got)
+	}
+
+	for name, fn := range map[string]func() error{
+		"not a struct": func() error {
+			_, err := NewTool("bad", "", func(ctx context.Context, args string) (string, error) { return args, nil })
+			return err
+		},
+		"unknown option": func() error {
+			type args struct {
+				Name string `json:"name" jsonschema:"requird"`
+			}
+			_, err := NewTool("bad", "", func(ctx context.Context, a args) (string, error) { return "", nil })
+			return err
+		},
+		"mistyped default": func() error {
+			type args struct {
+				Count int `json:"count" jsonschema:"default=many"`
+			}
+			_, err := NewTool("bad", "", func(ctx context.Context, a args) (string, error) { return "", nil })
+			return err
+		},
+		"unsupported type": func() error {
+			type args struct {
+				Done chan bool `json:"done"`
+			}
+			_, err := NewTool("bad", "", func(ctx context.Context, a args) (string, error) { return "", nil })
+			return err
+		},
+	} {
+		if err := fn(); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestToolkit_Execute(t *testing.T) {
+	ctx := context.Background()
+	search := Must(NewTool("search", "Search", func(ctx context.Context, args searchArgs) (map[string]interface{}, error) {
+		if args.Query == "fail" {
+			return nil, errors.New("search backend unavailable")
+		}
+		return map[string]interface{}{"query": args.Query, "limit": args.Limit, "tags": args.Tags}, nil
+	}))
+
+	if _, err := New(search, search); err == nil {
+		t.Error("Expected duplicate tool names to be rejected")
+	}
+	kit := Must(New(search))
+
+	result, err := kit.Execute(ctx, "search", map[string]interface{}{"query": "go", "tags": []interface{}{"a"}})
+	if err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	want := map[string]interface{}{"query": "go", "limit": 10, "tags": []string{"a"}}
+	if !result.Success || !reflect.DeepEqual(result.Data, want) {
+		t.Errorf("Expected the decoded arguments with defaults filled in, got %+v", result)
+	}
+
+	result, _ = kit.Execute(ctx, "search", map[string]interface{}{"query": "fail"})
+	if result.Success || result.Error != "search backend unavailable" {
+		t.Errorf("Expected the function's error as a failed result, got %+v", result)
+	}
+
+	result, _ = kit.Execute(ctx, "search", map[string]interface{}{"query": 5.0})
+	if result.Success || !strings.Contains(result.Error, "invalid arguments for tool search") {
+		t.Errorf("Expected mistyped arguments to fail the call, got %+v", result)
+	}
+
+	result, _ = kit.Execute(ctx, "missing", nil)
+	if result.Success {
+		t.Errorf("Expected an unknown tool to fail, got %+v", result)
+	}
+	if _, err := kit.GetTool(ctx, "search"); err != nil {
+		t.Errorf("GetTool() error = %v", err)
+	}
+}